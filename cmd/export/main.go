@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"crow.watch/internal/dotenv"
+	"crow.watch/internal/store"
+	"crow.watch/internal/storyio"
+)
+
+func main() {
+	dotenv.Load(".env")
+
+	ctx := context.Background()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL is required")
+	}
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		log.Fatalf("connect db: %v", err)
+	}
+	defer pool.Close()
+
+	stories, err := storyio.Export(ctx, store.New(pool))
+	if err != nil {
+		log.Fatalf("export stories: %v", err)
+	}
+
+	// Write to the named file, or stdout when none is given.
+	out := os.Stdout
+	if len(os.Args) >= 2 && os.Args[1] != "-" {
+		out, err = os.Create(os.Args[1])
+		if err != nil {
+			log.Fatalf("create %s: %v", os.Args[1], err)
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	if err := storyio.Write(w, stories); err != nil {
+		log.Fatalf("write stories: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("write stories: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("close output: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d stories.\n", len(stories))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"crow.watch/internal/dotenv"
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
+	"crow.watch/internal/storyio"
+)
+
+func main() {
+	dotenv.Load(".env")
+
+	authorsPath := flag.String("authors", "", "JSON file mapping exported usernames to local ones")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: import [-authors authors.json] <stories.json>\n\n")
+		fmt.Fprintf(os.Stderr, "Stories by authors not in the mapping are attributed to importbot.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var authorMap map[string]string
+	if *authorsPath != "" {
+		f, err := os.Open(*authorsPath)
+		if err != nil {
+			log.Fatalf("open %s: %v", *authorsPath, err)
+		}
+		authorMap, err = storyio.ReadAuthors(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("parse %s: %v", *authorsPath, err)
+		}
+	}
+
+	path := flag.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open %s: %v", path, err)
+	}
+	stories, err := storyio.Read(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("parse %s: %v", path, err)
+	}
+
+	ctx := context.Background()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL is required")
+	}
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		log.Fatalf("connect db: %v", err)
+	}
+	defer pool.Close()
+
+	queries := store.New(pool)
+
+	// Stories by authors without a mapping are attributed to this one.
+	fallback, err := storyio.SeedUser(ctx, queries, "importbot")
+	if err != nil {
+		log.Fatalf("import user: %v", err)
+	}
+	authors := storyio.Authors{Map: authorMap, Fallback: fallback}
+
+	// Imported links follow the same policy as links submitted on the site.
+	policy := link.Config{
+		RequireHTTPS:     os.Getenv("LINK_REQUIRE_HTTPS") == "true",
+		DefaultPortsOnly: os.Getenv("LINK_DEFAULT_PORTS_ONLY") == "true",
+	}
+
+	var created, skipped int
+	for _, s := range stories {
+		missing, err := importStory(ctx, pool, queries, policy, s, authors)
+		if errors.Is(err, storyio.ErrExists) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			fmt.Printf("  skip: %s: %v\n", s.Title, err)
+			skipped++
+			continue
+		}
+		created++
+		if len(missing) > 0 {
+			fmt.Printf("  %s: unknown tags %s\n", s.Title, strings.Join(missing, ", "))
+		}
+	}
+
+	fmt.Printf("Imported %d stories, skipped %d.\n", created, skipped)
+}
+
+// importStory imports s in its own transaction so a failure never leaves a
+// half-created story behind.
+func importStory(ctx context.Context, pool *pgxpool.Pool, queries *store.Queries, policy link.Config, s storyio.Story, authors storyio.Authors) ([]string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	missing, err := storyio.Import(ctx, queries.WithTx(tx), policy, s, authors)
+	if err != nil {
+		return nil, err
+	}
+	return missing, tx.Commit(ctx)
+}
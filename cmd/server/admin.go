@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"crow.watch/internal/app"
+	"crow.watch/internal/store"
+)
+
+type initialAdmin struct {
+	Username string
+	Email    string
+	Password string
+	// The admin is held to the same rules as anyone registering.
+	PasswordPolicy app.PasswordPolicy
+	EmailPolicy    app.EmailPolicy
+}
+
+// seedInitialAdmin creates admin as a confirmed moderator when the users
+// table is empty, so a fresh deploy can be bootstrapped without shell
+// access. It does nothing when no username is configured or any user
+// already exists.
+func seedInitialAdmin(ctx context.Context, q *store.Queries, admin initialAdmin, logger *slog.Logger) error {
+	if admin.Username == "" {
+		return nil
+	}
+	exists, err := q.HasUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("check for users: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if errs := app.ValidateAccount(admin.Username, admin.Email, admin.Password, admin.PasswordPolicy, admin.EmailPolicy); len(errs) > 0 {
+		var problems []string
+		for _, field := range slices.Sorted(maps.Keys(errs)) {
+			problems = append(problems, fmt.Sprintf("INITIAL_ADMIN_%s: %s", strings.ToUpper(field), errs[field]))
+		}
+		return errors.New(strings.Join(problems, " "))
+	}
+
+	digest, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	// Another instance may have seeded the admin since HasUsers.
+	user, err := q.CreateInitialAdmin(ctx, store.CreateInitialAdminParams{
+		Username:       admin.Username,
+		Email:          admin.Email,
+		PasswordDigest: string(digest),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("create initial admin: %w", err)
+	}
+
+	logger.Info("created initial admin", "id", user.ID, "username", user.Username, "email", user.Email)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"crow.watch/internal/app"
+	"crow.watch/internal/store"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testDB creates a throwaway schema from db/schema.sql in the database at
+// TEST_DATABASE_URL, skipping the test when it is not set.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	schema, err := os.ReadFile("../../db/schema.sql")
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	conn, err := pgx.Connect(ctx, url)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	return pool
+}
+
+func testAdmin() initialAdmin {
+	return initialAdmin{
+		Username:       "admin",
+		Email:          "admin@example.com",
+		Password:       "correct horse battery",
+		PasswordPolicy: app.PasswordPolicy{RejectCommon: true},
+	}
+}
+
+func TestSeedInitialAdminOnEmpty(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	require.NoError(t, seedInitialAdmin(ctx, q, testAdmin(), discardLogger))
+
+	var (
+		username, email, digest string
+		isModerator, confirmed  bool
+	)
+	require.NoError(t, pool.QueryRow(ctx,
+		"SELECT username, email, password_digest, is_moderator, email_confirmed_at IS NOT NULL FROM users",
+	).Scan(&username, &email, &digest, &isModerator, &confirmed))
+	assert.Equal(t, "admin", username)
+	assert.Equal(t, "admin@example.com", email)
+	assert.True(t, isModerator)
+	assert.True(t, confirmed)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(digest), []byte("correct horse battery")))
+}
+
+func TestSeedInitialAdminWhenUsersExist(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	_, err := q.CreateUser(ctx, store.CreateUserParams{Username: "alice", Email: "alice@example.com", PasswordDigest: "x"})
+	require.NoError(t, err)
+
+	admin := testAdmin()
+	admin.Password = "short"
+	require.NoError(t, seedInitialAdmin(ctx, q, admin, discardLogger), "an existing site ignores the admin settings")
+
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestSeedInitialAdminNotConfigured(t *testing.T) {
+	require.NoError(t, seedInitialAdmin(context.Background(), nil, initialAdmin{}, discardLogger))
+}
+
+func TestSeedInitialAdminValidates(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	tests := []struct {
+		name  string
+		admin func(*initialAdmin)
+		want  string
+	}{
+		{"missing e-mail and password", func(a *initialAdmin) { a.Email, a.Password = "", "" }, "INITIAL_ADMIN_EMAIL"},
+		{"bad username", func(a *initialAdmin) { a.Username = "the admin" }, "INITIAL_ADMIN_USERNAME"},
+		{"password too long", func(a *initialAdmin) { a.Password = strings.Repeat("a", 73) }, "INITIAL_ADMIN_PASSWORD"},
+		{"password too short", func(a *initialAdmin) { a.Password = "abc" }, "INITIAL_ADMIN_PASSWORD"},
+		{"common password", func(a *initialAdmin) { a.Password = "password" }, "INITIAL_ADMIN_PASSWORD"},
+		{"denied e-mail domain", func(a *initialAdmin) { a.EmailPolicy.DeniedDomains = []string{"example.com"} }, "INITIAL_ADMIN_EMAIL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin := testAdmin()
+			tt.admin(&admin)
+			err := seedInitialAdmin(ctx, q, admin, discardLogger)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	exists, err := q.HasUsers(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io/fs"
 	"log/slog"
 	"net/http"
@@ -21,8 +22,12 @@ import (
 	"crow.watch/internal/dev"
 	"crow.watch/internal/dotenv"
 	"crow.watch/internal/email"
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/link"
 	"crow.watch/internal/ratelimit"
 	"crow.watch/internal/store"
+	"crow.watch/internal/viewcount"
+	"crow.watch/internal/webhook"
 	"crow.watch/web"
 )
 
@@ -84,6 +89,29 @@ func main() {
 	}
 
 	queries := store.New(pool)
+
+	passwordPolicy := app.PasswordPolicy{
+		MinLength:    envInt(logger, "PASSWORD_MIN_LENGTH", app.DefaultPasswordMinLength),
+		RejectCommon: envOrDefault("PASSWORD_REJECT_COMMON", "true") != "false",
+	}
+	emailPolicy := app.EmailPolicy{
+		AllowedDomains:   app.ParseEmailDomains(os.Getenv("EMAIL_ALLOWED_DOMAINS")),
+		DeniedDomains:    app.ParseEmailDomains(os.Getenv("EMAIL_DENIED_DOMAINS")),
+		RejectDisposable: os.Getenv("EMAIL_REJECT_DISPOSABLE") == "true",
+	}
+
+	err = seedInitialAdmin(ctx, queries, initialAdmin{
+		Username:       os.Getenv("INITIAL_ADMIN_USERNAME"),
+		Email:          os.Getenv("INITIAL_ADMIN_EMAIL"),
+		Password:       os.Getenv("INITIAL_ADMIN_PASSWORD"),
+		PasswordPolicy: passwordPolicy,
+		EmailPolicy:    emailPolicy,
+	}, logger)
+	if err != nil {
+		logger.Error("seed initial admin", "error", err)
+		os.Exit(1)
+	}
+
 	cookieName := envOrDefault("SESSION_COOKIE_NAME", "crowwatch_session")
 	ttlHours, err := strconv.Atoi(envOrDefault("SESSION_TTL_HOURS", "720"))
 	if err != nil || ttlHours <= 0 {
@@ -92,7 +120,16 @@ func main() {
 	}
 
 	secureCookies := envOrDefault("SECURE_COOKIES", "true") != "false" && !devMode
-	sessions := auth.NewSessionManager(queries, cookieName, time.Duration(ttlHours)*time.Hour, secureCookies, logger)
+	sameSite, err := auth.ParseSameSite(os.Getenv("SESSION_COOKIE_SAMESITE"))
+	if err != nil {
+		logger.Error("SESSION_COOKIE_SAMESITE must be lax or strict", "error", err)
+		os.Exit(1)
+	}
+	sessions := auth.NewSessionManager(queries, cookieName, time.Duration(ttlHours)*time.Hour, auth.CookieOptions{
+		Secure:   secureCookies,
+		Domain:   os.Getenv("SESSION_COOKIE_DOMAIN"),
+		SameSite: sameSite,
+	}, logger)
 
 	emailTemplates, err := app.ParseEmailTemplates(web.FS)
 	if err != nil {
@@ -104,6 +141,8 @@ func main() {
 		envOrDefault("ZOHO_HOST", "api.zeptomail.eu"),
 		os.Getenv("ZOHO_TOKEN"),
 		envOrDefault("FROM_EMAIL", "noreply@crow.watch"),
+		envOrDefault("FROM_NAME", email.DefaultFromName),
+		os.Getenv("REPLY_TO_EMAIL"),
 		logger,
 	)
 
@@ -118,18 +157,44 @@ func main() {
 			os.Exit(1)
 		}
 		defer devReloader.Close()
+		if os.Getenv("DEV_RESTART_ON_GO") == "1" {
+			// Exit so a supervisor (e.g. watchexec) rebuilds the server.
+			devReloader.OnGoChange = func() { os.Exit(0) }
+		}
 		go devReloader.Run()
 		logger.Info("dev mode enabled")
 	}
 
+	inviteQuota := app.InviteQuota{
+		Outstanding: envInt(logger, "INVITE_MAX_OUTSTANDING", 0),
+		Total:       envInt(logger, "INVITE_MAX_TOTAL", 0),
+	}
+
+	hsts := &app.HSTSConfig{
+		MaxAge:            envInt(logger, "HSTS_MAX_AGE", app.DefaultHSTS.MaxAge),
+		IncludeSubDomains: envOrDefault("HSTS_INCLUDE_SUBDOMAINS", "true") != "false",
+		Preload:           os.Getenv("HSTS_PRELOAD") == "true",
+	}
+
+	csp := &app.CSPConfig{
+		ScriptSrc: envSources("CSP_SCRIPT_SRC", app.DefaultCSP.ScriptSrc),
+		StyleSrc:  envSources("CSP_STYLE_SRC", app.DefaultCSP.StyleSrc),
+		FontSrc:   envSources("CSP_FONT_SRC", app.DefaultCSP.FontSrc),
+		ImgSrc:    envSources("CSP_IMG_SRC", app.DefaultCSP.ImgSrc),
+	}
+
 	loginIPLimiter := ratelimit.New(10, 15*time.Minute)
 	loginAcctLimiter := ratelimit.New(5, 15*time.Minute)
 	inviteLimiter := ratelimit.New(20, time.Hour)
+	emailIPLimiter := ratelimit.New(5, time.Hour)
+	exportLimiter := ratelimit.New(3, time.Hour)
 	captchaStore := captcha.New(5 * time.Minute)
 	shutdownDone := make(chan struct{})
 	loginIPLimiter.StartCleanup(5*time.Minute, shutdownDone)
 	loginAcctLimiter.StartCleanup(5*time.Minute, shutdownDone)
 	inviteLimiter.StartCleanup(5*time.Minute, shutdownDone)
+	emailIPLimiter.StartCleanup(5*time.Minute, shutdownDone)
+	exportLimiter.StartCleanup(5*time.Minute, shutdownDone)
 	captchaStore.StartCleanup(5*time.Minute, shutdownDone)
 
 	analyticsSecret := os.Getenv("ANALYTICS_SECRET")
@@ -143,9 +208,69 @@ func main() {
 	}
 	collector := analytics.NewCollector(queries, analyticsSecret, logger)
 
+	var reader *store.Queries
+	if readURL := os.Getenv("READ_DATABASE_URL"); readURL != "" {
+		replicaPool, err := pgxpool.New(ctx, readURL)
+		if err != nil {
+			logger.Error("connect read replica", "error", err)
+			os.Exit(1)
+		}
+		defer replicaPool.Close()
+
+		var replica *store.ReplicaDB
+		reader, replica = store.NewReader(pool, replicaPool)
+		if err := replica.Check(ctx); err != nil {
+			logger.Warn("read replica unhealthy, reading from primary", "error", err)
+		}
+		go replica.Monitor(15*time.Second, shutdownDone, func(healthy bool, err error) {
+			if healthy {
+				logger.Info("read replica healthy again")
+			} else {
+				logger.Warn("read replica unhealthy, reading from primary", "error", err)
+			}
+		})
+	}
+
+	views := viewcount.New(func(ctx context.Context, storyIDs []int64, counts []int32) error {
+		return queries.IncrementStoryViews(ctx, store.IncrementStoryViewsParams{
+			StoryIds: storyIDs,
+			Views:    counts,
+		})
+	}, logger)
+	go views.Run(time.Minute, shutdownDone)
+
+	storyFlags, err := flagreason.ParseOrDefault(os.Getenv("STORY_FLAG_REASONS"), flagreason.DefaultStory)
+	if err != nil {
+		logger.Error("STORY_FLAG_REASONS", "error", err)
+		os.Exit(1)
+	}
+	commentFlags, err := flagreason.ParseOrDefault(os.Getenv("COMMENT_FLAG_REASONS"), flagreason.DefaultComment)
+	if err != nil {
+		logger.Error("COMMENT_FLAG_REASONS", "error", err)
+		os.Exit(1)
+	}
+	trustedProxies, err := app.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		logger.Error("TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+	flagTrust := flagreason.DefaultTrust
+	if os.Getenv("FLAG_TRUST_WEIGHTING") == "false" {
+		flagTrust = flagreason.Trust{}
+	}
+
+	modWebhook := webhook.New(os.Getenv("MOD_WEBHOOK_URL"), logger)
+
+	shortCodeLength := envInt(logger, "SHORT_CODE_LENGTH", link.DefaultShortCodeLength)
+	if shortCodeLength < link.DefaultShortCodeLength || shortCodeLength > link.MaxShortCodeLength {
+		logger.Error(fmt.Sprintf("SHORT_CODE_LENGTH must be between %d and %d", link.DefaultShortCodeLength, link.MaxShortCodeLength))
+		os.Exit(1)
+	}
+
 	a := &app.App{
 		Pool:             pool,
 		Queries:          queries,
+		Reader:           reader,
 		Sessions:         sessions,
 		Templates:        templates,
 		EmailTemplates:   emailTemplates,
@@ -159,8 +284,43 @@ func main() {
 		LoginIPLimiter:   loginIPLimiter,
 		LoginAcctLimiter: loginAcctLimiter,
 		InviteLimiter:    inviteLimiter,
+		EmailIPLimiter:   emailIPLimiter,
+		ExportLimiter:    exportLimiter,
+		HSTS:             hsts,
+		CSP:              csp,
+		InviteQuota:      inviteQuota,
 		Captcha:          captchaStore,
+		CaptchaAfter:     envInt(logger, "CAPTCHA_AFTER_ATTEMPTS", 3),
 		Analytics:        collector,
+		Views:            views,
+		MaxBodyBytes:     int64(envInt(logger, "MAX_BODY_BYTES", app.DefaultMaxBodyBytes)),
+		MaintenanceMode:  os.Getenv("MAINTENANCE_MODE") == "1",
+		PasswordPolicy:   passwordPolicy,
+		EmailPolicy:      emailPolicy,
+		LinkPolicy: link.Config{
+			RequireHTTPS:     os.Getenv("LINK_REQUIRE_HTTPS") == "true",
+			DefaultPortsOnly: os.Getenv("LINK_DEFAULT_PORTS_ONLY") == "true",
+		},
+		StoryFlags:      storyFlags,
+		CommentFlags:    commentFlags,
+		FlagMinAge:      time.Duration(envInt(logger, "FLAG_MIN_ACCOUNT_AGE_HOURS", int(app.DefaultFlagMinAge/time.Hour))) * time.Hour,
+		FlagTrust:       flagTrust,
+		ModWebhook:      modWebhook,
+		ShortCodeLength: shortCodeLength,
+		MinStoryScore:   envSignedInt(logger, "MIN_STORY_SCORE", 0),
+		DuplicateWindow: time.Duration(envInt(logger, "DUPLICATE_WINDOW_DAYS", int(app.DefaultDuplicateWindow/(24*time.Hour)))) * 24 * time.Hour,
+		SubmitCooldown:  time.Duration(envInt(logger, "SUBMIT_COOLDOWN_MINUTES", 0)) * time.Minute,
+		Probation: app.Probation{
+			Period: time.Duration(envInt(logger, "PROBATION_DAYS", 0)) * 24 * time.Hour,
+			Karma:  envInt(logger, "PROBATION_KARMA", 0),
+		},
+		RequestTimeout: time.Duration(envInt(logger, "REQUEST_TIMEOUT_SECONDS", int(app.DefaultRequestTimeout/time.Second))) * time.Second,
+		CommentCollapse: app.CommentCollapse{
+			Score: envSignedInt(logger, "COMMENT_COLLAPSE_SCORE", app.DefaultCommentCollapse.Score),
+			Flags: envInt(logger, "COMMENT_COLLAPSE_FLAGS", app.DefaultCommentCollapse.Flags),
+		},
+		SubmitterFirst: os.Getenv("SUBMITTER_COMMENTS_FIRST") == "true",
+		TrustedProxies: trustedProxies,
 	}
 
 	addr := envOrDefault("ADDR", ":8080")
@@ -206,8 +366,16 @@ func main() {
 			logger.Error("shutdown", "error", err)
 		}
 		collector.Close()
+		if err := views.Flush(shutdownCtx); err != nil {
+			logger.Error("flush story views", "error", err)
+		}
+		modWebhook.Wait()
 	}()
 
+	if a.MaintenanceMode {
+		logger.Warn("maintenance mode enabled, writes are blocked")
+	}
+
 	logger.Info("server starting", "addr", addr)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		logger.Error("serve", "error", err)
@@ -223,3 +391,43 @@ func envOrDefault(key, fallback string) string {
 	}
 	return fallback
 }
+
+// envSources reads a list of CSP sources from the environment, falling
+// back when unset.
+func envSources(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if strings.TrimSpace(v) == "" {
+		return fallback
+	}
+	return app.ParseCSPSources(v)
+}
+
+// envInt reads a non-negative integer from the environment, exiting on
+// malformed values.
+func envInt(logger *slog.Logger, key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logger.Error(key + " must be a non-negative integer")
+		os.Exit(1)
+	}
+	return n
+}
+
+// envSignedInt reads an integer that may be negative from the environment,
+// exiting on malformed values.
+func envSignedInt(logger *slog.Logger, key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Error(key + " must be an integer")
+		os.Exit(1)
+	}
+	return n
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
-	crand "crypto/rand"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand/v2"
@@ -11,14 +11,13 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gopkg.in/yaml.v3"
 
 	"crow.watch/internal/dotenv"
 	"crow.watch/internal/link"
 	"crow.watch/internal/store"
+	"crow.watch/internal/storyio"
 )
 
 type seedStory struct {
@@ -26,22 +25,42 @@ type seedStory struct {
 	Title string `yaml:"title"`
 }
 
+// plannedStory is a story picked for seeding, with everything random
+// about it decided up front so a dry run shows exactly what a real run
+// with the same seed creates.
+type plannedStory struct {
+	seedStory
+	Normalized string
+	Age        time.Duration
+	TagIDs     []int64
+	Score      int
+}
+
 func main() {
 	dotenv.Load(".env")
 
+	dryRun := flag.Bool("dry-run", false, "print the stories that would be created without writing anything")
+	seed := flag.Uint64("seed", 0, "seed for the random number generator (default: random)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: storyseed [-dry-run] [-seed N] [stories.yaml] [count]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	storiesPath := "stories.yaml"
 	count := 25
 
-	if len(os.Args) >= 2 {
-		n, err := strconv.Atoi(os.Args[1])
+	args := flag.Args()
+	if len(args) >= 1 {
+		n, err := strconv.Atoi(args[0])
 		if err == nil {
 			count = n
 		} else {
-			storiesPath = os.Args[1]
+			storiesPath = args[0]
 		}
 	}
-	if len(os.Args) >= 3 {
-		n, err := strconv.Atoi(os.Args[2])
+	if len(args) >= 2 {
+		n, err := strconv.Atoi(args[1])
 		if err == nil {
 			count = n
 		}
@@ -57,9 +76,11 @@ func main() {
 		log.Fatalf("parse yaml: %v", err)
 	}
 
-	if count > len(stories) {
-		count = len(stories)
+	if *seed == 0 {
+		*seed = rand.Uint64()
 	}
+	fmt.Printf("Using seed %d\n", *seed)
+	rng := rand.New(rand.NewPCG(*seed, *seed))
 
 	ctx := context.Background()
 
@@ -76,13 +97,6 @@ func main() {
 
 	queries := store.New(pool)
 
-	// Get or create a seed user.
-	user, err := getOrCreateSeedUser(ctx, queries)
-	if err != nil {
-		log.Fatalf("seed user: %v", err)
-	}
-	fmt.Printf("Using user %q (id=%d)\n", user.Username, user.ID)
-
 	// Load existing tags for random assignment.
 	tags, err := queries.ListActiveTagsWithCategory(ctx)
 	if err != nil {
@@ -91,127 +105,164 @@ func main() {
 	if len(tags) == 0 {
 		fmt.Println("Warning: no active tags found. Run tagseed first to assign tags to stories.")
 	}
+	tagIDs := make([]int64, len(tags))
+	for i, t := range tags {
+		tagIDs[i] = t.ID
+	}
 
-	// Shuffle and pick stories.
-	perm := rand.Perm(len(stories))
-	selected := make([]seedStory, count)
-	for i := range count {
-		selected[i] = stories[perm[i]]
+	exists := func(normalized string) (bool, error) {
+		var found bool
+		err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM stories WHERE normalized_url = $1)", normalized).Scan(&found)
+		return found, err
 	}
 
-	var created int
-	for i, s := range selected {
-		result, err := link.Clean(s.URL)
-		if err != nil {
-			fmt.Printf("  skip (bad url): %s\n", s.URL)
-			continue
-		}
+	planned, err := plan(stories, count, tagIDs, rng, exists)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		domain, err := queries.GetOrCreateDomain(ctx, result.Domain)
+	var userID int64
+	if !*dryRun {
+		// Get or create a seed user.
+		user, err := storyio.SeedUser(ctx, queries, "seedbot")
 		if err != nil {
-			log.Fatalf("domain %q: %v", result.Domain, err)
+			log.Fatalf("seed user: %v", err)
 		}
+		fmt.Printf("Using user %q (id=%d)\n", user.Username, user.ID)
+		userID = user.ID
+	}
 
-		var originID pgtype.Int8
-		if result.Origin != "" {
-			origin, err := queries.GetOrCreateOrigin(ctx, store.GetOrCreateOriginParams{DomainID: domain.ID, Origin: result.Origin})
-			if err != nil {
-				log.Fatalf("origin %q: %v", result.Origin, err)
-			}
-			originID = pgtype.Int8{Int64: origin.ID, Valid: true}
+	apply(planned, *dryRun, func(p plannedStory) error {
+		return create(ctx, pool, queries, userID, p)
+	})
+}
+
+// apply creates the planned stories, or only lists them on a dry run.
+// It returns how many were created.
+func apply(planned []plannedStory, dryRun bool, create func(plannedStory) error) int {
+	if dryRun {
+		for i, p := range planned {
+			fmt.Printf("  [%d/%d] would create %s (score=%d, tags=%d)\n", i+1, len(planned), p.Title, p.Score, len(p.TagIDs))
 		}
+		fmt.Printf("Dry run: %d stories would be seeded.\n", len(planned))
+		return 0
+	}
 
-		story, err := queries.CreateStory(ctx, store.CreateStoryParams{
-			UserID:        user.ID,
-			DomainID:      pgtype.Int8{Int64: domain.ID, Valid: true},
-			OriginID:      originID,
-			Url:           pgtype.Text{String: result.Cleaned, Valid: true},
-			NormalizedUrl: pgtype.Text{String: result.Normalized, Valid: true},
-			Title:         s.Title,
-			ShortCode:     generateShortCode(),
-		})
-		if err != nil {
-			fmt.Printf("  skip (create): %s: %v\n", s.Title, err)
+	var created int
+	for _, p := range planned {
+		if err := create(p); err != nil {
+			fmt.Printf("  skip (create): %s: %v\n", p.Title, err)
 			continue
 		}
+		created++
+		fmt.Printf("  [%d/%d] %s (score=%d)\n", created, len(planned), p.Title, p.Score)
+	}
 
-		_ = queries.IncrementDomainStoryCount(ctx, domain.ID)
-		if originID.Valid {
-			_ = queries.IncrementOriginStoryCount(ctx, originID.Int64)
-		}
+	fmt.Printf("Seeded %d stories.\n", created)
+	return created
+}
+
+// plan picks count stories at random and decides their age, tags and
+// score. Stories with a bad URL or whose URL is already on the site are
+// skipped, so re-running with the same seed creates only what is missing.
+func plan(stories []seedStory, count int, tagIDs []int64, rng *rand.Rand, exists func(normalized string) (bool, error)) ([]plannedStory, error) {
+	count = min(count, len(stories))
+
+	// Shuffle and pick stories.
+	perm := rng.Perm(len(stories))
+	seen := make(map[string]bool)
+	var planned []plannedStory
+	for i := range count {
+		s := stories[perm[i]]
 
-		// Backdate: spread stories over the last 72 hours.
-		age := time.Duration(i) * (72 * time.Hour) / time.Duration(count)
-		// Add jitter: ±30 minutes.
-		jitter := time.Duration(rand.IntN(60)-30) * time.Minute
-		backdateTo := time.Now().Add(-age + jitter)
-		_, _ = pool.Exec(ctx,
-			"UPDATE stories SET created_at = $1, updated_at = $1 WHERE id = $2",
-			backdateTo, story.ID,
-		)
-
-		// Assign 1-3 random tags.
-		if len(tags) > 0 {
-			tagCount := 1 + rand.IntN(min(3, len(tags)))
-			tagPerm := rand.Perm(len(tags))
-			for t := range tagCount {
-				_ = queries.CreateTagging(ctx, store.CreateTaggingParams{
-					StoryID: story.ID,
-					TagID:   tags[tagPerm[t]].ID,
-				})
+		// Randomness is drawn for every pick, skipped or not, so that
+		// which stories exist doesn't change the plan for the others.
+		// Backdate: spread stories over the last 72 hours, ±30 minutes.
+		age := time.Duration(i)*(72*time.Hour)/time.Duration(count) - time.Duration(rng.IntN(60)-30)*time.Minute
+		var picked []int64
+		if len(tagIDs) > 0 {
+			// Assign 1-3 random tags.
+			tagCount := 1 + rng.IntN(min(3, len(tagIDs)))
+			for _, t := range rng.Perm(len(tagIDs))[:tagCount] {
+				picked = append(picked, tagIDs[t])
 			}
 		}
+		score := 1 + rng.IntN(30)
 
-		// Auto-upvote from the author and set a random score for testing.
-		_, _ = pool.Exec(ctx,
-			"INSERT INTO votes (user_id, story_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-			user.ID, story.ID,
-		)
-		seedScore := 1 + rand.IntN(30)
-		_, _ = pool.Exec(ctx,
-			"UPDATE stories SET upvotes = $1 WHERE id = $2",
-			seedScore, story.ID,
-		)
+		result, err := link.Clean(s.URL)
+		if err != nil {
+			var ve *link.ValidationError
+			if errors.As(err, &ve) {
+				fmt.Printf("  skip (bad url): %s\n", s.URL)
+				continue
+			}
+			return nil, err
+		}
+		if seen[result.Normalized] {
+			fmt.Printf("  skip (duplicate): %s\n", s.URL)
+			continue
+		}
+		seen[result.Normalized] = true
+		found, err := exists(result.Normalized)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", s.URL, err)
+		}
+		if found {
+			fmt.Printf("  skip (exists): %s\n", s.URL)
+			continue
+		}
 
-		created++
-		fmt.Printf("  [%d/%d] %s (score=%d)\n", created, count, s.Title, seedScore)
+		planned = append(planned, plannedStory{
+			seedStory:  s,
+			Normalized: result.Normalized,
+			Age:        age,
+			TagIDs:     picked,
+			Score:      score,
+		})
 	}
-
-	fmt.Printf("Seeded %d stories.\n", created)
+	return planned, nil
 }
 
-func getOrCreateSeedUser(ctx context.Context, q *store.Queries) (store.User, error) {
-	u, err := q.GetUserByLogin(ctx, "seedbot")
-	if err == nil {
-		return u, nil
-	}
-	if !errors.Is(err, pgx.ErrNoRows) {
-		return store.User{}, err
+func create(ctx context.Context, pool *pgxpool.Pool, queries *store.Queries, userID int64, p plannedStory) error {
+	l, err := storyio.ResolveLink(ctx, queries, link.Config{}, p.URL)
+	if err != nil {
+		return err
 	}
 
-	row, err := q.CreateUser(ctx, store.CreateUserParams{
-		Username:       "seedbot",
-		Email:          "seedbot@localhost",
-		PasswordDigest: "!", // unusable password
-	})
+	params := store.CreateStoryParams{
+		UserID:    userID,
+		Title:     p.Title,
+		ShortCode: link.ShortCode(link.DefaultShortCodeLength),
+	}
+	l.Apply(&params)
+	story, err := queries.CreateStory(ctx, params)
 	if err != nil {
-		return store.User{}, err
+		return err
 	}
-	return store.User{
-		ID:       row.ID,
-		Username: row.Username,
-		Email:    row.Email,
-	}, nil
-}
 
-func generateShortCode() string {
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, 6)
-	if _, err := crand.Read(b); err != nil {
-		log.Fatalf("crypto/rand failed: %v", err)
-	}
-	for i := range b {
-		b[i] = charset[int(b[i])%len(charset)]
+	_ = l.Count(ctx, queries)
+
+	backdateTo := time.Now().Add(-p.Age)
+	_, _ = pool.Exec(ctx,
+		"UPDATE stories SET created_at = $1, updated_at = $1 WHERE id = $2",
+		backdateTo, story.ID,
+	)
+
+	for _, tagID := range p.TagIDs {
+		_ = queries.CreateTagging(ctx, store.CreateTaggingParams{
+			StoryID: story.ID,
+			TagID:   tagID,
+		})
 	}
-	return string(b)
+
+	// Auto-upvote from the author and set a random score for testing.
+	_, _ = pool.Exec(ctx,
+		"INSERT INTO votes (user_id, story_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID, story.ID,
+	)
+	_, _ = pool.Exec(ctx,
+		"UPDATE stories SET upvotes = $1 WHERE id = $2",
+		p.Score, story.ID,
+	)
+	return nil
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStories(n int) []seedStory {
+	stories := make([]seedStory, n)
+	for i := range stories {
+		stories[i] = seedStory{URL: fmt.Sprintf("https://example.com/post/%d", i), Title: fmt.Sprintf("Post %d", i)}
+	}
+	return stories
+}
+
+func noneExist(string) (bool, error) { return false, nil }
+
+func TestPlanReproducibleWithSeed(t *testing.T) {
+	stories := testStories(50)
+	tags := []int64{1, 2, 3, 4, 5}
+
+	first, err := plan(stories, 10, tags, rand.New(rand.NewPCG(42, 42)), noneExist)
+	require.NoError(t, err)
+	second, err := plan(stories, 10, tags, rand.New(rand.NewPCG(42, 42)), noneExist)
+	require.NoError(t, err)
+	require.Len(t, first, 10)
+	assert.Equal(t, first, second)
+
+	other, err := plan(stories, 10, tags, rand.New(rand.NewPCG(7, 7)), noneExist)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestPlanSkipsExistingAndInvalid(t *testing.T) {
+	stories := []seedStory{
+		{URL: "https://example.com/a", Title: "A"},
+		{URL: "not a url", Title: "Bad"},
+		{URL: "https://example.com/b", Title: "B"},
+		{URL: "https://example.com/b?utm_source=x", Title: "B again"},
+	}
+	exists := func(normalized string) (bool, error) {
+		return normalized == "https://example.com/a", nil
+	}
+
+	planned, err := plan(stories, len(stories), nil, rand.New(rand.NewPCG(1, 1)), exists)
+	require.NoError(t, err)
+	require.Len(t, planned, 1)
+	assert.Contains(t, planned[0].URL, "example.com/b")
+}
+
+func TestPlanExistingDoesNotShiftOthers(t *testing.T) {
+	stories := testStories(20)
+	all, err := plan(stories, 20, []int64{1, 2, 3}, rand.New(rand.NewPCG(3, 3)), noneExist)
+	require.NoError(t, err)
+
+	skip := all[0].Normalized
+	rest, err := plan(stories, 20, []int64{1, 2, 3}, rand.New(rand.NewPCG(3, 3)), func(n string) (bool, error) {
+		return n == skip, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, all[1:], rest, "a resumed run creates exactly the missing stories")
+}
+
+func TestApplyDryRunWritesNothing(t *testing.T) {
+	planned, err := plan(testStories(5), 5, []int64{1}, rand.New(rand.NewPCG(1, 1)), noneExist)
+	require.NoError(t, err)
+
+	created := apply(planned, true, func(plannedStory) error {
+		t.Fatal("dry run must not create stories")
+		return nil
+	})
+	assert.Zero(t, created)
+
+	var calls int
+	created = apply(planned, false, func(plannedStory) error {
+		calls++
+		return nil
+	})
+	assert.Equal(t, 5, created)
+	assert.Equal(t, 5, calls)
+}
@@ -17,16 +17,81 @@ import (
 	"crow.watch/internal/store"
 )
 
-// mediaCategories contains category names whose tags get is_media=true.
+// mediaCategories contains category names whose tags get is_media=true
+// unless the spec says otherwise.
 var mediaCategories = map[string]bool{
 	"format": true,
 }
 
-// privilegedCategories contains category names whose tags get privileged=true.
+// privilegedCategories contains category names whose tags get
+// privileged=true unless the spec says otherwise.
 var privilegedCategories = map[string]bool{
 	"crow": true,
 }
 
+// category is one entry of the spec. It is either a plain map of tag name
+// to description, or a map with a "tags" map plus optional "media" and
+// "privileged" flags:
+//
+//	format:
+//	  media: true
+//	  tags:
+//	    video: Video content
+type category struct {
+	Tags       map[string]string
+	Media      *bool
+	Privileged *bool
+}
+
+func (c *category) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Value == "tags" && node.Content[i+1].Kind == yaml.MappingNode {
+				var full struct {
+					Tags       map[string]string `yaml:"tags"`
+					Media      *bool             `yaml:"media"`
+					Privileged *bool             `yaml:"privileged"`
+				}
+				if err := node.Decode(&full); err != nil {
+					return err
+				}
+				*c = category(full)
+				return nil
+			}
+		}
+	}
+	return node.Decode(&c.Tags)
+}
+
+// flags returns the is_media and privileged flags for the tags of the
+// named category, falling back to the built-in defaults.
+func (c category) flags(name string) (isMedia, privileged bool) {
+	isMedia = mediaCategories[name]
+	if c.Media != nil {
+		isMedia = *c.Media
+	}
+	privileged = privilegedCategories[name]
+	if c.Privileged != nil {
+		privileged = *c.Privileged
+	}
+	return isMedia, privileged
+}
+
+func parseSpec(data []byte) (map[string]category, error) {
+	var spec map[string]category
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// tagStore is the subset of store.Queries used for seeding.
+type tagStore interface {
+	GetCategoryByName(ctx context.Context, name string) (store.Category, error)
+	CreateCategory(ctx context.Context, name string) (store.Category, error)
+	UpsertTag(ctx context.Context, arg store.UpsertTagParams) error
+}
+
 func main() {
 	dotenv.Load(".env")
 
@@ -41,9 +106,8 @@ func main() {
 		log.Fatalf("read %s: %v", path, err)
 	}
 
-	// Parse: map of category name → map of tag name → description
-	var spec map[string]map[string]string
-	if err := yaml.Unmarshal(data, &spec); err != nil {
+	spec, err := parseSpec(data)
+	if err != nil {
 		log.Fatalf("parse yaml: %v", err)
 	}
 
@@ -60,8 +124,12 @@ func main() {
 	}
 	defer pool.Close()
 
-	queries := store.New(pool)
+	if err := seed(ctx, store.New(pool), spec); err != nil {
+		log.Fatal(err)
+	}
+}
 
+func seed(ctx context.Context, q tagStore, spec map[string]category) error {
 	// Sort categories for deterministic output
 	catNames := make([]string, 0, len(spec))
 	for name := range spec {
@@ -71,46 +139,45 @@ func main() {
 
 	var totalTags int
 	for _, catName := range catNames {
-		tags := spec[catName]
+		c := spec[catName]
 
 		// Get or create category
-		cat, err := getOrCreateCategory(ctx, queries, catName)
+		cat, err := getOrCreateCategory(ctx, q, catName)
 		if err != nil {
-			log.Fatalf("category %q: %v", catName, err)
+			return fmt.Errorf("category %q: %w", catName, err)
 		}
 
-		isMedia := mediaCategories[catName]
-		privileged := privilegedCategories[catName]
+		isMedia, privileged := c.flags(catName)
 
 		// Sort tags for deterministic output
-		tagNames := make([]string, 0, len(tags))
-		for name := range tags {
+		tagNames := make([]string, 0, len(c.Tags))
+		for name := range c.Tags {
 			tagNames = append(tagNames, name)
 		}
 		sort.Strings(tagNames)
 
 		for _, tagName := range tagNames {
-			desc := tags[tagName]
-			err := queries.UpsertTag(ctx, store.UpsertTagParams{
+			err := q.UpsertTag(ctx, store.UpsertTagParams{
 				Tag:         tagName,
-				Description: desc,
+				Description: c.Tags[tagName],
 				CategoryID:  pgtype.Int8{Int64: cat.ID, Valid: true},
 				Privileged:  privileged,
 				IsMedia:     isMedia,
 			})
 			if err != nil {
-				log.Fatalf("tag %q: %v", tagName, err)
+				return fmt.Errorf("tag %q: %w", tagName, err)
 			}
 			totalTags++
 		}
 
-		fmt.Printf("  %s: %d tags\n", catName, len(tags))
+		fmt.Printf("  %s: %d tags\n", catName, len(c.Tags))
 	}
 
 	fmt.Printf("Seeded %d tags across %d categories.\n", totalTags, len(catNames))
+	return nil
 }
 
-func getOrCreateCategory(ctx context.Context, q *store.Queries, name string) (store.Category, error) {
+func getOrCreateCategory(ctx context.Context, q tagStore, name string) (store.Category, error) {
 	cat, err := q.GetCategoryByName(ctx, name)
 	if err == nil {
 		return cat, nil
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+type fakeStore struct {
+	categories map[string]store.Category
+	tags       map[string]store.UpsertTagParams
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{categories: map[string]store.Category{}, tags: map[string]store.UpsertTagParams{}}
+}
+
+func (f *fakeStore) GetCategoryByName(_ context.Context, name string) (store.Category, error) {
+	cat, ok := f.categories[name]
+	if !ok {
+		return store.Category{}, pgx.ErrNoRows
+	}
+	return cat, nil
+}
+
+func (f *fakeStore) CreateCategory(_ context.Context, name string) (store.Category, error) {
+	cat := store.Category{ID: int64(len(f.categories) + 1), Name: name}
+	f.categories[name] = cat
+	return cat, nil
+}
+
+func (f *fakeStore) UpsertTag(_ context.Context, arg store.UpsertTagParams) error {
+	f.tags[arg.Tag] = arg
+	return nil
+}
+
+func TestSeedFlagsFromSpec(t *testing.T) {
+	spec, err := parseSpec([]byte(`
+format:
+  video: Video content
+crow:
+  meta: About this site
+media:
+  media: true
+  tags:
+    podcast: Audio content
+staff:
+  privileged: true
+  tags:
+    announce: Announcements
+programming:
+  go: The Go language
+`))
+	require.NoError(t, err)
+
+	f := newFakeStore()
+	require.NoError(t, seed(context.Background(), f, spec))
+
+	tests := []struct {
+		tag        string
+		isMedia    bool
+		privileged bool
+	}{
+		{"video", true, false},
+		{"meta", false, true},
+		{"podcast", true, false},
+		{"announce", false, true},
+		{"go", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := f.tags[tt.tag]
+			require.True(t, ok)
+			assert.Equal(t, tt.isMedia, got.IsMedia)
+			assert.Equal(t, tt.privileged, got.Privileged)
+		})
+	}
+	assert.Equal(t, "Audio content", f.tags["podcast"].Description)
+	assert.Len(t, f.categories, 5)
+}
+
+func TestSeedSpecOverridesDefaults(t *testing.T) {
+	spec, err := parseSpec([]byte(`
+format:
+  media: false
+  tags:
+    video: Video content
+`))
+	require.NoError(t, err)
+
+	f := newFakeStore()
+	require.NoError(t, seed(context.Background(), f, spec))
+	assert.False(t, f.tags["video"].IsMedia)
+}
+
+func TestParseSpecPlainTagNamedMedia(t *testing.T) {
+	// Without a "tags" map the category is a plain tag list, even if a
+	// tag happens to be called "media".
+	spec, err := parseSpec([]byte(`
+topics:
+  media: News about the media
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"media": "News about the media"}, spec["topics"].Tags)
+	assert.Nil(t, spec["topics"].Media)
+}
+
+// testDB creates a throwaway schema from db/schema.sql in the database at
+// TEST_DATABASE_URL, skipping the test when it is not set.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	schema, err := os.ReadFile("../../db/schema.sql")
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	conn, err := pgx.Connect(ctx, url)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	return pool
+}
+
+func TestUpsertTagKeepsEditedDescription(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	_, err := pool.Exec(ctx, "INSERT INTO tags (tag, description) VALUES ('go', ''), ('rust', 'Old seed')")
+	require.NoError(t, err)
+	var goID int64
+	require.NoError(t, pool.QueryRow(ctx, "SELECT id FROM tags WHERE tag = 'go'").Scan(&goID))
+	require.NoError(t, q.UpdateTagDescription(ctx, store.UpdateTagDescriptionParams{ID: goID, Description: "Edited by a moderator"}))
+
+	for _, tag := range []string{"go", "rust"} {
+		require.NoError(t, q.UpsertTag(ctx, store.UpsertTagParams{Tag: tag, Description: "Seeded"}))
+	}
+
+	var goDesc, rustDesc string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT description FROM tags WHERE tag = 'go'").Scan(&goDesc))
+	require.NoError(t, pool.QueryRow(ctx, "SELECT description FROM tags WHERE tag = 'rust'").Scan(&rustDesc))
+	assert.Equal(t, "Edited by a moderator", goDesc)
+	assert.Equal(t, "Seeded", rustDesc, "seeded descriptions follow the seed")
+}
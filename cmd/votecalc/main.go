@@ -2,18 +2,34 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"crow.watch/internal/dotenv"
+	"crow.watch/internal/flagreason"
 	"crow.watch/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
 	dotenv.Load(".env")
 
+	incremental := flag.Bool("incremental", false, "only recount stories with vote, flag or hide activity since their last recount")
+	maxAge := flag.Duration("max-age", 24*time.Hour, "with -incremental, also recount stories not recounted for this long (0 disables)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: votecalc [-incremental [-max-age 24h]]\n\n")
+		fmt.Fprintf(os.Stderr, "Flag weights depend on each flagger's account age, karma and standing,\n")
+		fmt.Fprintf(os.Stderr, "which change without any story activity. An incremental run leaves\n")
+		fmt.Fprintf(os.Stderr, "quiet stories alone, so their downvotes drift from a full run until\n")
+		fmt.Fprintf(os.Stderr, "-max-age forces a recount.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	ctx := context.Background()
 
 	databaseURL := os.Getenv("DATABASE_URL")
@@ -29,7 +45,37 @@ func main() {
 
 	queries := store.New(pool)
 
-	updated, err := queries.RecalculateStoryScores(ctx)
+	flags, err := flagreason.ParseOrDefault(os.Getenv("STORY_FLAG_REASONS"), flagreason.DefaultStory)
+	if err != nil {
+		log.Fatalf("STORY_FLAG_REASONS: %v", err)
+	}
+	reasons, weights := flags.Weights()
+
+	trust := flagreason.DefaultTrust
+	if os.Getenv("FLAG_TRUST_WEIGHTING") == "false" {
+		trust = flagreason.Trust{}
+	}
+	now := time.Now()
+	tp := trust.Params(now)
+
+	var rescoreBefore pgtype.Timestamptz
+	if *maxAge > 0 {
+		rescoreBefore = pgtype.Timestamptz{Time: now.Add(-*maxAge), Valid: true}
+	}
+
+	updated, err := queries.RecalculateStoryScores(ctx, store.RecalculateStoryScoresParams{
+		Incremental:    *incremental,
+		RescoreBefore:  rescoreBefore,
+		MaxPercent:     tp.MaxPercent,
+		WeighFlaggers:  tp.WeighFlaggers,
+		TrustedPercent: tp.TrustedPercent,
+		NewBefore:      pgtype.Timestamptz{Time: tp.NewBefore, Valid: true},
+		NewPercent:     tp.NewPercent,
+		TrustedBefore:  pgtype.Timestamptz{Time: tp.TrustedBefore, Valid: true},
+		TrustedKarma:   tp.TrustedKarma,
+		Reasons:        reasons,
+		Weights:        weights,
+	})
 	if err != nil {
 		log.Fatalf("recalculate scores: %v", err)
 	}
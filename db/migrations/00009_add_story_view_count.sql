@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN view_count INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE stories DROP COLUMN IF EXISTS view_count;
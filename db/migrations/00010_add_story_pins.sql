@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN pinned_until TIMESTAMPTZ;
+CREATE INDEX stories_pinned_until_idx ON stories (pinned_until) WHERE pinned_until IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS stories_pinned_until_idx;
+ALTER TABLE stories DROP COLUMN IF EXISTS pinned_until;
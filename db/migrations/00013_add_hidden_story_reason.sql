@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE hidden_stories ADD COLUMN reason TEXT NOT NULL DEFAULT 'unspecified';
+
+-- +goose Down
+ALTER TABLE hidden_stories DROP COLUMN IF EXISTS reason;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN enable_embeds BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS enable_embeds;
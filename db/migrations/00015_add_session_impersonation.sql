@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN impersonated_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN IF EXISTS impersonated_user_id;
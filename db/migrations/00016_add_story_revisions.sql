@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE story_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    editor_id BIGINT NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL,
+    url TEXT,
+    body TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX story_revisions_story_id_idx ON story_revisions (story_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS story_revisions;
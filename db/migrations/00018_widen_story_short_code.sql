@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE stories ALTER COLUMN short_code TYPE VARCHAR(16);
+
+-- +goose Down
+ALTER TABLE stories ALTER COLUMN short_code TYPE CHAR(6);
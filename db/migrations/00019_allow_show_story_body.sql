@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE stories DROP CONSTRAINT stories_link_xor_text;
+ALTER TABLE stories ADD CONSTRAINT stories_link_or_text CHECK (
+    (url IS NOT NULL AND normalized_url IS NOT NULL AND domain_id IS NOT NULL)
+    OR
+    (url IS NULL AND normalized_url IS NULL AND domain_id IS NULL AND body IS NOT NULL)
+);
+
+-- +goose Down
+ALTER TABLE stories DROP CONSTRAINT stories_link_or_text;
+ALTER TABLE stories ADD CONSTRAINT stories_link_xor_text CHECK (
+    (url IS NOT NULL AND normalized_url IS NOT NULL AND domain_id IS NOT NULL AND body IS NULL)
+    OR
+    (url IS NULL AND normalized_url IS NULL AND domain_id IS NULL AND body IS NOT NULL)
+);
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN admin_adjustment INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE stories DROP COLUMN admin_adjustment;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE domain_subscriptions (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    domain_id BIGINT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, domain_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS domain_subscriptions;
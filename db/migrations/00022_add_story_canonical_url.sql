@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN canonical_url TEXT;
+ALTER TABLE stories ADD COLUMN normalized_canonical_url TEXT;
+CREATE INDEX stories_normalized_canonical_url_idx ON stories (normalized_canonical_url) WHERE normalized_canonical_url IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS stories_normalized_canonical_url_idx;
+ALTER TABLE stories DROP COLUMN IF EXISTS normalized_canonical_url;
+ALTER TABLE stories DROP COLUMN IF EXISTS canonical_url;
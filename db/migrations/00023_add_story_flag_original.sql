@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE story_flags ADD COLUMN original_story_id BIGINT REFERENCES stories(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE story_flags DROP COLUMN IF EXISTS original_story_id;
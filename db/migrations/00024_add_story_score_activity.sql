@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN score_activity_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE stories ADD COLUMN scored_at TIMESTAMPTZ;
+CREATE INDEX stories_score_stale_idx ON stories (id) WHERE scored_at IS NULL OR score_activity_at > scored_at;
+
+-- +goose Down
+DROP INDEX IF EXISTS stories_score_stale_idx;
+ALTER TABLE stories DROP COLUMN IF EXISTS scored_at;
+ALTER TABLE stories DROP COLUMN IF EXISTS score_activity_at;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE tag_synonyms (
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    synonym TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX tag_synonyms_synonym_unique ON tag_synonyms (lower(synonym));
+CREATE INDEX tag_synonyms_tag_id_idx ON tag_synonyms (tag_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS tag_synonyms;
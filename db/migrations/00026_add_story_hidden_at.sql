@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN hidden_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE stories DROP COLUMN IF EXISTS hidden_at;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN picked_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE stories DROP COLUMN IF EXISTS picked_at;
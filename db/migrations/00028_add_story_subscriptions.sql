@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE story_subscriptions (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    subscribed BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, story_id)
+);
+
+-- Submitters and commenters of existing stories are subscribed from now on.
+INSERT INTO story_subscriptions (user_id, story_id)
+SELECT user_id, id FROM stories WHERE deleted_at IS NULL
+UNION
+SELECT user_id, story_id FROM comments WHERE deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS story_subscriptions;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE comment_flags ADD COLUMN weight INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE comment_flags DROP COLUMN IF EXISTS weight;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE stories ADD COLUMN imported_upvotes INT NOT NULL DEFAULT 0;
+ALTER TABLE stories ADD COLUMN imported_downvotes INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE stories DROP COLUMN IF EXISTS imported_downvotes;
+ALTER TABLE stories DROP COLUMN IF EXISTS imported_upvotes;
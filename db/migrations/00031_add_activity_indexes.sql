@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE INDEX stories_updated_at_idx ON stories (updated_at);
+CREATE INDEX stories_score_activity_at_idx ON stories (score_activity_at);
+CREATE INDEX idx_comments_created_at ON comments(created_at);
+CREATE INDEX idx_comments_deleted_at ON comments(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+DROP INDEX IF EXISTS idx_comments_created_at;
+DROP INDEX IF EXISTS stories_score_activity_at_idx;
+DROP INDEX IF EXISTS stories_updated_at_idx;
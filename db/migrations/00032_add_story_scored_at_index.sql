@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX stories_scored_at_idx ON stories (scored_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS stories_scored_at_idx;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE tags ADD COLUMN description_edited_by_moderator BOOLEAN NOT NULL DEFAULT false;
+UPDATE tags SET description_edited_by_moderator = true
+WHERE id IN (SELECT target_id FROM moderation_log WHERE action = 'tag.edit_description' AND target_type = 'tag');
+
+-- +goose Down
+ALTER TABLE tags DROP COLUMN IF EXISTS description_edited_by_moderator;
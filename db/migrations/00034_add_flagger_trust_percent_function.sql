@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE FUNCTION flagger_trust_percent(
+    flagger users,
+    weigh_flaggers BOOLEAN,
+    trusted_percent INTEGER,
+    new_before TIMESTAMPTZ,
+    new_percent INTEGER,
+    trusted_before TIMESTAMPTZ,
+    trusted_karma INTEGER
+) RETURNS INTEGER LANGUAGE sql STABLE AS $$
+    SELECT CASE
+        WHEN NOT weigh_flaggers THEN 100
+        WHEN flagger.is_moderator THEN trusted_percent
+        WHEN flagger.email_confirmed_at IS NULL THEN 0
+        WHEN flagger.created_at > new_before THEN new_percent
+        WHEN flagger.created_at <= trusted_before
+            AND (SELECT coalesce(sum(s.upvotes), 0) FROM stories s WHERE s.user_id = flagger.id AND s.deleted_at IS NULL)
+              + (SELECT coalesce(sum(c.upvotes), 0) FROM comments c WHERE c.user_id = flagger.id AND c.deleted_at IS NULL)
+              >= trusted_karma
+            THEN trusted_percent
+        ELSE 100
+    END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION IF EXISTS flagger_trust_percent(users, BOOLEAN, INTEGER, TIMESTAMPTZ, INTEGER, TIMESTAMPTZ, INTEGER);
@@ -0,0 +1,32 @@
+-- name: ListUserActivity :many
+-- A user's live stories and comments, newest first.
+SELECT kind, story_short_code, story_title, comment_id, body, created_at
+FROM (
+    SELECT
+        'story'::text AS kind,
+        s.short_code AS story_short_code,
+        s.title AS story_title,
+        0::bigint AS comment_id,
+        ''::text AS body,
+        s.created_at,
+        s.id AS item_id
+    FROM stories s
+    WHERE s.user_id = @user_id
+      AND s.deleted_at IS NULL
+    UNION ALL
+    SELECT
+        'comment'::text,
+        s.short_code,
+        s.title,
+        c.id,
+        c.body,
+        c.created_at,
+        c.id
+    FROM comments c
+    JOIN stories s ON s.id = c.story_id
+    WHERE c.user_id = @user_id
+      AND c.deleted_at IS NULL
+      AND s.deleted_at IS NULL
+) AS activity
+ORDER BY created_at DESC, item_id DESC
+LIMIT @item_limit OFFSET @item_offset;
@@ -23,6 +23,9 @@ SELECT
     u.unconfirmed_email,
     u.website,
     u.about,
+    u.slogan,
+    u.last_seen_at,
+    u.enable_embeds,
     u.created_at,
     u.updated_at
 FROM api_keys ak
@@ -13,6 +13,22 @@ WHERE lower(c.slug) = lower(@slug)
   AND c.active = true
 LIMIT 1;
 
+-- name: ListCampaignSignups :many
+-- The most recent signups of each campaign, matched the same way as
+-- ListCampaigns' registered_count.
+SELECT campaign, username, created_at
+FROM (
+    SELECT
+        campaign,
+        username,
+        created_at,
+        row_number() OVER (PARTITION BY campaign ORDER BY created_at DESC, id DESC) AS rn
+    FROM users
+    WHERE campaign <> ''
+) AS signups
+WHERE rn <= @per_campaign::int
+ORDER BY campaign, created_at DESC;
+
 -- name: ListCampaigns :many
 SELECT
     c.*,
@@ -1,11 +1,14 @@
 -- name: CreateCommentFlag :one
+-- The flag costs the comment its reason's weight in downvotes. The weight
+-- is stored on the flag so removing it gives back exactly what it took,
+-- even if the configured weights change in between.
 WITH ins AS (
-    INSERT INTO comment_flags (user_id, comment_id, reason)
-    VALUES (@user_id, @comment_id, @reason)
+    INSERT INTO comment_flags (user_id, comment_id, reason, weight)
+    VALUES (@user_id, @comment_id, @reason, @weight)
     ON CONFLICT DO NOTHING
-    RETURNING comment_id
+    RETURNING weight
 )
-UPDATE comments SET downvotes = downvotes + (SELECT count(*) FROM ins)::int
+UPDATE comments SET downvotes = downvotes + coalesce((SELECT sum(weight) FROM ins), 0)::int
 WHERE id = @comment_id
 RETURNING upvotes - downvotes AS score;
 
@@ -13,9 +16,9 @@ RETURNING upvotes - downvotes AS score;
 WITH del AS (
     DELETE FROM comment_flags
     WHERE comment_flags.user_id = @user_id AND comment_flags.comment_id = @comment_id
-    RETURNING comment_id
+    RETURNING weight
 )
-UPDATE comments SET downvotes = downvotes - (SELECT count(*) FROM del)::int
+UPDATE comments SET downvotes = downvotes - coalesce((SELECT sum(weight) FROM del), 0)::int
 WHERE id = @comment_id
 RETURNING upvotes - downvotes AS score;
 
@@ -30,3 +33,15 @@ FROM comment_flags
 WHERE comment_id = ANY(@comment_ids::bigint[])
 GROUP BY comment_id, reason
 ORDER BY comment_id, count DESC;
+
+-- name: ClearCommentFlags :many
+-- Each removed flag gives back the downvotes it cost the comment.
+WITH del AS (
+    DELETE FROM comment_flags
+    WHERE comment_id = ANY(@comment_ids::bigint[])
+    RETURNING comment_id, weight
+)
+UPDATE comments AS c SET downvotes = c.downvotes - d.flags
+FROM (SELECT comment_id, sum(weight)::int AS flags FROM del GROUP BY comment_id) AS d
+WHERE c.id = d.comment_id
+RETURNING c.id;
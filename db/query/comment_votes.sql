@@ -23,3 +23,14 @@ RETURNING upvotes - downvotes AS score;
 SELECT comment_id
 FROM comment_votes
 WHERE user_id = @user_id AND comment_id = ANY(@comment_ids::bigint[]);
+
+-- name: ExportUserCommentVotes :many
+SELECT
+    v.comment_id,
+    s.short_code AS story_short_code,
+    v.created_at
+FROM comment_votes AS v
+JOIN comments AS c ON c.id = v.comment_id
+JOIN stories AS s ON s.id = c.story_id
+WHERE v.user_id = @user_id
+ORDER BY v.created_at, v.comment_id;
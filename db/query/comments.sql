@@ -1,10 +1,10 @@
 -- name: CreateComment :one
 INSERT INTO comments (story_id, user_id, parent_id, body, depth)
 VALUES (@story_id, @user_id, @parent_id, @body, @depth)
-RETURNING id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at;
+RETURNING id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at, edited_at;
 
 -- name: GetCommentByID :one
-SELECT id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at
+SELECT id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at, edited_at
 FROM comments
 WHERE id = @id;
 
@@ -21,14 +21,35 @@ SELECT
     c.created_at,
     c.updated_at,
     c.deleted_at,
-    u.username
+    c.edited_at,
+    u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at
 FROM comments AS c
 JOIN users AS u ON u.id = c.user_id
 WHERE c.story_id = @story_id
 ORDER BY c.created_at ASC;
 
+-- name: ListCommentsByUsername :many
+SELECT
+    c.id,
+    c.body,
+    c.upvotes,
+    c.downvotes,
+    c.created_at,
+    c.deleted_at,
+    s.title AS story_title,
+    s.short_code AS story_short_code
+FROM comments AS c
+JOIN users AS u ON u.id = c.user_id
+JOIN stories AS s ON s.id = c.story_id
+WHERE lower(u.username) = lower(@username)
+  AND s.deleted_at IS NULL
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT @comment_limit OFFSET @comment_offset;
+
 -- name: UpdateCommentBody :exec
-UPDATE comments SET body = @body, updated_at = now()
+UPDATE comments SET body = @body, updated_at = now(), edited_at = now()
 WHERE id = @id;
 
 -- name: SoftDeleteComment :exec
@@ -41,3 +62,25 @@ UPDATE stories SET comment_count = comment_count + 1 WHERE id = @id;
 -- name: DecrementStoryCommentCount :exec
 UPDATE stories SET comment_count = comment_count - 1 WHERE id = @id AND comment_count > 0;
 
+-- name: ExportUserComments :many
+SELECT
+    c.id,
+    c.parent_id,
+    c.body,
+    c.upvotes,
+    c.downvotes,
+    c.created_at,
+    c.edited_at,
+    c.deleted_at,
+    s.short_code AS story_short_code,
+    s.title AS story_title
+FROM comments AS c
+JOIN stories AS s ON s.id = c.story_id
+WHERE c.user_id = @user_id
+ORDER BY c.created_at, c.id;
+
+-- name: SoftDeleteComments :many
+UPDATE comments SET deleted_at = now(), body = ''
+WHERE id = ANY(@ids::bigint[])
+  AND deleted_at IS NULL
+RETURNING id, story_id;
@@ -0,0 +1,11 @@
+-- name: SubscribeDomain :exec
+INSERT INTO domain_subscriptions (user_id, domain_id)
+VALUES (@user_id, @domain_id)
+ON CONFLICT DO NOTHING;
+
+-- name: UnsubscribeDomain :exec
+DELETE FROM domain_subscriptions
+WHERE user_id = @user_id AND domain_id = @domain_id;
+
+-- name: IsSubscribedToDomain :one
+SELECT EXISTS(SELECT 1 FROM domain_subscriptions WHERE user_id = @user_id AND domain_id = @domain_id) AS exists;
@@ -8,3 +8,18 @@ RETURNING id, domain, banned, ban_reason, story_count, created_at, updated_at;
 UPDATE domains
 SET story_count = story_count + 1, updated_at = now()
 WHERE id = @id;
+
+-- name: GetDomainByName :one
+SELECT id, domain, banned, ban_reason, story_count, created_at, updated_at
+FROM domains
+WHERE lower(domain) = lower(@domain);
+
+-- name: GetDomainByID :one
+SELECT id, domain, banned, ban_reason, story_count, created_at, updated_at
+FROM domains
+WHERE id = @id;
+
+-- name: DecrementDomainStoryCount :exec
+UPDATE domains
+SET story_count = greatest(story_count - 1, 0), updated_at = now()
+WHERE id = @id;
@@ -1,6 +1,6 @@
 -- name: HideStory :exec
-INSERT INTO hidden_stories (user_id, story_id)
-VALUES (@user_id, @story_id)
+INSERT INTO hidden_stories (user_id, story_id, reason)
+VALUES (@user_id, @story_id, @reason)
 ON CONFLICT DO NOTHING;
 
 -- name: UnhideStory :exec
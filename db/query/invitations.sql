@@ -3,6 +3,24 @@ INSERT INTO invitations (inviter_id, email, token_hash)
 VALUES (@inviter_id, @email, @token_hash)
 RETURNING id, inviter_id, email, token_hash, used_by_id, created_at;
 
+-- name: CreateInvitationWithinQuota :one
+-- Inserts nothing, and so returns no row, once the inviter has created
+-- max_total invitations or has max_outstanding pending ones, counted as in
+-- CountInvitationsByUser. A limit of zero means none. Run it holding the
+-- inviter's LockUser lock so concurrent invitations are counted in turn.
+INSERT INTO invitations (inviter_id, email, token_hash)
+SELECT @inviter_id::bigint, sqlc.narg('email')::text, @token_hash::text
+WHERE (@max_total::int <= 0 OR (
+        SELECT count(*) FROM invitations WHERE inviter_id = @inviter_id
+    ) < @max_total)
+  AND (@max_outstanding::int <= 0 OR (
+        SELECT count(*) FROM invitations
+        WHERE inviter_id = @inviter_id
+          AND used_by_id IS NULL
+          AND created_at > now() - INTERVAL '24 hours'
+    ) < @max_outstanding)
+RETURNING id, inviter_id, email, token_hash, used_by_id, created_at;
+
 -- name: GetInvitationByTokenHash :one
 SELECT
     i.id,
@@ -41,3 +59,25 @@ SELECT id, username, email
 FROM users
 WHERE lower(email) = lower(@email)
 LIMIT 1;
+
+-- name: CountInvitationsByUser :one
+SELECT
+    count(*) FILTER (
+        WHERE used_by_id IS NULL
+          AND created_at > now() - INTERVAL '24 hours'
+    ) AS outstanding,
+    count(*) AS total
+FROM invitations
+WHERE inviter_id = @inviter_id;
+
+-- name: ExportUserInvitations :many
+-- Addresses of claimed invitations are left out: they belong to the
+-- invitee, who is listed by username instead.
+SELECT
+    CASE WHEN i.used_by_id IS NULL THEN i.email END AS email,
+    i.created_at,
+    ru.username AS registered_username
+FROM invitations AS i
+LEFT JOIN users AS ru ON ru.id = i.used_by_id
+WHERE i.inviter_id = @inviter_id
+ORDER BY i.created_at, i.id;
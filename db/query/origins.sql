@@ -8,3 +8,8 @@ RETURNING id, domain_id, origin, banned, ban_reason, story_count, created_at, up
 UPDATE origins
 SET story_count = story_count + 1, updated_at = now()
 WHERE id = @id;
+
+-- name: DecrementOriginStoryCount :exec
+UPDATE origins
+SET story_count = greatest(story_count - 1, 0), updated_at = now()
+WHERE id = @id;
@@ -23,6 +23,7 @@ INSERT INTO sessions (
 SELECT
     s.id AS session_id,
     s.expires_at,
+    s.impersonated_user_id,
     u.id,
     u.username,
     u.email,
@@ -38,6 +39,9 @@ SELECT
     u.unconfirmed_email,
     u.website,
     u.about,
+    u.slogan,
+    u.last_seen_at,
+    u.enable_embeds,
     u.created_at,
     u.updated_at
 FROM sessions AS s
@@ -63,3 +67,9 @@ WHERE user_id = @user_id;
 -- name: DeleteExpiredSessions :exec
 DELETE FROM sessions
 WHERE expires_at <= now();
+
+-- name: SetSessionImpersonation :exec
+UPDATE sessions
+SET impersonated_user_id = @impersonated_user_id,
+    updated_at = now()
+WHERE id = @id;
@@ -0,0 +1,19 @@
+-- name: GetSiteStats :one
+SELECT
+    (SELECT COUNT(*)::int FROM users WHERE deleted_at IS NULL) AS total_users,
+    (SELECT COUNT(DISTINCT user_id)::int FROM sessions WHERE last_seen_at >= @active_since::timestamptz) AS active_users,
+    (SELECT COUNT(*)::int FROM stories WHERE created_at >= @today::timestamptz AND deleted_at IS NULL) AS stories_today,
+    (SELECT COUNT(*)::int FROM stories WHERE created_at >= @week_start::timestamptz AND deleted_at IS NULL) AS stories_this_week,
+    (SELECT COUNT(*)::int FROM comments WHERE deleted_at IS NULL) AS total_comments,
+    (SELECT COUNT(*)::int FROM invitations WHERE used_by_id IS NULL AND created_at > @invitations_since::timestamptz) AS pending_invitations,
+    (SELECT COUNT(*)::int FROM story_flags WHERE created_at >= @week_start::timestamptz) AS story_flags_this_week,
+    (SELECT COUNT(*)::int FROM comment_flags WHERE created_at >= @week_start::timestamptz) AS comment_flags_this_week;
+
+-- name: GetTopDomains :many
+SELECT d.domain, COUNT(*)::int AS stories
+FROM stories s
+JOIN domains d ON d.id = s.domain_id
+WHERE s.created_at >= @since::timestamptz AND s.deleted_at IS NULL
+GROUP BY d.domain
+ORDER BY stories DESC, d.domain
+LIMIT @max_results::int;
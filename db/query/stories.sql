@@ -6,9 +6,9 @@ RETURNING id, user_id, domain_id, origin_id, url, normalized_url, title, body, s
 -- name: FindRecentByNormalizedURL :one
 SELECT id, url, title, short_code, created_at
 FROM stories
-WHERE normalized_url = @normalized_url
+WHERE (normalized_url = @normalized_url OR normalized_canonical_url = @normalized_url)
   AND deleted_at IS NULL
-  AND created_at > now() - INTERVAL '30 days'
+  AND (sqlc.narg('created_after')::timestamptz IS NULL OR created_at > sqlc.narg('created_after'))
 ORDER BY created_at DESC
 LIMIT 1;
 
@@ -19,21 +19,50 @@ VALUES (@story_id, @tag_id);
 -- name: ListStories :many
 SELECT
     s.id,
+    s.user_id,
     s.url,
     s.title,
     s.body,
     s.short_code,
     s.upvotes,
     s.downvotes,
+    s.admin_adjustment,
     s.comment_count,
     s.created_at,
     s.deleted_at,
     s.duplicate_of_id,
+    s.pinned_until,
+    s.picked_at,
     u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at,
+    u.created_at AS user_created_at,
     d.domain,
     o.origin,
     dup.short_code AS duplicate_of_short_code,
-    dup.title AS duplicate_of_title
+    dup.title AS duplicate_of_title,
+    (
+        SELECT coalesce(jsonb_agg(jsonb_build_object(
+            'tag', t.tag,
+            'is_media', t.is_media,
+            'hotness_mod', t.hotness_mod
+        ) ORDER BY t.is_media DESC, t.tag ASC), '[]')
+        FROM taggings AS tg3
+        JOIN tags AS t ON t.id = tg3.tag_id
+        WHERE tg3.story_id = s.id
+    )::jsonb AS tags,
+    EXISTS (
+        SELECT 1 FROM votes AS v
+        WHERE v.story_id = s.id AND v.user_id = sqlc.narg('viewer_id')
+    ) AS has_upvoted,
+    EXISTS (
+        SELECT 1 FROM story_flags AS sf
+        WHERE sf.story_id = s.id AND sf.user_id = sqlc.narg('viewer_id')
+    ) AS has_flagged,
+    EXISTS (
+        SELECT 1 FROM hidden_stories AS hs
+        WHERE hs.story_id = s.id AND hs.user_id = sqlc.narg('viewer_id')
+    ) AS has_hidden
 FROM stories AS s
 JOIN users AS u ON u.id = s.user_id
 LEFT JOIN domains AS d ON d.id = s.domain_id
@@ -44,28 +73,50 @@ WHERE
     (sqlc.narg('tag_id')::bigint IS NULL OR tg.tag_id IS NOT NULL)
     AND (sqlc.narg('username')::text IS NULL OR lower(u.username) = lower(sqlc.narg('username')))
     AND (NOT @hide_deleted::bool OR s.deleted_at IS NULL)
+    AND s.hidden_at IS NULL
     AND s.id NOT IN (
         SELECT tg2.story_id FROM taggings AS tg2
         WHERE tg2.tag_id = ANY(@hidden_tag_ids::bigint[])
     )
-ORDER BY s.created_at DESC
-LIMIT @story_limit;
+    AND (sqlc.narg('created_after')::timestamptz IS NULL OR s.created_at >= sqlc.narg('created_after'))
+    AND (sqlc.narg('domain_id')::bigint IS NULL OR s.domain_id = sqlc.narg('domain_id'))
+    AND (sqlc.narg('subscriber_id')::bigint IS NULL OR s.domain_id IN (
+        SELECT ds.domain_id FROM domain_subscriptions AS ds
+        WHERE ds.user_id = sqlc.narg('subscriber_id')
+    ))
+    AND (NOT @hide_viewer_hidden::bool OR NOT EXISTS (
+        SELECT 1 FROM hidden_stories AS hs2
+        WHERE hs2.story_id = s.id AND hs2.user_id = sqlc.narg('viewer_id')
+    ))
+    AND (NOT @hide_duplicates::bool OR s.duplicate_of_id IS NULL)
+ORDER BY
+    CASE WHEN @order_by_score::bool THEN s.upvotes - s.downvotes + s.admin_adjustment END DESC,
+    CASE WHEN NOT @chronological::bool THEN s.pinned_until IS NOT NULL AND s.pinned_until > now() END DESC,
+    s.created_at DESC
+LIMIT @story_limit OFFSET @story_offset;
 
 -- name: GetStory :one
 SELECT
     s.id,
     s.user_id,
     s.url,
+    s.canonical_url,
     s.title,
     s.body,
     s.short_code,
     s.upvotes,
     s.downvotes,
+    s.admin_adjustment,
     s.comment_count,
+    s.view_count,
     s.created_at,
     s.deleted_at,
     s.duplicate_of_id,
+    s.pinned_until,
+    s.picked_at,
     u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at,
     d.domain,
     o.origin,
     dup.short_code AS duplicate_of_short_code,
@@ -88,20 +139,66 @@ ORDER BY t.is_media DESC, t.tag ASC;
 -- name: CountStories :one
 SELECT count(*) FROM stories WHERE deleted_at IS NULL;
 
+-- name: GetLatestStoryActivity :one
+-- The last time anything a chronological listing shows changed: a story
+-- was submitted, edited, deleted or voted on, or a comment was posted or
+-- removed. Deletions move it forward, never back. A story's updated_at
+-- starts at its created_at, and every max() here reads the end of an index.
+SELECT greatest(
+    (SELECT max(updated_at) FROM stories),
+    (SELECT max(score_activity_at) FROM stories),
+    (SELECT max(created_at) FROM comments),
+    (SELECT max(deleted_at) FROM comments)
+)::timestamptz AS latest;
+
+-- name: GetUserLatestStoryTime :one
+-- Deleted stories count too, so deleting a story doesn't reset the
+-- submission cooldown.
+SELECT max(created_at)::timestamptz AS latest FROM stories WHERE user_id = @user_id;
+
 -- name: RecalculateStoryScores :execrows
+-- Flags are weighed the same way as in RecalculateStoryDownvotes. With
+-- @incremental set, only stories with vote, flag or hide activity since
+-- they were last recalculated are recounted, plus any last recounted
+-- before @rescore_before. Flag weights depend on the flagger's age, karma
+-- and standing, which change without any story activity, so incremental
+-- counts drift from a full run until the story is recounted again. Each
+-- branch of stale stands alone so the incremental ones can use
+-- stories_score_stale_idx and stories_scored_at_idx.
+WITH stale AS (
+    SELECT id FROM stories WHERE NOT @incremental::bool
+    UNION
+    SELECT id FROM stories
+    WHERE @incremental::bool AND (scored_at IS NULL OR score_activity_at > scored_at)
+    UNION
+    SELECT id FROM stories
+    WHERE @incremental::bool AND scored_at < sqlc.narg('rescore_before')::timestamptz
+)
 UPDATE stories SET
-  upvotes = coalesce(v.cnt, 0)::int,
-  downvotes = coalesce(hf.cnt, 0)::int
-FROM stories s2
-LEFT JOIN (SELECT story_id, count(*) AS cnt FROM votes GROUP BY story_id) v ON v.story_id = s2.id
+  upvotes = stories.imported_upvotes + coalesce(v.cnt, 0)::int,
+  downvotes = stories.imported_downvotes + coalesce(hf.cnt, 0)::int,
+  scored_at = now()
+FROM stale s2
+LEFT JOIN (
+    SELECT story_id, count(*) AS cnt FROM votes
+    WHERE story_id IN (SELECT id FROM stale)
+    GROUP BY story_id
+) v ON v.story_id = s2.id
 LEFT JOIN (
-    SELECT hs.story_id, count(*) AS cnt
+    SELECT hs.story_id, sum(least(coalesce(w.weight, 1) * ft.percent, @max_percent::int)) / 100 AS cnt
     FROM hidden_stories hs
     JOIN story_flags sf ON sf.user_id = hs.user_id AND sf.story_id = hs.story_id
-    WHERE NOT EXISTS (
-        SELECT 1 FROM comments c
-        WHERE c.story_id = hs.story_id AND c.user_id = hs.user_id AND c.deleted_at IS NULL
-    )
+    JOIN users u ON u.id = sf.user_id
+    CROSS JOIN LATERAL flagger_trust_percent(
+        u, @weigh_flaggers::bool, @trusted_percent::int, @new_before::timestamptz,
+        @new_percent::int, @trusted_before::timestamptz, @trusted_karma::int
+    ) AS ft(percent)
+    LEFT JOIN unnest(@reasons::text[], @weights::int[]) AS w(reason, weight) ON w.reason = sf.reason
+    WHERE hs.story_id IN (SELECT id FROM stale)
+      AND NOT EXISTS (
+          SELECT 1 FROM comments c
+          WHERE c.story_id = hs.story_id AND c.user_id = hs.user_id AND c.deleted_at IS NULL
+      )
     GROUP BY hs.story_id
 ) hf ON hf.story_id = s2.id
 WHERE stories.id = s2.id;
@@ -115,8 +212,21 @@ UPDATE stories SET body = @body, updated_at = now() WHERE id = @id;
 -- name: UpdateStoryURL :exec
 UPDATE stories SET url = @url, normalized_url = @normalized_url, domain_id = @domain_id, origin_id = @origin_id, updated_at = now() WHERE id = @id;
 
+-- name: ClearStoryLink :one
+-- Turns a link story into a text post, which needs a body first, and
+-- returns the domain and origin it was counted under.
+UPDATE stories AS s
+SET url = NULL, normalized_url = NULL, canonical_url = NULL, normalized_canonical_url = NULL,
+    domain_id = NULL, origin_id = NULL, updated_at = now()
+FROM (SELECT id, domain_id, origin_id FROM stories WHERE id = @id FOR UPDATE) AS old
+WHERE s.id = old.id
+RETURNING old.domain_id, old.origin_id;
+
+-- name: UpdateStoryCanonicalURL :exec
+UPDATE stories SET canonical_url = @canonical_url, normalized_canonical_url = @normalized_canonical_url, updated_at = now() WHERE id = @id;
+
 -- name: SetStoryUpvotes :exec
-UPDATE stories SET upvotes = @upvotes WHERE id = @id;
+UPDATE stories SET upvotes = @upvotes, score_activity_at = now() WHERE id = @id;
 
 -- name: DeleteTaggingsByStory :exec
 DELETE FROM taggings WHERE story_id = @story_id;
@@ -138,7 +248,119 @@ WHERE s.duplicate_of_id = @story_id::bigint
 ORDER BY s.created_at DESC;
 
 -- name: GetTagsByNames :many
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+-- Names match a tag itself or any of its synonyms.
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
-WHERE lower(tag) = ANY(@names::text[])
+WHERE (lower(tag) = ANY(@names::text[])
+       OR id IN (SELECT tag_id FROM tag_synonyms WHERE lower(synonym) = ANY(@names::text[])))
   AND active = true;
+
+-- name: IncrementStoryViews :exec
+UPDATE stories AS s
+SET view_count = s.view_count + v.views
+FROM unnest(@story_ids::bigint[], @views::int[]) AS v(story_id, views)
+WHERE s.id = v.story_id;
+
+-- name: PinStory :exec
+UPDATE stories SET pinned_until = @pinned_until, updated_at = now() WHERE id = @id;
+
+-- name: UnpinStory :exec
+UPDATE stories SET pinned_until = NULL, updated_at = now() WHERE id = @id;
+
+-- name: PickStory :exec
+UPDATE stories SET picked_at = now(), updated_at = now() WHERE id = @id;
+
+-- name: UnpickStory :exec
+UPDATE stories SET picked_at = NULL, updated_at = now() WHERE id = @id;
+
+-- name: CountPinnedStories :one
+SELECT count(*)
+FROM stories
+WHERE pinned_until > now()
+  AND deleted_at IS NULL
+  AND id != @exclude_id;
+
+-- name: LockStory :exec
+-- Take the story's row lock so concurrent score updates apply one at a time.
+SELECT id FROM stories WHERE id = @id FOR UPDATE;
+
+-- name: ExportStories :many
+SELECT
+    s.short_code,
+    s.title,
+    s.url,
+    s.body,
+    s.upvotes,
+    s.downvotes,
+    s.created_at,
+    u.username,
+    COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.id IS NOT NULL), '{}')::text[] AS tags
+FROM stories AS s
+JOIN users AS u ON u.id = s.user_id
+LEFT JOIN taggings AS tg ON tg.story_id = s.id
+LEFT JOIN tags AS t ON t.id = tg.tag_id
+WHERE s.deleted_at IS NULL
+GROUP BY s.id, u.username
+ORDER BY s.created_at, s.id;
+
+-- name: RestoreStoryStats :exec
+-- Carries scores and the original timestamp over to an imported story.
+-- The scores are kept as a baseline that vote recounts add to, since the
+-- votes behind them weren't imported.
+UPDATE stories
+SET upvotes = @upvotes, downvotes = @downvotes,
+    imported_upvotes = @upvotes, imported_downvotes = @downvotes,
+    created_at = @created_at, updated_at = @created_at
+WHERE id = @id;
+
+-- name: AdjustStoryScore :one
+-- Moderator score adjustments live apart from upvotes/downvotes so vote
+-- recalculation leaves them alone.
+UPDATE stories SET admin_adjustment = admin_adjustment + @delta::int
+WHERE id = @id
+RETURNING admin_adjustment;
+
+-- name: ResetStoryScore :one
+-- Sets the adjustment so the story's current score equals @baseline.
+UPDATE stories SET admin_adjustment = @baseline::int - (upvotes - downvotes)
+WHERE id = @id
+RETURNING admin_adjustment;
+
+-- name: ExportUserStories :many
+SELECT
+    s.short_code,
+    s.title,
+    s.url,
+    s.body,
+    s.upvotes,
+    s.downvotes,
+    s.created_at,
+    s.deleted_at,
+    COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.id IS NOT NULL), '{}')::text[] AS tags
+FROM stories AS s
+LEFT JOIN taggings AS tg ON tg.story_id = s.id
+LEFT JOIN tags AS t ON t.id = tg.tag_id
+WHERE s.user_id = @user_id
+GROUP BY s.id
+ORDER BY s.created_at, s.id;
+
+-- name: HideStories :many
+-- Hidden stories stay reachable by link but drop out of listings.
+UPDATE stories SET hidden_at = now(), updated_at = now()
+WHERE id = ANY(@ids::bigint[])
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+RETURNING id;
+
+-- name: UnhideStories :many
+-- Puts stories hidden by a moderator back into listings.
+UPDATE stories SET hidden_at = NULL, updated_at = now()
+WHERE id = ANY(@ids::bigint[])
+  AND hidden_at IS NOT NULL
+RETURNING id;
+
+-- name: SoftDeleteStories :many
+UPDATE stories SET deleted_at = now(), updated_at = now()
+WHERE id = ANY(@ids::bigint[])
+  AND deleted_at IS NULL
+RETURNING id;
@@ -1,6 +1,6 @@
 -- name: CreateStoryFlag :exec
-INSERT INTO story_flags (user_id, story_id, reason)
-VALUES (@user_id, @story_id, @reason)
+INSERT INTO story_flags (user_id, story_id, reason, original_story_id)
+VALUES (@user_id, @story_id, @reason, sqlc.narg('original_story_id'))
 ON CONFLICT DO NOTHING;
 
 -- name: DeleteStoryFlag :exec
@@ -19,12 +19,57 @@ WHERE story_id = @story_id
 GROUP BY reason
 ORDER BY count DESC;
 
+-- name: ListFlaggedStories :many
+-- The moderation queue: live, unhidden stories with flags, most flagged first, with
+-- the originals named by "already posted" flags.
+SELECT
+    s.id,
+    s.short_code,
+    s.title,
+    u.username,
+    s.created_at,
+    count(*)::int AS flag_count,
+    array_agg(DISTINCT sf.reason ORDER BY sf.reason)::text[] AS reasons,
+    coalesce(o.short_codes, '{}')::text[] AS original_short_codes,
+    coalesce(o.titles, '{}')::text[] AS original_titles
+FROM story_flags AS sf
+JOIN stories AS s ON s.id = sf.story_id
+JOIN users AS u ON u.id = s.user_id
+LEFT JOIN LATERAL (
+    SELECT array_agg(os.short_code ORDER BY os.short_code) AS short_codes,
+           array_agg(os.title ORDER BY os.short_code) AS titles
+    FROM stories AS os
+    WHERE os.deleted_at IS NULL
+      AND os.id IN (SELECT f.original_story_id FROM story_flags AS f WHERE f.story_id = s.id)
+) AS o ON true
+WHERE s.deleted_at IS NULL
+  AND s.hidden_at IS NULL
+GROUP BY s.id, u.username, o.short_codes, o.titles
+ORDER BY flag_count DESC, s.created_at DESC
+LIMIT @story_limit;
+
+-- name: GetStoryFlagCountsByStories :many
+SELECT story_id, reason, count(*)::int AS count
+FROM story_flags
+WHERE story_id = ANY(@story_ids::bigint[])
+GROUP BY story_id, reason
+ORDER BY story_id, count DESC;
+
 -- name: RecalculateStoryDownvotes :exec
--- Count users who hid AND flagged this story AND have no comments on it
-UPDATE stories SET downvotes = (
-    SELECT count(*)
+-- Sum the flag-reason weights of users who hid AND flagged this story AND
+-- have no comments on it. Reasons missing from the weight list count as 1.
+-- Each flag is scaled by the flagger's trust percentage and capped at
+-- @max_percent before the total is turned back into whole downvotes.
+UPDATE stories SET score_activity_at = now(), downvotes = imported_downvotes + (
+    SELECT (coalesce(sum(least(coalesce(w.weight, 1) * ft.percent, @max_percent::int)), 0) / 100)::int
     FROM hidden_stories hs
     JOIN story_flags sf ON sf.user_id = hs.user_id AND sf.story_id = hs.story_id
+    JOIN users u ON u.id = sf.user_id
+    CROSS JOIN LATERAL flagger_trust_percent(
+        u, @weigh_flaggers::bool, @trusted_percent::int, @new_before::timestamptz,
+        @new_percent::int, @trusted_before::timestamptz, @trusted_karma::int
+    ) AS ft(percent)
+    LEFT JOIN unnest(@reasons::text[], @weights::int[]) AS w(reason, weight) ON w.reason = sf.reason
     WHERE hs.story_id = @story_id
       AND NOT EXISTS (
           SELECT 1 FROM comments c
@@ -32,3 +77,11 @@ UPDATE stories SET downvotes = (
       )
 )
 WHERE id = @story_id;
+
+-- name: ClearStoryFlags :many
+WITH del AS (
+    DELETE FROM story_flags
+    WHERE story_id = ANY(@story_ids::bigint[])
+    RETURNING story_id
+)
+SELECT DISTINCT story_id FROM del;
@@ -0,0 +1,20 @@
+-- name: CountStoryRevisions :one
+SELECT count(*) FROM story_revisions WHERE story_id = @story_id;
+
+-- name: CreateStoryRevision :exec
+INSERT INTO story_revisions (story_id, editor_id, title, url, body, tags, created_at)
+VALUES (@story_id, @editor_id, @title, @url, @body, @tags, @created_at);
+
+-- name: ListStoryRevisions :many
+SELECT
+    sr.id,
+    sr.title,
+    sr.url,
+    sr.body,
+    sr.tags,
+    sr.created_at,
+    u.username AS editor_username
+FROM story_revisions AS sr
+JOIN users AS u ON u.id = sr.editor_id
+WHERE sr.story_id = @story_id
+ORDER BY sr.created_at DESC, sr.id DESC;
@@ -0,0 +1,19 @@
+-- name: AutoSubscribeStory :exec
+-- Submitters and commenters are subscribed unless they already
+-- unsubscribed from the story.
+INSERT INTO story_subscriptions (user_id, story_id)
+VALUES (@user_id, @story_id)
+ON CONFLICT DO NOTHING;
+
+-- name: SetStorySubscription :exec
+INSERT INTO story_subscriptions (user_id, story_id, subscribed)
+VALUES (@user_id, @story_id, @subscribed)
+ON CONFLICT (user_id, story_id) DO UPDATE
+SET subscribed = excluded.subscribed, updated_at = now()
+WHERE story_subscriptions.subscribed != excluded.subscribed;
+
+-- name: IsSubscribedToStory :one
+SELECT EXISTS(
+    SELECT 1 FROM story_subscriptions
+    WHERE user_id = @user_id AND story_id = @story_id AND subscribed
+) AS exists;
@@ -9,6 +9,29 @@ FROM story_visits
 WHERE user_id = @user_id AND story_id = @story_id;
 
 -- name: ListReplies :many
+-- Replies to the user's comments, and new comments on stories they are
+-- subscribed to. The two are separate branches of a UNION so each can use
+-- its own index; an OR across them would scan every comment.
+WITH candidates AS (
+    -- Replies to the user's comments, found through idx_comments_user_id
+    -- and idx_comments_parent_id.
+    SELECT c.id, c.story_id, c.created_at
+    FROM comments AS parent
+    JOIN comments AS c ON c.parent_id = parent.id
+    WHERE parent.user_id = @user_id
+      AND c.user_id != @user_id
+      AND c.deleted_at IS NULL
+    UNION
+    -- New comments on subscribed stories, found through the subscription
+    -- key and idx_comments_story_id.
+    SELECT c.id, c.story_id, c.created_at
+    FROM story_subscriptions AS ss
+    JOIN comments AS c ON c.story_id = ss.story_id AND c.created_at > ss.updated_at
+    WHERE ss.user_id = @user_id
+      AND ss.subscribed
+      AND c.user_id != @user_id
+      AND c.deleted_at IS NULL
+)
 SELECT
     c.id AS comment_id,
     c.body,
@@ -17,24 +40,42 @@ SELECT
     u.username AS comment_author,
     s.title AS story_title,
     s.short_code AS story_short_code,
+    parent.body AS parent_body,
+    parent.deleted_at AS parent_deleted_at,
+    (parent.user_id IS NOT NULL AND parent.user_id = @user_id)::bool AS is_reply,
     (sv.last_seen_at IS NULL OR c.created_at > sv.last_seen_at)::bool AS is_unread
-FROM comments AS c
+FROM candidates
+JOIN comments AS c ON c.id = candidates.id
 JOIN users AS u ON u.id = c.user_id
-JOIN comments AS parent ON parent.id = c.parent_id
+LEFT JOIN comments AS parent ON parent.id = c.parent_id
 JOIN stories AS s ON s.id = c.story_id
 LEFT JOIN story_visits AS sv ON sv.user_id = @user_id AND sv.story_id = c.story_id
-WHERE parent.user_id = @user_id
-  AND c.user_id != @user_id
-  AND c.deleted_at IS NULL
 ORDER BY c.created_at DESC
 LIMIT 50;
 
 -- name: CountUnreadReplies :one
+-- Counts the unread rows of ListReplies, without its limit.
+WITH candidates AS (
+    -- Replies to the user's comments, found through idx_comments_user_id
+    -- and idx_comments_parent_id.
+    SELECT c.id, c.story_id, c.created_at
+    FROM comments AS parent
+    JOIN comments AS c ON c.parent_id = parent.id
+    WHERE parent.user_id = @user_id
+      AND c.user_id != @user_id
+      AND c.deleted_at IS NULL
+    UNION
+    -- New comments on subscribed stories, found through the subscription
+    -- key and idx_comments_story_id.
+    SELECT c.id, c.story_id, c.created_at
+    FROM story_subscriptions AS ss
+    JOIN comments AS c ON c.story_id = ss.story_id AND c.created_at > ss.updated_at
+    WHERE ss.user_id = @user_id
+      AND ss.subscribed
+      AND c.user_id != @user_id
+      AND c.deleted_at IS NULL
+)
 SELECT count(*)
-FROM comments AS c
-JOIN comments AS parent ON parent.id = c.parent_id
+FROM candidates AS c
 LEFT JOIN story_visits AS sv ON sv.user_id = @user_id AND sv.story_id = c.story_id
-WHERE parent.user_id = @user_id
-  AND c.user_id != @user_id
-  AND c.deleted_at IS NULL
-  AND (sv.last_seen_at IS NULL OR c.created_at > sv.last_seen_at);
+WHERE sv.last_seen_at IS NULL OR c.created_at > sv.last_seen_at;
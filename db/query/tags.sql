@@ -7,7 +7,7 @@ WHERE t.active = true
 ORDER BY category_name, t.tag;
 
 -- name: GetTagsByIDs :many
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
 WHERE id = ANY(@ids::bigint[])
   AND active = true;
@@ -24,11 +24,12 @@ VALUES (@name)
 RETURNING id, name, created_at, updated_at;
 
 -- name: UpsertTag :exec
+-- A description a moderator has edited is kept; the seed owns the rest.
 INSERT INTO tags (tag, description, category_id, privileged, is_media)
 VALUES (@tag, @description, @category_id, @privileged, @is_media)
 ON CONFLICT ((lower(tag)))
 DO UPDATE SET
-  description = EXCLUDED.description,
+  description = CASE WHEN tags.description_edited_by_moderator THEN tags.description ELSE EXCLUDED.description END,
   category_id = EXCLUDED.category_id,
   privileged = EXCLUDED.privileged,
   is_media = EXCLUDED.is_media,
@@ -36,8 +37,27 @@ DO UPDATE SET
   updated_at = now();
 
 -- name: GetTagByName :one
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
 WHERE lower(tag) = lower(@tag)
   AND active = true
 LIMIT 1;
+
+-- name: UpdateTagDescription :exec
+-- Marks the description as the moderators', so seeding no longer touches it.
+UPDATE tags
+SET description = @description, description_edited_by_moderator = true, updated_at = now()
+WHERE id = @id;
+
+-- name: ListTagSynonyms :many
+SELECT synonym
+FROM tag_synonyms
+WHERE tag_id = @tag_id
+ORDER BY lower(synonym);
+
+-- name: DeleteTagSynonyms :exec
+DELETE FROM tag_synonyms WHERE tag_id = @tag_id;
+
+-- name: CreateTagSynonym :exec
+INSERT INTO tag_synonyms (tag_id, synonym)
+VALUES (@tag_id, @synonym);
@@ -0,0 +1,31 @@
+-- name: ListFlagsGivenByUser :many
+-- Flags the user cast, grouped by target type and reason. users is the
+-- number of distinct users whose content was flagged.
+SELECT 'story'::text AS target_type, sf.reason, count(*)::int AS count, count(DISTINCT s.user_id)::int AS users
+FROM story_flags sf
+JOIN stories s ON s.id = sf.story_id
+WHERE sf.user_id = @user_id
+GROUP BY sf.reason
+UNION ALL
+SELECT 'comment'::text AS target_type, cf.reason, count(*)::int AS count, count(DISTINCT c.user_id)::int AS users
+FROM comment_flags cf
+JOIN comments c ON c.id = cf.comment_id
+WHERE cf.user_id = @user_id
+GROUP BY cf.reason
+ORDER BY target_type DESC, count DESC, reason;
+
+-- name: ListFlagsReceivedByUser :many
+-- Flags on the user's stories and comments, grouped by target type and
+-- reason. users is the number of distinct flaggers.
+SELECT 'story'::text AS target_type, sf.reason, count(*)::int AS count, count(DISTINCT sf.user_id)::int AS users
+FROM story_flags sf
+JOIN stories s ON s.id = sf.story_id
+WHERE s.user_id = @user_id
+GROUP BY sf.reason
+UNION ALL
+SELECT 'comment'::text AS target_type, cf.reason, count(*)::int AS count, count(DISTINCT cf.user_id)::int AS users
+FROM comment_flags cf
+JOIN comments c ON c.id = cf.comment_id
+WHERE c.user_id = @user_id
+GROUP BY cf.reason
+ORDER BY target_type DESC, count DESC, reason;
@@ -1,5 +1,5 @@
 -- name: GetUserByLogin :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE (lower(email) = lower(sqlc.arg(login)) AND email_confirmed_at IS NOT NULL)
    OR lower(username) = lower(sqlc.arg(login))
@@ -11,6 +11,20 @@ INSERT INTO users (username, email, password_digest, inviter_id, campaign)
 VALUES (@username, @email, @password_digest, @inviter_id, @campaign)
 RETURNING id, username, email;
 
+-- name: CreateInitialAdmin :one
+-- Inserts nothing, and so returns no row, once any user exists.
+INSERT INTO users (username, email, password_digest, is_moderator, email_confirmed_at)
+SELECT @username, @email, @password_digest, true, now()
+WHERE NOT EXISTS (SELECT 1 FROM users)
+RETURNING id, username, email;
+
+-- name: LockUser :exec
+-- Take the user's row lock so their quota-limited writes apply one at a time.
+SELECT id FROM users WHERE id = @id FOR UPDATE;
+
+-- name: HasUsers :one
+SELECT EXISTS(SELECT 1 FROM users) AS exists;
+
 -- name: SetPasswordResetTokenHash :exec
 UPDATE users
 SET password_reset_token_hash = @password_reset_token_hash,
@@ -19,18 +33,21 @@ SET password_reset_token_hash = @password_reset_token_hash,
 WHERE id = @id;
 
 -- name: GetUserByPasswordResetTokenHash :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE password_reset_token_hash = @password_reset_token_hash
   AND password_reset_token_created_at > now() - INTERVAL '24 hours'
 LIMIT 1;
 
 -- name: GetUserByID :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE id = @id
 LIMIT 1;
 
+-- name: GetUserIDByUsername :one
+SELECT id FROM users WHERE lower(username) = lower(@username) LIMIT 1;
+
 -- name: UpdateUserEmail :exec
 UPDATE users
 SET email = @email, updated_at = now()
@@ -54,6 +71,7 @@ SELECT
     u.about,
     u.website,
     u.is_moderator,
+    u.last_seen_at,
     u.created_at,
     (SELECT count(*) FROM stories s WHERE s.user_id = u.id AND s.deleted_at IS NULL)::bigint AS story_count,
     inviter.username AS inviter_name
@@ -64,9 +82,22 @@ WHERE lower(u.username) = lower(@username)
   AND u.deleted_at IS NULL
 LIMIT 1;
 
+-- name: GetUserTombstone :one
+-- Looks up a banned or deleted account so its profile can say so.
+SELECT username, banned_at, deleted_at
+FROM users
+WHERE lower(username) = lower(@username)
+  AND (banned_at IS NOT NULL OR deleted_at IS NOT NULL)
+LIMIT 1;
+
 -- name: UpdateUserProfile :exec
 UPDATE users
-SET website = @website, about = @about, updated_at = now()
+SET website = @website, about = @about, slogan = @slogan, enable_embeds = @enable_embeds, updated_at = now()
+WHERE id = @id;
+
+-- name: TouchUserLastSeen :exec
+UPDATE users
+SET last_seen_at = now()
 WHERE id = @id;
 
 -- name: SetEmailConfirmationToken :exec
@@ -85,7 +116,7 @@ SET email_confirmation_token_hash = @email_confirmation_token_hash,
 WHERE id = @id;
 
 -- name: GetUserByEmailConfirmationTokenHash :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE email_confirmation_token_hash = @email_confirmation_token_hash
   AND email_confirmation_token_created_at > now() - INTERVAL '24 hours'
@@ -103,3 +134,13 @@ WHERE id = @id;
 
 -- name: CheckEmailExists :one
 SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower(@email) AND id != @id) AS exists;
+
+-- name: GetUserKarmas :many
+-- Karma is the upvotes a user's live stories and comments have received.
+SELECT
+    u.id,
+    u.is_moderator,
+    ((SELECT coalesce(sum(s.upvotes), 0) FROM stories s WHERE s.user_id = u.id AND s.deleted_at IS NULL)
+      + (SELECT coalesce(sum(c.upvotes), 0) FROM comments c WHERE c.user_id = u.id AND c.deleted_at IS NULL))::int AS karma
+FROM users u
+WHERE u.id = ANY(@user_ids::bigint[]);
@@ -5,7 +5,7 @@ WITH ins AS (
     ON CONFLICT DO NOTHING
     RETURNING story_id
 )
-UPDATE stories SET upvotes = upvotes + (SELECT count(*) FROM ins)::int
+UPDATE stories SET upvotes = upvotes + (SELECT count(*) FROM ins)::int, score_activity_at = now()
 WHERE id = @story_id
 RETURNING upvotes;
 
@@ -15,7 +15,7 @@ WITH del AS (
     WHERE votes.user_id = @user_id AND votes.story_id = @story_id
     RETURNING story_id
 )
-UPDATE stories SET upvotes = upvotes - (SELECT count(*) FROM del)::int
+UPDATE stories SET upvotes = upvotes - (SELECT count(*) FROM del)::int, score_activity_at = now()
 WHERE id = @story_id
 RETURNING upvotes;
 
@@ -23,3 +23,13 @@ RETURNING upvotes;
 SELECT story_id
 FROM votes
 WHERE user_id = @user_id AND story_id = ANY(@story_ids::bigint[]);
+
+-- name: ExportUserVotes :many
+SELECT
+    s.short_code AS story_short_code,
+    s.title AS story_title,
+    v.created_at
+FROM votes AS v
+JOIN stories AS s ON s.id = v.story_id
+WHERE v.user_id = @user_id
+ORDER BY v.created_at, v.story_id;
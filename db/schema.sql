@@ -16,6 +16,9 @@ CREATE TABLE users (
     unconfirmed_email TEXT,
     website TEXT NOT NULL DEFAULT '',
     about TEXT NOT NULL DEFAULT '',
+    slogan TEXT NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMPTZ,
+    enable_embeds BOOLEAN NOT NULL DEFAULT false,
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
@@ -36,7 +39,8 @@ CREATE TABLE sessions (
     expires_at TIMESTAMPTZ NOT NULL,
     last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    impersonated_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL
 );
 
 CREATE UNIQUE INDEX sessions_token_hash_unique ON sessions (token_hash);
@@ -60,11 +64,21 @@ CREATE TABLE tags (
     active BOOLEAN NOT NULL DEFAULT true,
     hotness_mod FLOAT NOT NULL DEFAULT 0.0 CHECK (hotness_mod >= -10 AND hotness_mod <= 10),
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    description_edited_by_moderator BOOLEAN NOT NULL DEFAULT false
 );
 
 CREATE UNIQUE INDEX tags_tag_unique ON tags (lower(tag));
 
+CREATE TABLE tag_synonyms (
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    synonym TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX tag_synonyms_synonym_unique ON tag_synonyms (lower(synonym));
+CREATE INDEX tag_synonyms_tag_id_idx ON tag_synonyms (tag_id);
+
 CREATE TABLE domains (
     id BIGSERIAL PRIMARY KEY,
     domain TEXT NOT NULL,
@@ -97,28 +111,45 @@ CREATE TABLE stories (
     origin_id BIGINT REFERENCES origins(id),
     url TEXT,
     normalized_url TEXT,
+    canonical_url TEXT,
+    normalized_canonical_url TEXT,
     title TEXT NOT NULL,
     body TEXT,
-    short_code CHAR(6) NOT NULL,
+    short_code VARCHAR(16) NOT NULL,
     upvotes INT NOT NULL DEFAULT 0,
     downvotes INT NOT NULL DEFAULT 0,
+    admin_adjustment INT NOT NULL DEFAULT 0,
     comment_count INT NOT NULL DEFAULT 0,
+    view_count INT NOT NULL DEFAULT 0,
     duplicate_of_id BIGINT REFERENCES stories(id),
+    pinned_until TIMESTAMPTZ,
+    score_activity_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    scored_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     deleted_at TIMESTAMPTZ,
+    hidden_at TIMESTAMPTZ,
+    picked_at TIMESTAMPTZ,
+    imported_upvotes INT NOT NULL DEFAULT 0,
+    imported_downvotes INT NOT NULL DEFAULT 0,
     CONSTRAINT stories_short_code_unique UNIQUE (short_code),
-    CONSTRAINT stories_link_xor_text CHECK (
-        (url IS NOT NULL AND normalized_url IS NOT NULL AND domain_id IS NOT NULL AND body IS NULL)
+    CONSTRAINT stories_link_or_text CHECK (
+        (url IS NOT NULL AND normalized_url IS NOT NULL AND domain_id IS NOT NULL)
         OR
         (url IS NULL AND normalized_url IS NULL AND domain_id IS NULL AND body IS NOT NULL)
     )
 );
 
 CREATE INDEX stories_normalized_url_idx ON stories (normalized_url);
+CREATE INDEX stories_normalized_canonical_url_idx ON stories (normalized_canonical_url) WHERE normalized_canonical_url IS NOT NULL;
 CREATE INDEX stories_created_at_idx ON stories (created_at);
+CREATE INDEX stories_updated_at_idx ON stories (updated_at);
+CREATE INDEX stories_score_activity_at_idx ON stories (score_activity_at);
 CREATE INDEX stories_user_id_idx ON stories (user_id);
 CREATE INDEX stories_duplicate_of_id_idx ON stories (duplicate_of_id) WHERE duplicate_of_id IS NOT NULL;
+CREATE INDEX stories_pinned_until_idx ON stories (pinned_until) WHERE pinned_until IS NOT NULL;
+CREATE INDEX stories_score_stale_idx ON stories (id) WHERE scored_at IS NULL OR score_activity_at > scored_at;
+CREATE INDEX stories_scored_at_idx ON stories (scored_at);
 
 CREATE TABLE taggings (
     story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
@@ -141,6 +172,13 @@ CREATE TABLE hidden_tags (
     PRIMARY KEY (user_id, tag_id)
 );
 
+CREATE TABLE domain_subscriptions (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    domain_id BIGINT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, domain_id)
+);
+
 CREATE TABLE comments (
     id BIGSERIAL PRIMARY KEY,
     story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
@@ -152,12 +190,15 @@ CREATE TABLE comments (
     downvotes INT NOT NULL DEFAULT 0,
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-    deleted_at TIMESTAMPTZ
+    deleted_at TIMESTAMPTZ,
+    edited_at TIMESTAMPTZ
 );
 
 CREATE INDEX idx_comments_story_id ON comments(story_id);
 CREATE INDEX idx_comments_user_id ON comments(user_id);
 CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+CREATE INDEX idx_comments_created_at ON comments(created_at);
+CREATE INDEX idx_comments_deleted_at ON comments(deleted_at) WHERE deleted_at IS NOT NULL;
 
 CREATE TABLE comment_votes (
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -171,12 +212,14 @@ CREATE TABLE comment_flags (
     comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
     reason TEXT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    weight INTEGER NOT NULL DEFAULT 1,
     PRIMARY KEY (user_id, comment_id)
 );
 
 CREATE TABLE hidden_stories (
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT 'unspecified',
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     PRIMARY KEY (user_id, story_id)
 );
@@ -186,6 +229,7 @@ CREATE TABLE story_flags (
     story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
     reason TEXT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    original_story_id BIGINT REFERENCES stories(id) ON DELETE SET NULL,
     PRIMARY KEY (user_id, story_id)
 );
 
@@ -196,6 +240,26 @@ CREATE TABLE story_visits (
     PRIMARY KEY (user_id, story_id)
 );
 
+CREATE TABLE story_subscriptions (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    subscribed BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, story_id)
+);
+
+CREATE TABLE story_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    story_id BIGINT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    editor_id BIGINT NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL,
+    url TEXT,
+    body TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX story_revisions_story_id_idx ON story_revisions (story_id, created_at DESC);
+
 CREATE TABLE invitations (
     id         BIGSERIAL PRIMARY KEY,
     inviter_id BIGINT NOT NULL REFERENCES users(id),
@@ -295,3 +359,26 @@ CREATE TABLE daily_user_stats (
     new_stories    INTEGER NOT NULL DEFAULT 0,
     new_comments   INTEGER NOT NULL DEFAULT 0
 );
+
+CREATE FUNCTION flagger_trust_percent(
+    flagger users,
+    weigh_flaggers BOOLEAN,
+    trusted_percent INTEGER,
+    new_before TIMESTAMPTZ,
+    new_percent INTEGER,
+    trusted_before TIMESTAMPTZ,
+    trusted_karma INTEGER
+) RETURNS INTEGER LANGUAGE sql STABLE AS $$
+    SELECT CASE
+        WHEN NOT weigh_flaggers THEN 100
+        WHEN flagger.is_moderator THEN trusted_percent
+        WHEN flagger.email_confirmed_at IS NULL THEN 0
+        WHEN flagger.created_at > new_before THEN new_percent
+        WHEN flagger.created_at <= trusted_before
+            AND (SELECT coalesce(sum(s.upvotes), 0) FROM stories s WHERE s.user_id = flagger.id AND s.deleted_at IS NULL)
+              + (SELECT coalesce(sum(c.upvotes), 0) FROM comments c WHERE c.user_id = flagger.id AND c.deleted_at IS NULL)
+              >= trusted_karma
+            THEN trusted_percent
+        ELSE 100
+    END
+$$;
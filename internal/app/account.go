@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/store"
@@ -26,14 +28,25 @@ func (a *App) accountPage(w http.ResponseWriter, r *http.Request) {
 		tab = "profile"
 	}
 
+	probationEnds, err := a.probationEnds(r.Context(), current.User, time.Now())
+	if err != nil {
+		a.serverError(w, r, "check probation", err)
+		return
+	}
+
 	a.render(w, "account", AccountPageData{
 		Base:             a.baseData(r),
 		Tab:              tab,
 		Email:            current.User.Email,
 		About:            current.User.About,
 		Website:          current.User.Website,
+		Slogan:           current.User.Slogan,
+		SloganChoices:    sloganChoices(),
+		EnableEmbeds:     current.User.EnableEmbeds,
 		EmailConfirmed:   current.User.EmailConfirmedAt.Valid,
 		UnconfirmedEmail: current.User.UnconfirmedEmail.String,
+		ProbationEnds:    probationEnds,
+		ProbationKarma:   a.Probation.Karma,
 	})
 }
 
@@ -44,20 +57,14 @@ func (a *App) updateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "account", AccountPageData{
-			Base:    a.baseData(r),
-			Tab:     "profile",
-			Email:   current.User.Email,
-			About:   current.User.About,
-			Website: current.User.Website,
-			Errors:  map[string]string{"about": "Invalid request."},
-		})
+	if !parseForm(w, r) {
 		return
 	}
 
 	website := strings.TrimSpace(r.FormValue("website"))
 	about := strings.TrimSpace(r.FormValue("about"))
+	slogan := r.FormValue("slogan")
+	enableEmbeds := r.FormValue("enable_embeds") == "1"
 
 	errs := make(map[string]string)
 	if len(website) > 250 {
@@ -66,35 +73,48 @@ func (a *App) updateProfile(w http.ResponseWriter, r *http.Request) {
 	if len(about) > 500 {
 		errs["about"] = "About must be 500 characters or fewer."
 	}
+	if slogan != "" && slogan != sloganNone && !slices.Contains(slogans, slogan) {
+		errs["slogan"] = "Please pick one of the listed slogans."
+	}
 
 	if len(errs) > 0 {
 		a.render(w, "account", AccountPageData{
-			Base:    a.baseData(r),
-			Tab:     "profile",
-			Email:   current.User.Email,
-			About:   about,
-			Website: website,
-			Errors:  errs,
+			Base:          a.baseData(r),
+			Tab:           "profile",
+			Email:         current.User.Email,
+			About:         about,
+			Website:       website,
+			Slogan:        slogan,
+			SloganChoices: sloganChoices(),
+			EnableEmbeds:  enableEmbeds,
+			Errors:        errs,
 		})
 		return
 	}
 
 	if err := a.Queries.UpdateUserProfile(r.Context(), store.UpdateUserProfileParams{
-		Website: website,
-		About:   about,
-		ID:      current.User.ID,
+		Website:      website,
+		About:        about,
+		Slogan:       slogan,
+		EnableEmbeds: enableEmbeds,
+		ID:           current.User.ID,
 	}); err != nil {
 		a.serverError(w, r, "update profile", err)
 		return
 	}
 
+	base := a.baseData(r)
+	base.Slogan = pickSlogan(slogan)
 	a.render(w, "account", AccountPageData{
-		Base:    a.baseData(r),
-		Tab:     "profile",
-		Email:   current.User.Email,
-		About:   about,
-		Website: website,
-		Success: "Profile updated.",
+		Base:          base,
+		Tab:           "profile",
+		Email:         current.User.Email,
+		About:         about,
+		Website:       website,
+		Slogan:        slogan,
+		SloganChoices: sloganChoices(),
+		EnableEmbeds:  enableEmbeds,
+		Success:       "Profile updated.",
 	})
 }
 
@@ -117,17 +137,7 @@ func (a *App) updateEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "account", AccountPageData{
-			Base:             a.baseData(r),
-			Tab:              "email",
-			Email:            current.User.Email,
-			About:            current.User.About,
-			Website:          current.User.Website,
-			EmailConfirmed:   current.User.EmailConfirmedAt.Valid,
-			UnconfirmedEmail: current.User.UnconfirmedEmail.String,
-			Errors:           map[string]string{"email": "Invalid request."},
-		})
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -156,6 +166,11 @@ func (a *App) updateEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if msg := a.EmailPolicy.check(newEmail); msg != "" {
+		renderErr(map[string]string{"email": msg})
+		return
+	}
+
 	// Check if the new email is already taken.
 	taken, err := a.Queries.CheckEmailExists(r.Context(), store.CheckEmailExistsParams{
 		Email: newEmail,
@@ -235,15 +250,7 @@ func (a *App) updatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "account", AccountPageData{
-			Base:    a.baseData(r),
-			Tab:     "password",
-			Email:   current.User.Email,
-			About:   current.User.About,
-			Website: current.User.Website,
-			Errors:  map[string]string{"current_password": "Invalid request."},
-		})
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -257,8 +264,8 @@ func (a *App) updatePassword(w http.ResponseWriter, r *http.Request) {
 	}
 	if newPassword == "" {
 		errs["new_password"] = "Please enter a new password."
-	} else if len(newPassword) > 72 {
-		errs["new_password"] = "Password must be 72 bytes or fewer."
+	} else if msg := a.PasswordPolicy.check(newPassword); msg != "" {
+		errs["new_password"] = msg
 	}
 	if newPassword != confirmation {
 		errs["new_password_confirmation"] = "Passwords do not match."
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
+)
+
+const activityPerPage = 25
+
+type ActivityPageData struct {
+	Base        Base
+	Items       []ActivityItem
+	CurrentPage int
+	HasMore     bool
+	PagePath    string
+}
+
+// ActivityItem is one of the viewer's stories or comments. Link points at
+// the item itself: the story page, or the comment's thread.
+type ActivityItem struct {
+	Kind       string // "story" or "comment"
+	StoryTitle string
+	StoryPath  string
+	Link       string
+	Body       template.HTML // comments only
+	CreatedAt  time.Time
+}
+
+// activityPage lists the viewer's recent stories and comments in one
+// chronological feed (GET /activity and GET /activity/page/{page}).
+func (a *App) activityPage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	page := parsePage(r)
+	// The viewer's own activity, so it must include what they just posted.
+	rows, err := a.Queries.ListUserActivity(r.Context(), store.ListUserActivityParams{
+		UserID:     current.User.ID,
+		ItemLimit:  activityPerPage + 1,
+		ItemOffset: offsetFor(page, activityPerPage),
+	})
+	if err != nil {
+		a.serverError(w, r, "list user activity", err)
+		return
+	}
+
+	rows, hasMore := trimLookahead(rows, activityPerPage)
+
+	a.render(w, "activity", ActivityPageData{
+		Base:        a.baseData(r),
+		Items:       buildActivityItems(rows),
+		CurrentPage: page,
+		HasMore:     hasMore,
+		PagePath:    "/activity/page",
+	})
+}
+
+func buildActivityItems(rows []store.ListUserActivityRow) []ActivityItem {
+	items := make([]ActivityItem, 0, len(rows))
+	for _, row := range rows {
+		item := ActivityItem{
+			Kind:       row.Kind,
+			StoryTitle: row.StoryTitle,
+			StoryPath:  storyPath(row.StoryShortCode, row.StoryTitle),
+			CreatedAt:  row.CreatedAt.Time,
+		}
+		item.Link = item.StoryPath
+		if row.Kind == "comment" {
+			item.Link = commentPath(row.StoryShortCode, row.CommentID)
+			item.Body = markdown.Render(row.Body)
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestActivityRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.activityPage(w, httptest.NewRequest(http.MethodGet, "/activity", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestBuildActivityItems(t *testing.T) {
+	now := time.Now()
+	items := buildActivityItems([]store.ListUserActivityRow{
+		{Kind: "comment", StoryShortCode: "abc123", StoryTitle: "Story", CommentID: 7, Body: "**hi**", CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}},
+		{Kind: "story", StoryShortCode: "abc123", StoryTitle: "Story", CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}},
+	})
+	require.Len(t, items, 2)
+	assert.Equal(t, commentPath("abc123", 7), items[0].Link)
+	assert.Contains(t, string(items[0].Body), "<strong>hi</strong>")
+	assert.Equal(t, storyPath("abc123", "Story"), items[1].Link)
+	assert.Empty(t, items[1].Body)
+}
+
+func TestActivityPage(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	var users []store.User
+	for _, name := range []string{"alice", "bob"} {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		users = append(users, store.User{ID: u.ID, Username: u.Username})
+	}
+	alice, bob := users[0], users[1]
+
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    alice.ID,
+		Title:     "Alice story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "alice1",
+	})
+	require.NoError(t, err)
+	for i := range activityPerPage {
+		_, err := a.Queries.CreateComment(ctx, store.CreateCommentParams{
+			StoryID: story.ID,
+			UserID:  alice.ID,
+			Body:    fmt.Sprintf("alice comment %d", i),
+		})
+		require.NoError(t, err)
+	}
+	_, err = a.Queries.CreateComment(ctx, store.CreateCommentParams{
+		StoryID: story.ID,
+		UserID:  bob.ID,
+		Body:    "bob comment",
+	})
+	require.NoError(t, err)
+
+	get := func(page string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/activity", nil)
+		if page != "" {
+			req.SetPathValue("page", page)
+		}
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: alice}))
+		w := httptest.NewRecorder()
+		a.activityPage(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	first := get("")
+	assert.Equal(t, activityPerPage, strings.Count(first, `class="activity-item"`))
+	assert.Contains(t, first, "alice comment")
+	assert.NotContains(t, first, "bob comment")
+	assert.Contains(t, first, `href="/activity/page/2"`)
+
+	second := get("2")
+	assert.Equal(t, 1, strings.Count(second, `class="activity-item"`))
+	assert.Contains(t, second, "Submitted", "the oldest item, the story, lands on page two")
+	assert.NotContains(t, second, `href="/activity/page/3"`)
+}
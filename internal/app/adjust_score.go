@@ -0,0 +1,154 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+const (
+	// maxScoreAdjustment bounds a single adjustment or reset baseline so a
+	// typo can't bury or boost a story for good.
+	maxScoreAdjustment = 100
+	// defaultScoreBaseline is the score of a fresh story: the submitter's
+	// own upvote.
+	defaultScoreBaseline = 1
+)
+
+// parseScoreAmount parses the adjustment amount or reset baseline. An empty
+// baseline means defaultScoreBaseline; an adjustment must be non-zero.
+func parseScoreAmount(mode, v string) (int, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" && mode == "reset" {
+		return defaultScoreBaseline, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < -maxScoreAdjustment || n > maxScoreAdjustment {
+		return 0, false
+	}
+	if mode == "adjust" && n == 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func (a *App) adjustStoryScore(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	renderErr := func(msg string) {
+		a.renderStoryActionError(w, r, current, code, row, msg)
+	}
+
+	mode := r.FormValue("mode")
+	if mode != "adjust" && mode != "reset" {
+		renderErr("Choose whether to adjust or reset the score.")
+		return
+	}
+
+	amount, ok := parseScoreAmount(mode, r.FormValue("amount"))
+	if !ok {
+		if mode == "reset" {
+			renderErr(fmt.Sprintf("Baseline score must be between %d and %d.", -maxScoreAdjustment, maxScoreAdjustment))
+		} else {
+			renderErr(fmt.Sprintf("Adjustment must be a non-zero number between %d and %d.", -maxScoreAdjustment, maxScoreAdjustment))
+		}
+		return
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		renderErr("A reason is required to change a story's score.")
+		return
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.LockStory(r.Context(), row.ID); err != nil {
+		a.serverError(w, r, "lock story", err)
+		return
+	}
+
+	var adjustment int32
+	action := "story.adjust_score"
+	metadata := map[string]any{"previous_adjustment": row.AdminAdjustment}
+	if mode == "reset" {
+		action = "story.reset_score"
+		metadata["baseline"] = amount
+		adjustment, err = qtx.ResetStoryScore(r.Context(), store.ResetStoryScoreParams{Baseline: int32(amount), ID: row.ID})
+	} else {
+		metadata["delta"] = amount
+		adjustment, err = qtx.AdjustStoryScore(r.Context(), store.AdjustStoryScoreParams{Delta: int32(amount), ID: row.ID})
+	}
+	if err != nil {
+		a.serverError(w, r, "update story score", err)
+		return
+	}
+	metadata["adjustment"] = adjustment
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		a.serverError(w, r, "marshal metadata", err)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      action,
+		TargetType:  "story",
+		TargetID:    row.ID,
+		Reason:      reason,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+}
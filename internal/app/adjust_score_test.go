@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/store"
+)
+
+func TestParseScoreAmount(t *testing.T) {
+	tests := []struct {
+		mode, in string
+		want     int
+		ok       bool
+	}{
+		{"reset", "", defaultScoreBaseline, true},
+		{"reset", "0", 0, true},
+		{"reset", "-100", -100, true},
+		{"adjust", "", 0, false},
+		{"adjust", "0", 0, false},
+		{"adjust", " -5 ", -5, true},
+		{"adjust", "100", 100, true},
+		{"adjust", "101", 0, false},
+		{"adjust", "abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseScoreAmount(tt.mode, tt.in)
+		assert.Equal(t, tt.ok, ok, "%s %q", tt.mode, tt.in)
+		assert.Equal(t, tt.want, got, "%s %q", tt.mode, tt.in)
+	}
+}
+
+func TestBuildStoryListAdminAdjustmentAffectsRanking(t *testing.T) {
+	created := pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}
+	rows := []store.ListStoriesRow{
+		{ID: 1, Upvotes: 10, CreatedAt: created, Tags: []byte("[]")},
+		{ID: 2, Upvotes: 5, CreatedAt: created, Tags: []byte("[]")},
+	}
+	order := func() []int64 {
+		t.Helper()
+		items, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{rankByHotness: true})
+		require.NoError(t, err)
+		var ids []int64
+		for _, it := range items {
+			ids = append(ids, it.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int64{1, 2}, order())
+
+	rows[0].AdminAdjustment = -8
+	assert.Equal(t, []int64{2, 1}, order(), "the adjustment pushes the brigaded story down")
+
+	items, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	for _, it := range items {
+		if it.ID == 1 {
+			assert.Equal(t, 2, it.Score)
+			assert.Equal(t, 10, it.Upvotes, "votes are left as they are")
+		}
+	}
+}
+
+func TestAdjustStoryScore(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	mod := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username, IsModerator: true}}
+
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Brigaded",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.SetStoryUpvotes(ctx, store.SetStoryUpvotesParams{Upvotes: 40, ID: story.ID}))
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/adjust-score", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), mod))
+		w := httptest.NewRecorder()
+		a.adjustStoryScore(w, req)
+		return w
+	}
+	score := func() int {
+		t.Helper()
+		row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: story.ID, Valid: true}})
+		require.NoError(t, err)
+		return int(row.Upvotes - row.Downvotes + row.AdminAdjustment)
+	}
+
+	w := post(url.Values{"mode": {"adjust"}, "amount": {"-10"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "A reason is required")
+	assert.Equal(t, 40, score())
+
+	w = post(url.Values{"mode": {"adjust"}, "amount": {"-10"}, "reason": {"vote ring"}})
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, 30, score())
+
+	w = post(url.Values{"mode": {"reset"}, "reason": {"start over"}})
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, defaultScoreBaseline, score())
+
+	// Recounting votes resets upvotes from the votes table but leaves the
+	// adjustment alone.
+	reasons, weights := flagreason.DefaultStory.Weights()
+	trust := flagreason.Trust{}.Params(time.Now())
+	_, err = a.Queries.RecalculateStoryScores(ctx, store.RecalculateStoryScoresParams{
+		MaxPercent:     trust.MaxPercent,
+		TrustedPercent: trust.TrustedPercent,
+		Reasons:        reasons,
+		Weights:        weights,
+	})
+	require.NoError(t, err)
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: story.ID, Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), row.Upvotes)
+	assert.Equal(t, int32(defaultScoreBaseline-40), row.AdminAdjustment)
+
+	logs, err := a.Queries.ListModerationLog(ctx, store.ListModerationLogParams{LogLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+}
@@ -1,17 +1,13 @@
 package app
 
 import (
-	"encoding/json"
 	"errors"
-	"io"
 	"net/http"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgtype"
 
 	"crow.watch/internal/auth"
-	"crow.watch/internal/link"
 	"crow.watch/internal/store"
 )
 
@@ -22,7 +18,7 @@ func (a *App) apiKeyUserFromRequest(w http.ResponseWriter, r *http.Request) (sto
 	authHeader := r.Header.Get("Authorization")
 	token, ok := strings.CutPrefix(authHeader, "Bearer ")
 	if !ok || token == "" {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Missing or invalid Authorization header."})
+		writeJSONError(w, http.StatusUnauthorized, "Missing or invalid Authorization header.")
 		return store.User{}, 0, false
 	}
 
@@ -30,16 +26,15 @@ func (a *App) apiKeyUserFromRequest(w http.ResponseWriter, r *http.Request) (sto
 	row, err := a.Queries.GetAPIKeyUserByTokenHash(r.Context(), tokenHash)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid API key."})
+			writeJSONError(w, http.StatusUnauthorized, "Invalid API key.")
 			return store.User{}, 0, false
 		}
-		a.Log.Error("api key lookup", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
+		a.jsonServerError(w, r, "api key lookup", err)
 		return store.User{}, 0, false
 	}
 
 	if row.BannedAt.Valid || row.DeletedAt.Valid {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Account is not active."})
+		writeJSONError(w, http.StatusUnauthorized, "Account is not active.")
 		return store.User{}, 0, false
 	}
 
@@ -63,6 +58,9 @@ func (a *App) apiKeyUserFromRequest(w http.ResponseWriter, r *http.Request) (sto
 		UnconfirmedEmail:                row.UnconfirmedEmail,
 		Website:                         row.Website,
 		About:                           row.About,
+		Slogan:                          row.Slogan,
+		LastSeenAt:                      row.LastSeenAt,
+		EnableEmbeds:                    row.EnableEmbeds,
 		CreatedAt:                       row.CreatedAt,
 		UpdatedAt:                       row.UpdatedAt,
 	}
@@ -72,8 +70,7 @@ func (a *App) apiKeyUserFromRequest(w http.ResponseWriter, r *http.Request) (sto
 func (a *App) apiListTags(w http.ResponseWriter, r *http.Request) {
 	tags, err := a.Queries.ListActiveTagsWithCategory(r.Context())
 	if err != nil {
-		a.Log.Error("api list tags", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
+		a.jsonServerError(w, r, "api list tags", err)
 		return
 	}
 
@@ -94,6 +91,9 @@ func (a *App) apiListTags(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, result)
 }
 
+// apiSubmitStory creates a story for an API key holder (POST /api/story).
+// Tags are given by name or synonym. Validation and creation are shared
+// with the submit form through submitNewStory.
 func (a *App) apiSubmitStory(w http.ResponseWriter, r *http.Request) {
 	user, _, ok := a.apiKeyUserFromRequest(w, r)
 	if !ok {
@@ -107,226 +107,47 @@ func (a *App) apiSubmitStory(w http.ResponseWriter, r *http.Request) {
 		Tags    []string `json:"tags"`
 		Hotness int32    `json:"hotness"`
 	}
-	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body."})
+	if !decodeJSON(w, r, 1<<20, &req) {
 		return
 	}
 
-	req.URL = strings.TrimSpace(req.URL)
-	req.Title = strings.TrimSpace(req.Title)
-	req.Body = strings.TrimSpace(req.Body)
-
-	type fieldErrors map[string]string
-	errs := make(fieldErrors)
-
-	// Validate title
-	if req.Title == "" {
-		errs["title"] = "Title is required."
-	} else if len(req.Title) > 150 {
-		errs["title"] = "Title must be 150 characters or fewer."
-	}
-
-	// Validate content: URL xor body
-	hasURL := req.URL != ""
-	hasBody := req.Body != ""
-	if hasURL && hasBody {
-		errs["url"] = "A story must have either a URL or a text body, not both."
-	} else if !hasURL && !hasBody {
-		errs["url"] = "URL or text body is required."
-	}
-
-	if hasBody && len(req.Body) > 10000 {
-		errs["body"] = "Text body must be 10,000 characters or fewer."
-	}
-
 	if len(req.Tags) == 0 {
-		errs["tags"] = "At least one tag is required."
-	}
-
-	// Clean URL early so validation errors can be reported together
-	var cleanResult link.CleanResult
-	if hasURL && !hasBody {
-		var err error
-		cleanResult, err = link.Clean(req.URL)
-		if err != nil {
-			var ve *link.ValidationError
-			if errors.As(err, &ve) {
-				errs["url"] = ve.Message
-			} else {
-				errs["url"] = "Invalid URL."
-			}
-		}
-	}
-
-	if len(errs) > 0 {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": errs})
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": map[string]string{"tags": "At least one tag is required."}})
 		return
 	}
-
-	// Resolve tag names to tag rows
-	lowerNames := make([]string, len(req.Tags))
+	names := make([]string, len(req.Tags))
 	for i, t := range req.Tags {
-		lowerNames[i] = strings.ToLower(strings.TrimSpace(t))
+		names[i] = strings.ToLower(strings.TrimSpace(t))
 	}
-	tags, err := a.Queries.GetTagsByNames(r.Context(), lowerNames)
+	tags, err := a.Queries.GetTagsByNames(r.Context(), names)
 	if err != nil {
-		a.Log.Error("api get tags by names", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
+		a.jsonServerError(w, r, "api get tags by names", err)
 		return
 	}
 	if len(tags) == 0 {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": fieldErrors{"tags": "No valid tags found."}})
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": map[string]string{"tags": "No valid tags found."}})
 		return
 	}
 
-	hasNonMedia := false
-	for _, tag := range tags {
-		if !tag.IsMedia {
-			hasNonMedia = true
-		}
-		if tag.Privileged && !user.IsModerator {
-			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
-				"errors": fieldErrors{"tags": "You do not have permission to use the tag \"" + tag.Tag + "\"."},
-			})
-			return
-		}
+	sub := storySubmission{
+		URL:     strings.TrimSpace(req.URL),
+		Title:   cleanText(req.Title),
+		Body:    strings.TrimSpace(req.Body),
+		Upvotes: req.Hotness,
 	}
-	if !hasNonMedia {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": fieldErrors{"tags": "At least one non-media tag is required."}})
-		return
-	}
-
-	isText := hasBody
-
-	var domain store.Domain
-	var originID pgtype.Int8
-
-	if !isText {
-		domain, err = a.Queries.GetOrCreateDomain(r.Context(), cleanResult.Domain)
-		if err != nil {
-			a.Log.Error("api get or create domain", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-			return
-		}
-		if domain.Banned {
-			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "This domain has been banned: " + domain.BanReason})
-			return
-		}
-
-		if cleanResult.Origin != "" {
-			origin, err := a.Queries.GetOrCreateOrigin(r.Context(), store.GetOrCreateOriginParams{DomainID: domain.ID, Origin: cleanResult.Origin})
-			if err != nil {
-				a.Log.Error("api get or create origin", "error", err)
-				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-				return
-			}
-			if origin.Banned {
-				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "This origin has been banned: " + origin.BanReason})
-				return
-			}
-			originID = pgtype.Int8{Int64: origin.ID, Valid: true}
-		}
-
-		existing, err := a.Queries.FindRecentByNormalizedURL(r.Context(), pgtype.Text{String: cleanResult.Normalized, Valid: true})
-		if err == nil {
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":         "This link has already been submitted recently.",
-				"duplicate_url": storyPath(existing.ShortCode, existing.Title),
-			})
-			return
-		}
-		if !errors.Is(err, pgx.ErrNoRows) {
-			a.Log.Error("api check duplicate url", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-			return
-		}
-	}
-
-	tx, err := a.Pool.Begin(r.Context())
-	if err != nil {
-		a.Log.Error("api begin transaction", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-		return
-	}
-	defer tx.Rollback(r.Context())
-
-	qtx := a.Queries.WithTx(tx)
-
-	shortCode := generateShortCode()
-	params := store.CreateStoryParams{
-		UserID:    user.ID,
-		Title:     req.Title,
-		ShortCode: shortCode,
-	}
-	if isText {
-		params.Body = pgtype.Text{String: req.Body, Valid: true}
-	} else {
-		params.DomainID = pgtype.Int8{Int64: domain.ID, Valid: true}
-		params.OriginID = originID
-		params.Url = pgtype.Text{String: cleanResult.Cleaned, Valid: true}
-		params.NormalizedUrl = pgtype.Text{String: cleanResult.Normalized, Valid: true}
-	}
-
-	story, err := qtx.CreateStory(r.Context(), params)
-	if err != nil {
-		a.Log.Error("api create story", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-		return
-	}
-
 	for _, tag := range tags {
-		if err := qtx.CreateTagging(r.Context(), store.CreateTaggingParams{
-			StoryID: story.ID,
-			TagID:   tag.ID,
-		}); err != nil {
-			a.Log.Error("api create tagging", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-			return
-		}
+		sub.TagIDs = append(sub.TagIDs, tag.ID)
 	}
-
-	if _, err := qtx.CreateVote(r.Context(), store.CreateVoteParams{
-		UserID:  user.ID,
-		StoryID: story.ID,
-	}); err != nil {
-		a.Log.Error("api auto-upvote story", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
+	story, rejected, err := a.submitNewStory(r.Context(), user, sub)
+	if err != nil {
+		a.jsonServerError(w, r, "api submit story", err)
 		return
 	}
-
-	if req.Hotness > 1 {
-		if err := qtx.SetStoryUpvotes(r.Context(), store.SetStoryUpvotesParams{
-			ID:      story.ID,
-			Upvotes: req.Hotness,
-		}); err != nil {
-			a.Log.Error("api set story upvotes", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-			return
-		}
-	}
-
-	if !isText {
-		if err := qtx.IncrementDomainStoryCount(r.Context(), domain.ID); err != nil {
-			a.Log.Error("api increment domain story count", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-			return
-		}
-		if originID.Valid {
-			if err := qtx.IncrementOriginStoryCount(r.Context(), originID.Int64); err != nil {
-				a.Log.Error("api increment origin story count", "error", err)
-				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
-				return
-			}
-		}
-	}
-
-	if err := tx.Commit(r.Context()); err != nil {
-		a.Log.Error("api commit transaction", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error."})
+	if rejected != nil {
+		writeSubmitRejection(w, rejected)
 		return
 	}
 
 	a.recordIP(r, user.ID, "story")
-
-	writeJSON(w, http.StatusOK, map[string]string{"url": storyPath(shortCode, req.Title)})
+	writeJSON(w, http.StatusOK, map[string]string{"url": storyPath(story.ShortCode, sub.Title)})
 }
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+const (
+	maxAPITokens          = 10
+	maxAPITokenNameLength = 100
+)
+
+type APITokensPageData struct {
+	Base     Base
+	Tokens   []APIToken
+	NewToken string // raw token, shown once right after it is created
+	Name     string
+	Error    string
+}
+
+type APIToken struct {
+	ID         int64
+	Name       string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// apiTokensPage lists the viewer's API tokens (GET /account/tokens).
+func (a *App) apiTokensPage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	a.renderAPITokens(w, r, current.User.ID, APITokensPageData{})
+}
+
+// createAPIToken issues a new token for the viewer (POST /account/tokens).
+// Only its hash is stored, so the raw token is shown on this response and
+// never again. Moderators impersonating a user can't create or revoke
+// tokens, since a token would outlive the impersonation.
+func (a *App) createAPIToken(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if current.Impersonator != nil {
+		http.Error(w, "API tokens can't be managed while impersonating", http.StatusForbidden)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	name := cleanText(r.FormValue("name"))
+	if len(name) > maxAPITokenNameLength {
+		a.renderAPITokens(w, r, current.User.ID, APITokensPageData{
+			Name:  name,
+			Error: "Name must be " + strconv.Itoa(maxAPITokenNameLength) + " characters or fewer.",
+		})
+		return
+	}
+
+	existing, err := a.Queries.ListAPIKeysByUserID(r.Context(), current.User.ID)
+	if err != nil {
+		a.serverError(w, r, "list api keys", err)
+		return
+	}
+	if len(existing) >= maxAPITokens {
+		a.renderAPITokens(w, r, current.User.ID, APITokensPageData{
+			Name:  name,
+			Error: "You have " + strconv.Itoa(maxAPITokens) + " tokens already. Revoke one to create another.",
+		})
+		return
+	}
+
+	raw, err := generateAPIToken()
+	if err != nil {
+		a.serverError(w, r, "generate api token", err)
+		return
+	}
+	if _, err := a.Queries.CreateAPIKey(r.Context(), store.CreateAPIKeyParams{
+		UserID:    current.User.ID,
+		TokenHash: auth.HashToken(raw),
+		Name:      name,
+	}); err != nil {
+		a.serverError(w, r, "create api key", err)
+		return
+	}
+
+	a.renderAPITokens(w, r, current.User.ID, APITokensPageData{NewToken: raw})
+}
+
+// revokeAPIToken deletes one of the viewer's tokens
+// (POST /account/tokens/{id}/delete). Other users' tokens are left alone.
+func (a *App) revokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if current.Impersonator != nil {
+		http.Error(w, "API tokens can't be managed while impersonating", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		a.notFound(w, r)
+		return
+	}
+	if err := a.Queries.DeleteAPIKey(r.Context(), store.DeleteAPIKeyParams{
+		ID:     id,
+		UserID: current.User.ID,
+	}); err != nil {
+		a.serverError(w, r, "delete api key", err)
+		return
+	}
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (a *App) renderAPITokens(w http.ResponseWriter, r *http.Request, userID int64, data APITokensPageData) {
+	rows, err := a.Queries.ListAPIKeysByUserID(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "list api keys", err)
+		return
+	}
+	data.Base = a.baseData(r)
+	data.Tokens = apiTokens(rows)
+	a.render(w, "api_tokens", data)
+}
+
+func apiTokens(rows []store.ListAPIKeysByUserIDRow) []APIToken {
+	tokens := make([]APIToken, 0, len(rows))
+	for _, row := range rows {
+		t := APIToken{
+			ID:        row.ID,
+			Name:      row.Name,
+			CreatedAt: row.CreatedAt.Time,
+		}
+		if row.LastUsedAt.Valid {
+			t.LastUsedAt = &row.LastUsedAt.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// tokenAuth lets a JSON endpoint be called with an API token in an
+// "Authorization: Bearer <token>" header instead of a session cookie. The
+// token's owner becomes the request's user; a bad token is a JSON 401.
+// Requests without the header keep whatever session they came with.
+func (a *App) tokenAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			next(w, r)
+			return
+		}
+		user, _, ok := a.apiKeyUserFromRequest(w, r)
+		if !ok {
+			return
+		}
+		next(w, r.WithContext(auth.ContextWithUser(r.Context(), auth.AuthenticatedUser{User: user})))
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestTokenAuthWithoutHeaderKeepsSession(t *testing.T) {
+	a := testApp(t)
+	var got auth.AuthenticatedUser
+	h := a.tokenAuth(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = auth.UserFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/stories/1/upvote", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{SessionID: 7, User: store.User{ID: 1}}))
+	h(httptest.NewRecorder(), req)
+	assert.Equal(t, int64(7), got.SessionID)
+}
+
+func TestTokenAuthEmptyBearer(t *testing.T) {
+	a := testApp(t)
+	called := false
+	h := a.tokenAuth(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/stories/1/upvote", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	h(w, req)
+	assert.False(t, called)
+	assertJSONError(t, w, http.StatusUnauthorized)
+}
+
+func TestRenderAPITokens(t *testing.T) {
+	a := testApp(t)
+	used := time.Now().Add(-time.Hour)
+	w := httptest.NewRecorder()
+	a.render(w, "api_tokens", APITokensPageData{
+		Base:     Base{IsLoggedIn: true, Username: "alice"},
+		NewToken: "raw-token-value",
+		Tokens: []APIToken{
+			{ID: 3, Name: "deploy script", LastUsedAt: &used, CreatedAt: time.Now()},
+			{ID: 4, CreatedAt: time.Now()},
+		},
+	})
+	body := w.Body.String()
+	assert.Contains(t, body, "raw-token-value")
+	assert.Contains(t, body, "deploy script")
+	assert.Contains(t, body, `action="/account/tokens/3/delete"`)
+	assert.Contains(t, body, "(unnamed)")
+	assert.Contains(t, body, "never")
+}
+
+func TestAPITokenAuthenticatesAndRevokes(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	alice, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	bob, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "bob", Email: "bob@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    bob.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	raw, err := generateAPIToken()
+	require.NoError(t, err)
+	key, err := a.Queries.CreateAPIKey(ctx, store.CreateAPIKeyParams{
+		UserID: alice.ID, TokenHash: auth.HashToken(raw), Name: "script",
+	})
+	require.NoError(t, err)
+
+	upvote := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+		req.SetPathValue("id", strconv.FormatInt(story.ID, 10))
+		w := httptest.NewRecorder()
+		a.tokenAuth(a.upvote)(w, req)
+		return w
+	}
+	revoke := func(userID int64) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.SetPathValue("id", strconv.FormatInt(key.ID, 10))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: userID}}))
+		w := httptest.NewRecorder()
+		a.revokeAPIToken(w, req)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+	}
+
+	w := upvote()
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	votes, err := a.Queries.GetUserVotes(ctx, store.GetUserVotesParams{UserID: alice.ID, StoryIds: []int64{story.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{story.ID}, votes, "the vote is cast as the token's owner")
+
+	revoke(bob.ID)
+	assert.Equal(t, http.StatusOK, upvote().Code, "another user cannot revoke the token")
+
+	revoke(alice.ID)
+	assertJSONError(t, upvote(), http.StatusUnauthorized)
+}
+
+func TestAPITokensRefusedWhileImpersonating(t *testing.T) {
+	a := testApp(t)
+	moderator := store.User{ID: 1, Username: "mod", IsModerator: true}
+	user := auth.AuthenticatedUser{User: store.User{ID: 2, Username: "alice"}, Impersonator: &moderator}
+
+	req := httptest.NewRequest(http.MethodPost, "/account/tokens", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w := httptest.NewRecorder()
+	a.createAPIToken(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/account/tokens/3/delete", nil)
+	req.SetPathValue("id", "3")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w = httptest.NewRecorder()
+	a.revokeAPIToken(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
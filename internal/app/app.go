@@ -2,8 +2,10 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -11,7 +13,10 @@ import (
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"net/netip"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,13 +27,18 @@ import (
 	"crow.watch/internal/auth"
 	"crow.watch/internal/captcha"
 	"crow.watch/internal/email"
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/link"
 	"crow.watch/internal/ratelimit"
 	"crow.watch/internal/store"
+	"crow.watch/internal/viewcount"
+	"crow.watch/internal/webhook"
 )
 
 type App struct {
 	Pool             *pgxpool.Pool
 	Queries          *store.Queries
+	Reader           *store.Queries
 	Sessions         *auth.SessionManager
 	Templates        map[string]*template.Template
 	EmailTemplates   map[string]*template.Template
@@ -42,8 +52,36 @@ type App struct {
 	LoginIPLimiter   *ratelimit.Limiter
 	LoginAcctLimiter *ratelimit.Limiter
 	InviteLimiter    *ratelimit.Limiter
+	EmailIPLimiter   *ratelimit.Limiter
+	ExportLimiter    *ratelimit.Limiter
+	HSTS             *HSTSConfig
+	CSP              *CSPConfig
+	InviteQuota      InviteQuota
 	Captcha          *captcha.Store
+	CaptchaAfter     int // recorded attempts before login and password reset ask for a CAPTCHA; 0 never
 	Analytics        *analytics.Collector
+	Views            *viewcount.Counter
+	MaxBodyBytes     int64
+	MaintenanceMode  bool
+	PasswordPolicy   PasswordPolicy
+	EmailPolicy      EmailPolicy
+	StoryFlags       flagreason.List
+	CommentFlags     flagreason.List
+	FlagMinAge       time.Duration
+	FlagTrust        flagreason.Trust
+	ModWebhook       *webhook.Notifier
+	ShortCodeLength  int
+	MinStoryScore    int
+	DuplicateWindow  time.Duration // 0 blocks resubmitting a link forever
+	SubmitCooldown   time.Duration // minimum gap between a user's stories; 0 disables
+	LinkPolicy       link.Config   // scheme and port rules for submitted links
+	Probation        Probation
+	RequestTimeout   time.Duration // 0 leaves requests without a deadline
+	CommentCollapse  CommentCollapse
+	SubmitterFirst   bool           // list the story submitter's top-level comments first
+	TrustedProxies   []netip.Prefix // proxies whose X-Forwarded-For is believed
+
+	siteStats siteStatsCache
 }
 
 type Base struct {
@@ -54,6 +92,8 @@ type Base struct {
 	Slogan         string
 	DevMode        bool
 	UnreadReplies  int64
+	ImpersonatedBy string // moderator acting as this user, if any
+	Flash          string // one-time message set before a redirect
 }
 
 type HomePageData struct {
@@ -62,6 +102,24 @@ type HomePageData struct {
 	CurrentPage int
 	HasMore     bool
 	PagePath    string // "/page" or "/newest/page" for building pagination links
+	Window      string // time window of the /top listing, kept across pages
+	Since       string // ?since= bound of the /newest listing, kept across pages
+	// ShowLowScore is set while a logged-in viewer reveals stories below
+	// the score threshold; Links.ScoreToggle switches it on or off.
+	ShowLowScore bool
+	Links        ListingLinks
+	// Explain lists the filters that shaped the listing, shown on ?why=1.
+	Explain []string
+}
+
+// ListingLinks are the URLs a paginated listing links to, built by
+// listingLinks so that each keeps the listing's query.
+type ListingLinks struct {
+	Canonical   string
+	Prev        string // empty on the first page
+	Next        string // empty on the last page
+	ScoreToggle string // reveals or hides low-scoring stories; empty for anonymous viewers
+	Why         string // the page with ?why=1
 }
 
 type StoryItem struct {
@@ -69,12 +127,18 @@ type StoryItem struct {
 	ShortCode            string
 	URL                  string
 	Title                string
-	Domain               string
+	CanonicalURL         string // original source set by a moderator
+	CanonicalDomain      string
+	Domain               string // origin when known, for display
+	DomainName           string // registered domain, linked to /d/{domain}
 	Username             string
+	AuthorState          string // "banned" or "deleted" when the submitter is gone
 	Tags                 []StoryTag
 	Upvotes              int
 	Downvotes            int
+	Score                int // Upvotes - Downvotes, used for ranking and filtering
 	CommentCount         int
+	ViewCount            int
 	HasUpvoted           bool
 	HasFlagged           bool
 	HasHidden            bool
@@ -82,11 +146,17 @@ type StoryItem struct {
 	FlagCounts           []FlagCount
 	IsText               bool
 	IsLoggedIn           bool
+	CanFlag              bool // logged in and not on probation
 	IsModerator          bool
+	IsOwn                bool // the viewer submitted this story
+	CanEdit              bool
 	CreatedAt            time.Time
 	DeletedAt            *time.Time
 	DuplicateOfShortCode string
 	DuplicateOfTitle     string
+	IsPinned             bool
+	IsPick               bool   // a moderator marked this as an editor's pick
+	Why                  string // why the story is listed where it is, for logged-in viewers
 }
 
 type StoryTag struct {
@@ -97,11 +167,28 @@ type StoryTag struct {
 type TagPageData struct {
 	Base           Base
 	TagName        string
-	TagDescription string
+	TagDescription template.HTML // rendered markdown
+	RawDescription string        // markdown source, for the moderator edit form
+	Synonyms       []string      // other names that map to this tag on submit
 	Stories        []StoryItem
 	CurrentPage    int
 	HasMore        bool
 	PagePath       string // "/t/{tag}/page"
+	ShowLowScore   bool
+	Links          ListingLinks
+}
+
+type DomainPageData struct {
+	Base         Base
+	DomainID     int64
+	Domain       string
+	IsSubscribed bool
+	Stories      []StoryItem
+	CurrentPage  int
+	HasMore      bool
+	PagePath     string // "/d/{domain}/page"
+	ShowLowScore bool
+	Links        ListingLinks
 }
 
 type LoginPageData struct {
@@ -109,12 +196,14 @@ type LoginPageData struct {
 	Tab        string
 	Identifier string
 	Error      string
+	CaptchaID  string // set once repeated attempts require a CAPTCHA
 }
 
 type SubmitPageData struct {
 	Base                 Base
 	Tab                  string
 	URL                  string
+	CanonicalURL         string // moderator override of the original source
 	Title                string
 	Body                 string
 	TagGroups            []TagGroup
@@ -124,9 +213,16 @@ type SubmitPageData struct {
 	DuplicateURL         string
 	EditMode             bool
 	EditCode             string
+	AuthorEdit           bool
+	ConvertTo            string // "link" or "text" while an edit changes the story's kind
 	Reason               string
 	DuplicateOfShortCode string
 	DuplicateOfTitle     string
+	PinnedUntil          *time.Time
+	IsPick               bool
+	IsHidden             bool // a moderator hid this from listings
+	Score                int
+	ScoreAdjustment      int
 }
 
 type TagGroup struct {
@@ -145,17 +241,32 @@ type DuplicateStory struct {
 }
 
 type StoryPageData struct {
-	Base       Base
-	Story      StoryItem
-	Body       template.HTML
-	Comments   []*CommentNode
-	Duplicates []DuplicateStory
+	Base        Base
+	Story       StoryItem
+	Body        template.HTML
+	Comments    []*CommentNode
+	CommentSort string
+	Duplicates  []DuplicateStory
+	FocusID     int64   // set when showing a single comment thread
+	UnreadIDs   []int64 // unread comments in page order
+	EmbedURL    string  // media player for the story link, if enabled
+	CanHistory  bool    // viewer may see the edit history
+	Draft       CommentDraft
+	Subscribed  bool // viewer is notified of new comments
+}
+
+// CommentDraft is a rejected comment handed back to the comment form so
+// the author can fix it instead of retyping it.
+type CommentDraft struct {
+	Body     string
+	ParentID int64
+	Error    string
 }
 
 type TagOption struct {
 	ID          int64
 	Tag         string
-	Description string
+	Description template.HTML // rendered markdown
 	IsMedia     bool
 	Privileged  bool
 }
@@ -167,10 +278,11 @@ type TagsPageData struct {
 }
 
 type ForgotPasswordPageData struct {
-	Base    Base
-	Email   string
-	Error   string
-	Success string
+	Base      Base
+	Email     string
+	Error     string
+	Success   string
+	CaptchaID string // set once repeated requests require a CAPTCHA
 }
 
 type ResetPasswordPageData struct {
@@ -185,8 +297,13 @@ type AccountPageData struct {
 	Email            string
 	About            string
 	Website          string
+	Slogan           string
+	SloganChoices    []string
+	EnableEmbeds     bool
 	EmailConfirmed   bool
 	UnconfirmedEmail string
+	ProbationEnds    time.Time // zero when the account is not on probation
+	ProbationKarma   int       // karma that lifts probation early, 0 if none
 	Errors           map[string]string
 	Success          string
 }
@@ -200,12 +317,15 @@ type ConfirmEmailPageData struct {
 type ProfilePageData struct {
 	Base            Base
 	ProfileUsername string
+	Tombstone       string // "banned" or "deleted"; the rest is empty then
 	About           string
 	Website         string
 	IsModerator     bool
 	StoryCount      int64
 	InvitedBy       string
 	CreatedAt       time.Time
+	LastActive      string
+	CanImpersonate  bool
 }
 
 type UserStoriesPageData struct {
@@ -217,6 +337,15 @@ type UserStoriesPageData struct {
 	PagePath        string
 }
 
+type UserCommentsPageData struct {
+	Base            Base
+	ProfileUsername string
+	Comments        []UserCommentItem
+	CurrentPage     int
+	HasMore         bool
+	PagePath        string
+}
+
 type InvitePageData struct {
 	Base        Base
 	Tab         string
@@ -282,6 +411,12 @@ type CampaignRow struct {
 	Active          bool
 	RegisteredCount int64
 	CreatedAt       time.Time
+	Signups         []CampaignSignup // most recent first, at most campaignSignupLimit
+}
+
+type CampaignSignup struct {
+	Username  string
+	CreatedAt time.Time
 }
 
 func (a *App) Routes() http.Handler {
@@ -297,14 +432,21 @@ func (a *App) Routes() http.Handler {
 	mux.HandleFunc("GET /", a.home)
 	mux.HandleFunc("GET /page/{page}", a.page)
 	mux.HandleFunc("GET /newest", a.newest)
+	mux.Handle("GET /newest.json", jsonRoute(a.newestJSON))
 	mux.HandleFunc("GET /newest/page/{page}", a.newest)
+	mux.HandleFunc("GET /top", a.top)
+	mux.HandleFunc("GET /top/page/{page}", a.top)
 	mux.HandleFunc("GET /login", a.loginPage)
 	mux.HandleFunc("POST /login", a.login)
 	mux.HandleFunc("POST /logout", a.logout)
 	mux.HandleFunc("GET /submit", a.submitPage)
 	mux.HandleFunc("POST /submit", a.submitStory)
-	mux.HandleFunc("POST /submit/fetch-title", a.fetchTitle)
+	mux.Handle("POST /submit.json", jsonRoute(a.tokenAuth(a.submitStoryJSON)))
+	mux.Handle("POST /submit/fetch-title", jsonRoute(a.tokenAuth(a.fetchTitle)))
+	mux.HandleFunc("GET /x/{file}", a.tokenAuth(a.storyFile))
 	mux.HandleFunc("GET /x/{code}/{slug...}", a.showStory)
+	mux.HandleFunc("GET /x/{code}/comments/{id}", a.showCommentThread)
+	mux.HandleFunc("GET /s/{code}", a.shortStoryLink)
 	mux.HandleFunc("GET /forgot-password", a.forgotPasswordPage)
 	mux.HandleFunc("POST /forgot-password", a.forgotPassword)
 	mux.HandleFunc("GET /reset-password", a.resetPasswordPage)
@@ -312,32 +454,51 @@ func (a *App) Routes() http.Handler {
 	mux.HandleFunc("GET /about", a.aboutPage)
 	mux.HandleFunc("GET /confirm-email", a.confirmEmail)
 	mux.HandleFunc("GET /account", a.accountPage)
+	mux.HandleFunc("GET /account/export", a.accountExportDownload)
 	mux.HandleFunc("POST /account/email", a.updateEmail)
 	mux.HandleFunc("POST /account/password", a.updatePassword)
 	mux.HandleFunc("POST /account/resend-confirmation", a.resendConfirmation)
+	mux.HandleFunc("GET /account/tokens", a.apiTokensPage)
+	mux.HandleFunc("POST /account/tokens", a.createAPIToken)
+	mux.HandleFunc("POST /account/tokens/{id}/delete", a.revokeAPIToken)
 	mux.HandleFunc("GET /u/{username}", a.profilePage)
 	mux.HandleFunc("GET /u/{username}/stories", a.userStoriesPage)
 	mux.HandleFunc("GET /u/{username}/stories/page/{page}", a.userStoriesPage)
+	mux.HandleFunc("GET /u/{username}/comments", a.userCommentsPage)
+	mux.HandleFunc("GET /u/{username}/comments/page/{page}", a.userCommentsPage)
+	mux.HandleFunc("GET /u/{username}/flags", a.userFlagsPage)
 	mux.HandleFunc("POST /account/profile", a.updateProfile)
 	mux.HandleFunc("GET /tags", a.tagsPage)
+	mux.HandleFunc("POST /t/{tag}/description", a.updateTagDescription)
+	mux.HandleFunc("POST /t/{tag}/synonyms", a.updateTagSynonyms)
 	mux.HandleFunc("GET /t/{tag}", a.tagPage)
 	mux.HandleFunc("GET /t/{tag}/page/{page}", a.tagPage)
-	mux.HandleFunc("POST /stories/{id}/upvote", a.upvote)
-	mux.HandleFunc("POST /stories/{id}/unvote", a.unvote)
-	mux.HandleFunc("POST /stories/{id}/flag", a.flagStory)
-	mux.HandleFunc("POST /stories/{id}/unflag", a.unflagStory)
-	mux.HandleFunc("POST /stories/{id}/hide", a.hideStory)
-	mux.HandleFunc("POST /stories/{id}/unhide", a.unhideStory)
-	mux.HandleFunc("POST /tags/{id}/hide", a.hideTag)
-	mux.HandleFunc("POST /tags/{id}/unhide", a.unhideTag)
+	mux.HandleFunc("GET /d/{domain}", a.domainPage)
+	mux.HandleFunc("GET /d/{domain}/page/{page}", a.domainPage)
+	mux.HandleFunc("GET /feed", a.feed)
+	mux.HandleFunc("GET /feed/page/{page}", a.feed)
+	mux.Handle("POST /stories/{id}/upvote", jsonRoute(a.tokenAuth(a.upvote)))
+	mux.Handle("POST /stories/{id}/unvote", jsonRoute(a.tokenAuth(a.unvote)))
+	mux.Handle("POST /stories/{id}/flag", jsonRoute(a.tokenAuth(a.flagStory)))
+	mux.Handle("POST /stories/{id}/unflag", jsonRoute(a.tokenAuth(a.unflagStory)))
+	mux.Handle("POST /stories/{id}/hide", jsonRoute(a.tokenAuth(a.hideStory)))
+	mux.Handle("POST /stories/{id}/unhide", jsonRoute(a.tokenAuth(a.unhideStory)))
+	mux.Handle("POST /tags/{id}/hide", jsonRoute(a.tokenAuth(a.hideTag)))
+	mux.Handle("POST /tags/{id}/unhide", jsonRoute(a.tokenAuth(a.unhideTag)))
+	mux.Handle("POST /domains/{id}/subscribe", jsonRoute(a.tokenAuth(a.subscribeDomain)))
+	mux.Handle("POST /domains/{id}/unsubscribe", jsonRoute(a.tokenAuth(a.unsubscribeDomain)))
 	mux.HandleFunc("POST /x/{code}/comments", a.createComment)
+	mux.HandleFunc("POST /x/{code}/subscribe", a.subscribeStory)
+	mux.HandleFunc("POST /x/{code}/unsubscribe", a.unsubscribeStory)
 	mux.HandleFunc("POST /comments/{id}/edit", a.editComment)
 	mux.HandleFunc("POST /comments/{id}/delete", a.deleteComment)
-	mux.HandleFunc("POST /comments/{id}/upvote", a.upvoteComment)
-	mux.HandleFunc("POST /comments/{id}/unvote", a.unvoteComment)
-	mux.HandleFunc("POST /comments/{id}/flag", a.flagComment)
-	mux.HandleFunc("POST /comments/{id}/unflag", a.unflagComment)
+	mux.Handle("POST /comments/{id}/upvote", jsonRoute(a.tokenAuth(a.upvoteComment)))
+	mux.Handle("POST /comments/{id}/unvote", jsonRoute(a.tokenAuth(a.unvoteComment)))
+	mux.Handle("POST /comments/{id}/flag", jsonRoute(a.tokenAuth(a.flagComment)))
+	mux.Handle("POST /comments/{id}/unflag", jsonRoute(a.tokenAuth(a.unflagComment)))
 	mux.HandleFunc("GET /replies", a.repliesPage)
+	mux.HandleFunc("GET /activity", a.activityPage)
+	mux.HandleFunc("GET /activity/page/{page}", a.activityPage)
 	mux.HandleFunc("GET /invite", a.invitePage)
 	mux.HandleFunc("POST /invite/email", a.inviteByEmail)
 	mux.HandleFunc("POST /invite/link", a.inviteByLink)
@@ -351,29 +512,166 @@ func (a *App) Routes() http.Handler {
 	mux.HandleFunc("POST /join/{slug}", a.joinRegister)
 	mux.HandleFunc("GET /x/{code}/edit", a.editStoryPage)
 	mux.HandleFunc("POST /x/{code}/edit", a.editStory)
+	mux.HandleFunc("GET /x/{code}/history", a.storyHistoryPage)
 	mux.HandleFunc("POST /x/{code}/delete", a.deleteStory)
 	mux.HandleFunc("POST /x/{code}/mark-duplicate", a.markDuplicate)
 	mux.HandleFunc("POST /x/{code}/unmark-duplicate", a.unmarkDuplicate)
+	mux.HandleFunc("POST /x/{code}/pin", a.pinStory)
+	mux.HandleFunc("POST /x/{code}/unpin", a.unpinStory)
+	mux.HandleFunc("POST /x/{code}/pick", a.pickStory)
+	mux.HandleFunc("POST /x/{code}/unpick", a.unpickStory)
+	mux.HandleFunc("POST /x/{code}/unhide", a.unhideFlaggedStory)
+	mux.HandleFunc("POST /x/{code}/adjust-score", a.adjustStoryScore)
+	mux.HandleFunc("POST /mod/impersonate/{username}", a.impersonateUser)
+	mux.HandleFunc("POST /mod/stop-impersonating", a.stopImpersonating)
 	mux.HandleFunc("GET /mod/log", a.moderationLogPage)
 	mux.HandleFunc("GET /mod/log/page/{page}", a.moderationLogPage)
 	mux.HandleFunc("GET /mod/analytics", a.analyticsPage)
-	mux.HandleFunc("GET /api/tags", a.apiListTags)
-	mux.HandleFunc("POST /api/story", a.apiSubmitStory)
+	mux.HandleFunc("GET /mod/stats", a.modStatsPage)
+	mux.HandleFunc("GET /mod/flags", a.flagQueuePage)
+	mux.HandleFunc("POST /mod/flags/bulk", a.bulkFlagAction)
+	mux.Handle("GET /api/tags", jsonRoute(a.apiListTags))
+	mux.Handle("POST /api/story", jsonRoute(a.apiSubmitStory))
 
 	if a.DevReload != nil {
 		mux.Handle("GET /__dev/reload", a.DevReload)
 	}
 
-	return a.securityHeaders(a.requestLog(a.analyticsMiddleware(a.Sessions.AuthenticateRequest(mux))))
+	return a.securityHeaders(a.compress(a.requestLog(a.requestTimeout(a.limitBody(a.analyticsMiddleware(a.Sessions.AuthenticateRequest(a.limitImpersonation(a.flashes(a.readOnly(mux))))))))))
+}
+
+// DefaultRequestTimeout is used unless configured otherwise.
+const DefaultRequestTimeout = 10 * time.Second
+
+// timeoutExempt lists paths that hold requests open on purpose, such as
+// the dev-mode reload long-poll.
+var timeoutExempt = []string{"/__dev/reload"}
+
+// requestTimeout gives each request a context deadline of RequestTimeout
+// so a slow query is cancelled rather than holding its connection until
+// the server's write timeout. Only the server-chosen timeoutExempt paths
+// keep the request's own context; nothing the client sends can opt out.
+func (a *App) requestTimeout(next http.Handler) http.Handler {
+	if a.RequestTimeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(timeoutExempt, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), a.RequestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HSTSConfig controls the Strict-Transport-Security header. Operators who
+// share a parent domain with non-HTTPS hosts can drop includeSubDomains,
+// and a MaxAge of zero tells browsers to forget the policy.
+type HSTSConfig struct {
+	MaxAge            int // seconds
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// DefaultHSTS is used when App.HSTS is nil.
+var DefaultHSTS = HSTSConfig{MaxAge: 63072000, IncludeSubDomains: true}
+
+func (c HSTSConfig) String() string {
+	v := "max-age=" + strconv.Itoa(c.MaxAge)
+	if c.IncludeSubDomains {
+		v += "; includeSubDomains"
+	}
+	if c.Preload {
+		v += "; preload"
+	}
+	return v
+}
+
+// CSPConfig lists the sources the Content-Security-Policy allows for
+// scripts, styles, fonts and images. Self-hosters who bundle their fonts
+// can drop the Google Fonts hosts, and themes can add their own CDNs.
+type CSPConfig struct {
+	ScriptSrc []string
+	StyleSrc  []string
+	FontSrc   []string
+	ImgSrc    []string
+}
+
+// DefaultCSP is used when App.CSP is nil.
+var DefaultCSP = CSPConfig{
+	ScriptSrc: []string{"'self'", "'unsafe-inline'"},
+	StyleSrc:  []string{"'self'", "'unsafe-inline'", "https://fonts.googleapis.com"},
+	FontSrc:   []string{"'self'", "https://fonts.gstatic.com"},
+	ImgSrc:    []string{"'self'", "https:"},
+}
+
+// cspKeywords are the source keywords that must be single-quoted in the
+// header. ParseCSPSources quotes them so they can be written bare.
+var cspKeywords = map[string]bool{
+	"self":           true,
+	"none":           true,
+	"unsafe-inline":  true,
+	"unsafe-eval":    true,
+	"strict-dynamic": true,
 }
 
+// ParseCSPSources parses a space-separated source list, as used by
+// CSP_SCRIPT_SRC, CSP_STYLE_SRC, CSP_FONT_SRC and CSP_IMG_SRC. Keywords
+// may be written with or without their quotes.
+func ParseCSPSources(s string) []string {
+	var sources []string
+	for _, src := range strings.Fields(s) {
+		if k := strings.Trim(src, "'"); cspKeywords[strings.ToLower(k)] {
+			src = "'" + strings.ToLower(k) + "'"
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+func (c CSPConfig) String() string {
+	directive := func(name string, sources []string) string {
+		if len(sources) == 0 {
+			return name + " 'none'; "
+		}
+		return name + " " + strings.Join(sources, " ") + "; "
+	}
+	return "default-src 'self'; " +
+		directive("script-src", c.ScriptSrc) +
+		directive("style-src", c.StyleSrc) +
+		directive("font-src", c.FontSrc) +
+		directive("img-src", c.ImgSrc) +
+		"frame-src https://www.youtube-nocookie.com; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+}
+
+// permissionsPolicy turns off browser features the site never uses.
+// Features YouTube embeds rely on (autoplay, fullscreen, encrypted-media,
+// picture-in-picture) are left at their defaults.
+const permissionsPolicy = "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=(), browsing-topics=()"
+
 func (a *App) securityHeaders(next http.Handler) http.Handler {
+	hsts := DefaultHSTS
+	if a.HSTS != nil {
+		hsts = *a.HSTS
+	}
+	hstsValue := hsts.String()
+	csp := DefaultCSP
+	if a.CSP != nil {
+		csp = *a.CSP
+	}
+	cspValue := csp.String()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		w.Header().Set("Strict-Transport-Security", hstsValue)
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
 		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; img-src 'self' https:; frame-ancestors 'none'; base-uri 'self'; form-action 'self'")
+		w.Header().Set("Permissions-Policy", permissionsPolicy)
+		// No Cross-Origin-Embedder-Policy: require-corp would block the
+		// YouTube embeds and the remote images stories link to.
+		w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+		w.Header().Set("Content-Security-Policy", cspValue)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -430,14 +728,51 @@ func (a *App) requestLog(next http.Handler) http.Handler {
 	})
 }
 
+// reads returns the Queries for listing and profile pages, which go to the
+// read replica when one is configured. Pages that must see the current
+// user's own writes should keep using a.Queries.
+func (a *App) reads() *store.Queries {
+	if a.Reader != nil {
+		return a.Reader
+	}
+	return a.Queries
+}
+
+// readsFor is reads for pages that carry the viewer's own state, such as
+// story listings with their votes, flags and hides: logged-in viewers read
+// from the primary so a lagging replica can't undo what they just did.
+func (a *App) readsFor(r *http.Request) *store.Queries {
+	if _, ok := auth.UserFromContext(r.Context()); ok {
+		return a.Queries
+	}
+	return a.reads()
+}
+
+// serverError logs err and answers 500, or 504 when the request ran past
+// its deadline.
 func (a *App) serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		a.Log.Warn(msg+": request timed out", "error", err, "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "request timed out", http.StatusGatewayTimeout)
+		return
+	}
 	a.Log.Error(msg, "error", err, "method", r.Method, "path", r.URL.Path)
 	http.Error(w, "internal server error", http.StatusInternalServerError)
 }
 
+// jsonServerError is serverError for JSON endpoints.
+func (a *App) jsonServerError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		a.Log.Warn(msg+": request timed out", "error", err, "method", r.Method, "path", r.URL.Path)
+		writeJSONError(w, http.StatusGatewayTimeout, "Request timed out.")
+		return
+	}
+	a.Log.Error(msg, "error", err, "method", r.Method, "path", r.URL.Path)
+	writeJSONError(w, http.StatusInternalServerError, "Internal server error.")
+}
+
 func (a *App) notFound(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	a.render(w, "not_found", struct{ Base Base }{Base: a.baseData(r)})
+	a.renderStatus(w, http.StatusNotFound, "not_found", struct{ Base Base }{Base: a.baseData(r)})
 }
 
 var slogans = []string{
@@ -447,14 +782,42 @@ var slogans = []string{
 	"collecting shiny things",
 }
 
+// sloganNone is the slogan preference that hides the header slogan.
+const sloganNone = "none"
+
+// pickSlogan returns the header slogan for a user's preference: a random
+// slogan by default, none when disabled, or the pinned one if it still
+// exists.
+func pickSlogan(pref string) string {
+	switch {
+	case pref == sloganNone:
+		return ""
+	case pref != "" && slices.Contains(slogans, pref):
+		return pref
+	default:
+		return slogans[rand.Intn(len(slogans))]
+	}
+}
+
+// sloganChoices lists the distinct slogans a user can pin.
+func sloganChoices() []string {
+	var choices []string
+	for _, s := range slogans {
+		if !slices.Contains(choices, s) {
+			choices = append(choices, s)
+		}
+	}
+	return choices
+}
+
 func (a *App) baseData(r *http.Request) Base {
 	if current, ok := auth.UserFromContext(r.Context()); ok {
-		slogan := slogans[rand.Intn(len(slogans))]
+		slogan := pickSlogan(current.User.Slogan)
 		var unread int64
 		if count, err := a.Queries.CountUnreadReplies(r.Context(), current.User.ID); err == nil {
 			unread = count
 		}
-		return Base{
+		base := Base{
 			IsLoggedIn:     true,
 			IsModerator:    current.User.IsModerator,
 			EmailConfirmed: current.User.EmailConfirmedAt.Valid,
@@ -463,60 +826,187 @@ func (a *App) baseData(r *http.Request) Base {
 			DevMode:        a.DevMode,
 			UnreadReplies:  unread,
 		}
+		if current.Impersonator != nil {
+			base.ImpersonatedBy = current.Impersonator.Username
+		}
+		base.Flash = flashFromContext(r.Context())
+		return base
 	}
-	return Base{DevMode: a.DevMode}
+	return Base{DevMode: a.DevMode, Flash: flashFromContext(r.Context())}
 }
 
 func (a *App) render(w http.ResponseWriter, name string, data any) {
-	var tmpl *template.Template
+	a.renderStatus(w, http.StatusOK, name, data)
+}
 
-	if a.DevMode && a.TemplateFS != nil {
-		templates, err := ParseTemplates(a.TemplateFS, nil, true)
-		if err != nil {
-			a.Log.Error("dev template parse", "error", err)
-			http.Error(w, "template parse error", http.StatusInternalServerError)
-			return
-		}
-		var ok bool
-		tmpl, ok = templates[name]
-		if !ok {
-			a.Log.Error("template not found", "template", name)
-			http.Error(w, "template not found", http.StatusInternalServerError)
-			return
-		}
-	} else {
-		var ok bool
-		tmpl, ok = a.Templates[name]
-		if !ok {
-			a.Log.Error("template not found", "template", name)
-			http.Error(w, "template not found", http.StatusInternalServerError)
-			return
-		}
+// renderStatus is render with a status other than 200. The status is
+// written only once the page has rendered, so a failure can still answer
+// with the fallback page instead.
+func (a *App) renderStatus(w http.ResponseWriter, status int, name string, data any) {
+	tmpl, ok := a.lookupTemplate(w, status, name)
+	if !ok {
+		return
 	}
 
 	var buf bytes.Buffer
 	if err := tmpl.ExecuteTemplate(&buf, "base", data); err != nil {
 		a.Log.Error("template execute", "error", err, "template", name)
-		http.Error(w, "template error", http.StatusInternalServerError)
+		renderFallbackError(w, status)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 	_, _ = buf.WriteTo(w)
 }
 
+// lookupTemplate returns the named template set, re-parsing templates from
+// disk in dev mode. On failure it writes an error page and returns false;
+// status is the one the caller meant to answer with.
+func (a *App) lookupTemplate(w http.ResponseWriter, status int, name string) (*template.Template, bool) {
+	templates := a.Templates
+	if a.DevMode && a.TemplateFS != nil {
+		var err error
+		templates, err = ParseTemplates(a.TemplateFS, nil, true)
+		if err != nil {
+			a.Log.Error("dev template parse", "error", err)
+			renderDevTemplateError(w, err)
+			return nil, false
+		}
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		a.Log.Error("template not found", "template", name)
+		renderFallbackError(w, status)
+		return nil, false
+	}
+	return tmpl, true
+}
+
+// fallbackErrorPage is shown when a page template is missing or fails to
+// execute. Like devTemplateErrorPage it doesn't depend on the site
+// templates, so it is always available. The verbs take the title and the
+// message.
+const fallbackErrorPage = `<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1" />
+    <title>%[1]s | Crow Watch</title>
+    <style>
+      body {
+        max-width: 640px;
+        margin: 64px auto;
+        padding: 0 16px;
+        font-family: system-ui, sans-serif;
+        color: #222;
+      }
+      a {
+        color: #4a6cf7;
+      }
+    </style>
+  </head>
+  <body>
+    <h1>%[1]s</h1>
+    <p>%[2]s</p>
+    <p><a href="/">Back to the front page</a></p>
+  </body>
+</html>
+`
+
+// renderFallbackError answers with fallbackErrorPage. A 404 keeps its
+// status and says the page wasn't found; anything else becomes a 500.
+func renderFallbackError(w http.ResponseWriter, status int) {
+	title, msg := "Something went wrong", "This page couldn't be displayed. Please try again in a moment."
+	if status == http.StatusNotFound {
+		title, msg = "Not found", "This page doesn't exist."
+	} else {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, fallbackErrorPage, title, msg)
+}
+
+// devTemplateErrorPage is shown in dev mode when templates fail to parse,
+// so a template saved mid-edit points at what broke instead of a blank 500.
+// It is self-contained because the site templates are what failed.
+var devTemplateErrorPage = template.Must(template.New("dev-template-error").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <title>Template error | Crow Watch</title>
+    <style>
+      body {
+        font-family: system-ui, sans-serif;
+        margin: 32px;
+        color: #222;
+      }
+      pre {
+        padding: 12px 16px;
+        background: #fdecec;
+        border: 1px solid #f5c2c2;
+        border-radius: 6px;
+        white-space: pre-wrap;
+      }
+    </style>
+  </head>
+  <body>
+    <h1>Template parse error</h1>
+    <pre>{{ . }}</pre>
+    <p>Fix the template and reload the page.</p>
+  </body>
+</html>
+`))
+
+func renderDevTemplateError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = devTemplateErrorPage.Execute(w, err.Error())
+}
+
+// timeAgo formats t relative to now, e.g. "3 hours ago".
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < 2*time.Minute:
+		return "1 minute ago"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 2*time.Hour:
+		return "1 hour ago"
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	case d < 48*time.Hour:
+		return "1 day ago"
+	default:
+		return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+	}
+}
+
+// pluralize picks the singular or plural form for count.
+func pluralize(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// partialsTemplate is the key under which ParseTemplates stores the base
+// set holding only the shared partials, for rendering fragments.
+const partialsTemplate = "partials"
+
 func ParseTemplates(fsys fs.FS, staticHashes map[string]string, devMode bool) (map[string]*template.Template, error) {
 	funcMap := template.FuncMap{
 		"storyPath": func(s StoryItem) string {
 			return storyPath(s.ShortCode, s.Title)
 		},
-		"static": func(path string) string {
+		"static": func(name string) (string, error) {
 			if devMode {
-				return "/static/" + path + "?_dev=" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+				return "/static/" + staticKey(name) + "?_dev=" + strconv.FormatInt(time.Now().UnixMilli(), 10), nil
 			}
-			if q, ok := staticHashes[path]; ok {
-				return "/static/" + path + q
-			}
-			return "/static/" + path
+			return staticURL(staticHashes, name)
 		},
 		"inSlice": func(needle int64, haystack []int64) bool {
 			for _, v := range haystack {
@@ -553,33 +1043,13 @@ func ParseTemplates(fsys fs.FS, staticHashes map[string]string, devMode bool) (m
 			}
 			return value * 100 / max
 		},
-		"add":      func(a, b int) int { return a + b },
-		"subtract": func(a, b int) int { return a - b },
-		"multiply": func(a, b int) int { return a * b },
-		"pluralize": func(count int, singular, plural string) string {
-			if count == 1 {
-				return singular
-			}
-			return plural
-		},
-		"timeAgo": func(t time.Time) string {
-			d := time.Since(t)
-			switch {
-			case d < time.Minute:
-				return "just now"
-			case d < 2*time.Minute:
-				return "1 minute ago"
-			case d < time.Hour:
-				return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
-			case d < 2*time.Hour:
-				return "1 hour ago"
-			case d < 24*time.Hour:
-				return fmt.Sprintf("%d hours ago", int(d.Hours()))
-			case d < 48*time.Hour:
-				return "1 day ago"
-			default:
-				return fmt.Sprintf("%d days ago", int(d.Hours()/24))
-			}
+		"add":       func(a, b int) int { return a + b },
+		"subtract":  func(a, b int) int { return a - b },
+		"multiply":  func(a, b int) int { return a * b },
+		"pluralize": pluralize,
+		"timeAgo":   timeAgo,
+		"isoTime": func(t time.Time) string {
+			return t.UTC().Format(time.RFC3339)
 		},
 	}
 
@@ -612,10 +1082,33 @@ func ParseTemplates(fsys fs.FS, staticHashes map[string]string, devMode bool) (m
 		}
 		templates[name] = clone
 	}
+	templates[partialsTemplate] = base
 
 	return templates, nil
 }
 
+// staticKey normalizes an asset path to the form HashStatic keys by, so
+// "/static/js/vote.js", "static/js/vote.js" and "js/vote.js" all match.
+func staticKey(name string) string {
+	name = path.Clean("/" + name)
+	name = strings.TrimPrefix(name, "/static/")
+	return strings.TrimPrefix(name, "/")
+}
+
+// staticURL returns the cache-busted URL of a static asset. An asset with
+// no hash doesn't exist in the static FS, which fails the render so a
+// broken reference shows up as soon as the page is exercised.
+func staticURL(hashes map[string]string, name string) (string, error) {
+	key := staticKey(name)
+	q, ok := hashes[key]
+	if !ok {
+		return "", fmt.Errorf("static asset %q not found", name)
+	}
+	return "/static/" + key + q, nil
+}
+
+// HashStatic returns a "?v=" query string for every file in fsys, keyed by
+// its slash-separated path relative to the root (see staticKey).
 func HashStatic(fsys fs.FS) (map[string]string, error) {
 	hashes := make(map[string]string)
 	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
@@ -631,7 +1124,7 @@ func HashStatic(fsys fs.FS) (map[string]string, error) {
 		if _, err := io.Copy(h, f); err != nil {
 			return err
 		}
-		hashes[path] = "?v=" + hex.EncodeToString(h.Sum(nil))[:8]
+		hashes[staticKey(path)] = "?v=" + hex.EncodeToString(h.Sum(nil))[:8]
 		return nil
 	})
 	if err != nil {
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"io"
 	"io/fs"
@@ -8,15 +10,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 	"testing/fstest"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
 	"crow.watch/web"
 )
 
@@ -40,7 +46,7 @@ func testApp(t *testing.T) *App {
 	staticFS, err := fs.Sub(web.FS, "static")
 	require.NoError(t, err)
 	log := discardLogger()
-	sessions := auth.NewSessionManager(nil, "test_session", time.Hour, false, log)
+	sessions := auth.NewSessionManager(nil, "test_session", time.Hour, auth.CookieOptions{}, log)
 	emailTemplates, err := ParseEmailTemplates(web.FS)
 	require.NoError(t, err)
 	return &App{
@@ -65,6 +71,29 @@ func TestParseTemplatesMissingBase(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRenderDevModeShowsTemplateParseError(t *testing.T) {
+	a := testApp(t)
+	a.DevMode = true
+	a.TemplateFS = fstest.MapFS{
+		"templates/base.tmpl":       &fstest.MapFile{Data: []byte(`{{define "base"}}{{block "content" .}}{{end}}{{end}}`)},
+		"templates/pages/home.tmpl": &fstest.MapFile{Data: []byte("{{define \"content\"}}\n{{if .Stories}}\n{{end}}")},
+	}
+	w := httptest.NewRecorder()
+
+	a.render(w, "home", HomePageData{})
+
+	body := w.Body.String()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, body, "Template parse error")
+	assert.Contains(t, body, "home.tmpl:3")
+
+	a.DevMode = false
+	w = httptest.NewRecorder()
+	a.render(w, "home", HomePageData{})
+	assert.Equal(t, http.StatusOK, w.Code, "production uses the prebuilt templates")
+}
+
 func TestRenderHomeLoggedOut(t *testing.T) {
 	a := testApp(t)
 	w := httptest.NewRecorder()
@@ -129,6 +158,49 @@ func TestRenderUnknownTemplate(t *testing.T) {
 	a.render(w, "nonexistent", nil)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<h1>Something went wrong</h1>")
+	assert.NotContains(t, w.Body.String(), "template not found")
+}
+
+func TestRenderTemplateExecuteError(t *testing.T) {
+	a := testApp(t)
+	a.Templates = map[string]*template.Template{
+		"broken": template.Must(template.New("base").Parse(`<main>{{ .Missing }}</main>`)),
+	}
+	w := httptest.NewRecorder()
+
+	a.render(w, "broken", struct{}{})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Something went wrong</h1>")
+	assert.NotContains(t, w.Body.String(), "<main>", "no partial output leaks through")
+}
+
+func TestNotFoundWithoutTemplate(t *testing.T) {
+	a := testApp(t)
+	delete(a.Templates, "not_found")
+	w := httptest.NewRecorder()
+
+	a.notFound(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<h1>Not found</h1>")
+}
+
+func TestRenderStatusExecuteErrorOverridesStatus(t *testing.T) {
+	a := testApp(t)
+	a.Templates = map[string]*template.Template{
+		"broken": template.Must(template.New("base").Parse(`<main>{{ .Missing }}</main>`)),
+	}
+	w := httptest.NewRecorder()
+
+	a.renderStatus(w, http.StatusGone, "broken", struct{}{})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<h1>Something went wrong</h1>")
 }
 
 func TestSecurityHeaders(t *testing.T) {
@@ -146,6 +218,62 @@ func TestSecurityHeaders(t *testing.T) {
 	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
 	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
 	assert.Equal(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+	assert.Contains(t, w.Header().Get("Permissions-Policy"), "geolocation=()")
+	assert.Contains(t, w.Header().Get("Permissions-Policy"), "camera=()")
+	assert.Contains(t, w.Header().Get("Permissions-Policy"), "microphone=()")
+	assert.Equal(t, "same-origin", w.Header().Get("Cross-Origin-Opener-Policy"))
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; img-src 'self' https:; frame-src https://www.youtube-nocookie.com; frame-ancestors 'none'; base-uri 'self'; form-action 'self'", w.Header().Get("Content-Security-Policy"))
+}
+
+func TestSecurityHeadersConfiguredCSP(t *testing.T) {
+	a := testApp(t)
+	a.CSP = &CSPConfig{
+		ScriptSrc: []string{"'self'", "https://cdn.example.com"},
+		StyleSrc:  []string{"'self'", "'unsafe-inline'"},
+		FontSrc:   []string{"'self'"},
+		ImgSrc:    nil,
+	}
+	handler := a.securityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src 'self' https://cdn.example.com;")
+	assert.Contains(t, csp, "style-src 'self' 'unsafe-inline';")
+	assert.Contains(t, csp, "font-src 'self';")
+	assert.Contains(t, csp, "img-src 'none';", "an empty list allows nothing")
+	assert.NotContains(t, csp, "fonts.googleapis.com")
+	assert.NotContains(t, csp, "fonts.gstatic.com")
+	assert.Contains(t, csp, "frame-ancestors 'none'", "fixed directives stay")
+}
+
+func TestParseCSPSources(t *testing.T) {
+	assert.Equal(t, []string{"'self'", "'unsafe-inline'", "https://cdn.example.com"},
+		ParseCSPSources("self  'unsafe-inline'\thttps://cdn.example.com"))
+	assert.Equal(t, []string{"'self'", "https:", "data:"}, ParseCSPSources("'SELF' https: data:"))
+	assert.Empty(t, ParseCSPSources("  "))
+}
+
+func TestSecurityHeadersConfiguredHSTS(t *testing.T) {
+	tests := []struct {
+		hsts HSTSConfig
+		want string
+	}{
+		{HSTSConfig{MaxAge: 300}, "max-age=300"},
+		{HSTSConfig{MaxAge: 31536000, IncludeSubDomains: true, Preload: true}, "max-age=31536000; includeSubDomains; preload"},
+		{HSTSConfig{}, "max-age=0"},
+	}
+	for _, tt := range tests {
+		a := testApp(t)
+		a.HSTS = &tt.hsts
+		handler := a.securityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		assert.Equal(t, tt.want, w.Header().Get("Strict-Transport-Security"))
+	}
 }
 
 func TestStaticFileServing(t *testing.T) {
@@ -179,7 +307,7 @@ func TestRenderSubmitForm(t *testing.T) {
 		Tab:  "link",
 		TagGroups: []TagGroup{
 			{Category: "Topics", Tags: []TagOption{
-				{ID: 1, Tag: "programming", Description: "Code and dev"},
+				{ID: 1, Tag: "programming", Description: "<p>Code and dev</p>"},
 			}},
 			{Category: "Media", Tags: []TagOption{
 				{ID: 2, Tag: "video", IsMedia: true},
@@ -383,6 +511,28 @@ func TestRenderStoryDetailPage(t *testing.T) {
 	assert.Contains(t, body, "Crow Watch")
 }
 
+func TestRenderTimestampsInUTC(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	zone := time.FixedZone("UTC+3", 3*60*60)
+	created := time.Now().Add(-2 * time.Hour).In(zone).Truncate(time.Second)
+	a.render(w, "story", StoryPageData{
+		Base:  Base{IsLoggedIn: true, Username: "alice"},
+		Story: StoryItem{ID: 1, ShortCode: "abc123", Title: "Story", Username: "bob", CreatedAt: created},
+		Comments: []*CommentNode{
+			{ID: 1, Username: "carol", Body: "hi", CreatedAt: created},
+		},
+	})
+
+	iso := created.UTC().Format(time.RFC3339)
+	body := w.Body.String()
+	assert.Contains(t, body, `<time datetime="`+iso+`" title="`+iso+`"`)
+	assert.Contains(t, body, ">2 hours ago</time")
+	assert.Equal(t, 4, strings.Count(body, iso), "story and comment each carry datetime and title")
+	assert.True(t, strings.HasSuffix(iso, "Z"))
+}
+
 func TestRenderSubmitFormHasBodyField(t *testing.T) {
 	a := testApp(t)
 	w := httptest.NewRecorder()
@@ -413,6 +563,22 @@ func TestSubmitLinkTabShowsURLNotBody(t *testing.T) {
 	assert.NotContains(t, body, "<textarea")
 }
 
+func TestSubmitShowTabShowsURLAndBody(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "submit", SubmitPageData{
+		Base: Base{IsLoggedIn: true, Username: "alice"},
+		Tab:  "show",
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `name="url"`)
+	assert.Contains(t, body, `name="body"`)
+	assert.Contains(t, body, "Show CW:")
+	assert.NotContains(t, body, "fetch-title-btn")
+}
+
 func TestSubmitTextTabShowsBodyNotURL(t *testing.T) {
 	a := testApp(t)
 	w := httptest.NewRecorder()
@@ -441,6 +607,35 @@ func TestHashStatic(t *testing.T) {
 	assert.Regexp(t, regexp.MustCompile(`^\?v=[0-9a-f]{8}$`), hashes["js/app.js"])
 }
 
+func TestStaticURL(t *testing.T) {
+	hashes, err := HashStatic(fstest.MapFS{
+		"js/vendor/x.js": &fstest.MapFile{Data: []byte("x()")},
+	})
+	require.NoError(t, err)
+	q := hashes["js/vendor/x.js"]
+	require.NotEmpty(t, q)
+
+	for _, name := range []string{"js/vendor/x.js", "/js/vendor/x.js", "/static/js/vendor/x.js", "js/../js/vendor/x.js"} {
+		got, err := staticURL(hashes, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, "/static/js/vendor/x.js"+q, got, name)
+	}
+
+	_, err = staticURL(hashes, "js/vendor/missing.js")
+	assert.ErrorContains(t, err, "missing.js")
+}
+
+func TestRenderFailsOnMissingStaticAsset(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/base.tmpl":        &fstest.MapFile{Data: []byte(`{{ define "base" }}{{ static "js/gone.js" }}{{ end }}`)},
+		"templates/pages/empty.tmpl": &fstest.MapFile{Data: []byte(``)},
+	}
+	templates, err := ParseTemplates(fsys, map[string]string{}, false)
+	require.NoError(t, err)
+	err = templates["empty"].ExecuteTemplate(io.Discard, "base", nil)
+	assert.ErrorContains(t, err, `static asset "js/gone.js" not found`)
+}
+
 func TestLongCacheMiddleware(t *testing.T) {
 	a := testApp(t)
 	handler := a.Routes()
@@ -452,3 +647,197 @@ func TestLongCacheMiddleware(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
 }
+
+func TestRenderStoryViewCount(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "story", StoryPageData{
+		Base: Base{},
+		Story: StoryItem{
+			ID:        1,
+			ShortCode: "abc123",
+			Title:     "Viewed story",
+			Username:  "bob",
+			ViewCount: 42,
+			CreatedAt: time.Now(),
+		},
+	})
+
+	assert.Regexp(t, regexp.MustCompile(`42\s+views`), w.Body.String())
+}
+
+func TestPickSlogan(t *testing.T) {
+	assert.Empty(t, pickSlogan(sloganNone), "disabled slogans")
+	assert.Equal(t, "clever by nature", pickSlogan("clever by nature"), "pinned slogan")
+
+	for _, pref := range []string{"", "a slogan that was removed"} {
+		got := pickSlogan(pref)
+		assert.Contains(t, slogans, got, "random slogan for %q", pref)
+	}
+}
+
+func TestSloganChoicesDistinct(t *testing.T) {
+	choices := sloganChoices()
+	seen := map[string]bool{}
+	for _, c := range choices {
+		assert.False(t, seen[c], "duplicate %q", c)
+		seen[c] = true
+	}
+	for _, s := range slogans {
+		assert.True(t, seen[s], "missing %q", s)
+	}
+}
+
+func TestRenderAccountSloganSelected(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "account", AccountPageData{
+		Base:          Base{IsLoggedIn: true, Username: "alice"},
+		Tab:           "profile",
+		Slogan:        sloganNone,
+		SloganChoices: sloganChoices(),
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `name="slogan"`)
+	assert.Regexp(t, `value="none"\s+selected`, body)
+}
+
+func TestStoryEmbedURL(t *testing.T) {
+	media := []StoryTag{{Tag: "video", IsMedia: true}}
+	plain := []StoryTag{{Tag: "go"}}
+	yt := "https://youtube.com/watch?v=dQw4w9WgXcQ"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		tags    []StoryTag
+		url     string
+		want    string
+	}{
+		{"youtube media story", true, media, yt, "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"embeds disabled", false, media, yt, ""},
+		{"no media tag", true, plain, yt, ""},
+		{"generic link", true, media, "https://example.com/talk", ""},
+		{"text story", true, media, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, storyEmbedURL(tt.enabled, tt.tags, tt.url))
+		})
+	}
+}
+
+func TestRenderStoryEmbed(t *testing.T) {
+	a := testApp(t)
+	story := StoryItem{ID: 1, ShortCode: "abc123", Title: "A talk", CreatedAt: time.Now()}
+
+	w := httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{
+		Story:    story,
+		EmbedURL: storyEmbedURL(true, []StoryTag{{Tag: "video", IsMedia: true}}, "https://youtube.com/watch?v=dQw4w9WgXcQ"),
+	})
+	body := w.Body.String()
+	assert.Contains(t, body, "<iframe")
+	assert.Contains(t, body, `src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`)
+
+	w = httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{
+		Story:    story,
+		EmbedURL: storyEmbedURL(true, []StoryTag{{Tag: "video", IsMedia: true}}, "https://example.com/talk"),
+	})
+	assert.NotContains(t, w.Body.String(), "<iframe")
+}
+
+// fakeDB is a store.DBTX that records the queries it is asked to run and
+// answers every one of them with err. It never has rows to return, so reads
+// fail with pgx.ErrNoRows when err is nil.
+type fakeDB struct {
+	calls int
+	err   error
+}
+
+func (f *fakeDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	f.calls++
+	return pgconn.CommandTag{}, f.err
+}
+
+func (f *fakeDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	f.calls++
+	return nil, f.readErr()
+}
+
+func (f *fakeDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	f.calls++
+	return fakeRow{f.readErr()}
+}
+
+func (f *fakeDB) readErr() error {
+	if f.err == nil {
+		return pgx.ErrNoRows
+	}
+	return f.err
+}
+
+type fakeRow struct{ err error }
+
+func (r fakeRow) Scan(...any) error { return r.err }
+
+func TestReadReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+	primary := &fakeDB{err: pgx.ErrNoRows}
+	replica := &fakeDB{err: pgx.ErrNoRows}
+
+	a := testApp(t)
+	a.Queries = store.New(primary)
+	var rdb *store.ReplicaDB
+	a.Reader, rdb = store.NewReader(primary, replica)
+
+	// Profile pages read from the replica.
+	req := httptest.NewRequest(http.MethodGet, "/~alice", nil)
+	req.SetPathValue("username", "alice")
+	w := httptest.NewRecorder()
+	a.profilePage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 2, replica.calls, "profile and tombstone lookups")
+	assert.Zero(t, primary.calls)
+
+	// Writes stay on the primary.
+	_, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{Title: "x", ShortCode: "abc123"})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 2, replica.calls)
+
+	// An unhealthy replica falls back to the primary.
+	replica.err = errors.New("connection refused")
+	require.Error(t, rdb.Check(ctx))
+	assert.False(t, rdb.Healthy())
+	_, _ = a.reads().ListStories(ctx, store.ListStoriesParams{})
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 3, replica.calls, "only the health check")
+
+	replica.err = nil
+	require.NoError(t, rdb.Check(ctx))
+	assert.True(t, rdb.Healthy())
+	_, _ = a.reads().ListStories(ctx, store.ListStoriesParams{})
+	assert.Equal(t, 5, replica.calls)
+	assert.Equal(t, 2, primary.calls)
+}
+
+func TestReadsWithoutReplica(t *testing.T) {
+	a := testApp(t)
+	a.Queries = store.New(&fakeDB{})
+	assert.Same(t, a.Queries, a.reads())
+}
+
+func TestListingsRenderNotFoundPage(t *testing.T) {
+	a := testApp(t)
+	for _, handler := range []http.HandlerFunc{a.tagPage, a.domainPage} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "404 | Crow Watch")
+	}
+}
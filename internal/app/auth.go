@@ -2,15 +2,32 @@ package app
 
 import (
 	"errors"
-	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"crow.watch/internal/auth"
+	"crow.watch/internal/ratelimit"
 	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// comparePassword checks a password against a bcrypt digest. It is a
+// variable so tests can observe that a comparison ran.
+var comparePassword = bcrypt.CompareHashAndPassword
+
+// dummyDigest is compared against when an account is missing or can't log
+// in, so those attempts take as long as a real one and don't reveal which
+// usernames and e-mails exist.
+var dummyDigest = sync.OnceValue(func() []byte {
+	digest, err := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return digest
+})
+
 func (a *App) loginPage(w http.ResponseWriter, r *http.Request) {
 	if _, ok := auth.UserFromContext(r.Context()); ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -21,65 +38,123 @@ func (a *App) loginPage(w http.ResponseWriter, r *http.Request) {
 	if tab != "register" {
 		tab = "login"
 	}
-	a.render(w, "login", LoginPageData{Base: a.baseData(r), Tab: tab})
+	a.render(w, "login", LoginPageData{
+		Base:      a.baseData(r),
+		Tab:       tab,
+		CaptchaID: a.newCaptchaID(a.captchaRequired(a.LoginIPLimiter, a.clientIP(r))),
+	})
+}
+
+// captchaRequired reports whether key has made enough attempts against l
+// that its next one must solve a CAPTCHA.
+func (a *App) captchaRequired(l *ratelimit.Limiter, key string) bool {
+	return a.Captcha != nil && a.CaptchaAfter > 0 && l != nil && l.Count(key) >= a.CaptchaAfter
+}
+
+// newCaptchaID returns a fresh challenge for a form when required is set,
+// or "" to render the form without one.
+func (a *App) newCaptchaID(required bool) string {
+	if !required {
+		return ""
+	}
+	id, err := a.Captcha.Generate()
+	if err != nil {
+		a.Log.Error("generate captcha", "error", err)
+		return ""
+	}
+	return id
+}
+
+// validCaptcha checks the CAPTCHA answer posted with a form.
+func (a *App) validCaptcha(r *http.Request) bool {
+	answer, _ := strconv.Atoi(r.FormValue("captcha_answer"))
+	return a.Captcha.Validate(r.FormValue("captcha_id"), answer)
 }
 
+const captchaErrorMessage = "Incorrect answer. Please try again."
+
 func (a *App) login(w http.ResponseWriter, r *http.Request) {
 	if _, ok := auth.UserFromContext(r.Context()); ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "login", LoginPageData{Base: a.baseData(r), Tab: "login", Error: "Invalid login request."})
+	if !parseForm(w, r) {
 		return
 	}
 
 	identifier := strings.TrimSpace(r.FormValue("identifier"))
 	password := r.FormValue("password")
+	ip := a.clientIP(r)
+	account := strings.ToLower(identifier)
+
+	// needCaptcha is re-evaluated for each re-rendered form, since the
+	// attempt being handled may be the one that crosses the threshold.
+	needCaptcha := func() bool {
+		return a.captchaRequired(a.LoginIPLimiter, ip) || a.captchaRequired(a.LoginAcctLimiter, account)
+	}
+	renderErrorStatus := func(status int, msg string) {
+		a.renderStatus(w, status, "login", LoginPageData{
+			Base:       a.baseData(r),
+			Tab:        "login",
+			Identifier: identifier,
+			Error:      msg,
+			CaptchaID:  a.newCaptchaID(needCaptcha()),
+		})
+	}
+	renderError := func(msg string) {
+		renderErrorStatus(http.StatusOK, msg)
+	}
+
+	if needCaptcha() && !a.validCaptcha(r) {
+		renderError(captchaErrorMessage)
+		return
+	}
 
-	rateLimitErr := LoginPageData{Base: a.baseData(r), Tab: "login", Identifier: identifier, Error: "Too many login attempts. Please try again later."}
+	rateLimited := func(l *ratelimit.Limiter, key string) {
+		renderErrorStatus(http.StatusTooManyRequests, rateLimitMessage(w, l, key, "login attempts"))
+	}
 
 	if a.LoginIPLimiter != nil {
-		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-		if ip == "" {
-			ip = r.RemoteAddr
-		}
 		if !a.LoginIPLimiter.Allow(ip) {
-			a.render(w, "login", rateLimitErr)
+			rateLimited(a.LoginIPLimiter, ip)
 			return
 		}
 	}
 
 	if a.LoginAcctLimiter != nil {
-		if !a.LoginAcctLimiter.Allow(strings.ToLower(identifier)) {
-			a.render(w, "login", rateLimitErr)
+		if !a.LoginAcctLimiter.Allow(account) {
+			rateLimited(a.LoginAcctLimiter, account)
 			return
 		}
 	}
 
+	const invalidErr = "Invalid e-mail/username and/or password."
+
+	// Checked before the lookup so it can't tell existing accounts apart.
+	if len(password) > 72 {
+		renderError(invalidErr)
+		return
+	}
+
 	user, err := a.Queries.GetUserByLogin(r.Context(), identifier)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			a.render(w, "login", LoginPageData{Base: a.baseData(r), Tab: "login", Identifier: identifier, Error: "Invalid e-mail/username and/or password."})
+			_ = comparePassword(dummyDigest(), []byte(password))
+			renderError(invalidErr)
 			return
 		}
 		a.serverError(w, r, "get user by login", err)
 		return
 	}
 
-	invalidErr := LoginPageData{Base: a.baseData(r), Tab: "login", Identifier: identifier, Error: "Invalid e-mail/username and/or password."}
-
 	if user.BannedAt.Valid || user.DeletedAt.Valid || user.PasswordDigest == "*" {
-		a.render(w, "login", invalidErr)
-		return
-	}
-	if len(password) > 72 {
-		a.render(w, "login", invalidErr)
+		_ = comparePassword(dummyDigest(), []byte(password))
+		renderError(invalidErr)
 		return
 	}
-	if bcrypt.CompareHashAndPassword([]byte(user.PasswordDigest), []byte(password)) != nil {
-		a.render(w, "login", invalidErr)
+	if comparePassword([]byte(user.PasswordDigest), []byte(password)) != nil {
+		renderError(invalidErr)
 		return
 	}
 
@@ -89,7 +164,7 @@ func (a *App) login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if a.LoginAcctLimiter != nil {
-		a.LoginAcctLimiter.Reset(strings.ToLower(identifier))
+		a.LoginAcctLimiter.Reset(account)
 	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
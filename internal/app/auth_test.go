@@ -0,0 +1,186 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"crow.watch/internal/captcha"
+	"crow.watch/internal/ratelimit"
+	"crow.watch/internal/store"
+)
+
+func TestDummyDigestMatchesRealCost(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyDigest())
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
+
+func TestLoginComparesPasswordForUnknownAccounts(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "banned", Email: "banned@example.com", PasswordDigest: "*",
+	})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "UPDATE users SET banned_at = now() WHERE id = $1", u.ID)
+	require.NoError(t, err)
+
+	var compared [][]byte
+	orig := comparePassword
+	comparePassword = func(digest, password []byte) error {
+		compared = append(compared, digest)
+		return orig(digest, password)
+	}
+	t.Cleanup(func() { comparePassword = orig })
+
+	for _, identifier := range []string{"nobody@example.com", "banned"} {
+		compared = nil
+		form := url.Values{"identifier": {identifier}, "password": {"hunter22"}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.login(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid e-mail/username and/or password.")
+		require.Len(t, compared, 1, identifier)
+		assert.True(t, bytes.Equal(dummyDigest(), compared[0]), identifier)
+	}
+}
+
+func TestLoginRateLimitSetsRetryAfter(t *testing.T) {
+	a := testApp(t)
+	a.LoginIPLimiter = ratelimit.New(1, 10*time.Minute)
+	require.True(t, a.LoginIPLimiter.Allow("192.0.2.1"))
+
+	form := url.Values{"identifier": {"alice"}, "password": {"hunter22"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	a.login(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "600", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Too many login attempts. Please try again in 10 minutes.")
+}
+
+func TestRetryMinutes(t *testing.T) {
+	assert.Equal(t, "1 minute", retryMinutes(0))
+	assert.Equal(t, "1 minute", retryMinutes(30*time.Second))
+	assert.Equal(t, "2 minutes", retryMinutes(61*time.Second))
+	assert.Equal(t, "10 minutes", retryMinutes(10*time.Minute))
+	assert.Equal(t, "3 hours", retryMinutes(150*time.Minute))
+}
+
+// solveCaptcha pulls the challenge ID out of a rendered form and returns
+// the form values answering it.
+func solveCaptcha(t *testing.T, a *App, body string) url.Values {
+	t.Helper()
+	m := regexp.MustCompile(`name="captcha_id" value="([^"]+)"`).FindStringSubmatch(body)
+	require.NotNil(t, m, "form has no CAPTCHA")
+	ca, cb, ok := a.Captcha.GetChallenge(m[1])
+	require.True(t, ok)
+	return url.Values{"captcha_id": {m[1]}, "captcha_answer": {strconv.Itoa(ca + cb)}}
+}
+
+func postForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestLoginRequiresCaptchaAfterFailures(t *testing.T) {
+	a := testApp(t)
+	a.Captcha = captcha.New(time.Minute)
+	a.CaptchaAfter = 3
+	a.LoginIPLimiter = ratelimit.New(10, time.Hour)
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		a.loginPage(w, req)
+		return w.Body.String()
+	}
+	assert.NotContains(t, get(), "captcha_answer", "no CAPTCHA before any failures")
+
+	for range 3 {
+		require.True(t, a.LoginIPLimiter.Allow("192.0.2.1"))
+	}
+	assert.Contains(t, get(), "captcha_answer")
+
+	w := postForm(a.login, "/login", url.Values{"identifier": {"alice"}, "password": {"hunter22"}})
+	assert.Contains(t, w.Body.String(), captchaErrorMessage)
+	assert.Contains(t, w.Body.String(), "captcha_answer", "a fresh challenge is offered")
+	assert.Equal(t, 3, a.LoginIPLimiter.Count("192.0.2.1"), "rejected before the password is checked")
+}
+
+func TestLoginAcceptsCorrectCaptcha(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.Captcha = captcha.New(time.Minute)
+	a.CaptchaAfter = 2
+	a.LoginAcctLimiter = ratelimit.New(10, time.Hour)
+
+	digest, err := bcrypt.GenerateFromPassword([]byte("hunter22"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: string(digest),
+	})
+	require.NoError(t, err)
+
+	var w *httptest.ResponseRecorder
+	for range 2 {
+		w = postForm(a.login, "/login", url.Values{"identifier": {"alice"}, "password": {"wrong"}})
+		require.Contains(t, w.Body.String(), "Invalid e-mail/username and/or password.")
+	}
+
+	form := solveCaptcha(t, a, w.Body.String())
+	form.Set("identifier", "alice")
+	form.Set("password", "hunter22")
+	w = postForm(a.login, "/login", form)
+	assert.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+}
+
+func TestForgotPasswordCaptcha(t *testing.T) {
+	a := testApp(t)
+	a.Captcha = captcha.New(time.Minute)
+	a.CaptchaAfter = 2
+	a.EmailIPLimiter = ratelimit.New(2, time.Hour)
+	for range 2 {
+		require.True(t, a.EmailIPLimiter.Allow("192.0.2.1"))
+	}
+
+	w := postForm(a.forgotPassword, "/forgot-password", url.Values{"email": {"alice@example.com"}})
+	assert.Contains(t, w.Body.String(), captchaErrorMessage)
+
+	form := solveCaptcha(t, a, w.Body.String())
+	form.Set("email", "alice@example.com")
+	w = postForm(a.forgotPassword, "/forgot-password", form)
+	assert.Contains(t, w.Body.String(), "If an account with that e-mail exists")
+}
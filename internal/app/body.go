@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the request body cap used when App.MaxBodyBytes
+// is not set.
+const DefaultMaxBodyBytes = 1 << 20
+
+func (a *App) maxBodyBytes() int64 {
+	if a.MaxBodyBytes > 0 {
+		return a.MaxBodyBytes
+	}
+	return DefaultMaxBodyBytes
+}
+
+// limitBody caps every request body. Requests that declare a larger
+// Content-Length are rejected up front; streamed bodies fail on read once
+// they cross the cap, so parseForm and decodeJSON never buffer more and
+// answer 413.
+func (a *App) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := a.maxBodyBytes()
+		if r.ContentLength > limit {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseForm parses the request form for a handler about to read it. It
+// answers 413 for a body over the cap and 400 for a malformed one, and
+// reports whether the handler should go on. Reading FormValue without it
+// would treat an oversized body as an empty form.
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseForm()
+	if err == nil {
+		return true
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+	} else {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}
+	return false
+}
+
+// decodeJSON decodes at most limit bytes of the request body into v,
+// leaving v as is for an empty body. It answers 413 for a body over the
+// limit and 400 for malformed JSON, and reports whether the handler
+// should go on.
+func decodeJSON(w http.ResponseWriter, r *http.Request, limit int64, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return true
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large.")
+	} else {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body.")
+	}
+	return false
+}
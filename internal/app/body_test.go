@@ -0,0 +1,89 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !parseForm(w, r) {
+			return
+		}
+		io.WriteString(w, r.FormValue("title"))
+	})
+}
+
+func TestLimitBodyRejectsOversizedForm(t *testing.T) {
+	a := &App{MaxBodyBytes: 64}
+	body := url.Values{"title": {strings.Repeat("x", 100)}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	a.limitBody(formHandler()).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestLimitBodyCapsStreamedBody(t *testing.T) {
+	a := &App{MaxBodyBytes: 64}
+	body := url.Values{"title": {strings.Repeat("x", 100)}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+
+	a.limitBody(formHandler()).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "ParseForm fails once the cap is crossed")
+}
+
+func TestLimitBodyAllowsNormalForm(t *testing.T) {
+	a := &App{}
+	body := url.Values{"title": {"Hello"}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	a.limitBody(formHandler()).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello", w.Body.String())
+}
+
+func TestParseFormRejectsMalformedBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader("title=%zz"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	formHandler().ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"spam"}`))
+	require.True(t, decodeJSON(httptest.NewRecorder(), r, 1024, &req))
+	assert.Equal(t, "spam", req.Reason)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	assert.True(t, decodeJSON(httptest.NewRecorder(), r, 1024, &req), "an empty body decodes to nothing")
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"`+strings.Repeat("x", 2048)+`"}`))
+	w := httptest.NewRecorder()
+	assert.False(t, decodeJSON(w, r, 1024, &req))
+	assertJSONError(t, w, http.StatusRequestEntityTooLarge)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":`))
+	w = httptest.NewRecorder()
+	assert.False(t, decodeJSON(w, r, 1024, &req))
+	assertJSONError(t, w, http.StatusBadRequest)
+}
@@ -27,6 +27,32 @@ func (a *App) campaignsPage(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "list campaigns", err)
 		return
 	}
+	signups, err := a.Queries.ListCampaignSignups(r.Context(), campaignSignupLimit)
+	if err != nil {
+		a.serverError(w, r, "list campaign signups", err)
+		return
+	}
+
+	a.render(w, "campaigns", CampaignsPageData{
+		Base:      a.baseData(r),
+		Campaigns: campaignRows(campaigns, signups),
+	})
+}
+
+// campaignSignupLimit is how many recent signups the campaigns page lists
+// under each campaign.
+const campaignSignupLimit = 10
+
+// campaignRows pairs each campaign with its recent signups, which users
+// reference by slug.
+func campaignRows(campaigns []store.ListCampaignsRow, signups []store.ListCampaignSignupsRow) []CampaignRow {
+	bySlug := make(map[string][]CampaignSignup)
+	for _, s := range signups {
+		bySlug[s.Campaign] = append(bySlug[s.Campaign], CampaignSignup{
+			Username:  s.Username,
+			CreatedAt: s.CreatedAt.Time,
+		})
+	}
 
 	rows := make([]CampaignRow, len(campaigns))
 	for i, c := range campaigns {
@@ -38,13 +64,10 @@ func (a *App) campaignsPage(w http.ResponseWriter, r *http.Request) {
 			Active:          c.Active,
 			RegisteredCount: c.RegisteredCount,
 			CreatedAt:       c.CreatedAt.Time,
+			Signups:         bySlug[c.Slug],
 		}
 	}
-
-	a.render(w, "campaigns", CampaignsPageData{
-		Base:      a.baseData(r),
-		Campaigns: rows,
-	})
+	return rows
 }
 
 func (a *App) createCampaign(w http.ResponseWriter, r *http.Request) {
@@ -54,8 +77,7 @@ func (a *App) createCampaign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.renderCampaignsPage(w, r, "", "", "", "Invalid request.")
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -112,22 +134,11 @@ func (a *App) createCampaign(w http.ResponseWriter, r *http.Request) {
 
 func (a *App) renderCampaignsPage(w http.ResponseWriter, r *http.Request, slug, welcomeMessage, sponsorUsername, errMsg string) {
 	campaigns, _ := a.Queries.ListCampaigns(r.Context())
-	rows := make([]CampaignRow, len(campaigns))
-	for i, c := range campaigns {
-		rows[i] = CampaignRow{
-			ID:              c.ID,
-			Slug:            c.Slug,
-			SponsorName:     c.SponsorName,
-			CreatedByName:   c.CreatedByName,
-			Active:          c.Active,
-			RegisteredCount: c.RegisteredCount,
-			CreatedAt:       c.CreatedAt.Time,
-		}
-	}
+	signups, _ := a.Queries.ListCampaignSignups(r.Context(), campaignSignupLimit)
 
 	a.render(w, "campaigns", CampaignsPageData{
 		Base:            a.baseData(r),
-		Campaigns:       rows,
+		Campaigns:       campaignRows(campaigns, signups),
 		Slug:            slug,
 		WelcomeMessage:  welcomeMessage,
 		SponsorUsername: sponsorUsername,
@@ -142,8 +153,7 @@ func (a *App) toggleCampaign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Redirect(w, r, "/mod/campaigns", http.StatusSeeOther)
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestCampaignRows(t *testing.T) {
+	now := time.Now()
+	campaigns := []store.ListCampaignsRow{
+		{ID: 1, Slug: "spring", RegisteredCount: 2},
+		{ID: 2, Slug: "summer", RegisteredCount: 0},
+	}
+	signups := []store.ListCampaignSignupsRow{
+		{Campaign: "spring", Username: "bob", CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}},
+		{Campaign: "spring", Username: "alice", CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}},
+		{Campaign: "gone", Username: "carol", CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}},
+	}
+
+	rows := campaignRows(campaigns, signups)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []CampaignSignup{
+		{Username: "bob", CreatedAt: now},
+		{Username: "alice", CreatedAt: now.Add(-time.Hour)},
+	}, rows[0].Signups)
+	assert.Empty(t, rows[1].Signups)
+}
+
+func TestRenderCampaignSignups(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "campaigns", CampaignsPageData{Campaigns: []CampaignRow{{
+		ID:              1,
+		Slug:            "spring",
+		RegisteredCount: 1,
+		Signups:         []CampaignSignup{{Username: "bob", CreatedAt: time.Now()}},
+	}}})
+	assert.Contains(t, w.Body.String(), `<a href="/u/bob">bob</a>`)
+}
+
+func TestCampaignSignups(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	mod, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	for _, slug := range []string{"spring", "summer"} {
+		_, err := a.Queries.CreateCampaign(ctx, store.CreateCampaignParams{
+			Slug: slug, SponsorID: mod.ID, CreatedByID: mod.ID,
+		})
+		require.NoError(t, err)
+	}
+	signup := func(name, campaign string, age time.Duration) {
+		t.Helper()
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, "UPDATE users SET campaign = $2, created_at = now() - $3::interval WHERE id = $1",
+			u.ID, campaign, fmt.Sprintf("%d seconds", int(age.Seconds())))
+		require.NoError(t, err)
+	}
+	for i, name := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8", "u9", "u10", "u11", "u12"} {
+		signup(name, "spring", time.Duration(i+1)*time.Hour)
+	}
+	signup("solo", "summer", time.Hour)
+
+	campaigns, err := a.Queries.ListCampaigns(ctx)
+	require.NoError(t, err)
+	signups, err := a.Queries.ListCampaignSignups(ctx, campaignSignupLimit)
+	require.NoError(t, err)
+	rows := campaignRows(campaigns, signups)
+
+	bySlug := make(map[string]CampaignRow)
+	for _, r := range rows {
+		bySlug[r.Slug] = r
+	}
+	assert.Equal(t, int64(12), bySlug["spring"].RegisteredCount)
+	require.Len(t, bySlug["spring"].Signups, campaignSignupLimit)
+	assert.Equal(t, "u1", bySlug["spring"].Signups[0].Username, "newest first")
+	assert.Equal(t, int64(1), bySlug["summer"].RegisteredCount)
+	require.Len(t, bySlug["summer"].Signups, 1)
+	assert.Equal(t, "solo", bySlug["summer"].Signups[0].Username)
+
+	req := httptest.NewRequest(http.MethodGet, "/mod/campaigns", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: mod.ID, IsModerator: true}}))
+	w := httptest.NewRecorder()
+	a.campaignsPage(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="/u/solo">solo</a>`)
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
 	"sort"
@@ -8,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"crow.watch/internal/auth"
@@ -22,19 +25,19 @@ const (
 	maxCommentLength  = 10000
 )
 
-var flagReasons = []string{"off-topic", "troll", "unkind", "spam"}
-
 type CommentNode struct {
 	ID          int64
 	StoryID     int64
 	UserID      int64
 	ParentID    int64
 	Username    string
+	AuthorState string // "banned" or "deleted" when the author's account is gone
 	Body        template.HTML
 	RawBody     string
 	Depth       int
 	Upvotes     int
 	Downvotes   int
+	Score       int // Upvotes - Downvotes, as shown and returned by the vote endpoints
 	HasUpvoted  bool
 	HasFlagged  bool
 	IsAuthor    bool
@@ -42,9 +45,14 @@ type CommentNode struct {
 	CanEdit     bool
 	IsDeleted   bool
 	IsUnread    bool
+	NextUnread  int64 // ID of the next unread comment in page order, or 0
 	IsLoggedIn  bool
+	CanFlag     bool // logged in and not on probation
 	IsMaxDepth  bool
+	IsContested bool
+	Collapsed   string // why the comment starts folded, or "" when it starts open
 	CreatedAt   time.Time
+	EditedAt    *time.Time // set once the author has changed the body
 	Children    []*CommentNode
 	FlagReasons []string
 	FlagCounts  []FlagCount
@@ -59,7 +67,101 @@ type buildTreeOpts struct {
 	flagCountsMap    map[int64][]FlagCount
 	lastVisit        time.Time
 	isLoggedIn       bool
+	canFlag          bool
 	storyCode        string
+	sort             string
+	flagReasons      []string
+	collapse         CommentCollapse
+	submitterFirst   bool // lift the submitter's top-level comments above the rest
+}
+
+// findComment returns the node with the given ID anywhere in the tree.
+func findComment(nodes []*CommentNode, id int64) *CommentNode {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n
+		}
+		if found := findComment(n.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// linkUnread walks the tree in page order, points each unread comment at
+// the one after it and returns the unread IDs in that order.
+func linkUnread(nodes []*CommentNode) []int64 {
+	var unread []*CommentNode
+	var walk func([]*CommentNode)
+	walk = func(nodes []*CommentNode) {
+		for _, n := range nodes {
+			if n.IsUnread {
+				unread = append(unread, n)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(nodes)
+
+	ids := make([]int64, len(unread))
+	for i, n := range unread {
+		ids[i] = n.ID
+		if i+1 < len(unread) {
+			n.NextUnread = unread[i+1].ID
+		}
+	}
+	return ids
+}
+
+// commentSortControversial orders siblings by rank.Controversy instead of
+// the default Wilson score.
+const commentSortControversial = "controversial"
+
+// parseCommentSort returns the comment sort mode requested by ?sort=,
+// or "" for the default ordering.
+func parseCommentSort(v string) string {
+	if v == commentSortControversial {
+		return v
+	}
+	return ""
+}
+
+// isContested reports whether a comment has drawn enough votes on both
+// sides to be flagged as contested in the UI.
+func isContested(upvotes, downvotes int) bool {
+	if min(upvotes, downvotes) < 2 {
+		return false
+	}
+	return float64(min(upvotes, downvotes))/float64(max(upvotes, downvotes)) >= 0.5
+}
+
+// CommentCollapse folds poorly received comments by default: those with a
+// net score at or below Score, or with at least Flags flags. A zero field
+// disables its threshold.
+type CommentCollapse struct {
+	Score int
+	Flags int
+}
+
+// DefaultCommentCollapse is used unless configured otherwise.
+var DefaultCommentCollapse = CommentCollapse{Score: -4, Flags: 5}
+
+// reason returns why a comment with the given score and flag counts
+// starts collapsed, or "" if it doesn't.
+func (c CommentCollapse) reason(score int, flags []FlagCount) string {
+	if c.Score < 0 && score <= c.Score {
+		return "comment score below threshold"
+	}
+	if c.Flags > 0 {
+		total := 0
+		for _, f := range flags {
+			total += f.Count
+		}
+		if total >= c.Flags {
+			return "comment flagged"
+		}
+	}
+	return ""
 }
 
 func buildCommentTree(rows []store.ListCommentsByStoryRow, opts buildTreeOpts) []*CommentNode {
@@ -98,11 +200,13 @@ func buildCommentTree(rows []store.ListCommentsByStoryRow, opts buildTreeOpts) [
 			StoryID:     r.StoryID,
 			UserID:      r.UserID,
 			Username:    r.Username,
+			AuthorState: authorState(r.UserBannedAt, r.UserDeletedAt),
 			Body:        body,
 			RawBody:     rawBody,
 			Depth:       int(r.Depth),
 			Upvotes:     int(r.Upvotes),
 			Downvotes:   int(r.Downvotes),
+			Score:       int(r.Upvotes - r.Downvotes),
 			HasUpvoted:  opts.votedMap[r.ID],
 			HasFlagged:  opts.flaggedMap[r.ID],
 			IsAuthor:    opts.isLoggedIn && r.UserID == opts.currentUserID,
@@ -111,12 +215,21 @@ func buildCommentTree(rows []store.ListCommentsByStoryRow, opts buildTreeOpts) [
 			IsDeleted:   isDeleted,
 			IsUnread:    isUnread,
 			IsLoggedIn:  opts.isLoggedIn,
+			CanFlag:     opts.canFlag,
 			IsMaxDepth:  int(r.Depth) >= maxCommentDepth,
+			IsContested: !isDeleted && isContested(int(r.Upvotes), int(r.Downvotes)),
 			CreatedAt:   r.CreatedAt.Time,
-			FlagReasons: flagReasons,
+			FlagReasons: opts.flagReasons,
 			FlagCounts:  opts.flagCountsMap[r.ID],
 			StoryCode:   opts.storyCode,
 		}
+		if !isDeleted {
+			node.Collapsed = opts.collapse.reason(node.Score, node.FlagCounts)
+		}
+		if r.EditedAt.Valid && !isDeleted {
+			t := r.EditedAt.Time
+			node.EditedAt = &t
+		}
 		if r.ParentID.Valid {
 			node.ParentID = r.ParentID.Int64
 		}
@@ -137,56 +250,98 @@ func buildCommentTree(rows []store.ListCommentsByStoryRow, opts buildTreeOpts) [
 		}
 	}
 
-	// Third pass: sort siblings by Wilson score descending, created_at ASC tiebreak
-	sortByWilson := func(nodes []*CommentNode) {
+	// Third pass: sort siblings by Wilson score descending (or controversy
+	// when requested), created_at ASC tiebreak
+	score := func(n *CommentNode) float64 {
+		return rank.WilsonScore(n.Upvotes, n.Downvotes)
+	}
+	if opts.sort == commentSortControversial {
+		score = func(n *CommentNode) float64 {
+			return rank.Controversy(n.Upvotes, n.Downvotes)
+		}
+	}
+	sortSiblings := func(nodes []*CommentNode) {
 		sort.SliceStable(nodes, func(i, j int) bool {
-			si := rank.WilsonScore(nodes[i].Upvotes, nodes[i].Downvotes)
-			sj := rank.WilsonScore(nodes[j].Upvotes, nodes[j].Downvotes)
+			si := score(nodes[i])
+			sj := score(nodes[j])
 			if si != sj {
 				return si > sj
 			}
 			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
 		})
 	}
-	sortByWilson(roots)
+	sortSiblings(roots)
+	if opts.submitterFirst {
+		sort.SliceStable(roots, func(i, j int) bool {
+			return submitterRoot(roots[i]) && !submitterRoot(roots[j])
+		})
+	}
 	for _, node := range nodeMap {
 		if len(node.Children) > 1 {
-			sortByWilson(node.Children)
+			sortSiblings(node.Children)
 		}
 	}
 
 	return roots
 }
 
+// submitterRoot reports whether the top-level comment n is a live comment
+// by the story submitter, which SubmitterFirst lists ahead of the rest.
+func submitterRoot(n *CommentNode) bool {
+	return n.IsSubmitter && !n.IsDeleted
+}
+
+// commentBodyError returns why body cannot be posted, or "" if it can.
+func commentBodyError(body string) string {
+	switch {
+	case body == "":
+		return "Comment cannot be empty."
+	case len(body) > maxCommentLength:
+		return fmt.Sprintf("Comment is too long (%d characters, the limit is %d).", len(body), maxCommentLength)
+	}
+	return ""
+}
+
 func (a *App) createComment(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
+		// The session may have expired while the comment was being written;
+		// keep the text for when the author is back on the story.
+		if code := r.PathValue("code"); a.validShortCode(code) && r.ParseForm() == nil {
+			a.stashCommentDraft(w, code, commentDraftFromForm(r))
+		}
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	story, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
-		http.NotFound(w, r)
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
 	body := strings.TrimSpace(r.FormValue("body"))
 	parentIDStr := r.FormValue("parent_id")
 
-	if body == "" || len(body) > maxCommentLength {
-		http.Redirect(w, r, storyPath(story.ShortCode, story.Title), http.StatusSeeOther)
+	if msg := commentBodyError(body); msg != "" {
+		draft := commentDraftFromForm(r)
+		a.stashCommentDraft(w, story.ShortCode, draft)
+		draft.Error = msg
+		a.renderStory(w, r, story, 0, draft)
 		return
 	}
 
@@ -220,6 +375,11 @@ func (a *App) createComment(w http.ResponseWriter, r *http.Request) {
 
 	qtx := a.Queries.WithTx(tx)
 
+	if err := qtx.LockStory(r.Context(), story.ID); err != nil {
+		a.serverError(w, r, "lock story", err)
+		return
+	}
+
 	comment, err := qtx.CreateComment(r.Context(), store.CreateCommentParams{
 		StoryID:  story.ID,
 		UserID:   current.User.ID,
@@ -237,15 +397,27 @@ func (a *App) createComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := qtx.AutoSubscribeStory(r.Context(), store.AutoSubscribeStoryParams{
+		UserID:  current.User.ID,
+		StoryID: story.ID,
+	}); err != nil {
+		a.serverError(w, r, "subscribe to story", err)
+		return
+	}
+
+	// This user's comment may neutralize a hide+flag penalty
+	if err := qtx.RecalculateStoryDownvotes(r.Context(), a.downvoteParams(story.ID)); err != nil {
+		a.serverError(w, r, "recalculate story downvotes", err)
+		return
+	}
+
 	if err := tx.Commit(r.Context()); err != nil {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
 
 	a.recordIP(r, current.User.ID, "comment")
-
-	// Recalculate downvotes: this user's comment may neutralize a hide+flag penalty
-	_ = a.Queries.RecalculateStoryDownvotes(r.Context(), story.ID)
+	a.clearCommentDraft(w, r, story.ShortCode)
 
 	http.Redirect(w, r, storyPath(story.ShortCode, story.Title)+"#comment-"+strconv.FormatInt(comment.ID, 10), http.StatusSeeOther)
 }
@@ -265,7 +437,7 @@ func (a *App) editComment(w http.ResponseWriter, r *http.Request) {
 
 	comment, err := a.Queries.GetCommentByID(r.Context(), commentID)
 	if err != nil {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
@@ -279,8 +451,7 @@ func (a *App) editComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -322,7 +493,7 @@ func (a *App) deleteComment(w http.ResponseWriter, r *http.Request) {
 
 	comment, err := a.Queries.GetCommentByID(r.Context(), commentID)
 	if err != nil {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
@@ -345,6 +516,11 @@ func (a *App) deleteComment(w http.ResponseWriter, r *http.Request) {
 
 	qtx := a.Queries.WithTx(tx)
 
+	if err := qtx.LockStory(r.Context(), comment.StoryID); err != nil {
+		a.serverError(w, r, "lock story", err)
+		return
+	}
+
 	if err := qtx.SoftDeleteComment(r.Context(), commentID); err != nil {
 		a.serverError(w, r, "soft delete comment", err)
 		return
@@ -355,14 +531,17 @@ func (a *App) deleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Deleting a comment may restore a hide+flag penalty
+	if err := qtx.RecalculateStoryDownvotes(r.Context(), a.downvoteParams(comment.StoryID)); err != nil {
+		a.serverError(w, r, "recalculate story downvotes", err)
+		return
+	}
+
 	if err := tx.Commit(r.Context()); err != nil {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
 
-	// Recalculate downvotes: deleting a comment may restore a hide+flag penalty
-	_ = a.Queries.RecalculateStoryDownvotes(r.Context(), comment.StoryID)
-
 	story, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ID: pgtype.Int8{Int64: comment.StoryID, Valid: true}})
 	if err != nil {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const commentDraftCookieName = "comment_draft"
+
+// commentDraftMaxAge is how long a stashed comment draft survives, long
+// enough to log back in after a session expired mid-comment.
+const commentDraftMaxAge = 30 * 60
+
+// commentDraftMaxBytes keeps the encoded draft under the 4KB browsers
+// allow per cookie. Longer drafts are not stashed.
+const commentDraftMaxBytes = 3500
+
+// commentDraftCookie is scoped to the story's paths, so each story keeps
+// its own draft and the cookie is not sent anywhere else.
+func (a *App) commentDraftCookie(code, value string) *http.Cookie {
+	opts := a.Sessions.CookieOptions()
+	return &http.Cookie{
+		Name:     commentDraftCookieName,
+		Value:    value,
+		Path:     "/x/" + code,
+		Domain:   opts.Domain,
+		MaxAge:   commentDraftMaxAge,
+		HttpOnly: true,
+		Secure:   opts.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// stashCommentDraft saves a comment that could not be posted so the
+// story's comment form can offer it again.
+func (a *App) stashCommentDraft(w http.ResponseWriter, code string, draft CommentDraft) {
+	if draft.Body == "" {
+		return
+	}
+	value := strconv.FormatInt(draft.ParentID, 10) + "." + base64.RawURLEncoding.EncodeToString([]byte(draft.Body))
+	if len(value) > commentDraftMaxBytes {
+		return
+	}
+	http.SetCookie(w, a.commentDraftCookie(code, value))
+}
+
+// clearCommentDraft drops the stashed draft once the comment is posted.
+func (a *App) clearCommentDraft(w http.ResponseWriter, r *http.Request, code string) {
+	if _, err := r.Cookie(commentDraftCookieName); err != nil {
+		return
+	}
+	expired := a.commentDraftCookie(code, "")
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
+}
+
+// commentDraftFromForm reads the comment being submitted.
+func commentDraftFromForm(r *http.Request) CommentDraft {
+	parentID, _ := strconv.ParseInt(r.FormValue("parent_id"), 10, 64)
+	return CommentDraft{Body: strings.TrimSpace(r.FormValue("body")), ParentID: parentID}
+}
+
+// stashedCommentDraft returns the draft saved for this story, if any. The
+// error message is not kept; the form only gets the text back.
+func stashedCommentDraft(r *http.Request) CommentDraft {
+	c, err := r.Cookie(commentDraftCookieName)
+	if err != nil {
+		return CommentDraft{}
+	}
+	parent, encoded, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return CommentDraft{}
+	}
+	parentID, err := strconv.ParseInt(parent, 10, 64)
+	if err != nil || parentID < 0 {
+		return CommentDraft{}
+	}
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return CommentDraft{}
+	}
+	return CommentDraft{Body: string(body), ParentID: parentID}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func postComment(code string, form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/x/"+code+"/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("code", code)
+	return req
+}
+
+func draftCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == commentDraftCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestCreateCommentLoggedOutStashesDraft(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.createComment(w, postComment("abc123", url.Values{"body": {"  half-written reply  "}, "parent_id": {"42"}}))
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	c := draftCookie(t, w)
+	require.NotNil(t, c)
+	assert.Equal(t, "/x/abc123", c.Path)
+	assert.Equal(t, commentDraftMaxAge, c.MaxAge)
+	assert.True(t, c.HttpOnly)
+
+	req := httptest.NewRequest(http.MethodGet, "/x/abc123/story", nil)
+	req.AddCookie(c)
+	assert.Equal(t, CommentDraft{Body: "half-written reply", ParentID: 42}, stashedCommentDraft(req))
+}
+
+func TestStashCommentDraftSkipsEmptyAndOversized(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.stashCommentDraft(w, "abc123", CommentDraft{})
+	a.stashCommentDraft(w, "abc123", CommentDraft{Body: strings.Repeat("a", maxCommentLength)})
+	assert.Nil(t, draftCookie(t, w))
+}
+
+func TestStashedCommentDraftRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "nodot", "x.aGk", "-1.aGk", "0.!!!"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: commentDraftCookieName, Value: value})
+		assert.Equal(t, CommentDraft{}, stashedCommentDraft(req), value)
+	}
+}
+
+func TestCreateCommentClearsDraft(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	user := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: "alice"}}
+
+	req := postComment("abc123", url.Values{"body": {"posted"}})
+	req.AddCookie(&http.Cookie{Name: commentDraftCookieName, Value: "0.cG9zdGVk"})
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w := httptest.NewRecorder()
+	a.createComment(w, req)
+
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	c := draftCookie(t, w)
+	require.NotNil(t, c, "a successful post expires the draft")
+	assert.Equal(t, -1, c.MaxAge)
+	assert.Equal(t, "/x/abc123", c.Path)
+}
@@ -0,0 +1,452 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/store"
+)
+
+func commentRow(id int64, parent int64, up, down int32, age time.Duration) store.ListCommentsByStoryRow {
+	row := store.ListCommentsByStoryRow{
+		ID:        id,
+		StoryID:   1,
+		UserID:    100 + id,
+		Body:      "comment",
+		Upvotes:   up,
+		Downvotes: down,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-age), Valid: true},
+		Username:  "user",
+	}
+	if parent != 0 {
+		row.ParentID = pgtype.Int8{Int64: parent, Valid: true}
+		row.Depth = 1
+	}
+	return row
+}
+
+func rootIDs(nodes []*CommentNode) []int64 {
+	ids := make([]int64, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func TestBuildCommentTreeDefaultSort(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, 3*time.Hour),
+		commentRow(2, 0, 10, 0, 2*time.Hour),
+		commentRow(3, 0, 6, 6, time.Hour),
+	}
+	roots := buildCommentTree(rows, buildTreeOpts{})
+	assert.Equal(t, []int64{2, 1, 3}, rootIDs(roots))
+}
+
+func TestBuildCommentTreeSubmitterFirst(t *testing.T) {
+	const submitter = 103
+	reply := commentRow(4, 2, 0, 3, time.Minute)
+	reply.UserID = submitter
+	deleted := commentRow(5, 0, 0, 0, time.Minute)
+	deleted.UserID = submitter
+	deleted.DeletedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, 3*time.Hour),
+		commentRow(2, 0, 10, 0, 2*time.Hour),
+		commentRow(3, 0, 6, 6, time.Hour),
+		reply,
+		deleted,
+	}
+
+	roots := buildCommentTree(rows, buildTreeOpts{storySubmitterID: submitter})
+	assert.Equal(t, []int64{2, 1, 3, 5}, rootIDs(roots), "off by default")
+
+	roots = buildCommentTree(rows, buildTreeOpts{storySubmitterID: submitter, submitterFirst: true})
+	assert.Equal(t, []int64{3, 2, 1, 5}, rootIDs(roots), "the submitter's root comes first regardless of score")
+	require.Len(t, roots[1].Children, 1)
+	assert.Equal(t, int64(4), roots[1].Children[0].ID, "replies stay under their parent")
+}
+
+func TestBuildCommentTreeControversialSort(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, 3*time.Hour),
+		commentRow(2, 0, 10, 0, 2*time.Hour),
+		commentRow(3, 0, 6, 6, time.Hour),
+		commentRow(4, 0, 9, 2, 4*time.Hour),
+		commentRow(5, 3, 1, 0, time.Minute),
+		commentRow(6, 3, 3, 3, 2*time.Minute),
+	}
+	roots := buildCommentTree(rows, buildTreeOpts{sort: commentSortControversial})
+	assert.Equal(t, []int64{3, 4, 1, 2}, rootIDs(roots), "uncontested comments keep created_at order")
+	require.Len(t, roots[0].Children, 2)
+	assert.Equal(t, []int64{6, 5}, rootIDs(roots[0].Children))
+}
+
+func TestParseCommentSort(t *testing.T) {
+	assert.Equal(t, "controversial", parseCommentSort("controversial"))
+	assert.Equal(t, "", parseCommentSort(""))
+	assert.Equal(t, "", parseCommentSort("bogus"))
+}
+
+func TestIsContested(t *testing.T) {
+	tests := []struct {
+		up, down int
+		want     bool
+	}{
+		{0, 0, false},
+		{10, 0, false},
+		{1, 1, false},
+		{2, 2, true},
+		{6, 4, true},
+		{10, 2, false},
+		{3, 6, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isContested(tt.up, tt.down), "%d up, %d down", tt.up, tt.down)
+	}
+}
+
+func TestRenderContestedBadge(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "story", StoryPageData{
+		Base:  Base{IsLoggedIn: true, Username: "alice"},
+		Story: StoryItem{ID: 1, ShortCode: "abc123", Title: "Story", CreatedAt: time.Now()},
+		Comments: []*CommentNode{
+			{ID: 1, Username: "bob", Body: "hot take", IsContested: true, CreatedAt: time.Now()},
+		},
+		CommentSort: commentSortControversial,
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, "comment__contested")
+	assert.Contains(t, body, `href="/x/abc123/story?sort=controversial"`)
+}
+
+func TestFindComment(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, 3*time.Hour),
+		commentRow(2, 1, 1, 0, 2*time.Hour),
+		commentRow(3, 2, 1, 0, time.Hour),
+		commentRow(4, 0, 1, 0, time.Hour),
+	}
+	roots := buildCommentTree(rows, buildTreeOpts{})
+
+	node := findComment(roots, 2)
+	require.NotNil(t, node)
+	assert.Equal(t, []int64{3}, rootIDs(node.Children))
+	assert.Nil(t, findComment(roots, 99))
+}
+
+func TestLinkUnread(t *testing.T) {
+	lastVisit := time.Now().Add(-2 * time.Hour)
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 5, 0, 3*time.Hour), // read
+		commentRow(2, 1, 1, 0, time.Hour),   // new
+		commentRow(3, 0, 1, 0, time.Hour),   // new, but the viewer's own
+		commentRow(4, 0, 0, 0, time.Hour),   // new
+		commentRow(5, 4, 0, 0, 30*time.Minute),
+	}
+	rows[2].UserID = 42
+
+	roots := buildCommentTree(rows, buildTreeOpts{
+		currentUserID: 42,
+		lastVisit:     lastVisit,
+		isLoggedIn:    true,
+	})
+	ids := linkUnread(roots)
+
+	assert.Equal(t, []int64{2, 4, 5}, ids)
+	assert.Equal(t, int64(4), findComment(roots, 2).NextUnread)
+	assert.Equal(t, int64(5), findComment(roots, 4).NextUnread)
+	assert.Zero(t, findComment(roots, 5).NextUnread)
+	assert.Zero(t, findComment(roots, 1).NextUnread)
+}
+
+func TestLinkUnreadFirstVisit(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{commentRow(1, 0, 0, 0, time.Minute)}
+	roots := buildCommentTree(rows, buildTreeOpts{currentUserID: 42, isLoggedIn: true})
+	assert.Empty(t, linkUnread(roots))
+}
+
+func TestRenderUnreadBanner(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "story", StoryPageData{
+		Base:  Base{IsLoggedIn: true, Username: "alice"},
+		Story: StoryItem{ID: 1, ShortCode: "abc123", Title: "Story", CreatedAt: time.Now()},
+		Comments: []*CommentNode{
+			{ID: 7, Username: "bob", Body: "new", IsUnread: true, NextUnread: 9, CreatedAt: time.Now()},
+			{ID: 9, Username: "carol", Body: "newer", IsUnread: true, CreatedAt: time.Now()},
+		},
+		UnreadIDs: []int64{7, 9},
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, "2 new comments since")
+	assert.Contains(t, body, `href="#comment-7"`)
+	assert.Contains(t, body, `href="#comment-9"`)
+}
+
+func TestBuildCommentTreeEditedAt(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, time.Hour),
+		commentRow(2, 0, 1, 0, time.Hour),
+		commentRow(3, 0, 1, 0, time.Hour),
+	}
+	edited := time.Now().Add(-time.Minute)
+	rows[0].EditedAt = pgtype.Timestamptz{Time: edited, Valid: true}
+	rows[2].EditedAt = pgtype.Timestamptz{Time: edited, Valid: true}
+	rows[2].DeletedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	roots := buildCommentTree(rows, buildTreeOpts{})
+	require.NotNil(t, findComment(roots, 1).EditedAt)
+	assert.True(t, findComment(roots, 1).EditedAt.Equal(edited))
+	assert.Nil(t, findComment(roots, 2).EditedAt)
+	assert.Nil(t, findComment(roots, 3).EditedAt, "deleted comments don't show an edit marker")
+}
+
+func TestRenderEditedComment(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	edited := time.Now().Add(-5 * time.Minute)
+
+	a.render(w, "story", StoryPageData{
+		Base:  Base{IsLoggedIn: true, Username: "alice"},
+		Story: StoryItem{ID: 1, ShortCode: "abc123", Title: "Story", CreatedAt: time.Now()},
+		Comments: []*CommentNode{
+			{ID: 1, Username: "bob", Body: "fixed typo", CreatedAt: time.Now().Add(-time.Hour), EditedAt: &edited},
+		},
+	})
+
+	assert.Contains(t, w.Body.String(), "5 minutes ago</time\n  >)")
+}
+
+func TestEditCommentSetsEditedAt(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool)}
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	comment, err := a.Queries.CreateComment(ctx, store.CreateCommentParams{
+		StoryID: story.ID, UserID: u.ID, Body: "frist",
+	})
+	require.NoError(t, err)
+	assert.False(t, comment.EditedAt.Valid)
+
+	form := url.Values{"body": {"first"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", strconv.FormatInt(comment.ID, 10))
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: u.ID}}))
+	w := httptest.NewRecorder()
+	a.editComment(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+
+	rows, err := a.Queries.ListCommentsByStory(ctx, story.ID)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "first", rows[0].Body)
+	assert.True(t, rows[0].EditedAt.Valid)
+
+	roots := buildCommentTree(rows, buildTreeOpts{})
+	assert.NotNil(t, roots[0].EditedAt)
+}
+
+func TestBuildCommentTreeScore(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 5, 2, time.Hour),
+		commentRow(2, 1, 0, 3, time.Minute),
+	}
+	nodes := buildCommentTree(rows, buildTreeOpts{})
+	require.Len(t, nodes, 1)
+	assert.Equal(t, 3, nodes[0].Score)
+	require.Len(t, nodes[0].Children, 1)
+	assert.Equal(t, -3, nodes[0].Children[0].Score)
+}
+
+func TestRenderCommentShowsNetScore(t *testing.T) {
+	a := testApp(t)
+	nodes := buildCommentTree([]store.ListCommentsByStoryRow{commentRow(1, 0, 5, 2, time.Hour)}, buildTreeOpts{})
+
+	w := httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{Story: StoryItem{ShortCode: "abc123", Title: "T"}, Comments: nodes})
+	assert.Regexp(t, `data-comment-id="1"\s*>\s*3\s*</span>`, w.Body.String())
+}
+
+func TestBuildCommentTreeCollapse(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 6, 3*time.Hour),
+		commentRow(2, 1, 3, 0, 2*time.Hour),
+		commentRow(3, 0, 4, 1, time.Hour),
+		commentRow(4, 0, 4, 0, time.Hour),
+		commentRow(5, 0, 0, 9, time.Hour),
+	}
+	rows[4].DeletedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	roots := buildCommentTree(rows, buildTreeOpts{
+		collapse:      CommentCollapse{Score: -4, Flags: 3},
+		flagCountsMap: map[int64][]FlagCount{4: {{Reason: "spam", Count: 2}, {Reason: "troll", Count: 1}}},
+	})
+
+	byID := make(map[int64]*CommentNode)
+	for _, n := range roots {
+		byID[n.ID] = n
+	}
+	assert.Equal(t, "comment score below threshold", byID[1].Collapsed)
+	require.Len(t, byID[1].Children, 1, "replies stay in the tree")
+	assert.Empty(t, byID[1].Children[0].Collapsed)
+	assert.Empty(t, byID[3].Collapsed)
+	assert.Equal(t, "comment flagged", byID[4].Collapsed)
+	assert.Empty(t, byID[5].Collapsed, "deleted comments are left as they are")
+}
+
+func TestCommentCollapseDisabled(t *testing.T) {
+	var c CommentCollapse
+	assert.Empty(t, c.reason(-100, []FlagCount{{Reason: "spam", Count: 100}}))
+}
+
+func TestRenderCollapsedComment(t *testing.T) {
+	a := testApp(t)
+	nodes := buildCommentTree([]store.ListCommentsByStoryRow{
+		commentRow(1, 0, 0, 5, time.Hour),
+		commentRow(2, 0, 5, 0, time.Hour),
+	}, buildTreeOpts{collapse: DefaultCommentCollapse})
+
+	w := httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{Story: StoryItem{ShortCode: "abc123", Title: "T"}, Comments: nodes})
+	body := w.Body.String()
+	assert.Regexp(t, `id="comment_folder_1"\s+class="comment_folder_button"\s+type="checkbox"\s+checked`, body)
+	assert.NotRegexp(t, `id="comment_folder_2"\s+class="comment_folder_button"\s+type="checkbox"\s+checked`, body)
+	assert.Contains(t, body, "comment score below threshold (show)")
+}
+
+func TestCommentBodyError(t *testing.T) {
+	assert.Empty(t, commentBodyError("hello"))
+	assert.NotEmpty(t, commentBodyError(""))
+	assert.Contains(t, commentBodyError(strings.Repeat("a", maxCommentLength+1)), "too long")
+}
+
+func TestRenderCommentDraft(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{
+		Base:  Base{IsLoggedIn: true, Username: "alice"},
+		Story: StoryItem{ShortCode: "abc123", Title: "T"},
+		Draft: CommentDraft{Body: "my <draft>", ParentID: 7, Error: "Comment is too long."},
+	})
+	body := w.Body.String()
+	assert.Contains(t, body, `<p class="error" role="alert">Comment is too long.</p>`)
+	assert.Contains(t, body, `<input type="hidden" name="parent_id" value="7" />`)
+	assert.Contains(t, body, `>my &lt;draft&gt;</textarea`)
+}
+
+func TestCreateCommentTooLongKeepsDraft(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	draft := "draft " + strings.Repeat("a", maxCommentLength)
+	form := url.Values{"body": {draft}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("code", "abc123")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: "alice"}}))
+	w := httptest.NewRecorder()
+	a.createComment(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "re-rendered rather than redirected")
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "Comment is too long")
+	assert.Contains(t, w.Body.String(), draft)
+
+	rows, err := a.Queries.ListCommentsByStory(ctx, story.ID)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestCommentFlagAppliesReasonWeight(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool), CommentFlags: flagreason.List{
+		{Name: "off-topic", Weight: 1},
+		{Name: "spam", Weight: 3},
+	}}
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	comment, err := a.Queries.CreateComment(ctx, store.CreateCommentParams{
+		StoryID: story.ID, UserID: u.ID, Body: "buy now",
+	})
+	require.NoError(t, err)
+
+	mod := auth.AuthenticatedUser{User: store.User{ID: u.ID, IsModerator: true}}
+	call := func(h http.HandlerFunc, body string) commentVoteResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", strconv.FormatInt(comment.ID, 10))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), mod))
+		w := httptest.NewRecorder()
+		h(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp commentVoteResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+
+	before := comment.Upvotes - comment.Downvotes
+	flagged := call(a.flagComment, `{"reason":"spam"}`)
+	assert.Equal(t, int(before)-3, flagged.Score, "a spam flag costs its weight")
+
+	a.CommentFlags = flagreason.List{{Name: "spam", Weight: 1}}
+	unflagged := call(a.unflagComment, "")
+	assert.Equal(t, int(before), unflagged.Score, "unflagging gives back the stored weight")
+}
@@ -2,9 +2,9 @@ package app
 
 import (
 	"encoding/json"
-	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/store"
@@ -18,23 +18,23 @@ type commentVoteResponse struct {
 func (a *App) upvoteComment(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
 	comment, err := a.Queries.GetCommentByID(r.Context(), commentID)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Comment not found.")
 		return
 	}
 	if comment.UserID == current.User.ID {
-		http.Error(w, "cannot vote on own comment", http.StatusForbidden)
+		writeJSONError(w, http.StatusForbidden, "You can't vote on your own comment.")
 		return
 	}
 
@@ -43,7 +43,7 @@ func (a *App) upvoteComment(w http.ResponseWriter, r *http.Request) {
 		CommentID: commentID,
 	})
 	if err != nil {
-		a.serverError(w, r, "create comment vote", err)
+		a.jsonServerError(w, r, "create comment vote", err)
 		return
 	}
 
@@ -54,13 +54,13 @@ func (a *App) upvoteComment(w http.ResponseWriter, r *http.Request) {
 func (a *App) unvoteComment(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -69,7 +69,7 @@ func (a *App) unvoteComment(w http.ResponseWriter, r *http.Request) {
 		CommentID: commentID,
 	})
 	if err != nil {
-		a.serverError(w, r, "delete comment vote", err)
+		a.jsonServerError(w, r, "delete comment vote", err)
 		return
 	}
 
@@ -80,33 +80,37 @@ func (a *App) unvoteComment(w http.ResponseWriter, r *http.Request) {
 func (a *App) flagComment(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
 	var req struct {
 		Reason string `json:"reason"`
 	}
-	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !decodeJSON(w, r, 1024, &req) {
 		return
 	}
 
-	validReason := false
-	for _, fr := range flagReasons {
-		if fr == req.Reason {
-			validReason = true
-			break
-		}
+	if !a.commentFlagReasons().Contains(req.Reason) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid flag reason.")
+		return
 	}
-	if !validReason {
-		http.Error(w, "invalid flag reason", http.StatusBadRequest)
+
+	if msg := flagIneligibility(current.User, a.FlagMinAge, time.Now()); msg != "" {
+		writeJSONError(w, http.StatusForbidden, msg)
+		return
+	}
+	if ends, err := a.probationEnds(r.Context(), current.User, time.Now()); err != nil {
+		a.jsonServerError(w, r, "check probation", err)
+		return
+	} else if !ends.IsZero() {
+		writeJSONError(w, http.StatusForbidden, probationFlagMessage)
 		return
 	}
 
@@ -114,9 +118,10 @@ func (a *App) flagComment(w http.ResponseWriter, r *http.Request) {
 		UserID:    current.User.ID,
 		CommentID: commentID,
 		Reason:    req.Reason,
+		Weight:    a.commentFlagReasons().Weight(req.Reason),
 	})
 	if err != nil {
-		a.serverError(w, r, "create comment flag", err)
+		a.jsonServerError(w, r, "create comment flag", err)
 		return
 	}
 
@@ -127,13 +132,13 @@ func (a *App) flagComment(w http.ResponseWriter, r *http.Request) {
 func (a *App) unflagComment(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -142,7 +147,7 @@ func (a *App) unflagComment(w http.ResponseWriter, r *http.Request) {
 		CommentID: commentID,
 	})
 	if err != nil {
-		a.serverError(w, r, "delete comment flag", err)
+		a.jsonServerError(w, r, "delete comment flag", err)
 		return
 	}
 
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"compress/gzip"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// compressMinBytes is the smallest body worth compressing; below it the
+// gzip framing eats most of the savings.
+const compressMinBytes = 1024
+
+// compressibleTypes are the media types compress gzips. Images, archives
+// and other already-compressed formats pass through untouched.
+var compressibleTypes = []string{
+	"text/html",
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"image/svg+xml",
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return slices.Contains(compressibleTypes, strings.ToLower(strings.TrimSpace(mediaType)))
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip,
+// honoring q=0 as a refusal.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// compress gzips text responses for clients that accept it. The decision
+// waits until compressMinBytes are buffered or the handler finishes, so
+// small bodies and ones that set their own Content-Encoding go out as
+// written.
+func (a *App) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, accepts: acceptsGzip(r.Header.Get("Accept-Encoding"))}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	accepts bool
+	status  int
+	buf     []byte
+	started bool
+	gz      *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.started || cw.status != 0 {
+		return
+	}
+	cw.status = code
+	if !cw.eligible() {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.started {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) >= compressMinBytes {
+			cw.start(true)
+		}
+		return len(p), nil
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// eligible reports whether the response may still be compressed, judging
+// by what the handler has set so far. An unset Content-Type is sniffed
+// from the body once it is known.
+func (cw *compressWriter) eligible() bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	if cw.status < 200 {
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	return ct == "" || compressible(ct)
+}
+
+// start writes the header and any buffered body, gzipped when wanted and
+// the response turned out to be compressible.
+func (cw *compressWriter) start(want bool) {
+	cw.started = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	eligible := cw.eligible()
+	if eligible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if want && eligible && cw.accepts {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		// The compressed bytes differ from the identity representation,
+		// so a strong validator would no longer be accurate.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.gz = gzip.NewWriter(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) > 0 {
+		if cw.gz != nil {
+			cw.gz.Write(cw.buf)
+		} else {
+			cw.ResponseWriter.Write(cw.buf)
+		}
+		cw.buf = nil
+	}
+}
+
+func (cw *compressWriter) close() {
+	if !cw.started && cw.status != 0 {
+		cw.start(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+	}
+}
+
+// Flush sends what has been written so far, compressing it if the
+// response qualifies, so streamed responses are not held back.
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.started {
+		cw.start(true)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
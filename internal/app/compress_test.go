@@ -0,0 +1,117 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compressTestHandler(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, body)
+	})
+}
+
+func serveCompressed(t *testing.T, h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	a := &App{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	a.compress(h).ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressGzipClient(t *testing.T) {
+	body := strings.Repeat("<p>crow</p>", 500)
+	w := serveCompressed(t, compressTestHandler("text/html; charset=utf-8", body), "br, gzip")
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+	assert.Less(t, w.Body.Len(), len(body))
+
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	got, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+}
+
+func TestCompressPlainClient(t *testing.T) {
+	body := strings.Repeat("<p>crow</p>", 500)
+	for _, ae := range []string{"", "identity", "gzip;q=0"} {
+		w := serveCompressed(t, compressTestHandler("text/html; charset=utf-8", body), ae)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), ae)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), ae)
+		assert.Equal(t, `"v1"`, w.Header().Get("ETag"), ae)
+		assert.Equal(t, body, w.Body.String(), ae)
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	large := strings.Repeat("x", 4*compressMinBytes)
+	tests := []struct {
+		name string
+		h    http.Handler
+	}{
+		{"small body", compressTestHandler("application/json", `{"ok":true}`)},
+		{"image", compressTestHandler("image/png", large)},
+		{"gzip file", compressTestHandler("application/gzip", large)},
+		{"already encoded", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, large)
+		})},
+		{"not modified", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotModified)
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCompressed(t, tt.h, "gzip")
+			assert.NotEqual(t, "gzip", w.Header().Get("Content-Encoding"))
+		})
+	}
+}
+
+func TestCompressSniffsContentType(t *testing.T) {
+	body := "<!DOCTYPE html>" + strings.Repeat("<p>crow</p>", 500)
+	w := serveCompressed(t, compressTestHandler("", body), "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	assert.True(t, acceptsGzip("gzip"))
+	assert.True(t, acceptsGzip("deflate, gzip;q=0.5"))
+	assert.True(t, acceptsGzip("GZIP"))
+	assert.False(t, acceptsGzip(""))
+	assert.False(t, acceptsGzip("br"))
+	assert.False(t, acceptsGzip("gzip;q=0"))
+}
+
+func TestStaticCSSIsCompressed(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/static/css/base.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	a.Routes().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+}
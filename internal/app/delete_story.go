@@ -20,15 +20,15 @@ func (a *App) deleteStory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -41,8 +41,7 @@ func (a *App) deleteStory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -65,14 +64,15 @@ func (a *App) deleteStory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
 		ModeratorID: current.User.ID,
 		Action:      "story.delete",
 		TargetType:  "story",
 		TargetID:    row.ID,
 		Reason:      reason,
 		Metadata:    []byte("{}"),
-	}); err != nil {
+	})
+	if err != nil {
 		a.serverError(w, r, "create moderation log", err)
 		return
 	}
@@ -81,6 +81,7 @@ func (a *App) deleteStory(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
+	a.notifyModeration(current.User.Username, entry)
 
 	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
 }
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// domainPage serves the hotness-ranked story listing for a single domain
+// (GET /d/{domain} and GET /d/{domain}/page/{page}).
+func (a *App) domainPage(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("domain")
+	if name == "" {
+		a.notFound(w, r)
+		return
+	}
+
+	domain, err := a.Queries.GetDomainByName(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get domain by name", err)
+		return
+	}
+
+	page := parsePage(r)
+	data := DomainPageData{
+		Base:        a.baseData(r),
+		DomainID:    domain.ID,
+		Domain:      domain.Domain,
+		CurrentPage: page,
+		PagePath:    fmt.Sprintf("/d/%s/page", domain.Domain),
+	}
+
+	if current, ok := auth.UserFromContext(r.Context()); ok {
+		data.IsSubscribed, err = a.Queries.IsSubscribedToDomain(r.Context(), store.IsSubscribedToDomainParams{
+			UserID:   current.User.ID,
+			DomainID: domain.ID,
+		})
+		if err != nil {
+			a.serverError(w, r, "check domain subscription", err)
+			return
+		}
+	}
+
+	opts, reveal := a.scoreFilter(r, data.Base, storyListOpts{rankByHotness: true, filterHidden: true})
+	data.ShowLowScore = reveal
+
+	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
+		DomainID:    pgtype.Int8{Int64: domain.ID, Valid: true},
+		HideDeleted: true,
+		StoryLimit:  500,
+	}, opts)
+	if err != nil {
+		a.serverError(w, r, "load stories", err)
+		return
+	}
+
+	data.Stories = stories
+	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, scoreQuery(reveal), data.Base.IsLoggedIn)
+	a.render(w, "domain", data)
+}
+
+// feed serves the newest stories from the domains the viewer follows
+// (GET /feed and GET /feed/page/{page}).
+func (a *App) feed(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	page := parsePage(r)
+	data := HomePageData{
+		Base:        a.baseData(r),
+		CurrentPage: page,
+		PagePath:    "/feed/page",
+	}
+
+	hiddenTagIDs, err := a.Queries.ListUserHiddenTagIDs(r.Context(), current.User.ID)
+	if err != nil {
+		a.serverError(w, r, "get hidden tags", err)
+		return
+	}
+
+	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
+		SubscriberID: pgtype.Int8{Int64: current.User.ID, Valid: true},
+		HideDeleted:  true,
+		HiddenTagIds: hiddenTagIDs,
+	}, storyListOpts{filterHidden: true, filterDuplicates: true, paged: true})
+	if err != nil {
+		a.serverError(w, r, "load stories", err)
+		return
+	}
+
+	data.Stories = stories
+	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, nil, false)
+	a.render(w, "home", data)
+}
+
+func (a *App) subscribeDomain(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
+		return
+	}
+
+	domainID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
+		return
+	}
+
+	if _, err := a.Queries.GetDomainByID(r.Context(), domainID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Domain not found.")
+			return
+		}
+		a.jsonServerError(w, r, "get domain", err)
+		return
+	}
+
+	if err := a.Queries.SubscribeDomain(r.Context(), store.SubscribeDomainParams{
+		UserID:   current.User.ID,
+		DomainID: domainID,
+	}); err != nil {
+		a.jsonServerError(w, r, "subscribe domain", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`))
+}
+
+func (a *App) unsubscribeDomain(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
+		return
+	}
+
+	domainID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
+		return
+	}
+
+	if err := a.Queries.UnsubscribeDomain(r.Context(), store.UnsubscribeDomainParams{
+		UserID:   current.User.ID,
+		DomainID: domainID,
+	}); err != nil {
+		a.jsonServerError(w, r, "unsubscribe domain", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`))
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestFeedRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+	w := httptest.NewRecorder()
+	a.feed(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestDomainSubscribeToggle(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	viewer := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+	domain, err := a.Queries.GetOrCreateDomain(ctx, "example.com")
+	require.NoError(t, err)
+
+	post := func(handler http.HandlerFunc) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.SetPathValue("id", strconv.FormatInt(domain.ID, 10))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), viewer))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	subscribed := func() bool {
+		t.Helper()
+		ok, err := a.Queries.IsSubscribedToDomain(ctx, store.IsSubscribedToDomainParams{UserID: u.ID, DomainID: domain.ID})
+		require.NoError(t, err)
+		return ok
+	}
+
+	post(a.subscribeDomain)
+	assert.True(t, subscribed())
+	post(a.subscribeDomain)
+	assert.True(t, subscribed(), "subscribing twice is a no-op")
+
+	req := httptest.NewRequest(http.MethodGet, "/d/Example.com", nil)
+	req.SetPathValue("domain", "Example.com")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), viewer))
+	w := httptest.NewRecorder()
+	a.domainPage(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-subscribed="true"`)
+
+	post(a.unsubscribeDomain)
+	assert.False(t, subscribed())
+}
+
+func TestSubscribeUnknownDomain(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.SetPathValue("id", "999999")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: u.ID}}))
+	w := httptest.NewRecorder()
+	a.subscribeDomain(w, req)
+	assertJSONError(t, w, http.StatusNotFound)
+}
+
+func TestFeedIncludesFollowedDomains(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	viewer := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+	newStory := func(code, host string) store.Domain {
+		t.Helper()
+		d, err := a.Queries.GetOrCreateDomain(ctx, host)
+		require.NoError(t, err)
+		_, err = a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    u.ID,
+			DomainID:  pgtype.Int8{Int64: d.ID, Valid: true},
+			Url:       pgtype.Text{String: "https://" + host + "/" + code, Valid: true},
+			Title:     "Story " + code,
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	followed := newStory("blog01", "blog.example")
+	newStory("other1", "other.example")
+	require.NoError(t, a.Queries.SubscribeDomain(ctx, store.SubscribeDomainParams{UserID: u.ID, DomainID: followed.ID}))
+
+	req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), viewer))
+	w := httptest.NewRecorder()
+	a.feed(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "Story blog01")
+	assert.Contains(t, body, `href="/d/blog.example"`)
+	assert.NotContains(t, body, "Story other1")
+}
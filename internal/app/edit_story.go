@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -16,23 +17,49 @@ import (
 	"crow.watch/internal/store"
 )
 
+// storyEditWindowMinutes is how long a submitter can fix the title and
+// tags of their own story.
+const storyEditWindowMinutes = 30
+
+type storyEditRole int
+
+const (
+	storyEditNone storyEditRole = iota
+	storyEditAuthor
+	storyEditModerator
+)
+
+// storyEditRoleFor decides how user may edit a story. Moderators can edit
+// every field at any time and must give a reason; the submitter can fix
+// the title and tags, or switch between a link and a text post, within
+// the edit window, without a reason or a moderation log entry.
+func storyEditRoleFor(user store.User, authorID int64, createdAt, now time.Time) storyEditRole {
+	if user.IsModerator {
+		return storyEditModerator
+	}
+	if user.ID == authorID && now.Sub(createdAt) < storyEditWindowMinutes*time.Minute {
+		return storyEditAuthor
+	}
+	return storyEditNone
+}
+
 func (a *App) editStoryPage(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
-	if !ok || !current.User.IsModerator {
+	if !ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -40,7 +67,12 @@ func (a *App) editStoryPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if row.DeletedAt.Valid {
-		http.NotFound(w, r)
+		a.notFound(w, r)
+		return
+	}
+
+	if storyEditRoleFor(current.User, row.UserID, row.CreatedAt.Time, time.Now()) == storyEditNone {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
@@ -60,43 +92,83 @@ func (a *App) editStoryPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tab := "link"
-	if row.Body.Valid {
-		tab = "text"
-	}
+	convertTo := storyKindChange(row, r.URL.Query().Get("kind"))
 
 	a.render(w, "submit", SubmitPageData{
 		Base:                 a.baseData(r),
-		Tab:                  tab,
+		Tab:                  editTab(row, convertTo),
 		Title:                row.Title,
 		Body:                 row.Body.String,
 		URL:                  row.Url.String,
+		CanonicalURL:         row.CanonicalUrl.String,
 		TagGroups:            toTagGroups(allTags, current.User.IsModerator),
 		Selected:             selectedIDs,
 		EditMode:             true,
 		EditCode:             code,
+		AuthorEdit:           !current.User.IsModerator,
+		ConvertTo:            convertTo,
 		DuplicateOfShortCode: row.DuplicateOfShortCode.String,
 		DuplicateOfTitle:     row.DuplicateOfTitle.String,
+		PinnedUntil:          activePin(row.PinnedUntil, time.Now()),
+		IsPick:               row.PickedAt.Valid,
+		IsHidden:             row.HiddenAt.Valid,
+		Score:                int(row.Upvotes - row.Downvotes + row.AdminAdjustment),
+		ScoreAdjustment:      int(row.AdminAdjustment),
 	})
 }
 
+// storyKindChange returns the kind ("link" or "text") an edit converts row
+// to when kind asks for the other one, or "" when the kind stays.
+func storyKindChange(row store.GetStoryRow, kind string) string {
+	switch {
+	case kind == "text" && row.Url.Valid:
+		return "text"
+	case kind == "link" && !row.Url.Valid:
+		return "link"
+	}
+	return ""
+}
+
+// editTab picks the submit form tab for editing row, or for converting it
+// to the kind convertTo.
+func editTab(row store.GetStoryRow, convertTo string) string {
+	switch {
+	case convertTo != "":
+		return convertTo
+	case row.Url.Valid && row.Body.Valid:
+		return "show"
+	case row.Body.Valid:
+		return "text"
+	}
+	return "link"
+}
+
+// activePin returns the pin expiry if the story is currently pinned.
+func activePin(pinnedUntil pgtype.Timestamptz, now time.Time) *time.Time {
+	if !pinnedUntil.Valid || !pinnedUntil.Time.After(now) {
+		return nil
+	}
+	t := pinnedUntil.Time
+	return &t
+}
+
 func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
-	if !ok || !current.User.IsModerator {
+	if !ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -104,70 +176,49 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if row.DeletedAt.Valid {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	role := storyEditRoleFor(current.User, row.UserID, row.CreatedAt.Time, time.Now())
+	if role == storyEditNone {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if !parseForm(w, r) {
 		return
 	}
 
 	rawURL := strings.TrimSpace(r.FormValue("url"))
-	title := strings.TrimSpace(r.FormValue("title"))
+	rawCanonical := strings.TrimSpace(r.FormValue("canonical_url"))
+	title := cleanText(r.FormValue("title"))
 	body := strings.TrimSpace(r.FormValue("body"))
 	reason := strings.TrimSpace(r.FormValue("reason"))
 	tagIDStrs := r.Form["tags"]
 
+	convertTo := storyKindChange(row, r.FormValue("kind"))
 	isLinkPost := row.Url.Valid
-
-	errs := make(map[string]string)
-
-	if title == "" {
-		errs["title"] = "Title is required."
-	} else if len(title) > 150 {
-		errs["title"] = "Title must be 150 characters or fewer."
-	}
-
-	if row.Body.Valid {
-		if body == "" {
-			errs["body"] = "Text body is required for text posts."
-		} else if len(body) > 10000 {
-			errs["body"] = "Text body must be 10,000 characters or fewer."
-		}
-	}
-
-	// Validate URL for link posts
-	var urlResult link.CleanResult
-	if isLinkPost {
-		if rawURL == "" {
-			errs["url"] = "URL is required."
-		} else {
-			var err error
-			urlResult, err = link.Clean(rawURL)
-			if err != nil {
-				var ve *link.ValidationError
-				if errors.As(err, &ve) {
-					errs["url"] = ve.Message
-				} else {
-					errs["url"] = "Invalid URL."
-				}
-			}
+	isModEdit := role == storyEditModerator
+	// Converting replaces the URL or body wholesale, so the field-level
+	// edits below only apply when the kind stays.
+	editsFields := isModEdit && convertTo == ""
+
+	urlResult, errs := validateStoryEdit(a.LinkPolicy, role, row, convertTo, title, body, rawURL, reason)
+
+	var canonical link.CleanResult
+	if editsFields && isLinkPost && rawCanonical != "" {
+		var msg string
+		canonical, msg = validateCanonicalURL(a.LinkPolicy, rawCanonical)
+		if msg != "" {
+			errs["canonical_url"] = msg
 		}
 	}
 
-	if reason == "" {
-		errs["reason"] = "Reason is required."
-	} else if len(reason) > 500 {
-		errs["reason"] = "Reason must be 500 characters or fewer."
-	}
-
-	var tagIDs []int64
-	for _, s := range tagIDStrs {
-		id, err := strconv.ParseInt(s, 10, 64)
-		if err == nil {
-			tagIDs = append(tagIDs, id)
-		}
+	tagIDs, err := a.formTagIDs(r.Context(), tagIDStrs)
+	if err != nil {
+		a.serverError(w, r, "resolve tag names", err)
+		return
 	}
 
 	if len(errs) > 0 {
@@ -181,20 +232,6 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasNonMedia := false
-	for _, tag := range tags {
-		if !tag.IsMedia {
-			hasNonMedia = true
-		}
-	}
-
-	if !hasNonMedia {
-		errs["tags"] = "At least one non-media tag is required."
-		a.renderEditError(w, r, current, code, row, title, body, reason, rawURL, tagIDs, errs, "")
-		return
-	}
-
-	// Compute diff
 	oldTagRows, err := a.Queries.GetStoryTags(r.Context(), row.ID)
 	if err != nil {
 		a.serverError(w, r, "get story tags", err)
@@ -207,17 +244,42 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 		oldTagNames[t.ID] = t.Tag
 	}
 
+	if msg := checkEditTags(tags, oldTagIDs, isModEdit); msg != "" {
+		errs["tags"] = msg
+		a.renderEditError(w, r, current, code, row, title, body, reason, rawURL, tagIDs, errs, "")
+		return
+	}
+
+	// Converting to a link posts that URL as far as duplicates go, so it
+	// gets the same check as a new submission.
+	if convertTo == "link" {
+		existing, err := a.findDuplicate(r.Context(), urlResult.Normalized, time.Now())
+		if err == nil && existing.ID != row.ID {
+			data := a.editFormData(r, current, code, row, title, body, reason, rawURL, tagIDs, nil, duplicateMessage(existing))
+			data.DuplicateURL = storyPath(existing.ShortCode, existing.Title)
+			a.render(w, "submit", data)
+			return
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			a.serverError(w, r, "check duplicate url", err)
+			return
+		}
+	}
+
+	// Compute diff
+
 	newTagNames := make(map[int64]string)
 	for _, t := range tags {
 		newTagNames[t.ID] = t.Tag
 	}
 
 	titleChanged := title != row.Title
-	bodyChanged := row.Body.Valid && body != row.Body.String
+	bodyChanged := editsFields && row.Body.Valid && body != row.Body.String
 	tagsChanged := !equalSortedIDs(oldTagIDs, tagIDs)
-	urlChanged := isLinkPost && urlResult.Cleaned != row.Url.String
+	urlChanged := editsFields && isLinkPost && urlResult.Cleaned != row.Url.String
+	canonicalChanged := editsFields && isLinkPost && canonical.Cleaned != row.CanonicalUrl.String
 
-	if !titleChanged && !bodyChanged && !tagsChanged && !urlChanged {
+	if convertTo == "" && !titleChanged && !bodyChanged && !tagsChanged && !urlChanged && !canonicalChanged {
 		http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
 		return
 	}
@@ -225,11 +287,25 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 	metadata := make(map[string]any)
 	var actions []string
 
+	switch convertTo {
+	case "text":
+		actions = append(actions, "story.convert_to_text")
+		metadata["url_before"] = row.Url.String
+	case "link":
+		actions = append(actions, "story.convert_to_link")
+		metadata["url_after"] = urlResult.Cleaned
+	}
+
 	if urlChanged {
 		actions = append(actions, "story.edit_url")
 		metadata["url_before"] = row.Url.String
 		metadata["url_after"] = urlResult.Cleaned
 	}
+	if canonicalChanged {
+		actions = append(actions, "story.edit_canonical_url")
+		metadata["canonical_url_before"] = row.CanonicalUrl.String
+		metadata["canonical_url_after"] = canonical.Cleaned
+	}
 	if titleChanged {
 		actions = append(actions, "story.edit_title")
 		metadata["title_before"] = row.Title
@@ -238,17 +314,17 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 	if bodyChanged {
 		actions = append(actions, "story.edit_body")
 	}
+	var oldNames, newNames []string
+	for _, id := range oldTagIDs {
+		oldNames = append(oldNames, oldTagNames[id])
+	}
+	for _, id := range tagIDs {
+		if name, ok := newTagNames[id]; ok {
+			newNames = append(newNames, name)
+		}
+	}
 	if tagsChanged {
 		actions = append(actions, "story.edit_tags")
-		var oldNames, newNames []string
-		for _, id := range oldTagIDs {
-			oldNames = append(oldNames, oldTagNames[id])
-		}
-		for _, id := range tagIDs {
-			if name, ok := newTagNames[id]; ok {
-				newNames = append(newNames, name)
-			}
-		}
 		metadata["tags_before"] = oldNames
 		metadata["tags_after"] = newNames
 	}
@@ -268,7 +344,35 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 
 	qtx := a.Queries.WithTx(tx)
 
-	if urlChanged {
+	if convertTo == "text" {
+		// The body goes in first: a story without a URL needs one.
+		if err := qtx.UpdateStoryBody(r.Context(), store.UpdateStoryBodyParams{
+			Body: pgtype.Text{String: body, Valid: true},
+			ID:   row.ID,
+		}); err != nil {
+			a.serverError(w, r, "update story body", err)
+			return
+		}
+		old, err := qtx.ClearStoryLink(r.Context(), row.ID)
+		if err != nil {
+			a.serverError(w, r, "clear story link", err)
+			return
+		}
+		if old.DomainID.Valid {
+			if err := qtx.DecrementDomainStoryCount(r.Context(), old.DomainID.Int64); err != nil {
+				a.serverError(w, r, "decrement domain story count", err)
+				return
+			}
+		}
+		if old.OriginID.Valid {
+			if err := qtx.DecrementOriginStoryCount(r.Context(), old.OriginID.Int64); err != nil {
+				a.serverError(w, r, "decrement origin story count", err)
+				return
+			}
+		}
+	}
+
+	if urlChanged || convertTo == "link" {
 		domain, err := a.Queries.GetOrCreateDomain(r.Context(), urlResult.Domain)
 		if err != nil {
 			a.serverError(w, r, "get or create domain", err)
@@ -305,6 +409,36 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 			a.serverError(w, r, "update story url", err)
 			return
 		}
+
+		if convertTo == "link" {
+			if err := qtx.IncrementDomainStoryCount(r.Context(), domain.ID); err != nil {
+				a.serverError(w, r, "increment domain story count", err)
+				return
+			}
+			if urlParams.OriginID.Valid {
+				if err := qtx.IncrementOriginStoryCount(r.Context(), urlParams.OriginID.Int64); err != nil {
+					a.serverError(w, r, "increment origin story count", err)
+					return
+				}
+			}
+			// A link post carries no text; the URL replaces it.
+			if err := qtx.UpdateStoryBody(r.Context(), store.UpdateStoryBodyParams{ID: row.ID}); err != nil {
+				a.serverError(w, r, "clear story body", err)
+				return
+			}
+		}
+	}
+
+	if canonicalChanged {
+		// An empty canonical URL clears the override.
+		if err := qtx.UpdateStoryCanonicalURL(r.Context(), store.UpdateStoryCanonicalURLParams{
+			CanonicalUrl:           pgtype.Text{String: canonical.Cleaned, Valid: canonical.Cleaned != ""},
+			NormalizedCanonicalUrl: pgtype.Text{String: canonical.Normalized, Valid: canonical.Normalized != ""},
+			ID:                     row.ID,
+		}); err != nil {
+			a.serverError(w, r, "update story canonical url", err)
+			return
+		}
 	}
 
 	if titleChanged {
@@ -343,23 +477,51 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	actionStr := strings.Join(actions, ",")
-	if _, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
-		ModeratorID: current.User.ID,
-		Action:      actionStr,
-		TargetType:  "story",
-		TargetID:    row.ID,
-		Reason:      reason,
-		Metadata:    metadataJSON,
-	}); err != nil {
-		a.serverError(w, r, "create moderation log", err)
+	revised := storySnapshot{Title: title, URL: row.Url, Body: row.Body, Tags: newNames}
+	if urlChanged || convertTo == "link" {
+		revised.URL = pgtype.Text{String: urlResult.Cleaned, Valid: true}
+	}
+	if bodyChanged || convertTo == "text" {
+		revised.Body = pgtype.Text{String: body, Valid: true}
+	}
+	switch convertTo {
+	case "text":
+		revised.URL = pgtype.Text{}
+	case "link":
+		revised.Body = pgtype.Text{}
+	}
+	original := storySnapshot{Title: row.Title, URL: row.Url, Body: row.Body, Tags: oldNames}
+	if err := recordStoryRevision(r.Context(), qtx, row, original, revised, current.User.ID, time.Now()); err != nil {
+		a.serverError(w, r, "record story revision", err)
 		return
 	}
 
+	// Authors fixing their own story within the edit window are not
+	// moderating, so nothing is logged for them.
+	var entry store.ModerationLog
+	if isModEdit {
+		actionStr := strings.Join(actions, ",")
+		entry, err = qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+			ModeratorID: current.User.ID,
+			Action:      actionStr,
+			TargetType:  "story",
+			TargetID:    row.ID,
+			Reason:      reason,
+			Metadata:    metadataJSON,
+		})
+		if err != nil {
+			a.serverError(w, r, "create moderation log", err)
+			return
+		}
+	}
+
 	if err := tx.Commit(r.Context()); err != nil {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
+	if isModEdit {
+		a.notifyModeration(current.User.Username, entry)
+	}
 
 	displayTitle := title
 	if !titleChanged {
@@ -369,32 +531,157 @@ func (a *App) editStory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) renderEditError(w http.ResponseWriter, r *http.Request, current auth.AuthenticatedUser, code string, row store.GetStoryRow, title, body, reason, rawURL string, selectedIDs []int64, errs map[string]string, generalErr string) {
-	allTags, _ := a.Queries.ListActiveTagsWithCategory(r.Context())
+	a.render(w, "submit", a.editFormData(r, current, code, row, title, body, reason, rawURL, selectedIDs, errs, generalErr))
+}
 
-	tab := "link"
-	if row.Body.Valid {
-		tab = "text"
+// renderStoryActionError re-renders the edit form with msg when one of the
+// moderator actions on it (pin, score, duplicate) is rejected, keeping the
+// story's current fields and tags.
+func (a *App) renderStoryActionError(w http.ResponseWriter, r *http.Request, current auth.AuthenticatedUser, code string, row store.GetStoryRow, msg string) {
+	tagRows, err := a.Queries.GetStoryTags(r.Context(), row.ID)
+	if err != nil {
+		a.serverError(w, r, "get story tags", err)
+		return
+	}
+	tagIDs := make([]int64, 0, len(tagRows))
+	for _, t := range tagRows {
+		tagIDs = append(tagIDs, t.ID)
 	}
+	a.renderEditError(w, r, current, code, row, row.Title, row.Body.String, "", row.Url.String, tagIDs, nil, msg)
+}
+
+// editFormData is the edit form as renderEditError shows it, for callers
+// that need to add to it first.
+func (a *App) editFormData(r *http.Request, current auth.AuthenticatedUser, code string, row store.GetStoryRow, title, body, reason, rawURL string, selectedIDs []int64, errs map[string]string, generalErr string) SubmitPageData {
+	allTags, _ := a.Queries.ListActiveTagsWithCategory(r.Context())
+
+	convertTo := storyKindChange(row, r.FormValue("kind"))
 
 	displayURL := rawURL
-	if displayURL == "" {
+	if displayURL == "" && convertTo == "" {
 		displayURL = row.Url.String
 	}
 
-	a.render(w, "submit", SubmitPageData{
-		Base:      a.baseData(r),
-		Tab:       tab,
-		Title:     title,
-		Body:      body,
-		URL:       displayURL,
-		TagGroups: toTagGroups(allTags, current.User.IsModerator),
-		Selected:  selectedIDs,
-		Errors:    errs,
-		Error:     generalErr,
-		EditMode:  true,
-		EditCode:  code,
-		Reason:    reason,
-	})
+	// Keep what the moderator typed when re-rendering the edit form; the
+	// other moderator forms don't carry the field.
+	canonicalURL := row.CanonicalUrl.String
+	if v, ok := r.Form["canonical_url"]; ok && len(v) > 0 {
+		canonicalURL = strings.TrimSpace(v[0])
+	}
+
+	return SubmitPageData{
+		Base:            a.baseData(r),
+		Tab:             editTab(row, convertTo),
+		Title:           title,
+		Body:            body,
+		URL:             displayURL,
+		CanonicalURL:    canonicalURL,
+		TagGroups:       toTagGroups(allTags, current.User.IsModerator),
+		Selected:        selectedIDs,
+		Errors:          errs,
+		Error:           generalErr,
+		EditMode:        true,
+		EditCode:        code,
+		AuthorEdit:      !current.User.IsModerator,
+		ConvertTo:       convertTo,
+		Reason:          reason,
+		PinnedUntil:     activePin(row.PinnedUntil, time.Now()),
+		IsPick:          row.PickedAt.Valid,
+		IsHidden:        row.HiddenAt.Valid,
+		Score:           int(row.Upvotes - row.Downvotes + row.AdminAdjustment),
+		ScoreAdjustment: int(row.AdminAdjustment),
+	}
+}
+
+// validateStoryEdit checks the submitted edit form. Authors can only
+// change the title and tags, or supply the URL or body of the kind they
+// convert to (see storyKindChange); the reason is for moderators only.
+func validateStoryEdit(policy link.Config, role storyEditRole, row store.GetStoryRow, convertTo, title, body, rawURL, reason string) (link.CleanResult, map[string]string) {
+	errs := make(map[string]string)
+
+	if title == "" {
+		errs["title"] = "Title is required."
+	} else if len(title) > 150 {
+		errs["title"] = "Title must be 150 characters or fewer."
+	}
+
+	if role != storyEditModerator && convertTo == "" {
+		return link.CleanResult{}, errs
+	}
+
+	if (row.Body.Valid && convertTo == "") || convertTo == "text" {
+		if body == "" {
+			errs["body"] = "Text body is required for text posts."
+		} else if len(body) > 10000 {
+			errs["body"] = "Text body must be 10,000 characters or fewer."
+		}
+	}
+
+	// Validate URL for link posts
+	var urlResult link.CleanResult
+	if (row.Url.Valid && convertTo == "") || convertTo == "link" {
+		if rawURL == "" {
+			errs["url"] = "URL is required."
+		} else {
+			var err error
+			urlResult, err = policy.Clean(rawURL)
+			if err != nil {
+				var ve *link.ValidationError
+				if errors.As(err, &ve) {
+					errs["url"] = ve.Message
+				} else {
+					errs["url"] = "Invalid URL."
+				}
+			}
+		}
+	}
+
+	if role != storyEditModerator {
+		return urlResult, errs
+	}
+	if reason == "" {
+		errs["reason"] = "Reason is required."
+	} else if len(reason) > 500 {
+		errs["reason"] = "Reason must be 500 characters or fewer."
+	}
+
+	return urlResult, errs
+}
+
+// validateCanonicalURL checks a moderator-supplied canonical URL against
+// the link policy and returns an error message, or "" if it is acceptable.
+func validateCanonicalURL(policy link.Config, raw string) (link.CleanResult, string) {
+	if len(raw) > 250 {
+		return link.CleanResult{}, "Canonical URL must be 250 characters or fewer."
+	}
+	res, err := policy.Clean(raw)
+	if err != nil {
+		var ve *link.ValidationError
+		if errors.As(err, &ve) {
+			return link.CleanResult{}, ve.Message
+		}
+		return link.CleanResult{}, "Invalid URL."
+	}
+	return res, ""
+}
+
+// checkEditTags validates the tags chosen for an edited story and returns
+// an error message, or "" if they are acceptable. Privileged tags already
+// on the story may stay, but only moderators can add new ones.
+func checkEditTags(tags []store.Tag, oldTagIDs []int64, isModerator bool) string {
+	hasNonMedia := false
+	for _, tag := range tags {
+		if !tag.IsMedia {
+			hasNonMedia = true
+		}
+		if tag.Privileged && !isModerator && !slices.Contains(oldTagIDs, tag.ID) {
+			return "You do not have permission to use the tag \"" + tag.Tag + "\"."
+		}
+	}
+	if !hasNonMedia {
+		return "At least one non-media tag is required."
+	}
+	return ""
 }
 
 func equalSortedIDs(a, b []int64) bool {
@@ -0,0 +1,458 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
+	"crow.watch/internal/webhook"
+)
+
+func TestStoryEditRoleFor(t *testing.T) {
+	now := time.Now()
+	author := store.User{ID: 1}
+	other := store.User{ID: 2}
+	mod := store.User{ID: 3, IsModerator: true}
+
+	tests := []struct {
+		name    string
+		user    store.User
+		created time.Time
+		want    storyEditRole
+	}{
+		{"author within window", author, now.Add(-10 * time.Minute), storyEditAuthor},
+		{"author after window", author, now.Add(-storyEditWindowMinutes*time.Minute - time.Second), storyEditNone},
+		{"other user", other, now.Add(-time.Minute), storyEditNone},
+		{"moderator", mod, now.Add(-24 * time.Hour), storyEditModerator},
+		{"moderator on own story", store.User{ID: 1, IsModerator: true}, now, storyEditModerator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, storyEditRoleFor(tt.user, 1, tt.created, now))
+		})
+	}
+}
+
+func TestValidateStoryEdit(t *testing.T) {
+	linkRow := store.GetStoryRow{Title: "Old", Url: pgtype.Text{String: "https://example.com/a", Valid: true}}
+	textRow := store.GetStoryRow{Title: "Old", Body: pgtype.Text{String: "body", Valid: true}}
+
+	t.Run("moderator requires reason", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditModerator, linkRow, "", "New", "", "https://example.com/a", "")
+		assert.Equal(t, "Reason is required.", errs["reason"])
+	})
+
+	t.Run("moderator with reason", func(t *testing.T) {
+		res, errs := validateStoryEdit(link.Config{}, storyEditModerator, linkRow, "", "New", "", "https://example.com/a", "typo")
+		assert.Empty(t, errs)
+		assert.Equal(t, "https://example.com/a", res.Cleaned)
+	})
+
+	t.Run("moderator text body required", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditModerator, textRow, "", "New", "", "", "typo")
+		assert.Contains(t, errs, "body")
+	})
+
+	t.Run("author needs no reason", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditAuthor, linkRow, "", "New", "", "", "")
+		assert.Empty(t, errs)
+	})
+
+	t.Run("author url and body are ignored", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditAuthor, textRow, "", "New", "", "not a url", "")
+		assert.Empty(t, errs)
+	})
+
+	t.Run("author title still validated", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditAuthor, linkRow, "", "", "", "", "")
+		assert.Equal(t, "Title is required.", errs["title"])
+	})
+
+	t.Run("converting to link requires a valid url", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditAuthor, textRow, "link", "New", "", "", "")
+		assert.Equal(t, "URL is required.", errs["url"])
+		_, errs = validateStoryEdit(link.Config{}, storyEditAuthor, textRow, "link", "New", "", "not a url", "")
+		assert.Contains(t, errs, "url")
+		res, errs := validateStoryEdit(link.Config{}, storyEditAuthor, textRow, "link", "New", "", "https://example.com/b", "")
+		assert.Empty(t, errs)
+		assert.Equal(t, "https://example.com/b", res.Cleaned)
+	})
+
+	t.Run("url follows the link policy", func(t *testing.T) {
+		policy := link.Config{RequireHTTPS: true}
+		_, errs := validateStoryEdit(policy, storyEditAuthor, textRow, "link", "New", "", "http://example.com/b", "")
+		assert.Contains(t, errs, "url")
+		_, errs = validateStoryEdit(policy, storyEditModerator, linkRow, "", "New", "", "http://example.com/a", "typo")
+		assert.Contains(t, errs, "url")
+	})
+
+	t.Run("converting to text requires a body", func(t *testing.T) {
+		_, errs := validateStoryEdit(link.Config{}, storyEditAuthor, linkRow, "text", "New", "", "", "")
+		assert.Contains(t, errs, "body")
+		_, errs = validateStoryEdit(link.Config{}, storyEditAuthor, linkRow, "text", "New", "Now a text post", "", "")
+		assert.Empty(t, errs, "the old url is not checked")
+	})
+}
+
+func TestStoryKindChange(t *testing.T) {
+	linkRow := store.GetStoryRow{Url: pgtype.Text{String: "https://example.com/a", Valid: true}}
+	textRow := store.GetStoryRow{Body: pgtype.Text{String: "body", Valid: true}}
+
+	assert.Equal(t, "text", storyKindChange(linkRow, "text"))
+	assert.Equal(t, "link", storyKindChange(textRow, "link"))
+	assert.Empty(t, storyKindChange(linkRow, "link"), "already a link")
+	assert.Empty(t, storyKindChange(textRow, ""))
+	assert.Empty(t, storyKindChange(textRow, "video"))
+}
+
+func TestCheckEditTags(t *testing.T) {
+	golang := store.Tag{ID: 1, Tag: "go"}
+	video := store.Tag{ID: 2, Tag: "video", IsMedia: true}
+	meta := store.Tag{ID: 3, Tag: "meta", Privileged: true}
+
+	tests := []struct {
+		name   string
+		tags   []store.Tag
+		old    []int64
+		isMod  bool
+		errMsg string
+	}{
+		{"author retag", []store.Tag{golang, video}, []int64{2}, false, ""},
+		{"media only", []store.Tag{video}, []int64{1}, false, "At least one non-media tag is required."},
+		{"author adds privileged", []store.Tag{golang, meta}, []int64{1}, false, `You do not have permission to use the tag "meta".`},
+		{"author keeps privileged", []store.Tag{golang, meta}, []int64{1, 3}, false, ""},
+		{"moderator adds privileged", []store.Tag{golang, meta}, []int64{1}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.errMsg, checkEditTags(tt.tags, tt.old, tt.isMod))
+		})
+	}
+}
+
+func TestRenderAuthorEditForm(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "submit", SubmitPageData{
+		Base:       Base{IsLoggedIn: true, Username: "alice"},
+		Tab:        "text",
+		Title:      "My story",
+		Body:       "Original body",
+		EditMode:   true,
+		EditCode:   "abc123",
+		AuthorEdit: true,
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `name="title"`)
+	assert.NotContains(t, body, `name="reason"`)
+	assert.NotContains(t, body, `name="body"`)
+	assert.NotContains(t, body, `name="url"`)
+}
+
+func TestRenderAuthorConvertForm(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "submit", SubmitPageData{
+		Base:       Base{IsLoggedIn: true, Username: "alice"},
+		Tab:        "text",
+		Title:      "My story",
+		EditMode:   true,
+		EditCode:   "abc123",
+		AuthorEdit: true,
+		ConvertTo:  "text",
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `<input type="hidden" name="kind" value="text" />`)
+	assert.Contains(t, body, `name="body"`, "the new kind's field is shown to authors")
+	assert.NotContains(t, body, `name="url"`)
+	assert.NotContains(t, body, `name="reason"`)
+}
+
+func TestRenderModeratorEditFormRequiresReason(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "submit", SubmitPageData{
+		Base:     Base{IsLoggedIn: true, IsModerator: true, Username: "mod"},
+		Tab:      "link",
+		Title:    "A story",
+		URL:      "https://example.com",
+		EditMode: true,
+		EditCode: "abc123",
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `name="reason"`)
+	assert.Contains(t, body, `name="url"`)
+}
+
+func TestModeratorEditSendsWebhook(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	got := make(chan webhook.Event, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhook.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		got <- e
+	}))
+	defer hook.Close()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.ModWebhook = webhook.New(hook.URL, discardLogger())
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	mod := store.User{ID: u.ID, Username: u.Username, IsModerator: true}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Fixd title",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: tagID}))
+
+	form := url.Values{
+		"title":  {"Fixed title"},
+		"body":   {"body"},
+		"reason": {"typo"},
+		"tags":   {strconv.FormatInt(tagID, 10)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/x/abc123/edit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("code", "abc123")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: mod}))
+	w := httptest.NewRecorder()
+	a.editStory(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	a.ModWebhook.Wait()
+	e := <-got
+	assert.Equal(t, "story.edit_title", e.Action)
+	assert.Equal(t, story.ID, e.TargetID)
+	assert.Equal(t, "mod", e.Moderator)
+	assert.Equal(t, "typo", e.Reason)
+	assert.Contains(t, e.Text, "edited title")
+}
+
+func TestNotifyModerationDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	a := &App{ModWebhook: webhook.New(hook.URL, discardLogger())}
+
+	start := time.Now()
+	a.notifyModeration("mod", store.ModerationLog{Action: "story.delete", TargetType: "story", TargetID: 1})
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(release)
+}
+
+func TestValidateCanonicalURL(t *testing.T) {
+	res, msg := validateCanonicalURL(link.Config{}, "https://example.com/post?utm_source=feed")
+	assert.Empty(t, msg)
+	assert.Equal(t, "https://example.com/post", res.Cleaned)
+
+	_, msg = validateCanonicalURL(link.Config{}, "not a url")
+	assert.NotEmpty(t, msg)
+
+	_, msg = validateCanonicalURL(link.Config{RequireHTTPS: true}, "http://example.com/post")
+	assert.NotEmpty(t, msg)
+
+	_, msg = validateCanonicalURL(link.Config{}, "https://example.com/"+strings.Repeat("a", 250))
+	assert.Equal(t, "Canonical URL must be 250 characters or fewer.", msg)
+}
+
+func TestCanonicalURLAffectsDuplicateDetection(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	mod := store.User{ID: u.ID, Username: u.Username, IsModerator: true}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+	domain, err := a.Queries.GetOrCreateDomain(ctx, "reprints.example")
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:        u.ID,
+		DomainID:      pgtype.Int8{Int64: domain.ID, Valid: true},
+		Url:           pgtype.Text{String: "https://reprints.example/copy", Valid: true},
+		NormalizedUrl: pgtype.Text{String: "https://reprints.example/copy", Valid: true},
+		Title:         "Reprinted",
+		ShortCode:     "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: tagID}))
+
+	edit := func(canonical string) {
+		t.Helper()
+		form := url.Values{
+			"url":           {"https://reprints.example/copy"},
+			"canonical_url": {canonical},
+			"title":         {"Reprinted"},
+			"reason":        {"original source"},
+			"tags":          {strconv.FormatInt(tagID, 10)},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: mod}))
+		w := httptest.NewRecorder()
+		a.editStory(w, req)
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	}
+	original, err := link.Clean("https://original.example/post")
+	require.NoError(t, err)
+	findOriginal := func() error {
+		t.Helper()
+		found, err := a.findDuplicate(ctx, original.Normalized, time.Now())
+		if err == nil {
+			assert.Equal(t, story.ID, found.ID)
+		}
+		return err
+	}
+
+	require.ErrorIs(t, findOriginal(), pgx.ErrNoRows)
+
+	edit("https://original.example/post?utm_source=feed")
+	require.NoError(t, findOriginal(), "submitting the original now finds the reprint")
+
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: "abc123", Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://original.example/post", row.CanonicalUrl.String)
+	item, err := a.storyItem(httptest.NewRequest(http.MethodGet, "/x/abc123", nil), row)
+	require.NoError(t, err)
+	assert.Equal(t, "original.example", item.CanonicalDomain)
+
+	edit("")
+	require.ErrorIs(t, findOriginal(), pgx.ErrNoRows, "clearing the override stops matching")
+}
+
+func TestConvertStoryKind(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	alice := store.User{ID: u.ID, Username: u.Username}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+	domain, err := a.Queries.GetOrCreateDomain(ctx, "example.com")
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.IncrementDomainStoryCount(ctx, domain.ID))
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:        u.ID,
+		DomainID:      pgtype.Int8{Int64: domain.ID, Valid: true},
+		Url:           pgtype.Text{String: "https://example.com/q", Valid: true},
+		NormalizedUrl: pgtype.Text{String: "https://example.com/q", Valid: true},
+		Title:         "A question",
+		ShortCode:     "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: tagID}))
+
+	edit := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		form.Set("title", "A question")
+		form.Set("tags", strconv.FormatInt(tagID, 10))
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: alice}))
+		w := httptest.NewRecorder()
+		a.editStory(w, req)
+		return w
+	}
+	get := func() store.GetStoryRow {
+		t.Helper()
+		row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: "abc123", Valid: true}})
+		require.NoError(t, err)
+		return row
+	}
+	domainCount := func() int32 {
+		t.Helper()
+		d, err := a.Queries.GetDomainByName(ctx, "example.com")
+		require.NoError(t, err)
+		return d.StoryCount
+	}
+
+	w := edit(url.Values{"kind": {"text"}, "body": {"What do you use?"}})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	row := get()
+	assert.False(t, row.Url.Valid)
+	assert.False(t, row.Domain.Valid)
+	assert.Equal(t, "What do you use?", row.Body.String)
+	assert.Equal(t, int32(0), domainCount(), "link to text decrements the domain count")
+
+	w = edit(url.Values{"kind": {"link"}, "url": {"not a url"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `class="field-error"`)
+	assert.False(t, get().Url.Valid, "an invalid URL leaves the text post alone")
+
+	_, err = a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:        u.ID,
+		DomainID:      pgtype.Int8{Int64: domain.ID, Valid: true},
+		Url:           pgtype.Text{String: "https://example.com/taken", Valid: true},
+		NormalizedUrl: pgtype.Text{String: "https://example.com/taken", Valid: true},
+		Title:         "Taken",
+		ShortCode:     "def456",
+	})
+	require.NoError(t, err)
+	w = edit(url.Values{"kind": {"link"}, "url": {"https://example.com/taken?utm_source=x"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "This link was already submitted")
+	assert.Contains(t, w.Body.String(), `href="/x/def456/taken"`)
+	assert.False(t, get().Url.Valid, "a duplicate URL leaves the text post alone")
+
+	w = edit(url.Values{"kind": {"link"}, "url": {"https://example.com/answer"}})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	row = get()
+	assert.Equal(t, "https://example.com/answer", row.Url.String)
+	assert.False(t, row.Body.Valid)
+	assert.Equal(t, int32(1), domainCount())
+}
@@ -66,9 +66,11 @@ func (a *App) resendConfirmation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Rate-limit: skip if a confirmation email was already sent recently.
-	if current.User.EmailConfirmationTokenCreatedAt.Valid &&
-		time.Since(current.User.EmailConfirmationTokenCreatedAt.Time) < confirmationEmailCooldown {
+	// Rate-limit: skip if a confirmation email was already sent recently,
+	// to this account or from this IP.
+	recentlySent := current.User.EmailConfirmationTokenCreatedAt.Valid &&
+		time.Since(current.User.EmailConfirmationTokenCreatedAt.Time) < confirmationEmailCooldown
+	if recentlySent || !a.allowEmailFrom(r) {
 		a.render(w, "account", AccountPageData{
 			Base:             a.baseData(r),
 			Tab:              "email",
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/ratelimit"
+	"crow.watch/internal/store"
+)
+
+func TestResendConfirmationIPLimit(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.EmailIPLimiter = ratelimit.New(1, time.Hour)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "bob", Email: "bob@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+
+	// Another account already used up this IP's allowance.
+	require.True(t, a.EmailIPLimiter.Allow("203.0.113.7"))
+
+	req := httptest.NewRequest(http.MethodPost, "/account/resend-confirmation", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username, Email: u.Email}}))
+	w := httptest.NewRecorder()
+	a.resendConfirmation(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "already sent recently")
+
+	user, err := a.Queries.GetUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.False(t, user.EmailConfirmationTokenCreatedAt.Valid, "limited IP must not send a confirmation")
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed disposable_domains.txt
+var disposableDomainsTxt string
+
+var disposableDomains = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(disposableDomainsTxt, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			m[strings.ToLower(line)] = true
+		}
+	}
+	return m
+}()
+
+// EmailPolicy decides which e-mail domains may register or be invited.
+// A domain also matches its subdomains. Empty lists allow everything.
+type EmailPolicy struct {
+	AllowedDomains   []string
+	DeniedDomains    []string
+	RejectDisposable bool
+}
+
+// ParseEmailDomains parses a comma-separated list of domains, as used by
+// EMAIL_ALLOWED_DOMAINS and EMAIL_DENIED_DOMAINS.
+func ParseEmailDomains(s string) []string {
+	var domains []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// check returns a field error for a non-empty e-mail whose domain breaks
+// the policy, or "" if it is acceptable.
+func (p EmailPolicy) check(email string) string {
+	if len(p.AllowedDomains) == 0 && len(p.DeniedDomains) == 0 && !p.RejectDisposable {
+		return ""
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 || at == len(email)-1 {
+		return "Please enter a valid e-mail address."
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	if len(p.AllowedDomains) > 0 && !matchesDomain(domain, p.AllowedDomains) {
+		return "E-mail addresses from this domain are not accepted."
+	}
+	if matchesDomain(domain, p.DeniedDomains) {
+		return "E-mail addresses from this domain are not accepted."
+	}
+	if p.RejectDisposable && isDisposableDomain(domain) {
+		return "Disposable e-mail addresses are not accepted."
+	}
+	return ""
+}
+
+// matchesDomain reports whether domain is one of list or a subdomain of one.
+func matchesDomain(domain string, list []string) bool {
+	for _, d := range list {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDisposableDomain(domain string) bool {
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
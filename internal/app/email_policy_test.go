@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestEmailPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EmailPolicy
+		email  string
+		errMsg string
+	}{
+		{"empty policy allows anything", EmailPolicy{}, "bob@mailinator.com", ""},
+		{"empty policy skips format", EmailPolicy{}, "not-an-email", ""},
+		{"allowed domain", EmailPolicy{AllowedDomains: []string{"corp.com"}}, "alice@corp.com", ""},
+		{"allowed subdomain", EmailPolicy{AllowedDomains: []string{"corp.com"}}, "alice@eng.corp.com", ""},
+		{"allowed any case", EmailPolicy{AllowedDomains: []string{"corp.com"}}, "alice@Corp.COM", ""},
+		{"outside allow list", EmailPolicy{AllowedDomains: []string{"corp.com"}}, "alice@notcorp.com", "E-mail addresses from this domain are not accepted."},
+		{"denied domain", EmailPolicy{DeniedDomains: []string{"spam.org"}}, "bob@spam.org", "E-mail addresses from this domain are not accepted."},
+		{"not denied", EmailPolicy{DeniedDomains: []string{"spam.org"}}, "bob@example.org", ""},
+		{"disposable", EmailPolicy{RejectDisposable: true}, "bob@mailinator.com", "Disposable e-mail addresses are not accepted."},
+		{"disposable subdomain", EmailPolicy{RejectDisposable: true}, "bob@x.yopmail.com", "Disposable e-mail addresses are not accepted."},
+		{"not disposable", EmailPolicy{RejectDisposable: true}, "bob@example.com", ""},
+		{"missing domain", EmailPolicy{RejectDisposable: true}, "bob@", "Please enter a valid e-mail address."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.errMsg, tt.policy.check(tt.email))
+		})
+	}
+}
+
+func TestParseEmailDomains(t *testing.T) {
+	assert.Equal(t, []string{"corp.com", "example.org"}, ParseEmailDomains(" Corp.com, @example.org ,,"))
+	assert.Nil(t, ParseEmailDomains(""))
+}
+
+func TestValidateRegistrationEmailDomain(t *testing.T) {
+	policy := EmailPolicy{AllowedDomains: []string{"corp.com"}, RejectDisposable: true}
+
+	errs := validateRegistration("alice", "alice@corp.com", "tangerine sky", "tangerine sky", PasswordPolicy{}, policy)
+	assert.Empty(t, errs)
+
+	errs = validateRegistration("alice", "alice@gmail.com", "tangerine sky", "tangerine sky", PasswordPolicy{}, policy)
+	assert.Equal(t, "E-mail addresses from this domain are not accepted.", errs["email"])
+}
+
+func TestUpdateEmailChecksPolicy(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.EmailPolicy = EmailPolicy{DeniedDomains: []string{"spam.example"}}
+
+	digest, err := bcrypt.GenerateFromPassword([]byte("hunter22"), bcrypt.MinCost)
+	require.NoError(t, err)
+	created, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: string(digest),
+	})
+	require.NoError(t, err)
+	u, err := a.Queries.GetUserByID(ctx, created.ID)
+	require.NoError(t, err)
+
+	form := url.Values{"email": {"alice@spam.example"}, "password": {"hunter22"}}
+	req := httptest.NewRequest(http.MethodPost, "/account/email", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: u}))
+	w := httptest.NewRecorder()
+	a.updateEmail(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "E-mail addresses from this domain are not accepted.")
+	row, err := a.Queries.GetUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.False(t, row.UnconfirmedEmail.Valid, "no change is pending")
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// accountExport is everything a user has contributed, as served by
+// GET /account/export. It deliberately holds no secrets (password digest,
+// tokens) and nothing written by other users.
+type accountExport struct {
+	Profile      exportProfile       `json:"profile"`
+	Stories      []exportStory       `json:"stories"`
+	Comments     []exportComment     `json:"comments"`
+	Votes        []exportVote        `json:"votes"`
+	CommentVotes []exportCommentVote `json:"comment_votes"`
+	Invitations  []exportInvitation  `json:"invitations"`
+	ExportedAt   time.Time           `json:"exported_at"`
+}
+
+type exportProfile struct {
+	Username         string     `json:"username"`
+	Email            string     `json:"email"`
+	EmailConfirmedAt *time.Time `json:"email_confirmed_at,omitempty"`
+	About            string     `json:"about"`
+	Website          string     `json:"website"`
+	Slogan           string     `json:"slogan"`
+	EnableEmbeds     bool       `json:"enable_embeds"`
+	IsModerator      bool       `json:"is_moderator"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type exportStory struct {
+	ShortCode string     `json:"short_code"`
+	Title     string     `json:"title"`
+	URL       string     `json:"url,omitempty"`
+	Body      string     `json:"body,omitempty"`
+	Tags      []string   `json:"tags"`
+	Upvotes   int32      `json:"upvotes"`
+	Downvotes int32      `json:"downvotes"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type exportComment struct {
+	ID             int64      `json:"id"`
+	ParentID       int64      `json:"parent_id,omitempty"`
+	StoryShortCode string     `json:"story_short_code"`
+	StoryTitle     string     `json:"story_title"`
+	Body           string     `json:"body"`
+	Upvotes        int32      `json:"upvotes"`
+	Downvotes      int32      `json:"downvotes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EditedAt       *time.Time `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+type exportVote struct {
+	StoryShortCode string    `json:"story_short_code"`
+	StoryTitle     string    `json:"story_title"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type exportCommentVote struct {
+	CommentID      int64     `json:"comment_id"`
+	StoryShortCode string    `json:"story_short_code"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type exportInvitation struct {
+	Email              string    `json:"email,omitempty"`
+	RegisteredUsername string    `json:"registered_username,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// accountExportDownload serves the signed-in user's data as a JSON
+// attachment (GET /account/export). It is built on demand and rate
+// limited per user since it reads every row the user ever wrote.
+func (a *App) accountExportDownload(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if a.ExportLimiter != nil {
+		key := strconv.FormatInt(current.User.ID, 10)
+		if !a.ExportLimiter.Allow(key) {
+			http.Error(w, rateLimitMessage(w, a.ExportLimiter, key, "data exports"), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	export, err := a.buildAccountExport(r.Context(), current.User)
+	if err != nil {
+		a.serverError(w, r, "build account export", err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="crow-watch-%s.json"`, current.User.Username))
+	writeJSON(w, http.StatusOK, export)
+}
+
+func (a *App) buildAccountExport(ctx context.Context, user store.User) (accountExport, error) {
+	export := accountExport{
+		Profile: exportProfile{
+			Username:         user.Username,
+			Email:            user.Email,
+			EmailConfirmedAt: exportTime(user.EmailConfirmedAt),
+			About:            user.About,
+			Website:          user.Website,
+			Slogan:           user.Slogan,
+			EnableEmbeds:     user.EnableEmbeds,
+			IsModerator:      user.IsModerator,
+			CreatedAt:        user.CreatedAt.Time,
+		},
+		Stories:      []exportStory{},
+		Comments:     []exportComment{},
+		Votes:        []exportVote{},
+		CommentVotes: []exportCommentVote{},
+		Invitations:  []exportInvitation{},
+		ExportedAt:   time.Now().UTC(),
+	}
+
+	stories, err := a.Queries.ExportUserStories(ctx, user.ID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("export stories: %w", err)
+	}
+	for _, s := range stories {
+		export.Stories = append(export.Stories, exportStory{
+			ShortCode: s.ShortCode,
+			Title:     s.Title,
+			URL:       s.Url.String,
+			Body:      s.Body.String,
+			Tags:      s.Tags,
+			Upvotes:   s.Upvotes,
+			Downvotes: s.Downvotes,
+			CreatedAt: s.CreatedAt.Time,
+			DeletedAt: exportTime(s.DeletedAt),
+		})
+	}
+
+	comments, err := a.Queries.ExportUserComments(ctx, user.ID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("export comments: %w", err)
+	}
+	for _, c := range comments {
+		export.Comments = append(export.Comments, exportComment{
+			ID:             c.ID,
+			ParentID:       c.ParentID.Int64,
+			StoryShortCode: c.StoryShortCode,
+			StoryTitle:     c.StoryTitle,
+			Body:           c.Body,
+			Upvotes:        c.Upvotes,
+			Downvotes:      c.Downvotes,
+			CreatedAt:      c.CreatedAt.Time,
+			EditedAt:       exportTime(c.EditedAt),
+			DeletedAt:      exportTime(c.DeletedAt),
+		})
+	}
+
+	votes, err := a.Queries.ExportUserVotes(ctx, user.ID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("export votes: %w", err)
+	}
+	for _, v := range votes {
+		export.Votes = append(export.Votes, exportVote{
+			StoryShortCode: v.StoryShortCode,
+			StoryTitle:     v.StoryTitle,
+			CreatedAt:      v.CreatedAt.Time,
+		})
+	}
+
+	commentVotes, err := a.Queries.ExportUserCommentVotes(ctx, user.ID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("export comment votes: %w", err)
+	}
+	for _, v := range commentVotes {
+		export.CommentVotes = append(export.CommentVotes, exportCommentVote{
+			CommentID:      v.CommentID,
+			StoryShortCode: v.StoryShortCode,
+			CreatedAt:      v.CreatedAt.Time,
+		})
+	}
+
+	invitations, err := a.Queries.ExportUserInvitations(ctx, user.ID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("export invitations: %w", err)
+	}
+	for _, i := range invitations {
+		export.Invitations = append(export.Invitations, exportInvitation{
+			Email:              i.Email.String,
+			RegisteredUsername: i.RegisteredUsername.String,
+			CreatedAt:          i.CreatedAt.Time,
+		})
+	}
+
+	return export, nil
+}
+
+// exportTime returns nil for a NULL timestamp so it is omitted from the
+// export.
+func exportTime(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
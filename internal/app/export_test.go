@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/ratelimit"
+	"crow.watch/internal/store"
+)
+
+func TestAccountExportRateLimited(t *testing.T) {
+	a := testApp(t)
+	a.ExportLimiter = ratelimit.New(1, time.Hour)
+	require.True(t, a.ExportLimiter.Allow("1"))
+
+	req := httptest.NewRequest(http.MethodGet, "/account/export", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+	w := httptest.NewRecorder()
+	a.accountExportDownload(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestAccountExportOnlyOwnData(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	alice, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "alice-secret-digest",
+	})
+	require.NoError(t, err)
+	bob, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "bob", Email: "bob@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+
+	newStory := func(userID int64, code, title string) store.CreateStoryRow {
+		t.Helper()
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    userID,
+			Title:     title,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		return s
+	}
+	aliceStory := newStory(alice.ID, "alice1", "Alice's story")
+	bobStory := newStory(bob.ID, "bob111", "Bob's story")
+
+	_, err = a.Queries.CreateComment(ctx, store.CreateCommentParams{
+		StoryID: bobStory.ID, UserID: alice.ID, Body: "alice on bob's story",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateComment(ctx, store.CreateCommentParams{
+		StoryID: aliceStory.ID, UserID: bob.ID, Body: "bob on alice's story",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateVote(ctx, store.CreateVoteParams{UserID: alice.ID, StoryID: bobStory.ID})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateVote(ctx, store.CreateVoteParams{UserID: bob.ID, StoryID: aliceStory.ID})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/account/export", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{
+		ID: alice.ID, Username: alice.Username, Email: alice.Email, PasswordDigest: "alice-secret-digest",
+	}}))
+	w := httptest.NewRecorder()
+	a.accountExportDownload(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	assert.NotContains(t, w.Body.String(), "alice-secret-digest")
+	assert.NotContains(t, w.Body.String(), "bob@example.com")
+
+	var got accountExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "alice", got.Profile.Username)
+	require.Len(t, got.Stories, 1)
+	assert.Equal(t, "Alice's story", got.Stories[0].Title)
+	require.Len(t, got.Comments, 1)
+	assert.Equal(t, "alice on bob's story", got.Comments[0].Body)
+	require.Len(t, got.Votes, 1)
+	assert.Equal(t, "bob111", got.Votes[0].StoryShortCode)
+	assert.Empty(t, got.Invitations)
+}
@@ -0,0 +1,370 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// flagQueueLimit caps how many flagged stories the queue lists at once.
+const flagQueueLimit = 100
+
+type FlagQueuePageData struct {
+	Base    Base
+	Stories []FlaggedStory
+}
+
+// FlaggedStory is one row of the moderation queue. Originals are the stories
+// named by "already posted" flags, so a moderator can mark the story as a
+// duplicate of one of them without looking it up.
+type FlaggedStory struct {
+	ID        int64
+	ShortCode string
+	Title     string
+	URL       string
+	Username  string
+	CreatedAt time.Time
+	FlagCount int
+	Reasons   string
+	Originals []FlaggedOriginal
+}
+
+// FlaggedOriginal is a story named by an "already posted" flag. Reason is
+// the moderation log reason for marking the flagged story a duplicate of it.
+type FlaggedOriginal struct {
+	ShortCode string
+	Title     string
+	URL       string
+	Reason    string
+}
+
+// flagQueuePage lists live flagged stories for moderators (GET /mod/flags).
+func (a *App) flagQueuePage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	rows, err := a.Queries.ListFlaggedStories(r.Context(), flagQueueLimit)
+	if err != nil {
+		a.serverError(w, r, "list flagged stories", err)
+		return
+	}
+
+	a.render(w, "flag_queue", FlagQueuePageData{
+		Base:    a.baseData(r),
+		Stories: flaggedStories(rows, a.AppURL),
+	})
+}
+
+func flaggedStories(rows []store.ListFlaggedStoriesRow, appURL string) []FlaggedStory {
+	stories := make([]FlaggedStory, 0, len(rows))
+	for _, row := range rows {
+		originals := make([]FlaggedOriginal, 0, len(row.OriginalShortCodes))
+		for i, code := range row.OriginalShortCodes {
+			title := row.OriginalTitles[i]
+			path := storyPath(code, title)
+			originals = append(originals, FlaggedOriginal{
+				ShortCode: code,
+				Title:     title,
+				URL:       path,
+				Reason:    fmt.Sprintf("Already posted as %q: %s", title, appURL+path),
+			})
+		}
+		stories = append(stories, FlaggedStory{
+			ID:        row.ID,
+			ShortCode: row.ShortCode,
+			Title:     row.Title,
+			URL:       storyPath(row.ShortCode, row.Title),
+			Username:  row.Username,
+			CreatedAt: row.CreatedAt.Time,
+			FlagCount: int(row.FlagCount),
+			Reasons:   strings.Join(row.Reasons, ", "),
+			Originals: originals,
+		})
+	}
+	return stories
+}
+
+// Bulk actions a moderator can apply to flagged items from the queue.
+// Comments cannot be hidden; delete them instead.
+const (
+	bulkHide       = "hide"
+	bulkClearFlags = "clear-flags"
+	bulkDelete     = "delete"
+)
+
+// bulkFlagTargets are the story and comment IDs picked for a bulk action.
+type bulkFlagTargets struct {
+	Stories  []int64
+	Comments []int64
+}
+
+// parseBulkFlagForm reads the action and the story_id/comment_id values of
+// a bulk flag form. The message describes input the moderator must fix.
+func parseBulkFlagForm(form map[string][]string) (string, bulkFlagTargets, string) {
+	var targets bulkFlagTargets
+	action := ""
+	if v := form["action"]; len(v) > 0 {
+		action = v[0]
+	}
+	switch action {
+	case bulkHide, bulkClearFlags, bulkDelete:
+	default:
+		return "", targets, "Unknown action."
+	}
+
+	parse := func(values []string) ([]int64, bool) {
+		var ids []int64
+		for _, v := range values {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				return nil, false
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		return ids, true
+	}
+	var ok bool
+	if targets.Stories, ok = parse(form["story_id"]); !ok {
+		return "", targets, "Invalid story id."
+	}
+	if targets.Comments, ok = parse(form["comment_id"]); !ok {
+		return "", targets, "Invalid comment id."
+	}
+
+	switch n := len(targets.Stories) + len(targets.Comments); {
+	case n == 0:
+		return "", targets, "Select at least one item."
+	case n > flagQueueLimit:
+		return "", targets, fmt.Sprintf("Select at most %d items.", flagQueueLimit)
+	}
+	if action == bulkHide && len(targets.Comments) > 0 {
+		return "", targets, "Comments cannot be hidden."
+	}
+	return action, targets, ""
+}
+
+// bulkFlagAction applies one action to several flagged stories and
+// comments at once (POST /mod/flags/bulk). Everything happens in one
+// transaction, with a moderation log entry for each item the action
+// changed; items already in the requested state are skipped.
+func (a *App) bulkFlagAction(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+	action, targets, msg := parseBulkFlagForm(r.PostForm)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	entries, err := a.applyBulkFlagAction(r.Context(), a.Queries.WithTx(tx), current.User.ID, action, targets)
+	if err != nil {
+		a.serverError(w, r, "bulk flag action", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	for _, entry := range entries {
+		a.notifyModeration(current.User.Username, entry)
+	}
+
+	http.Redirect(w, r, "/mod/flags", http.StatusSeeOther)
+}
+
+// applyBulkFlagAction runs action against targets using q, which should
+// be bound to a transaction, and returns the moderation log entries.
+func (a *App) applyBulkFlagAction(ctx context.Context, q *store.Queries, moderatorID int64, action string, targets bulkFlagTargets) ([]store.ModerationLog, error) {
+	var entries []store.ModerationLog
+	logItems := func(logAction, targetType string, ids []int64) error {
+		metadata, err := json.Marshal(map[string]any{"bulk": true})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			entry, err := q.CreateModerationLog(ctx, store.CreateModerationLogParams{
+				ModeratorID: moderatorID,
+				Action:      logAction,
+				TargetType:  targetType,
+				TargetID:    id,
+				Reason:      "(bulk action from the flag queue)",
+				Metadata:    metadata,
+			})
+			if err != nil {
+				return fmt.Errorf("create moderation log: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+
+	switch action {
+	case bulkHide:
+		ids, err := q.HideStories(ctx, targets.Stories)
+		if err != nil {
+			return nil, fmt.Errorf("hide stories: %w", err)
+		}
+		if err := logItems("story.hide", "story", ids); err != nil {
+			return nil, err
+		}
+
+	case bulkClearFlags:
+		storyIDs, err := q.ClearStoryFlags(ctx, targets.Stories)
+		if err != nil {
+			return nil, fmt.Errorf("clear story flags: %w", err)
+		}
+		for _, id := range storyIDs {
+			// Flags feed the hide+flag penalty, so it goes with them.
+			if err := q.RecalculateStoryDownvotes(ctx, a.downvoteParams(id)); err != nil {
+				return nil, fmt.Errorf("recalculate story downvotes: %w", err)
+			}
+		}
+		if err := logItems("story.clear_flags", "story", storyIDs); err != nil {
+			return nil, err
+		}
+		commentIDs, err := q.ClearCommentFlags(ctx, targets.Comments)
+		if err != nil {
+			return nil, fmt.Errorf("clear comment flags: %w", err)
+		}
+		if err := logItems("comment.clear_flags", "comment", commentIDs); err != nil {
+			return nil, err
+		}
+
+	case bulkDelete:
+		storyIDs, err := q.SoftDeleteStories(ctx, targets.Stories)
+		if err != nil {
+			return nil, fmt.Errorf("soft delete stories: %w", err)
+		}
+		if err := logItems("story.delete", "story", storyIDs); err != nil {
+			return nil, err
+		}
+		deleted, err := q.SoftDeleteComments(ctx, targets.Comments)
+		if err != nil {
+			return nil, fmt.Errorf("soft delete comments: %w", err)
+		}
+		commentIDs := make([]int64, 0, len(deleted))
+		touched := make(map[int64]bool)
+		for _, c := range deleted {
+			commentIDs = append(commentIDs, c.ID)
+			if err := q.DecrementStoryCommentCount(ctx, c.StoryID); err != nil {
+				return nil, fmt.Errorf("decrement comment count: %w", err)
+			}
+			touched[c.StoryID] = true
+		}
+		for id := range touched {
+			// A deleted comment may restore a hide+flag penalty.
+			if err := q.RecalculateStoryDownvotes(ctx, a.downvoteParams(id)); err != nil {
+				return nil, fmt.Errorf("recalculate story downvotes: %w", err)
+			}
+		}
+		if err := logItems("comment.delete", "comment", commentIDs); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// unhideFlaggedStory puts a story hidden from the flag queue back into
+// listings (POST /x/{code}/unhide).
+func (a *App) unhideFlaggedStory(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		reason = "(no reason given)"
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+	ids, err := qtx.UnhideStories(r.Context(), []int64{row.ID})
+	if err != nil {
+		a.serverError(w, r, "unhide story", err)
+		return
+	}
+	// Not hidden, so nothing to log.
+	if len(ids) == 0 {
+		http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      "story.unhide",
+		TargetType:  "story",
+		TargetID:    row.ID,
+		Reason:      reason,
+		Metadata:    []byte("{}"),
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+}
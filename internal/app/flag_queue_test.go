@@ -0,0 +1,232 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestFlagQueueRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/mod/flags", nil)
+	w := httptest.NewRecorder()
+	a.flagQueuePage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRenderFlagQueue(t *testing.T) {
+	a := testApp(t)
+	stories := flaggedStories([]store.ListFlaggedStoriesRow{{
+		ID:                 7,
+		ShortCode:          "dupe01",
+		Title:              "A Dupe",
+		Username:           "alice",
+		CreatedAt:          pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		FlagCount:          2,
+		Reasons:            []string{"already posted", "spam"},
+		OriginalShortCodes: []string{"orig01"},
+		OriginalTitles:     []string{"The Original"},
+	}}, "https://crow.example")
+
+	w := httptest.NewRecorder()
+	a.render(w, "flag_queue", FlagQueuePageData{Stories: stories})
+	body := w.Body.String()
+	assert.Contains(t, body, storyPath("dupe01", "A Dupe"))
+	assert.Contains(t, body, "already posted, spam")
+	assert.Contains(t, body, `action="/x/dupe01/mark-duplicate"`)
+	assert.Regexp(t, `name="canonical_code"\s+value="orig01"`, body)
+	assert.Contains(t, body, `href="`+storyPath("orig01", "The Original")+`">The Original</a>`)
+	assert.Contains(t, body, `name="reason" value="Already posted as &#34;The Original&#34;: https://crow.example`+storyPath("orig01", "The Original")+`"`)
+	assert.Contains(t, body, `action="/x/dupe01/delete"`)
+	assert.Contains(t, body, `action="/mod/flags/bulk"`)
+	assert.Regexp(t, `name="story_id"\s+value="7"\s+form="flag-bulk"`, body)
+}
+
+func TestBulkFlagActionRequiresModerator(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/mod/flags/bulk", strings.NewReader("action=hide&story_id=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.bulkFlagAction(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestParseBulkFlagForm(t *testing.T) {
+	action, targets, msg := parseBulkFlagForm(url.Values{
+		"action":     {"clear-flags"},
+		"story_id":   {"3", "1", "3"},
+		"comment_id": {"9"},
+	})
+	assert.Empty(t, msg)
+	assert.Equal(t, bulkClearFlags, action)
+	assert.Equal(t, []int64{3, 1}, targets.Stories, "repeats are dropped")
+	assert.Equal(t, []int64{9}, targets.Comments)
+
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"unknown action", url.Values{"action": {"ban"}, "story_id": {"1"}}},
+		{"no items", url.Values{"action": {"delete"}}},
+		{"bad story id", url.Values{"action": {"delete"}, "story_id": {"x"}}},
+		{"negative comment id", url.Values{"action": {"delete"}, "comment_id": {"-2"}}},
+		{"hide comments", url.Values{"action": {"hide"}, "comment_id": {"2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, msg := parseBulkFlagForm(tt.form)
+			assert.NotEmpty(t, msg)
+		})
+	}
+}
+
+func TestBulkHideFlaggedStories(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	mod, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	alice, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+
+	var ids []int64
+	for _, code := range []string{"flag01", "flag02", "flag03"} {
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    alice.ID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.Queries.CreateStoryFlag(ctx, store.CreateStoryFlagParams{
+			UserID: mod.ID, StoryID: s.ID, Reason: "spam",
+		}))
+		ids = append(ids, s.ID)
+	}
+
+	form := url.Values{
+		"action":   {"hide"},
+		"story_id": {strconv.FormatInt(ids[0], 10), strconv.FormatInt(ids[2], 10)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/mod/flags/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{
+		User: store.User{ID: mod.ID, Username: mod.Username, IsModerator: true},
+	}))
+	w := httptest.NewRecorder()
+	a.bulkFlagAction(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	var hidden []int64
+	rows, err := pool.Query(ctx, "SELECT id FROM stories WHERE hidden_at IS NOT NULL ORDER BY id")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id int64
+		require.NoError(t, rows.Scan(&id))
+		hidden = append(hidden, id)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int64{ids[0], ids[2]}, hidden, "only the listed stories are hidden")
+
+	var logged []int64
+	rows, err = pool.Query(ctx, "SELECT target_id FROM moderation_log WHERE action = 'story.hide' AND moderator_id = $1 ORDER BY target_id", mod.ID)
+	require.NoError(t, err)
+	for rows.Next() {
+		var id int64
+		require.NoError(t, rows.Scan(&id))
+		logged = append(logged, id)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int64{ids[0], ids[2]}, logged, "one log entry per hidden story")
+
+	queue, err := a.Queries.ListFlaggedStories(ctx, flagQueueLimit)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, ids[1], queue[0].ID)
+}
+
+func TestUnhideFlaggedStoryRequiresModerator(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/x/abc123/unhide", nil)
+	req.SetPathValue("code", "abc123")
+	w := httptest.NewRecorder()
+	a.unhideFlaggedStory(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUnhideFlaggedStory(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	mod, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    mod.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.HideStories(ctx, []int64{story.ID})
+	require.NoError(t, err)
+
+	// The profile listing keeps deleted stories but not hidden ones.
+	listed := func() int {
+		t.Helper()
+		rows, err := a.Queries.ListStories(ctx, store.ListStoriesParams{
+			Username:   pgtype.Text{String: "mod", Valid: true},
+			StoryLimit: 10,
+		})
+		require.NoError(t, err)
+		return len(rows)
+	}
+	assert.Equal(t, 0, listed())
+
+	post := func() {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/unhide", strings.NewReader("reason=mistake"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{
+			User: store.User{ID: mod.ID, Username: mod.Username, IsModerator: true},
+		}))
+		w := httptest.NewRecorder()
+		a.unhideFlaggedStory(w, req)
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	}
+	post()
+	post()
+	assert.Equal(t, 1, listed())
+
+	logs, err := a.Queries.ListModerationLog(ctx, store.ListModerationLogParams{LogLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 1, "unhiding twice is logged once")
+	assert.Equal(t, "story.unhide", logs[0].Action)
+	assert.Equal(t, "mistake", logs[0].Reason)
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const flashCookieName = "flash"
+
+// flashMessages are the messages a flash cookie can carry. The cookie
+// holds only the key, so a forged cookie can't put arbitrary text on the
+// page.
+var flashMessages = map[string]string{
+	"story_submitted": "Story submitted.",
+}
+
+type flashKey struct{}
+
+// setFlash stores a message to show on the next page rendered for this
+// browser, typically right before a redirect.
+func (a *App) setFlash(w http.ResponseWriter, key string) {
+	if _, ok := flashMessages[key]; !ok {
+		a.Log.Error("unknown flash message", "key", key)
+		return
+	}
+	http.SetCookie(w, a.flashCookie(key))
+}
+
+func (a *App) flashCookie(value string) *http.Cookie {
+	opts := a.Sessions.CookieOptions()
+	return &http.Cookie{
+		Name:     flashCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   opts.Domain,
+		MaxAge:   60,
+		HttpOnly: true,
+		Secure:   opts.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// pendingFlash is the flash a request arrived with. It counts as shown
+// once a page reads it through baseData.
+type pendingFlash struct {
+	msg   string
+	shown bool
+}
+
+// flashes makes a pending flash message available to baseData. The cookie
+// is cleared only when a page actually showed the message, so fetch and
+// JSON requests made in between leave it for the next page.
+func (a *App) flashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c, err := r.Cookie(flashCookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		flash := &pendingFlash{msg: flashMessages[c.Value]}
+		expired := a.flashCookie("")
+		expired.MaxAge = -1
+		fw := &flashWriter{ResponseWriter: w, flash: flash, expired: expired}
+		next.ServeHTTP(fw, r.WithContext(context.WithValue(r.Context(), flashKey{}, flash)))
+	})
+}
+
+// flashFromContext returns the request's flash message and marks it shown.
+// Unknown keys read as empty but are still cleared.
+func flashFromContext(ctx context.Context) string {
+	flash, ok := ctx.Value(flashKey{}).(*pendingFlash)
+	if !ok {
+		return ""
+	}
+	flash.shown = true
+	return flash.msg
+}
+
+// flashWriter expires the flash cookie with the response headers if the
+// handler showed the message.
+type flashWriter struct {
+	http.ResponseWriter
+	flash       *pendingFlash
+	expired     *http.Cookie
+	wroteHeader bool
+}
+
+func (fw *flashWriter) WriteHeader(code int) {
+	if !fw.wroteHeader {
+		fw.wroteHeader = true
+		if fw.flash.shown {
+			http.SetCookie(fw.ResponseWriter, fw.expired)
+		}
+	}
+	fw.ResponseWriter.WriteHeader(code)
+}
+
+func (fw *flashWriter) Write(b []byte) (int, error) {
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+	return fw.ResponseWriter.Write(b)
+}
+
+func (fw *flashWriter) Flush() {
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := fw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (fw *flashWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlashShownOnceAfterRedirect(t *testing.T) {
+	a := testApp(t)
+
+	// A POST handler sets the flash and redirects.
+	w := httptest.NewRecorder()
+	a.setFlash(w, "story_submitted")
+	http.Redirect(w, httptest.NewRequest(http.MethodPost, "/submit", nil), "/", http.StatusSeeOther)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, flashCookieName, cookies[0].Name)
+
+	page := a.flashes(http.HandlerFunc(a.aboutPage))
+
+	// The next page shows the message and expires the cookie.
+	req := httptest.NewRequest(http.MethodGet, "/about", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	page.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "Story submitted.")
+	expired := w.Result().Cookies()
+	require.Len(t, expired, 1)
+	assert.Equal(t, flashCookieName, expired[0].Name)
+	assert.Negative(t, expired[0].MaxAge)
+
+	// Once the browser drops it, the message is gone.
+	w = httptest.NewRecorder()
+	page.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/about", nil))
+	assert.NotContains(t, w.Body.String(), "Story submitted.")
+}
+
+func TestFlashIgnoresStaticAndUnknownKeys(t *testing.T) {
+	a := testApp(t)
+	page := a.flashes(http.HandlerFunc(a.aboutPage))
+
+	req := httptest.NewRequest(http.MethodGet, "/static/css/base.css", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: "story_submitted"})
+	w := httptest.NewRecorder()
+	page.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Cookies(), "assets must not consume the flash")
+
+	req = httptest.NewRequest(http.MethodGet, "/about", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: "<script>"})
+	w = httptest.NewRecorder()
+	page.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), `class="success flash"`)
+	assert.Len(t, w.Result().Cookies(), 1, "unknown flashes are still cleared")
+}
+
+func TestFlashKeptForNonPageRequests(t *testing.T) {
+	a := testApp(t)
+	jsonGet := a.flashes(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/newest.json", nil)
+	req.AddCookie(&http.Cookie{Name: flashCookieName, Value: "story_submitted"})
+	w := httptest.NewRecorder()
+	jsonGet.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Result().Cookies(), "the flash waits for a page")
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// wantsHTML reports whether the client asked for text/html explicitly, as
+// no-JS forms and htmx-style clients do. A bare */*, which fetch sends by
+// default, keeps the JSON response.
+func wantsHTML(r *http.Request) bool {
+	return accepts(r, "text/html")
+}
+
+// accepts reports whether the Accept header lists mediaType by name.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mt == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// renderFragment writes the named partial on its own, without the page
+// layout, so a client can swap it into the existing page.
+func (a *App) renderFragment(w http.ResponseWriter, name string, data any) {
+	tmpl, ok := a.lookupTemplate(w, http.StatusOK, partialsTemplate)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		a.Log.Error("fragment execute", "error", err, "template", name)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestWantsHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/html", true},
+		{"text/html, */*; q=0.8", true},
+		{"application/json, text/html;q=0.5", true},
+		{"text/htmlx", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		assert.Equal(t, tt.want, wantsHTML(r), tt.accept)
+	}
+}
+
+func TestHideFragmentReflectsState(t *testing.T) {
+	a := testApp(t)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Accept", "text/html")
+
+	w := httptest.NewRecorder()
+	a.writeHideState(w, r, 7, true)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `data-action="story-unhide"`)
+	assert.NotContains(t, w.Body.String(), "<html")
+
+	w = httptest.NewRecorder()
+	a.writeHideState(w, r, 7, false)
+	assert.Contains(t, w.Body.String(), `data-action="story-hide"`)
+
+	w = httptest.NewRecorder()
+	a.writeHideState(w, httptest.NewRequest(http.MethodPost, "/", nil), 7, true)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+}
+
+func TestVoteAndFlagFragments(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.FlagMinAge = DefaultFlagMinAge
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := store.User{
+		ID:               u.ID,
+		Username:         u.Username,
+		EmailConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		CreatedAt:        pgtype.Timestamptz{Time: time.Now().Add(-30 * 24 * time.Hour), Valid: true},
+	}
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	call := func(handler http.HandlerFunc, accept, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("id", strconv.FormatInt(story.ID, 10))
+		req.Header.Set("Accept", accept)
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: user}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	w := call(a.upvote, "text/html", "")
+	assert.Contains(t, w.Body.String(), `aria-pressed="true"`)
+	assert.Contains(t, w.Body.String(), ">1</span")
+
+	w = call(a.unvote, "text/html", "")
+	assert.Contains(t, w.Body.String(), `aria-pressed="false"`)
+	assert.Contains(t, w.Body.String(), ">0</span")
+
+	w = call(a.upvote, "*/*", "")
+	var vote voteResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vote))
+	assert.Equal(t, voteResponse{OK: true, Upvotes: 1}, vote)
+
+	w = call(a.flagStory, "text/html", `{"reason":"spam"}`)
+	assert.Contains(t, w.Body.String(), `data-action="story-unflag"`)
+
+	w = call(a.unflagStory, "text/html", "")
+	assert.Contains(t, w.Body.String(), `data-action="story-flag"`)
+	assert.Contains(t, w.Body.String(), `data-reason="spam"`)
+}
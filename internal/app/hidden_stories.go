@@ -3,68 +3,105 @@ package app
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/store"
 )
 
+// hideReasonUnspecified is stored when a hide request carries no reason.
+const hideReasonUnspecified = "unspecified"
+
+var storyHideReasons = []string{"not interested", "seen it", "low quality"}
+
+// readHideReason reads the optional {"reason": ...} body of a hide request.
+// An empty body or reason means unspecified; anything outside
+// storyHideReasons is rejected. On failure it writes the JSON error and
+// returns false.
+func readHideReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if !decodeJSON(w, r, 1024, &req) {
+		return "", false
+	}
+	if req.Reason == "" {
+		return hideReasonUnspecified, true
+	}
+	if !slices.Contains(storyHideReasons, req.Reason) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid hide reason.")
+		return "", false
+	}
+	return req.Reason, true
+}
+
+// writeHideState acknowledges a hide or unhide, re-rendering the hide
+// control when the client asks for HTML.
+func (a *App) writeHideState(w http.ResponseWriter, r *http.Request, storyID int64, hasHidden bool) {
+	if wantsHTML(r) {
+		a.renderFragment(w, "story-hide", StoryItem{ID: storyID, HasHidden: hasHidden})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+}
+
 func (a *App) hideStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
-	if err := a.Queries.HideStory(r.Context(), store.HideStoryParams{
-		UserID:  current.User.ID,
-		StoryID: storyID,
-	}); err != nil {
-		a.serverError(w, r, "hide story", err)
+	reason, ok := readHideReason(w, r)
+	if !ok {
 		return
 	}
 
-	if err := a.Queries.RecalculateStoryDownvotes(r.Context(), storyID); err != nil {
-		a.serverError(w, r, "recalculate story downvotes", err)
+	if err := a.updateStoryScore(r.Context(), storyID, func(q *store.Queries) error {
+		return q.HideStory(r.Context(), store.HideStoryParams{
+			UserID:  current.User.ID,
+			StoryID: storyID,
+			Reason:  reason,
+		})
+	}); err != nil {
+		a.jsonServerError(w, r, "hide story", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	a.writeHideState(w, r, storyID, true)
 }
 
 func (a *App) unhideStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
-	if err := a.Queries.UnhideStory(r.Context(), store.UnhideStoryParams{
-		UserID:  current.User.ID,
-		StoryID: storyID,
+	if err := a.updateStoryScore(r.Context(), storyID, func(q *store.Queries) error {
+		return q.UnhideStory(r.Context(), store.UnhideStoryParams{
+			UserID:  current.User.ID,
+			StoryID: storyID,
+		})
 	}); err != nil {
-		a.serverError(w, r, "unhide story", err)
-		return
-	}
-
-	if err := a.Queries.RecalculateStoryDownvotes(r.Context(), storyID); err != nil {
-		a.serverError(w, r, "recalculate story downvotes", err)
+		a.jsonServerError(w, r, "unhide story", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	a.writeHideState(w, r, storyID, false)
 }
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadHideReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		reason string
+		ok     bool
+	}{
+		{"empty body", "", hideReasonUnspecified, true},
+		{"empty reason", `{}`, hideReasonUnspecified, true},
+		{"valid reason", `{"reason":"seen it"}`, "seen it", true},
+		{"another valid reason", `{"reason":"low quality"}`, "low quality", true},
+		{"unknown reason", `{"reason":"boring"}`, "", false},
+		{"malformed", `{"reason":`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/stories/1/hide", strings.NewReader(tt.body))
+			reason, ok := readHideReason(httptest.NewRecorder(), r)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
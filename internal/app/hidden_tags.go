@@ -11,13 +11,13 @@ import (
 func (a *App) hideTag(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	tagID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -25,7 +25,7 @@ func (a *App) hideTag(w http.ResponseWriter, r *http.Request) {
 		UserID: current.User.ID,
 		TagID:  tagID,
 	}); err != nil {
-		a.serverError(w, r, "hide tag", err)
+		a.jsonServerError(w, r, "hide tag", err)
 		return
 	}
 
@@ -36,13 +36,13 @@ func (a *App) hideTag(w http.ResponseWriter, r *http.Request) {
 func (a *App) unhideTag(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	tagID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -50,7 +50,7 @@ func (a *App) unhideTag(w http.ResponseWriter, r *http.Request) {
 		UserID: current.User.ID,
 		TagID:  tagID,
 	}); err != nil {
-		a.serverError(w, r, "unhide tag", err)
+		a.jsonServerError(w, r, "unhide tag", err)
 		return
 	}
 
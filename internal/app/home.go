@@ -1,8 +1,15 @@
 package app
 
 import (
+	"errors"
+	"maps"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/store"
@@ -37,28 +44,188 @@ func (a *App) page(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	opts, reveal := a.scoreFilter(r, data.Base, storyListOpts{rankByHotness: true, filterHidden: true, filterDuplicates: true, showPinned: true})
+	data.ShowLowScore = reveal
+
+	// Logged-in viewers get a summary of why each story is listed, and
+	// ?why=1 also explains what their filters left out.
+	var filtered filterCounts
+	explain := data.Base.IsLoggedIn && r.URL.Query().Get("why") == "1"
+	opts.explain = data.Base.IsLoggedIn
+	if explain {
+		opts.filtered = &filtered
+	}
+
 	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
 		HideDeleted:  true,
 		HiddenTagIds: hiddenTagIDs,
 		StoryLimit:   500,
-	}, storyListOpts{rankByHotness: true, filterNegScore: true, filterHidden: true, filterDuplicates: true})
+	}, opts)
 	if err != nil {
 		a.serverError(w, r, "load stories", err)
 		return
 	}
+	if explain {
+		data.Explain = explainFilters(opts, filtered, len(hiddenTagIDs))
+	}
 
 	data.Stories = stories
 	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, scoreQuery(reveal), data.Base.IsLoggedIn)
 	a.render(w, "home", data)
 }
 
+// newestSinces are the ?since= bounds GET /newest accepts.
+var newestSinces = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// newestSince returns the requested /newest bound and its length, or ""
+// and zero when ?since= is missing or unknown.
+func newestSince(r *http.Request) (string, time.Duration) {
+	name := r.URL.Query().Get("since")
+	if d, ok := newestSinces[name]; ok {
+		return name, d
+	}
+	return "", 0
+}
+
 // newest serves the chronological story listing (GET /newest and GET /newest/page/{page}).
 func (a *App) newest(w http.ResponseWriter, r *http.Request) {
+	since, d := newestSince(r)
+	// A bounded listing changes as stories age out of it, so the newest
+	// story's time says nothing about whether it is fresh.
+	if since == "" && a.listingNotModified(w, r) {
+		return
+	}
 	page := parsePage(r)
 	data := HomePageData{
 		Base:        a.baseData(r),
 		CurrentPage: page,
 		PagePath:    "/newest/page",
+		Since:       since,
+	}
+
+	var hiddenTagIDs []int64
+	if current, ok := auth.UserFromContext(r.Context()); ok {
+		var err error
+		hiddenTagIDs, err = a.Queries.ListUserHiddenTagIDs(r.Context(), current.User.ID)
+		if err != nil {
+			a.serverError(w, r, "get hidden tags", err)
+			return
+		}
+	}
+
+	params := store.ListStoriesParams{
+		HideDeleted:  true,
+		HiddenTagIds: hiddenTagIDs,
+	}
+	query := url.Values{}
+	if d > 0 {
+		params.CreatedAfter = pgtype.Timestamptz{Time: time.Now().Add(-d), Valid: true}
+		query.Set("since", since)
+	}
+
+	stories, hasMore, err := a.loadStoryList(r, data.Base, page, params, storyListOpts{filterHidden: true, filterDuplicates: true, paged: true})
+	if err != nil {
+		a.serverError(w, r, "load stories", err)
+		return
+	}
+
+	data.Stories = stories
+	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, query, false)
+	a.render(w, "home", data)
+}
+
+// listingStory is one story in a machine-readable listing.
+type listingStory struct {
+	ShortCode    string    `json:"short_code"`
+	Title        string    `json:"title"`
+	URL          string    `json:"url,omitempty"`
+	CommentsURL  string    `json:"comments_url"`
+	Username     string    `json:"username"`
+	Tags         []string  `json:"tags"`
+	Score        int       `json:"score"`
+	CommentCount int       `json:"comment_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// newestJSON serves the first page of /newest as JSON (GET /newest.json)
+// for pollers. It carries no per-user state, so If-Modified-Since is
+// answered for every caller and a 304 means nothing new.
+func (a *App) newestJSON(w http.ResponseWriter, r *http.Request) {
+	if a.storiesNotModified(w, r) {
+		return
+	}
+	stories, hasMore, err := a.loadStoryList(r, Base{}, 1, store.ListStoriesParams{
+		HideDeleted: true,
+	}, storyListOpts{filterDuplicates: true, paged: true})
+	if err != nil {
+		a.jsonServerError(w, r, "load stories", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"stories":  listingStories(stories, a.AppURL),
+		"has_more": hasMore,
+	})
+}
+
+func listingStories(items []StoryItem, appURL string) []listingStory {
+	stories := make([]listingStory, 0, len(items))
+	for _, item := range items {
+		s := listingStory{
+			ShortCode:    item.ShortCode,
+			Title:        item.Title,
+			CommentsURL:  appURL + storyPath(item.ShortCode, item.Title),
+			Username:     item.Username,
+			Tags:         make([]string, 0, len(item.Tags)),
+			Score:        item.Score,
+			CommentCount: item.CommentCount,
+			CreatedAt:    item.CreatedAt,
+		}
+		if !item.IsText {
+			s.URL = item.URL
+		}
+		for _, tag := range item.Tags {
+			s.Tags = append(s.Tags, tag.Tag)
+		}
+		stories = append(stories, s)
+	}
+	return stories
+}
+
+// topWindows are the time windows GET /top ranks stories within.
+var topWindows = map[string]time.Duration{
+	"24h":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// defaultTopWindow is used when ?window= is missing or unknown.
+const defaultTopWindow = "24h"
+
+// topWindow returns the requested /top window name and its length.
+func topWindow(r *http.Request) (string, time.Duration) {
+	name := r.URL.Query().Get("window")
+	if d, ok := topWindows[name]; ok {
+		return name, d
+	}
+	return defaultTopWindow, topWindows[defaultTopWindow]
+}
+
+// top serves stories from a fixed time window ranked by net score rather
+// than decayed hotness (GET /top and GET /top/page/{page}).
+func (a *App) top(w http.ResponseWriter, r *http.Request) {
+	page := parsePage(r)
+	window, d := topWindow(r)
+	data := HomePageData{
+		Base:        a.baseData(r),
+		CurrentPage: page,
+		PagePath:    "/top/page",
+		Window:      window,
 	}
 
 	var hiddenTagIDs []int64
@@ -74,8 +241,10 @@ func (a *App) newest(w http.ResponseWriter, r *http.Request) {
 	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
 		HideDeleted:  true,
 		HiddenTagIds: hiddenTagIDs,
+		CreatedAfter: pgtype.Timestamptz{Time: time.Now().Add(-d), Valid: true},
+		OrderByScore: true,
 		StoryLimit:   500,
-	}, storyListOpts{filterHidden: true, filterDuplicates: true})
+	}, storyListOpts{rankByScore: true, filterHidden: true, filterDuplicates: true})
 	if err != nil {
 		a.serverError(w, r, "load stories", err)
 		return
@@ -83,17 +252,185 @@ func (a *App) newest(w http.ResponseWriter, r *http.Request) {
 
 	data.Stories = stories
 	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, url.Values{"window": {window}}, false)
 	a.render(w, "home", data)
 }
 
+// listingNotModified answers conditional requests for anonymous
+// chronological listings, using the last story activity as Last-Modified.
+// Hotness-ranked listings reorder as stories age, so they never use it,
+// and logged-in viewers see per-user vote and hide state, so their pages
+// always render. The page also shows relative times ("5 minutes ago")
+// that go stale without any new activity, so the validator never predates
+// the current minute, the finest step timeAgo renders.
+func (a *App) listingNotModified(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := auth.UserFromContext(r.Context()); ok {
+		return false
+	}
+	latest, ok := a.latestStoryActivity(r)
+	if !ok {
+		return false
+	}
+	if minute := time.Now().Truncate(time.Minute); minute.After(latest) {
+		latest = minute
+	}
+	return checkNotModified(w, r, latest)
+}
+
+// storiesNotModified is listingNotModified for responses that carry
+// neither per-user state nor relative times.
+func (a *App) storiesNotModified(w http.ResponseWriter, r *http.Request) bool {
+	latest, ok := a.latestStoryActivity(r)
+	if !ok {
+		return false
+	}
+	return checkNotModified(w, r, latest)
+}
+
+// latestStoryActivity reads the last story activity from the same
+// database as the listing, so a lagging replica can't pair a stale page
+// with a fresh Last-Modified.
+func (a *App) latestStoryActivity(r *http.Request) (time.Time, bool) {
+	latest, err := a.readsFor(r).GetLatestStoryActivity(r.Context())
+	if err != nil {
+		a.Log.Error("get latest story activity", "error", err)
+		return time.Time{}, false
+	}
+	return latest.Time, latest.Valid
+}
+
+// checkNotModified sets Last-Modified and writes 304 Not Modified when the
+// request's If-Modified-Since is not older than modified.
+func checkNotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	// HTTP dates have one-second resolution.
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// maxPage caps page numbers so that page offsets, including the int32
+// offsets passed to SQL, can't overflow. No listing comes near it.
+const maxPage = 100_000
+
 func parsePage(r *http.Request) int {
 	pageStr := r.PathValue("page")
 	if pageStr == "" {
 		return 1
 	}
 	p, err := strconv.Atoi(pageStr)
+	// Atoi saturates numbers too big for an int, which are capped too.
+	if errors.Is(err, strconv.ErrRange) && p > 0 {
+		return maxPage
+	}
 	if err != nil || p < 1 {
 		return 1
 	}
-	return p
+	return min(p, maxPage)
+}
+
+// paginate returns the items on page (1-based) of perPage-sized pages and
+// whether any come after it. Pages below 1 are treated as page 1, and pages
+// past the end are empty with hasMore false. Listings paginated in SQL use
+// offsetFor and trimLookahead instead.
+func paginate[T any](items []T, page, perPage int) (pageItems []T, hasMore bool) {
+	if page < 1 {
+		page = 1
+	}
+	// Checked before multiplying so huge pages can't overflow.
+	if page-1 > len(items)/perPage {
+		return items[len(items):], false
+	}
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	return items[start:end], end < len(items)
+}
+
+// pageURL returns the URL of page n of a listing paginated under path
+// ("/page", "/t/go/page", ...). Page 1 is the listing's own URL, so
+// "/page" becomes "/" and "/newest/page" becomes "/newest".
+func pageURL(path string, n int) string {
+	if n <= 1 {
+		if root := strings.TrimSuffix(path, "/page"); root != "" {
+			return root
+		}
+		return "/"
+	}
+	return path + "/" + strconv.Itoa(n)
+}
+
+// listingLinks builds the links of page n of a listing paginated under
+// path, each keeping query. The canonical link leaves out ?show=low, which
+// only changes what a logged-in viewer sees; scoreToggle adds a link that
+// flips it.
+func listingLinks(path string, n int, hasMore bool, query url.Values, scoreToggle bool) ListingLinks {
+	link := func(n int, query url.Values) string {
+		if len(query) == 0 {
+			return pageURL(path, n)
+		}
+		return pageURL(path, n) + "?" + query.Encode()
+	}
+	with := func(key, value string) url.Values {
+		q := maps.Clone(query)
+		if q == nil {
+			q = url.Values{}
+		}
+		if value == "" {
+			q.Del(key)
+		} else {
+			q.Set(key, value)
+		}
+		return q
+	}
+
+	links := ListingLinks{
+		Canonical: link(n, with("show", "")),
+		Why:       link(n, with("why", "1")),
+	}
+	if n > 1 {
+		links.Prev = link(n-1, query)
+	}
+	if hasMore {
+		links.Next = link(n+1, query)
+	}
+	if scoreToggle {
+		if query.Get("show") == "low" {
+			links.ScoreToggle = link(n, with("show", ""))
+		} else {
+			links.ScoreToggle = link(n, with("show", "low"))
+		}
+	}
+	return links
+}
+
+// scoreQuery is the query that keeps low-scoring stories revealed across
+// a listing's links.
+func scoreQuery(reveal bool) url.Values {
+	if reveal {
+		return url.Values{"show": {"low"}}
+	}
+	return nil
+}
+
+// offsetFor returns the offset of page (1-based) of perPage-sized pages,
+// for listings paginated in SQL. parsePage caps pages so it fits an int32.
+func offsetFor(page, perPage int) int32 {
+	return int32((max(page, 1) - 1) * perPage)
+}
+
+// trimLookahead cuts rows fetched as perPage+1 from a page's offset down
+// to the page, reporting whether the extra row showed that more follow.
+func trimLookahead[T any](items []T, perPage int) ([]T, bool) {
+	if len(items) > perPage {
+		return items[:perPage], true
+	}
+	return items, false
 }
@@ -0,0 +1,556 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestCheckNotModified(t *testing.T) {
+	latest := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+
+	t.Run("no conditional header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.False(t, checkNotModified(w, r, latest))
+		assert.Equal(t, "Sun, 01 Mar 2026 12:00:00 GMT", w.Header().Get("Last-Modified"))
+	})
+
+	t.Run("no new story", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/newest", nil)
+		r.Header.Set("If-Modified-Since", latest.Format(http.TimeFormat))
+		assert.True(t, checkNotModified(w, r, latest))
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("new submission", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/newest", nil)
+		r.Header.Set("If-Modified-Since", latest.Format(http.TimeFormat))
+		assert.False(t, checkNotModified(w, r, latest.Add(time.Minute)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Sun, 01 Mar 2026 12:01:00 GMT", w.Header().Get("Last-Modified"))
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-Modified-Since", "yesterday")
+		assert.False(t, checkNotModified(w, r, latest))
+	})
+}
+
+func TestListingStories(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	got := listingStories([]StoryItem{
+		{ShortCode: "abc123", Title: "Go 2", URL: "https://go.dev/", Username: "alice", Tags: []StoryTag{{Tag: "go"}}, Score: 4, CommentCount: 2, CreatedAt: created},
+		{ShortCode: "def456", Title: "Ask: tips?", URL: "/x/def456", IsText: true, Username: "bob", CreatedAt: created},
+	}, "https://crow.watch")
+
+	require.Len(t, got, 2)
+	assert.Equal(t, listingStory{
+		ShortCode:    "abc123",
+		Title:        "Go 2",
+		URL:          "https://go.dev/",
+		CommentsURL:  "https://crow.watch/x/abc123/go_2",
+		Username:     "alice",
+		Tags:         []string{"go"},
+		Score:        4,
+		CommentCount: 2,
+		CreatedAt:    created,
+	}, got[0])
+	assert.Empty(t, got[1].URL, "text posts have no external link")
+	assert.Equal(t, []string{}, got[1].Tags)
+}
+
+func TestTopWindow(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		d     time.Duration
+	}{
+		{"", "24h", 24 * time.Hour},
+		{"?window=week", "week", 7 * 24 * time.Hour},
+		{"?window=month", "month", 30 * 24 * time.Hour},
+		{"?window=year", "24h", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/top"+tt.query, nil)
+		name, d := topWindow(r)
+		assert.Equal(t, tt.name, name, tt.query)
+		assert.Equal(t, tt.d, d, tt.query)
+	}
+}
+
+func TestBuildStoryListRankByScore(t *testing.T) {
+	now := time.Now()
+	rows := []store.ListStoriesRow{
+		{ID: 1, Upvotes: 2, CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}, Tags: []byte("[]")},
+		{ID: 2, Upvotes: 9, CreatedAt: pgtype.Timestamptz{Time: now.Add(-6 * 24 * time.Hour), Valid: true}, Tags: []byte("[]")},
+		{ID: 3, Upvotes: 9, Downvotes: 4, AdminAdjustment: 4, CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}, Tags: []byte("[]")},
+	}
+	items, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{rankByScore: true})
+	require.NoError(t, err)
+	var ids []int64
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+	assert.Equal(t, []int64{2, 3, 1}, ids, "highest score first regardless of age; uncontested votes win ties")
+}
+
+func TestTopListing(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	newStory := func(code string, upvotes int32, age time.Duration) {
+		t.Helper()
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    u.ID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+			Upvotes:   upvotes,
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-age), Valid: true},
+			ID:        s.ID,
+		}))
+	}
+	newStory("fresh1", 3, time.Hour)
+	newStory("fresh2", 8, 2*time.Hour)
+	newStory("weekly", 50, 3*24*time.Hour)
+	newStory("stale1", 99, 60*24*time.Hour)
+
+	get := func(query string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/top"+query, nil)
+		w := httptest.NewRecorder()
+		a.top(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	body := get("")
+	assert.Contains(t, body, "Story fresh1")
+	assert.NotContains(t, body, "Story weekly", "outside the 24h window")
+	assert.Less(t, strings.Index(body, "Story fresh2"), strings.Index(body, "Story fresh1"), "higher score first")
+
+	body = get("?window=week")
+	assert.Less(t, strings.Index(body, "Story weekly"), strings.Index(body, "Story fresh2"))
+	assert.NotContains(t, body, "Story stale1")
+
+	body = get("?window=month")
+	assert.NotContains(t, body, "Story stale1", "older than a month")
+}
+
+func TestNewestSince(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		d     time.Duration
+	}{
+		{"", "", 0},
+		{"?since=1h", "1h", time.Hour},
+		{"?since=24h", "24h", 24 * time.Hour},
+		{"?since=7d", "7d", 7 * 24 * time.Hour},
+		{"?since=2y", "", 0},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/newest"+tt.query, nil)
+		name, d := newestSince(r)
+		assert.Equal(t, tt.name, name, tt.query)
+		assert.Equal(t, tt.d, d, tt.query)
+	}
+}
+
+func TestNewestSinceListing(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	newStory := func(code string, age time.Duration) {
+		t.Helper()
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    u.ID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-age), Valid: true},
+			ID:        s.ID,
+		}))
+	}
+	newStory("minute", time.Minute)
+	newStory("hours3", 3*time.Hour)
+	newStory("days3x", 3*24*time.Hour)
+	newStory("month1", 30*24*time.Hour)
+
+	get := func(query string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/newest"+query, nil)
+		w := httptest.NewRecorder()
+		a.newest(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	body := get("?since=1h")
+	assert.Contains(t, body, "Story minute")
+	assert.NotContains(t, body, "Story hours3")
+
+	body = get("?since=24h")
+	assert.Contains(t, body, "Story hours3")
+	assert.NotContains(t, body, "Story days3x")
+
+	body = get("?since=7d")
+	assert.Contains(t, body, "Story days3x")
+	assert.NotContains(t, body, "Story month1")
+
+	body = get("?since=bogus")
+	assert.Contains(t, body, "Story month1", "unknown bounds fall back to no filter")
+}
+
+func TestNewestPagedInSQL(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	var ids []int64
+	for i := range storiesPerPage + 2 {
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    u.ID,
+			Title:     fmt.Sprintf("Story %02d", i),
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: fmt.Sprintf("code%02d", i),
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Duration(i) * time.Minute), Valid: true},
+			ID:        s.ID,
+		}))
+		ids = append(ids, s.ID)
+	}
+	// A pin lifts a story on the front page but not on /newest.
+	require.NoError(t, a.Queries.PinStory(ctx, store.PinStoryParams{
+		PinnedUntil: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		ID:          ids[len(ids)-1],
+	}))
+
+	get := func(path string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if page := strings.TrimPrefix(path, "/newest/page/"); page != path {
+			req.SetPathValue("page", page)
+		}
+		w := httptest.NewRecorder()
+		a.newest(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	body := get("/newest")
+	assert.Contains(t, body, "Story 00")
+	assert.Contains(t, body, fmt.Sprintf("Story %02d", storiesPerPage-1))
+	assert.NotContains(t, body, fmt.Sprintf("Story %02d", storiesPerPage))
+	assert.NotContains(t, body, fmt.Sprintf("Story %02d", storiesPerPage+1), "pins don't reorder /newest")
+	assert.Contains(t, body, `class="more-link" href="/newest/page/2"`)
+
+	body = get("/newest/page/2")
+	assert.NotContains(t, body, fmt.Sprintf("Story %02d", storiesPerPage-1))
+	assert.Contains(t, body, fmt.Sprintf("Story %02d", storiesPerPage))
+	assert.Contains(t, body, fmt.Sprintf("Story %02d", storiesPerPage+1))
+	assert.NotContains(t, body, `href="/newest/page/3"`)
+}
+
+func TestPageURL(t *testing.T) {
+	assert.Equal(t, "/", pageURL("/page", 1))
+	assert.Equal(t, "/page/2", pageURL("/page", 2))
+	assert.Equal(t, "/newest", pageURL("/newest/page", 1))
+	assert.Equal(t, "/t/go", pageURL("/t/go/page", 1))
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		page string
+		want int
+	}{
+		{"", 1},
+		{"2", 2},
+		{"0", 1},
+		{"-3", 1},
+		{"abc", 1},
+		{"400000000000000000", maxPage},
+		{"99999999999999999999999", maxPage},
+		{"-99999999999999999999999", 1},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/page/"+tt.page, nil)
+		r.SetPathValue("page", tt.page)
+		assert.Equal(t, tt.want, parsePage(r), tt.page)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name    string
+		page    int
+		want    []int
+		hasMore bool
+	}{
+		{"first page", 1, []int{1, 2, 3}, true},
+		{"middle page", 2, []int{4, 5, 6}, true},
+		{"last page", 3, []int{7}, false},
+		{"beyond last page", 4, []int{}, false},
+		{"far beyond last page", 100, []int{}, false},
+		{"page below one", 0, []int{1, 2, 3}, true},
+		{"huge page", math.MaxInt, []int{}, false},
+		{"huge page minus one", math.MaxInt / 3, []int{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hasMore := paginate(items, tt.page, 3)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.hasMore, hasMore)
+		})
+	}
+
+	t.Run("exact fit", func(t *testing.T) {
+		got, hasMore := paginate(items[:6], 2, 3)
+		assert.Equal(t, []int{4, 5, 6}, got)
+		assert.False(t, hasMore)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		got, hasMore := paginate([]int(nil), 1, 3)
+		assert.Empty(t, got)
+		assert.False(t, hasMore)
+	})
+}
+
+func TestListingLinks(t *testing.T) {
+	links := listingLinks("/newest/page", 2, true, nil, false)
+	assert.Equal(t, ListingLinks{
+		Canonical: "/newest/page/2",
+		Prev:      "/newest",
+		Next:      "/newest/page/3",
+		Why:       "/newest/page/2?why=1",
+	}, links)
+
+	links = listingLinks("/top/page", 1, false, url.Values{"window": {"week"}}, false)
+	assert.Equal(t, "/top?window=week", links.Canonical)
+	assert.Empty(t, links.Prev)
+	assert.Empty(t, links.Next)
+
+	links = listingLinks("/t/go/page", 2, true, scoreQuery(true), true)
+	assert.Equal(t, "/t/go/page/2", links.Canonical, "the canonical page leaves out ?show=low")
+	assert.Equal(t, "/t/go?show=low", links.Prev)
+	assert.Equal(t, "/t/go/page/3?show=low", links.Next)
+	assert.Equal(t, "/t/go/page/2", links.ScoreToggle)
+	assert.Equal(t, "/t/go/page/2?show=low&why=1", links.Why)
+
+	links = listingLinks("/t/go/page", 1, false, scoreQuery(false), true)
+	assert.Equal(t, "/t/go?show=low", links.ScoreToggle)
+}
+
+func TestRenderPaginationLinks(t *testing.T) {
+	a := testApp(t)
+
+	w := httptest.NewRecorder()
+	a.render(w, "home", HomePageData{PagePath: "/newest/page", CurrentPage: 2, HasMore: true,
+		Links: listingLinks("/newest/page", 2, true, nil, false)})
+	body := w.Body.String()
+	assert.Contains(t, body, `<link rel="canonical" href="/newest/page/2" />`)
+	assert.Contains(t, body, `<link rel="prev" href="/newest" />`)
+	assert.Contains(t, body, `<link rel="next" href="/newest/page/3" />`)
+
+	w = httptest.NewRecorder()
+	a.render(w, "home", HomePageData{PagePath: "/page", CurrentPage: 1,
+		Links: listingLinks("/page", 1, false, nil, false)})
+	body = w.Body.String()
+	assert.Contains(t, body, `<link rel="canonical" href="/" />`)
+	assert.NotContains(t, body, `rel="prev"`)
+	assert.NotContains(t, body, `rel="next"`)
+
+	w = httptest.NewRecorder()
+	a.render(w, "tag", TagPageData{TagName: "go", PagePath: "/t/go/page", CurrentPage: 2, HasMore: true,
+		Links: listingLinks("/t/go/page", 2, true, nil, false)})
+	body = w.Body.String()
+	assert.Contains(t, body, `<link rel="prev" href="/t/go" />`)
+	assert.Contains(t, body, `<link rel="next" href="/t/go/page/3" />`)
+}
+
+func TestOffsetFor(t *testing.T) {
+	assert.Equal(t, int32(0), offsetFor(1, 25))
+	assert.Equal(t, int32(50), offsetFor(3, 25))
+	assert.Equal(t, int32(0), offsetFor(0, 25))
+	assert.Equal(t, int32((maxPage-1)*50), offsetFor(maxPage, 50))
+}
+
+func TestTrimLookahead(t *testing.T) {
+	got, hasMore := trimLookahead([]int{1, 2, 3, 4}, 3)
+	assert.Equal(t, []int{1, 2, 3}, got)
+	assert.True(t, hasMore)
+
+	got, hasMore = trimLookahead([]int{1, 2, 3}, 3)
+	assert.Equal(t, []int{1, 2, 3}, got)
+	assert.False(t, hasMore)
+}
+
+func TestBuildStoryListWhy(t *testing.T) {
+	now := time.Now()
+	rows := []store.ListStoriesRow{
+		{ID: 1, UserID: 10, Upvotes: 42, CreatedAt: pgtype.Timestamptz{Time: now.Add(-2*time.Hour - time.Minute), Valid: true}, Tags: []byte("[]")},
+		{ID: 2, UserID: 20, Upvotes: 3, CreatedAt: pgtype.Timestamptz{Time: now.Add(-30 * time.Minute), Valid: true}, Tags: []byte("[]")},
+	}
+	base := Base{IsLoggedIn: true, Username: "alice"}
+
+	items, _, err := buildStoryList(rows, base, 1, storyListOpts{rankByHotness: true, explain: true, probation: map[int64]bool{20: true}})
+	require.NoError(t, err)
+	why := map[int64]string{}
+	for _, it := range items {
+		why[it.ID] = it.Why
+	}
+	assert.Equal(t, "ranked by hotness from score 42 and age, posted 2 hours ago", why[1])
+	assert.Equal(t, "ranked by hotness from score 3 and age, posted 30 minutes ago, ranked lower while the submitter is new", why[2])
+
+	items, _, err = buildStoryList(rows, base, 1, storyListOpts{rankByScore: true, explain: true})
+	require.NoError(t, err)
+	assert.Equal(t, "ranked by score 42, posted 2 hours ago", items[0].Why)
+
+	items, _, err = buildStoryList(rows, base, 1, storyListOpts{rankByHotness: true})
+	require.NoError(t, err)
+	assert.Empty(t, items[0].Why, "only filled in when asked for")
+
+	rows[0].DeletedAt = pgtype.Timestamptz{Time: now, Valid: true}
+	items, _, err = buildStoryList(rows, base, 1, storyListOpts{rankByHotness: true, explain: true})
+	require.NoError(t, err)
+	for _, it := range items {
+		if it.ID == 1 {
+			assert.Empty(t, it.Why, "deleted stories aren't explained")
+		}
+	}
+}
+
+func TestExplainFilters(t *testing.T) {
+	var counts filterCounts
+	rows := []store.ListStoriesRow{
+		{ID: 1, Tags: []byte("[]"), HasHidden: true},
+		{ID: 2, Tags: []byte("[]"), Upvotes: 5},
+		{ID: 3, Tags: []byte("[]"), Upvotes: 5, DuplicateOfShortCode: pgtype.Text{String: "abc123", Valid: true}},
+		{ID: 4, Tags: []byte("[]"), Downvotes: 3},
+		{ID: 5, Tags: []byte("[]"), Downvotes: 4},
+	}
+	opts := storyListOpts{rankByHotness: true, filterHidden: true, filterDuplicates: true, filterLowScore: true, minScore: -2, filtered: &counts}
+
+	items, _, err := buildStoryList(rows, Base{IsLoggedIn: true}, 1, opts)
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, filterCounts{Hidden: 1, LowScore: 2, Duplicates: 1}, counts)
+
+	assert.Equal(t, []string{
+		"Stories are ranked by score and age, with newer stories favored.",
+		"Left out stories tagged with any of your 3 hidden tags.",
+		"Left out 1 story you hid.",
+		"Left out 2 stories scoring below -2.",
+		"Left out 1 story marked as duplicates.",
+	}, explainFilters(opts, counts, 3))
+
+	assert.Equal(t, []string{"Stories are listed newest first."}, explainFilters(storyListOpts{}, filterCounts{}, 0))
+}
+
+func TestRenderStoryWhy(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "home", HomePageData{
+		Base:     Base{IsLoggedIn: true, Username: "alice"},
+		PagePath: "/page",
+		Stories: []StoryItem{
+			{ID: 1, ShortCode: "aaaaaa", Title: "Ranked", Username: "bob", IsText: true, Why: "ranked by score 42, posted 2 hours ago"},
+		},
+		Explain: []string{"Left out 1 story you hid."},
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `<div class="story-item__meta" title="ranked by score 42, posted 2 hours ago">`)
+	assert.Contains(t, body, "<li>Left out 1 story you hid.</li>")
+	assert.NotContains(t, body, "why these stories?", "no link while the explanation is shown")
+}
+
+func TestListingValidatorCoversRelativeTimes(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	author, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "author", Email: "author@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    author.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	posted := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	_, err = pool.Exec(ctx, "UPDATE stories SET created_at = $1, updated_at = $1, score_activity_at = $1", posted)
+	require.NoError(t, err)
+
+	request := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/newest", nil)
+		r.Header.Set("If-Modified-Since", posted.Format(http.TimeFormat))
+		return r
+	}
+
+	// The JSON listing carries absolute times, so nothing has changed.
+	w := httptest.NewRecorder()
+	assert.True(t, a.storiesNotModified(w, request()))
+
+	// The page's "1 hour ago" has, so it renders again.
+	w = httptest.NewRecorder()
+	assert.False(t, a.listingNotModified(w, request()))
+	modified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	require.NoError(t, err)
+	assert.True(t, modified.After(posted))
+}
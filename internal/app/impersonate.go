@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// limitImpersonation makes impersonation view-only: while a moderator is
+// acting as a user, every write except stopping is refused, and the
+// account pages, which show the user's private settings and data export,
+// are off limits.
+func (a *App) limitImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current, ok := auth.UserFromContext(r.Context())
+		if !ok || current.Impersonator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/account" || strings.HasPrefix(r.URL.Path, "/account/") {
+			http.Error(w, "Account pages aren't available while impersonating", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.URL.Path != "/mod/stop-impersonating" {
+			http.Error(w, "Impersonation is view-only", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// impersonateUser serves POST /mod/impersonate/{username}: the moderator's
+// own session starts acting as the user until they stop impersonating.
+func (a *App) impersonateUser(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || current.Impersonator != nil || !current.User.IsModerator {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	targetID, err := a.Queries.GetUserIDByUsername(r.Context(), r.PathValue("username"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get user by username", err)
+		return
+	}
+	target, err := a.Queries.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		a.serverError(w, r, "get user by id", err)
+		return
+	}
+
+	if err := auth.CheckImpersonation(current.User, target); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		reason = "(no reason given)"
+	}
+
+	if err := a.setImpersonation(r, current, "user.impersonate", target.ID, reason, pgtype.Int8{Int64: target.ID, Valid: true}); err != nil {
+		a.serverError(w, r, "start impersonation", err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// stopImpersonating serves POST /mod/stop-impersonating and returns the
+// session to the moderator.
+func (a *App) stopImpersonating(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || current.Impersonator == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	moderator := auth.AuthenticatedUser{SessionID: current.SessionID, User: *current.Impersonator}
+	if err := a.setImpersonation(r, moderator, "user.impersonate_stop", current.User.ID, "", pgtype.Int8{}); err != nil {
+		a.serverError(w, r, "stop impersonation", err)
+		return
+	}
+
+	http.Redirect(w, r, "/u/"+current.User.Username, http.StatusSeeOther)
+}
+
+// setImpersonation updates the session's impersonation target and records
+// the change in the moderation log.
+func (a *App) setImpersonation(r *http.Request, moderator auth.AuthenticatedUser, action string, targetID int64, reason string, impersonated pgtype.Int8) error {
+	metadataJSON, err := json.Marshal(map[string]any{"session_id": moderator.SessionID})
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.SetSessionImpersonation(r.Context(), store.SetSessionImpersonationParams{
+		ImpersonatedUserID: impersonated,
+		ID:                 moderator.SessionID,
+	}); err != nil {
+		return err
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: moderator.User.ID,
+		Action:      action,
+		TargetType:  "user",
+		TargetID:    targetID,
+		Reason:      reason,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		return err
+	}
+	a.notifyModeration(moderator.User.Username, entry)
+	return nil
+}
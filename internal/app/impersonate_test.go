@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestImpersonateRequiresModerator(t *testing.T) {
+	a := testApp(t)
+	mod := store.User{ID: 1, Username: "mod", IsModerator: true}
+
+	tests := []struct {
+		name string
+		user auth.AuthenticatedUser
+	}{
+		{"regular user", auth.AuthenticatedUser{User: store.User{ID: 2, Username: "alice"}}},
+		{"already impersonating", auth.AuthenticatedUser{User: store.User{ID: 2, Username: "alice"}, Impersonator: &mod}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mod/impersonate/bob", nil)
+			req.SetPathValue("username", "bob")
+			req = req.WithContext(auth.ContextWithUser(req.Context(), tt.user))
+			w := httptest.NewRecorder()
+			a.impersonateUser(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+	}
+}
+
+func TestImpersonationIsViewOnly(t *testing.T) {
+	a := testApp(t)
+	mod := store.User{ID: 1, Username: "mod", IsModerator: true}
+	impersonating := auth.AuthenticatedUser{User: store.User{ID: 2, Username: "alice"}, Impersonator: &mod}
+	h := a.limitImpersonation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/", http.StatusTeapot},
+		{http.MethodHead, "/u/alice", http.StatusTeapot},
+		{http.MethodPost, "/submit", http.StatusForbidden},
+		{http.MethodPost, "/stories/1/upvote", http.StatusForbidden},
+		{http.MethodPost, "/x/abc123/comments", http.StatusForbidden},
+		{http.MethodPost, "/account/profile", http.StatusForbidden},
+		{http.MethodGet, "/account", http.StatusForbidden},
+		{http.MethodGet, "/account/export", http.StatusForbidden},
+		{http.MethodGet, "/account/tokens", http.StatusForbidden},
+		{http.MethodPost, "/mod/stop-impersonating", http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req = req.WithContext(auth.ContextWithUser(req.Context(), impersonating))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, tt.want, w.Code, "%s %s", tt.method, tt.path)
+	}
+
+	// The moderator's own session is unaffected.
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: mod}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestRenderImpersonationBanner(t *testing.T) {
+	a := testApp(t)
+
+	w := httptest.NewRecorder()
+	a.render(w, "about", struct{ Base Base }{Base: Base{IsLoggedIn: true, Username: "alice", ImpersonatedBy: "mod"}})
+	body := w.Body.String()
+	assert.Contains(t, body, `action="/mod/stop-impersonating"`)
+	assert.Contains(t, body, "signed in as mod")
+
+	w = httptest.NewRecorder()
+	a.render(w, "about", struct{ Base Base }{Base: Base{IsLoggedIn: true, Username: "alice"}})
+	assert.NotContains(t, w.Body.String(), "stop-impersonating")
+}
+
+func TestImpersonationSession(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.Sessions = auth.NewSessionManager(a.Queries, "test_session", time.Hour, auth.CookieOptions{}, a.Log)
+
+	createUser := func(name string, moderator bool) int64 {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "secret-digest",
+		})
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, "UPDATE users SET is_moderator = $1 WHERE id = $2", moderator, u.ID)
+		require.NoError(t, err)
+		return u.ID
+	}
+	modID := createUser("mod", true)
+	createUser("alice", false)
+	createUser("othermod", true)
+
+	require.NoError(t, a.Queries.CreateSession(ctx, store.CreateSessionParams{
+		UserID:    modID,
+		TokenHash: auth.HashToken("mod-token"),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}))
+
+	// serve runs handler behind the session middleware and returns the
+	// user the next request on the same session sees.
+	var seen auth.AuthenticatedUser
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.UserFromContext(r.Context())
+	})
+	serve := func(path string, handler http.HandlerFunc) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.AddCookie(&http.Cookie{Name: "test_session", Value: "mod-token"})
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /mod/impersonate/{username}", handler)
+		mux.HandleFunc("POST /mod/stop-impersonating", handler)
+		w := httptest.NewRecorder()
+		a.Sessions.AuthenticateRequest(mux).ServeHTTP(w, req)
+
+		next := httptest.NewRequest(http.MethodGet, "/", nil)
+		next.AddCookie(&http.Cookie{Name: "test_session", Value: "mod-token"})
+		a.Sessions.AuthenticateRequest(whoami).ServeHTTP(httptest.NewRecorder(), next)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve("/mod/impersonate/othermod", a.impersonateUser))
+	assert.Equal(t, "mod", seen.User.Username)
+	assert.Nil(t, seen.Impersonator)
+
+	assert.Equal(t, http.StatusSeeOther, serve("/mod/impersonate/alice", a.impersonateUser))
+	assert.Equal(t, "alice", seen.User.Username)
+	require.NotNil(t, seen.Impersonator)
+	assert.Equal(t, "mod", seen.Impersonator.Username)
+	assert.Empty(t, seen.User.PasswordDigest, "the target's password digest is never exposed")
+
+	assert.Equal(t, http.StatusSeeOther, serve("/mod/stop-impersonating", a.stopImpersonating))
+	assert.Equal(t, "mod", seen.User.Username)
+	assert.Nil(t, seen.Impersonator)
+}
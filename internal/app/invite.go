@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,6 +19,83 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// InviteQuota caps how many invitations a regular user may create.
+// Outstanding counts pending invitations that have not been claimed or
+// expired; Total counts every invitation ever created. Zero means no limit.
+type InviteQuota struct {
+	Outstanding int
+	Total       int
+}
+
+func (q InviteQuota) unlimited() bool {
+	return q.Outstanding <= 0 && q.Total <= 0
+}
+
+// exceeded returns a user-facing message when the counts hit the quota,
+// or an empty string when another invitation is allowed.
+func (q InviteQuota) exceeded(counts store.CountInvitationsByUserRow) string {
+	if q.Total > 0 && counts.Total >= int64(q.Total) {
+		return fmt.Sprintf("You have used all %d of your invitations.", q.Total)
+	}
+	if q.Outstanding > 0 && counts.Outstanding >= int64(q.Outstanding) {
+		return fmt.Sprintf("You already have %d pending invitations. Wait for one to be used or expire.", q.Outstanding)
+	}
+	return ""
+}
+
+// inviteQuotaError checks the user's invitation counts against the
+// configured quota. Moderators are exempt.
+func (a *App) inviteQuotaError(ctx context.Context, user store.User) (string, error) {
+	if user.IsModerator || a.InviteQuota.unlimited() {
+		return "", nil
+	}
+	counts, err := a.Queries.CountInvitationsByUser(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return a.InviteQuota.exceeded(counts), nil
+}
+
+// createInvitation creates an invitation from user. For users under a
+// quota the quota is checked again in the same transaction, so concurrent
+// requests can't overshoot it, and a user-facing message is returned
+// instead when it is used up.
+func (a *App) createInvitation(ctx context.Context, user store.User, arg store.CreateInvitationParams) (string, error) {
+	if user.IsModerator || a.InviteQuota.unlimited() {
+		_, err := a.Queries.CreateInvitation(ctx, arg)
+		return "", err
+	}
+
+	tx, err := a.Pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := a.Queries.WithTx(tx)
+	if err := qtx.LockUser(ctx, user.ID); err != nil {
+		return "", fmt.Errorf("lock user: %w", err)
+	}
+	_, err = qtx.CreateInvitationWithinQuota(ctx, store.CreateInvitationWithinQuotaParams{
+		InviterID:      arg.InviterID,
+		Email:          arg.Email,
+		TokenHash:      arg.TokenHash,
+		MaxTotal:       int32(a.InviteQuota.Total),
+		MaxOutstanding: int32(a.InviteQuota.Outstanding),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		counts, err := qtx.CountInvitationsByUser(ctx, user.ID)
+		if err != nil {
+			return "", fmt.Errorf("count invitations: %w", err)
+		}
+		return a.InviteQuota.exceeded(counts), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", tx.Commit(ctx)
+}
+
 func (a *App) invitePage(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -36,23 +114,7 @@ func (a *App) invitePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows := make([]InviteRow, len(invitations))
-	for i, inv := range invitations {
-		rows[i] = InviteRow{
-			CreatedAt: inv.CreatedAt.Time,
-		}
-		if inv.Email.Valid {
-			rows[i].Email = inv.Email.String
-		}
-		if inv.RegisteredUsername.Valid {
-			rows[i].RegisteredUsername = inv.RegisteredUsername.String
-			rows[i].Status = "Registered"
-		} else if time.Since(inv.CreatedAt.Time) > 24*time.Hour {
-			rows[i].Status = "Expired"
-		} else {
-			rows[i].Status = "Pending"
-		}
-	}
+	rows := buildInviteRows(invitations, time.Now())
 
 	a.render(w, "invite", InvitePageData{
 		Base:        a.baseData(r),
@@ -68,15 +130,25 @@ func (a *App) inviteByEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A user out of invitations is told so without using up a rate
+	// limit slot.
+	if msg, err := a.inviteQuotaError(r.Context(), current.User); err != nil {
+		a.serverError(w, r, "count invitations", err)
+		return
+	} else if msg != "" {
+		a.renderInvitePage(w, r, "email", "", "", msg)
+		return
+	}
+
 	if a.InviteLimiter != nil {
-		if !a.InviteLimiter.Allow(strconv.FormatInt(current.User.ID, 10)) {
-			a.renderInvitePage(w, r, "email", "", "", "Too many invitations. Please try again later.")
+		key := strconv.FormatInt(current.User.ID, 10)
+		if !a.InviteLimiter.Allow(key) {
+			a.renderInvitePageStatus(w, r, http.StatusTooManyRequests, "email", "", "", rateLimitMessage(w, a.InviteLimiter, key, "invitations"))
 			return
 		}
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.renderInvitePage(w, r, "email", "", "", "Invalid request.")
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -85,6 +157,10 @@ func (a *App) inviteByEmail(w http.ResponseWriter, r *http.Request) {
 		a.renderInvitePage(w, r, "email", email, "", "Please enter an e-mail address.")
 		return
 	}
+	if msg := a.EmailPolicy.check(email); msg != "" {
+		a.renderInvitePage(w, r, "email", email, "", msg)
+		return
+	}
 
 	// Check if email is already registered.
 	_, err := a.Queries.GetUserByEmail(r.Context(), email)
@@ -103,7 +179,7 @@ func (a *App) inviteByEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = a.Queries.CreateInvitation(r.Context(), store.CreateInvitationParams{
+	msg, err := a.createInvitation(r.Context(), current.User, store.CreateInvitationParams{
 		InviterID: current.User.ID,
 		Email:     pgtype.Text{String: email, Valid: true},
 		TokenHash: auth.HashToken(token),
@@ -112,6 +188,10 @@ func (a *App) inviteByEmail(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "create invitation", err)
 		return
 	}
+	if msg != "" {
+		a.renderInvitePage(w, r, "email", email, "", msg)
+		return
+	}
 
 	inviteURL := a.AppURL + "/register/" + token
 
@@ -151,9 +231,20 @@ func (a *App) inviteByLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A user out of invitations is told so without using up a rate
+	// limit slot.
+	if msg, err := a.inviteQuotaError(r.Context(), current.User); err != nil {
+		a.serverError(w, r, "count invitations", err)
+		return
+	} else if msg != "" {
+		a.renderInvitePage(w, r, "link", "", "", msg)
+		return
+	}
+
 	if a.InviteLimiter != nil {
-		if !a.InviteLimiter.Allow(strconv.FormatInt(current.User.ID, 10)) {
-			a.renderInvitePage(w, r, "link", "", "", "Too many invitations. Please try again later.")
+		key := strconv.FormatInt(current.User.ID, 10)
+		if !a.InviteLimiter.Allow(key) {
+			a.renderInvitePageStatus(w, r, http.StatusTooManyRequests, "link", "", "", rateLimitMessage(w, a.InviteLimiter, key, "invitations"))
 			return
 		}
 	}
@@ -164,7 +255,7 @@ func (a *App) inviteByLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = a.Queries.CreateInvitation(r.Context(), store.CreateInvitationParams{
+	msg, err := a.createInvitation(r.Context(), current.User, store.CreateInvitationParams{
 		InviterID: current.User.ID,
 		TokenHash: auth.HashToken(token),
 	})
@@ -172,12 +263,20 @@ func (a *App) inviteByLink(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "create invitation", err)
 		return
 	}
+	if msg != "" {
+		a.renderInvitePage(w, r, "link", "", "", msg)
+		return
+	}
 
 	inviteURL := a.AppURL + "/register/" + token
 	a.renderInvitePage(w, r, "link", "", inviteURL, "")
 }
 
 func (a *App) renderInvitePage(w http.ResponseWriter, r *http.Request, tab, email, inviteURL, errMsg string) {
+	a.renderInvitePageStatus(w, r, http.StatusOK, tab, email, inviteURL, errMsg)
+}
+
+func (a *App) renderInvitePageStatus(w http.ResponseWriter, r *http.Request, status int, tab, email, inviteURL, errMsg string) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -185,23 +284,7 @@ func (a *App) renderInvitePage(w http.ResponseWriter, r *http.Request, tab, emai
 	}
 
 	invitations, _ := a.Queries.ListInvitationsByUser(r.Context(), current.User.ID)
-	rows := make([]InviteRow, len(invitations))
-	for i, inv := range invitations {
-		rows[i] = InviteRow{
-			CreatedAt: inv.CreatedAt.Time,
-		}
-		if inv.Email.Valid {
-			rows[i].Email = inv.Email.String
-		}
-		if inv.RegisteredUsername.Valid {
-			rows[i].RegisteredUsername = inv.RegisteredUsername.String
-			rows[i].Status = "Registered"
-		} else if time.Since(inv.CreatedAt.Time) > 24*time.Hour {
-			rows[i].Status = "Expired"
-		} else {
-			rows[i].Status = "Pending"
-		}
-	}
+	rows := buildInviteRows(invitations, time.Now())
 
 	data := InvitePageData{
 		Base:        a.baseData(r),
@@ -218,7 +301,36 @@ func (a *App) renderInvitePage(w http.ResponseWriter, r *http.Request, tab, emai
 		data.Success = "Invitation sent!"
 	}
 
-	a.render(w, "invite", data)
+	a.renderStatus(w, status, "invite", data)
+}
+
+// invitationTTL mirrors the 24 hour window GetInvitationByTokenHash uses
+// to accept an invitation token.
+const invitationTTL = 24 * time.Hour
+
+// buildInviteRows maps invitations to display rows. An unclaimed invitation
+// is expired once it is no longer newer than invitationTTL, matching the
+// database rule.
+func buildInviteRows(invitations []store.ListInvitationsByUserRow, now time.Time) []InviteRow {
+	rows := make([]InviteRow, len(invitations))
+	for i, inv := range invitations {
+		rows[i] = InviteRow{
+			CreatedAt: inv.CreatedAt.Time,
+		}
+		if inv.Email.Valid {
+			rows[i].Email = inv.Email.String
+		}
+		switch {
+		case inv.RegisteredUsername.Valid:
+			rows[i].RegisteredUsername = inv.RegisteredUsername.String
+			rows[i].Status = "Registered"
+		case !inv.CreatedAt.Time.After(now.Add(-invitationTTL)):
+			rows[i].Status = "Expired"
+		default:
+			rows[i].Status = "Pending"
+		}
+	}
+	return rows
 }
 
 func generateInviteToken() (string, error) {
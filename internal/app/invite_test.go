@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/ratelimit"
+	"crow.watch/internal/store"
+)
+
+func TestInviteQuotaExceeded(t *testing.T) {
+	tests := []struct {
+		name        string
+		quota       InviteQuota
+		outstanding int64
+		total       int64
+		blocked     bool
+	}{
+		{"unlimited", InviteQuota{}, 100, 1000, false},
+		{"below outstanding", InviteQuota{Outstanding: 3}, 2, 10, false},
+		{"at outstanding", InviteQuota{Outstanding: 3}, 3, 3, true},
+		{"below total", InviteQuota{Total: 5}, 0, 4, false},
+		{"at total", InviteQuota{Total: 5}, 0, 5, true},
+		{"total wins over free slots", InviteQuota{Outstanding: 3, Total: 5}, 0, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.quota.exceeded(store.CountInvitationsByUserRow{Outstanding: tt.outstanding, Total: tt.total})
+			assert.Equal(t, tt.blocked, msg != "", msg)
+		})
+	}
+}
+
+func TestInviteQuotaClaimFreesOutstandingSlot(t *testing.T) {
+	q := InviteQuota{Outstanding: 2}
+
+	// Two pending invitations fill the quota.
+	assert.NotEmpty(t, q.exceeded(store.CountInvitationsByUserRow{Outstanding: 2, Total: 2}))
+
+	// Once one is claimed it no longer counts as outstanding.
+	assert.Empty(t, q.exceeded(store.CountInvitationsByUserRow{Outstanding: 1, Total: 2}))
+}
+
+func TestInviteQuotaModeratorExempt(t *testing.T) {
+	// Queries is nil: a moderator or an unlimited quota must not hit the database.
+	a := &App{InviteQuota: InviteQuota{Outstanding: 1, Total: 1}}
+	msg, err := a.inviteQuotaError(context.Background(), store.User{ID: 1, IsModerator: true})
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+
+	a.InviteQuota = InviteQuota{}
+	msg, err = a.inviteQuotaError(context.Background(), store.User{ID: 2})
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+}
+
+func TestCreateInvitationEnforcesQuota(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.InviteQuota = InviteQuota{Outstanding: 2, Total: 3}
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := store.User{ID: u.ID, Username: u.Username}
+
+	// Concurrent requests all passed the handler's early check, so only
+	// the transaction stands between them and the quota.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created, refused int
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, err := a.createInvitation(ctx, user, store.CreateInvitationParams{
+				InviterID: user.ID,
+				TokenHash: fmt.Sprintf("hash-%d", i),
+			})
+			assert.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			if msg == "" {
+				created++
+			} else {
+				refused++
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, created, "outstanding quota")
+	assert.Equal(t, 3, refused)
+
+	// Claiming one frees an outstanding slot, but the total still applies.
+	_, err = pool.Exec(ctx, "UPDATE invitations SET used_by_id = $1 WHERE id = (SELECT min(id) FROM invitations)", user.ID)
+	require.NoError(t, err)
+	msg, err := a.createInvitation(ctx, user, store.CreateInvitationParams{InviterID: user.ID, TokenHash: "hash-a"})
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+	msg, err = a.createInvitation(ctx, user, store.CreateInvitationParams{InviterID: user.ID, TokenHash: "hash-b"})
+	require.NoError(t, err)
+	assert.Equal(t, "You have used all 3 of your invitations.", msg)
+
+	user.IsModerator = true
+	msg, err = a.createInvitation(ctx, user, store.CreateInvitationParams{InviterID: user.ID, TokenHash: "hash-c"})
+	require.NoError(t, err)
+	assert.Empty(t, msg, "moderators are exempt")
+}
+
+func TestInviteRateLimitStatus(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.InviteLimiter = ratelimit.New(1, time.Hour)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	require.True(t, a.InviteLimiter.Allow(strconv.FormatInt(u.ID, 10)))
+
+	req := httptest.NewRequest(http.MethodPost, "/invite/link", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}))
+	w := httptest.NewRecorder()
+	a.inviteByLink(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Too many invitations.")
+}
+
+func TestBuildInviteRows(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: now.Add(-d), Valid: true}
+	}
+	invitations := []store.ListInvitationsByUserRow{
+		{ID: 1, Email: pgtype.Text{String: "a@example.com", Valid: true}, CreatedAt: at(time.Hour)},
+		{ID: 2, CreatedAt: at(invitationTTL)},
+		{ID: 3, CreatedAt: at(48 * time.Hour)},
+		{ID: 4, CreatedAt: at(48 * time.Hour), RegisteredUsername: pgtype.Text{String: "bob", Valid: true}},
+		{ID: 5, CreatedAt: at(invitationTTL - time.Second)},
+	}
+
+	rows := buildInviteRows(invitations, now)
+	require.Len(t, rows, len(invitations))
+
+	assert.Equal(t, "Pending", rows[0].Status)
+	assert.Equal(t, "a@example.com", rows[0].Email)
+	assert.Equal(t, "Expired", rows[1].Status, "expires exactly at the TTL, like the token lookup")
+	assert.Equal(t, "Expired", rows[2].Status)
+	assert.Equal(t, "Registered", rows[3].Status, "claimed invitations never expire")
+	assert.Equal(t, "bob", rows[3].RegisteredUsername)
+	assert.Equal(t, "Pending", rows[4].Status)
+}
@@ -2,37 +2,92 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 
 	"crow.watch/internal/store"
 )
 
-// clientIP extracts the client IP address from a request, checking
-// proxy headers before falling back to r.RemoteAddr.
-func clientIP(r *http.Request) string {
-	// X-Forwarded-For may contain a comma-separated list; take the first.
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		if ip := strings.TrimSpace(strings.SplitN(xff, ",", 2)[0]); ip != "" {
-			return ip
+// allowEmailFrom reports whether the client may trigger another
+// password reset or confirmation e-mail, independent of the per-account
+// cooldowns.
+func (a *App) allowEmailFrom(r *http.Request) bool {
+	if a.EmailIPLimiter == nil {
+		return true
+	}
+	return a.EmailIPLimiter.Allow(a.clientIP(r))
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and
+// CIDR ranges, as used by TRUSTED_PROXIES.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", v, err)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
 		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", v, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
 	}
+	return prefixes, nil
+}
 
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
+func (a *App) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
 	}
+	return false
+}
 
+// clientIP returns the address of the client that sent r. X-Forwarded-For
+// is only believed when the connection comes from a trusted proxy, and
+// then only up to the first hop no trusted proxy vouches for, so a client
+// can't pick the address its rate limits are keyed by.
+func (a *App) clientIP(r *http.Request) string {
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return r.RemoteAddr
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !a.trustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		host = hop.String()
+		if !a.trustedProxy(hop) {
+			break
+		}
 	}
 	return host
 }
 
 // recordIP upserts a user_ips row in the background so it doesn't slow the request.
 func (a *App) recordIP(r *http.Request, userID int64, action string) {
-	ip := clientIP(r)
+	ip := a.clientIP(r)
 	go func() {
 		if err := a.Queries.UpsertUserIP(context.Background(), store.UpsertUserIPParams{
 			UserID:    userID,
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	got, err := ParseTrustedProxies(" 127.0.0.1, 10.0.0.0/8 ,::1,")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}, got)
+
+	_, err = ParseTrustedProxies("nginx")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	a := testApp(t)
+	a.TrustedProxies = []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"direct", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"spoofed header from untrusted peer", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "127.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"client-supplied hops are skipped", "127.0.0.1:1234", "192.0.2.99, 198.51.100.1", "198.51.100.1"},
+		{"trusted hops are walked", "127.0.0.1:1234", "198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"trusted proxy without header", "127.0.0.1:1234", "", "127.0.0.1"},
+		{"garbage hop stops the walk", "127.0.0.1:1234", "198.51.100.1, junk", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			assert.Equal(t, tt.want, a.clientIP(r))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// assertJSONError checks that w holds a JSON {"error": ...} body with the
+// given status rather than a plain-text error.
+func assertJSONError(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	assert.NotEmpty(t, body["error"])
+}
+
+func TestJSONEndpointsErrors(t *testing.T) {
+	a := testApp(t)
+	endpoints := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"upvote", a.upvote},
+		{"unvote", a.unvote},
+		{"flag story", a.flagStory},
+		{"unflag story", a.unflagStory},
+		{"hide story", a.hideStory},
+		{"unhide story", a.unhideStory},
+		{"upvote comment", a.upvoteComment},
+		{"unvote comment", a.unvoteComment},
+		{"flag comment", a.flagComment},
+		{"unflag comment", a.unflagComment},
+		{"hide tag", a.hideTag},
+		{"unhide tag", a.unhideTag},
+		{"subscribe domain", a.subscribeDomain},
+		{"unsubscribe domain", a.unsubscribeDomain},
+	}
+	for _, e := range endpoints {
+		t.Run(e.name+" unauthorized", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+			e.handler(w, req)
+			assertJSONError(t, w, http.StatusUnauthorized)
+		})
+		t.Run(e.name+" bad id", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.SetPathValue("id", "abc")
+			req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+			w := httptest.NewRecorder()
+			e.handler(w, req)
+			assertJSONError(t, w, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestFlagErrorsAreJSON(t *testing.T) {
+	a := testApp(t)
+	user := auth.AuthenticatedUser{User: store.User{ID: 1}}
+
+	for _, handler := range []http.HandlerFunc{a.flagStory, a.flagComment} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"nonsense"}`))
+		req.SetPathValue("id", "1")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		assertJSONError(t, w, http.StatusBadRequest)
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"spam"}`))
+		req.SetPathValue("id", "1")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+		w = httptest.NewRecorder()
+		handler(w, req)
+		assertJSONError(t, w, http.StatusForbidden)
+		assert.Contains(t, w.Body.String(), "Confirm your email")
+	}
+}
+
+func TestHideStoryInvalidReasonIsJSON(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"nope"}`))
+	req.SetPathValue("id", "1")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+	w := httptest.NewRecorder()
+	a.hideStory(w, req)
+	assertJSONError(t, w, http.StatusBadRequest)
+}
+
+func TestFetchTitleErrorsAreJSON(t *testing.T) {
+	a := testApp(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/submit/fetch-title", strings.NewReader(`{"url":"https://example.com"}`))
+	w := httptest.NewRecorder()
+	a.fetchTitle(w, req)
+	assertJSONError(t, w, http.StatusUnauthorized)
+
+	req = httptest.NewRequest(http.MethodPost, "/submit/fetch-title", strings.NewReader(`not json`))
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+	w = httptest.NewRecorder()
+	a.fetchTitle(w, req)
+	assertJSONError(t, w, http.StatusBadRequest)
+}
+
+func TestAPIErrorsAreJSON(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/story", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	a.apiSubmitStory(w, req)
+	assertJSONError(t, w, http.StatusUnauthorized)
+
+	req = httptest.NewRequest(http.MethodPost, "/submit.json", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	a.submitStoryJSON(w, req)
+	assertJSONError(t, w, http.StatusUnauthorized)
+}
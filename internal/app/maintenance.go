@@ -0,0 +1,64 @@
+package app
+
+import (
+	"mime"
+	"net/http"
+)
+
+// maintenanceExempt lists write endpoints that keep working in maintenance
+// mode so people can still sign in and out.
+var maintenanceExempt = map[string]bool{
+	"/login":                  true,
+	"/logout":                 true,
+	"/mod/stop-impersonating": true,
+}
+
+// jsonRoute marks a route whose responses, errors included, are JSON.
+// Middleware in front of the mux looks it up to answer in kind, since the
+// page scripts call these with fetch and send no Accept header to go by.
+type jsonRoute http.HandlerFunc
+
+func (h jsonRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h(w, r)
+}
+
+// wantsJSONError reports whether a request should get a JSON error rather
+// than an HTML page: JSON routes, JSON bodies, and clients that accept JSON.
+func wantsJSONError(mux *http.ServeMux, r *http.Request) bool {
+	if h, _ := mux.Handler(r); isJSONRoute(h) {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json" || accepts(r, "application/json")
+}
+
+func isJSONRoute(h http.Handler) bool {
+	_, ok := h.(jsonRoute)
+	return ok
+}
+
+// readOnly blocks state-changing requests while MaintenanceMode is set.
+// Reads are unaffected.
+func (a *App) readOnly(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.MaintenanceMode || isSafeMethod(r.Method) || maintenanceExempt[r.URL.Path] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "300")
+		if wantsJSONError(mux, r) {
+			writeJSONError(w, http.StatusServiceUnavailable, "The site is read-only right now.")
+			return
+		}
+		a.renderStatus(w, http.StatusServiceUnavailable, "maintenance", struct{ Base Base }{Base: a.baseData(r)})
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
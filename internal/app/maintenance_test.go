@@ -0,0 +1,131 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readOnlyMux routes every path to a handler answering code, registering
+// the given patterns as JSON routes.
+func readOnlyMux(code int, jsonPatterns ...string) *http.ServeMux {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	for _, pattern := range jsonPatterns {
+		mux.Handle(pattern, jsonRoute(handler))
+	}
+	return mux
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	a := testApp(t)
+	a.MaintenanceMode = true
+	h := a.readOnly(readOnlyMux(http.StatusTeapot))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/", http.StatusTeapot},
+		{http.MethodHead, "/newest", http.StatusTeapot},
+		{http.MethodPost, "/submit", http.StatusServiceUnavailable},
+		{http.MethodPost, "/stories/1/upvote", http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/story", http.StatusServiceUnavailable},
+		{http.MethodPost, "/login", http.StatusTeapot},
+		{http.MethodPost, "/logout", http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.want, w.Code, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestReadOnlyMiddlewareRendersPage(t *testing.T) {
+	a := testApp(t)
+	a.MaintenanceMode = true
+	h := a.readOnly(readOnlyMux(http.StatusNotFound))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Read-only right now")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestReadOnlyMiddlewareJSONEndpoints(t *testing.T) {
+	a := testApp(t)
+	a.MaintenanceMode = true
+	h := a.readOnly(readOnlyMux(http.StatusNotFound,
+		"POST /api/story",
+		"POST /stories/{id}/upvote",
+		"POST /stories/{id}/hide",
+		"POST /comments/{id}/flag",
+		"POST /domains/{id}/subscribe",
+	))
+
+	for _, path := range []string{
+		"/api/story",
+		"/stories/1/upvote",
+		"/stories/1/hide",
+		"/comments/1/flag",
+		"/domains/1/subscribe",
+	} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+			assertJSONError(t, w, http.StatusServiceUnavailable)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assertJSONError(t, w, http.StatusServiceUnavailable)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/comments/1/edit", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Read-only right now", "form posts still get the page")
+}
+
+func TestReadOnlyMiddlewareUsesRegisteredJSONRoutes(t *testing.T) {
+	a := testApp(t)
+	a.MaintenanceMode = true
+	h := a.Routes()
+
+	for _, path := range []string{
+		"/api/story",
+		"/submit.json",
+		"/stories/1/upvote",
+		"/tags/1/hide",
+		"/comments/1/unflag",
+		"/domains/1/unsubscribe",
+	} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+			assertJSONError(t, w, http.StatusServiceUnavailable)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/comments/1/edit", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Read-only right now")
+}
+
+func TestReadOnlyMiddlewareDisabled(t *testing.T) {
+	a := testApp(t)
+	h := a.readOnly(readOnlyMux(http.StatusNoContent))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -22,15 +22,15 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -42,8 +42,7 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -51,17 +50,17 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 	reason := strings.TrimSpace(r.FormValue("reason"))
 
 	if canonicalCode == "" {
-		a.renderEditError(w, r, current, code, row, row.Title, row.Body.String, "", row.Url.String, nil, nil, "Original story short code is required.")
+		a.renderStoryActionError(w, r, current, code, row, "Original story short code is required.")
 		return
 	}
 
-	if len(canonicalCode) != 6 {
-		a.renderEditError(w, r, current, code, row, row.Title, row.Body.String, "", row.Url.String, nil, nil, "Invalid story short code.")
+	if !a.validShortCode(canonicalCode) {
+		a.renderStoryActionError(w, r, current, code, row, "Invalid story short code.")
 		return
 	}
 
 	if canonicalCode == code {
-		a.renderEditError(w, r, current, code, row, row.Title, row.Body.String, "", row.Url.String, nil, nil, "A story cannot be a duplicate of itself.")
+		a.renderStoryActionError(w, r, current, code, row, "A story cannot be a duplicate of itself.")
 		return
 	}
 
@@ -73,7 +72,7 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 	canonical, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: canonicalCode, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			a.renderEditError(w, r, current, code, row, row.Title, row.Body.String, "", row.Url.String, nil, nil, fmt.Sprintf("Story with code %q not found.", canonicalCode))
+			a.renderStoryActionError(w, r, current, code, row, fmt.Sprintf("Story with code %q not found.", canonicalCode))
 			return
 		}
 		a.serverError(w, r, "get canonical story", err)
@@ -108,14 +107,15 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
 		ModeratorID: current.User.ID,
 		Action:      "story.mark_duplicate",
 		TargetType:  "story",
 		TargetID:    row.ID,
 		Reason:      reason,
 		Metadata:    metadataJSON,
-	}); err != nil {
+	})
+	if err != nil {
 		a.serverError(w, r, "create moderation log", err)
 		return
 	}
@@ -124,6 +124,7 @@ func (a *App) markDuplicate(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
+	a.notifyModeration(current.User.Username, entry)
 
 	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
 }
@@ -136,15 +137,15 @@ func (a *App) unmarkDuplicate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -156,6 +157,9 @@ func (a *App) unmarkDuplicate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !parseForm(w, r) {
+		return
+	}
 	reason := strings.TrimSpace(r.FormValue("reason"))
 	if reason == "" {
 		reason = "(no reason given)"
@@ -175,14 +179,15 @@ func (a *App) unmarkDuplicate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
 		ModeratorID: current.User.ID,
 		Action:      "story.unmark_duplicate",
 		TargetType:  "story",
 		TargetID:    row.ID,
 		Reason:      reason,
 		Metadata:    []byte("{}"),
-	}); err != nil {
+	})
+	if err != nil {
 		a.serverError(w, r, "create moderation log", err)
 		return
 	}
@@ -191,6 +196,7 @@ func (a *App) unmarkDuplicate(w http.ResponseWriter, r *http.Request) {
 		a.serverError(w, r, "commit transaction", err)
 		return
 	}
+	a.notifyModeration(current.User.Username, entry)
 
 	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
 }
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+// siteStatsTTL is how long the moderator dashboard reuses its numbers
+// before running the aggregate queries again.
+const siteStatsTTL = time.Minute
+
+// topDomainsLimit is how many domains the dashboard lists.
+const topDomainsLimit = 10
+
+type ModStatsPageData struct {
+	Base  Base
+	Stats SiteStats
+}
+
+type SiteStats struct {
+	TotalUsers           int
+	ActiveUsers          int
+	StoriesToday         int
+	StoriesThisWeek      int
+	TotalComments        int
+	PendingInvitations   int
+	StoryFlagsThisWeek   int
+	CommentFlagsThisWeek int
+	TopDomains           []DomainStat
+	GeneratedAt          time.Time
+}
+
+type DomainStat struct {
+	Domain  string
+	Stories int
+}
+
+// siteStatsCache holds the last computed SiteStats for siteStatsTTL.
+type siteStatsCache struct {
+	mu    sync.Mutex
+	stats SiteStats
+}
+
+// get returns the cached stats if they are fresh, otherwise it calls load
+// and caches the result. Errors are not cached.
+func (c *siteStatsCache) get(now time.Time, load func() (SiteStats, error)) (SiteStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.stats.GeneratedAt.IsZero() && now.Sub(c.stats.GeneratedAt) < siteStatsTTL {
+		return c.stats, nil
+	}
+	stats, err := load()
+	if err != nil {
+		return SiteStats{}, err
+	}
+	stats.GeneratedAt = now
+	c.stats = stats
+	return stats, nil
+}
+
+// loadSiteStats runs the dashboard queries. "Today" starts at UTC
+// midnight and "this week" covers the last seven days including today,
+// the same periods the analytics page uses.
+func loadSiteStats(ctx context.Context, q *store.Queries, now time.Time) (SiteStats, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	weekStart := today.AddDate(0, 0, -6)
+
+	row, err := q.GetSiteStats(ctx, store.GetSiteStatsParams{
+		ActiveSince:      pgtype.Timestamptz{Time: weekStart, Valid: true},
+		Today:            pgtype.Timestamptz{Time: today, Valid: true},
+		WeekStart:        pgtype.Timestamptz{Time: weekStart, Valid: true},
+		InvitationsSince: pgtype.Timestamptz{Time: now.Add(-invitationTTL), Valid: true},
+	})
+	if err != nil {
+		return SiteStats{}, err
+	}
+
+	domains, err := q.GetTopDomains(ctx, store.GetTopDomainsParams{
+		Since:      pgtype.Timestamptz{Time: today.AddDate(0, 0, -29), Valid: true},
+		MaxResults: topDomainsLimit,
+	})
+	if err != nil {
+		return SiteStats{}, err
+	}
+
+	stats := SiteStats{
+		TotalUsers:           int(row.TotalUsers),
+		ActiveUsers:          int(row.ActiveUsers),
+		StoriesToday:         int(row.StoriesToday),
+		StoriesThisWeek:      int(row.StoriesThisWeek),
+		TotalComments:        int(row.TotalComments),
+		PendingInvitations:   int(row.PendingInvitations),
+		StoryFlagsThisWeek:   int(row.StoryFlagsThisWeek),
+		CommentFlagsThisWeek: int(row.CommentFlagsThisWeek),
+	}
+	for _, d := range domains {
+		stats.TopDomains = append(stats.TopDomains, DomainStat{Domain: d.Domain, Stories: int(d.Stories)})
+	}
+	return stats, nil
+}
+
+func (a *App) modStatsPage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	stats, err := a.siteStats.get(time.Now(), func() (SiteStats, error) {
+		return loadSiteStats(r.Context(), a.Queries, time.Now())
+	})
+	if err != nil {
+		a.serverError(w, r, "load site stats", err)
+		return
+	}
+
+	a.render(w, "mod_stats", ModStatsPageData{
+		Base:  a.baseData(r),
+		Stats: stats,
+	})
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestModStatsPageRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/mod/stats", nil)
+	w := httptest.NewRecorder()
+	a.modStatsPage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSiteStatsCache(t *testing.T) {
+	var c siteStatsCache
+	loads := 0
+	load := func() (SiteStats, error) {
+		loads++
+		return SiteStats{TotalUsers: loads}, nil
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	stats, err := c.get(now, load)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalUsers)
+	assert.Equal(t, now, stats.GeneratedAt)
+
+	stats, err = c.get(now.Add(30*time.Second), load)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalUsers, "served from cache")
+
+	stats, err = c.get(now.Add(siteStatsTTL), load)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalUsers, "reloaded once stale")
+
+	_, err = c.get(now.Add(3*siteStatsTTL), func() (SiteStats, error) { return SiteStats{}, errors.New("boom") })
+	require.Error(t, err)
+	stats, err = c.get(now.Add(3*siteStatsTTL), load)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalUsers, "errors are not cached")
+}
+
+func TestModStats(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	newUser := func(name string) store.User {
+		t.Helper()
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		return store.User{ID: u.ID, Username: u.Username}
+	}
+	alice, bob := newUser("alice"), newUser("bob")
+	gone := newUser("gone")
+	_, err := pool.Exec(ctx, "UPDATE users SET deleted_at = now() WHERE id = $1", gone.ID)
+	require.NoError(t, err)
+
+	example, err := a.Queries.GetOrCreateDomain(ctx, "example.com")
+	require.NoError(t, err)
+	other, err := a.Queries.GetOrCreateDomain(ctx, "other.org")
+	require.NoError(t, err)
+
+	newStory := func(userID int64, code string, domainID int64) int64 {
+		t.Helper()
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    userID,
+			DomainID:  pgtype.Int8{Int64: domainID, Valid: true},
+			Url:       pgtype.Text{String: "https://example.com/" + code, Valid: true},
+			Title:     code,
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		return s.ID
+	}
+	s1 := newStory(alice.ID, "aaaaa1", example.ID)
+	newStory(alice.ID, "aaaaa2", example.ID)
+	newStory(bob.ID, "bbbbb1", other.ID)
+	old := newStory(bob.ID, "bbbbb2", other.ID)
+	_, err = pool.Exec(ctx, "UPDATE stories SET created_at = now() - interval '10 days' WHERE id = $1", old)
+	require.NoError(t, err)
+
+	_, err = a.Queries.CreateComment(ctx, store.CreateCommentParams{StoryID: s1, UserID: bob.ID, Body: "hi"})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: bob.ID, StoryID: s1, Reason: "spam"}))
+
+	_, err = a.Queries.CreateInvitation(ctx, store.CreateInvitationParams{InviterID: alice.ID, TokenHash: "pending"})
+	require.NoError(t, err)
+	expired, err := a.Queries.CreateInvitation(ctx, store.CreateInvitationParams{InviterID: alice.ID, TokenHash: "expired"})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "UPDATE invitations SET created_at = now() - interval '2 days' WHERE id = $1", expired.ID)
+	require.NoError(t, err)
+
+	stats, err := loadSiteStats(ctx, a.Queries, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalUsers, "deleted users are not counted")
+	assert.Equal(t, 3, stats.StoriesThisWeek)
+	assert.Equal(t, 1, stats.TotalComments)
+	assert.Equal(t, 1, stats.PendingInvitations)
+	assert.Equal(t, 1, stats.StoryFlagsThisWeek)
+	assert.Equal(t, []DomainStat{{Domain: "example.com", Stories: 2}, {Domain: "other.org", Stories: 2}}, stats.TopDomains)
+
+	// Only moderators see the page.
+	req := httptest.NewRequest(http.MethodGet, "/mod/stats", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: alice}))
+	w := httptest.NewRecorder()
+	a.modStatsPage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mod := alice
+	mod.IsModerator = true
+	req = httptest.NewRequest(http.MethodGet, "/mod/stats", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: mod}))
+	w = httptest.NewRecorder()
+	a.modStatsPage(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Pending invitations")
+	assert.Contains(t, w.Body.String(), "example.com")
+}
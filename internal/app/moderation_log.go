@@ -2,6 +2,7 @@ package app
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"crow.watch/internal/store"
+	"crow.watch/internal/webhook"
 )
 
 const modLogPerPage = 50
@@ -16,20 +18,16 @@ const modLogPerPage = 50
 func (a *App) moderationLogPage(w http.ResponseWriter, r *http.Request) {
 	page := parsePage(r)
 
-	offset := int32((page - 1) * modLogPerPage)
 	rows, err := a.Queries.ListModerationLog(r.Context(), store.ListModerationLogParams{
 		LogLimit:  modLogPerPage + 1,
-		LogOffset: offset,
+		LogOffset: offsetFor(page, modLogPerPage),
 	})
 	if err != nil {
 		a.serverError(w, r, "list moderation log", err)
 		return
 	}
 
-	hasMore := len(rows) > modLogPerPage
-	if hasMore {
-		rows = rows[:modLogPerPage]
-	}
+	rows, hasMore := trimLookahead(rows, modLogPerPage)
 
 	var entries []ModerationLogEntry
 	for _, row := range rows {
@@ -67,9 +65,62 @@ func (a *App) resolveModLogTarget(r *http.Request, targetType string, targetID i
 		}
 		return storyPath(row.ShortCode, row.Title), row.Title
 	}
+	if targetType == "user" {
+		user, err := a.Queries.GetUserByID(r.Context(), targetID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", "[deleted]"
+			}
+			return "", "[error]"
+		}
+		return "/u/" + user.Username, user.Username
+	}
+	if targetType == "tag" {
+		tags, err := a.Queries.GetTagsByIDs(r.Context(), []int64{targetID})
+		if err != nil {
+			return "", "[error]"
+		}
+		if len(tags) == 0 {
+			return "", "[inactive]"
+		}
+		return "/t/" + tags[0].Tag, tags[0].Tag
+	}
+	if targetType == "comment" {
+		comment, err := a.Queries.GetCommentByID(r.Context(), targetID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", "[deleted]"
+			}
+			return "", "[error]"
+		}
+		row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ID: pgtype.Int8{Int64: comment.StoryID, Valid: true}})
+		if err != nil {
+			return "", "[error]"
+		}
+		return fmt.Sprintf("/x/%s/comments/%d", row.ShortCode, targetID), "comment on " + row.Title
+	}
 	return "", ""
 }
 
+// notifyModeration forwards a committed moderation log entry to the
+// moderation webhook. It is a no-op when no webhook is configured.
+func (a *App) notifyModeration(moderator string, entry store.ModerationLog) {
+	text := fmt.Sprintf("%s %s (%s #%d)", moderator, formatActionDescription(entry.Action), entry.TargetType, entry.TargetID)
+	if entry.Reason != "" {
+		text += ": " + entry.Reason
+	}
+	a.ModWebhook.Notify(webhook.Event{
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Moderator:  moderator,
+		Reason:     entry.Reason,
+		Metadata:   entry.Metadata,
+		CreatedAt:  entry.CreatedAt.Time,
+		Text:       text,
+	})
+}
+
 func formatActionDescription(action string) string {
 	parts := strings.Split(action, ",")
 	var descriptions []string
@@ -77,18 +128,54 @@ func formatActionDescription(action string) string {
 		switch strings.TrimSpace(p) {
 		case "story.edit_url":
 			descriptions = append(descriptions, "edited URL")
+		case "story.edit_canonical_url":
+			descriptions = append(descriptions, "edited canonical URL")
 		case "story.edit_title":
 			descriptions = append(descriptions, "edited title")
 		case "story.edit_body":
 			descriptions = append(descriptions, "edited body")
 		case "story.edit_tags":
 			descriptions = append(descriptions, "edited tags")
+		case "story.convert_to_text":
+			descriptions = append(descriptions, "converted to text post")
+		case "story.convert_to_link":
+			descriptions = append(descriptions, "converted to link post")
 		case "story.delete":
 			descriptions = append(descriptions, "deleted story")
+		case "story.hide":
+			descriptions = append(descriptions, "hid story")
+		case "story.unhide":
+			descriptions = append(descriptions, "unhid story")
+		case "story.clear_flags":
+			descriptions = append(descriptions, "cleared story flags")
 		case "story.mark_duplicate":
 			descriptions = append(descriptions, "marked as duplicate")
 		case "story.unmark_duplicate":
 			descriptions = append(descriptions, "unmarked as duplicate")
+		case "story.pin":
+			descriptions = append(descriptions, "pinned story")
+		case "story.unpin":
+			descriptions = append(descriptions, "unpinned story")
+		case "story.pick":
+			descriptions = append(descriptions, "marked as editor's pick")
+		case "story.unpick":
+			descriptions = append(descriptions, "removed editor's pick")
+		case "story.adjust_score":
+			descriptions = append(descriptions, "adjusted score")
+		case "story.reset_score":
+			descriptions = append(descriptions, "reset score")
+		case "comment.delete":
+			descriptions = append(descriptions, "deleted comment")
+		case "comment.clear_flags":
+			descriptions = append(descriptions, "cleared comment flags")
+		case "tag.edit_description":
+			descriptions = append(descriptions, "edited tag description")
+		case "tag.edit_synonyms":
+			descriptions = append(descriptions, "edited tag synonyms")
+		case "user.impersonate":
+			descriptions = append(descriptions, "started impersonating user")
+		case "user.impersonate_stop":
+			descriptions = append(descriptions, "stopped impersonating user")
 		default:
 			descriptions = append(descriptions, strings.TrimSpace(p))
 		}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords are silently
+// truncated by the algorithm, so they are rejected instead.
+const maxPasswordBytes = 72
+
+// DefaultPasswordMinLength is used when PasswordPolicy.MinLength is unset.
+const DefaultPasswordMinLength = 8
+
+//go:embed common_passwords.txt
+var commonPasswordsTxt string
+
+var commonPasswords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordsTxt, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			m[strings.ToLower(line)] = true
+		}
+	}
+	return m
+}()
+
+// PasswordPolicy decides which new passwords are accepted at registration,
+// password change and password reset.
+type PasswordPolicy struct {
+	MinLength    int
+	RejectCommon bool
+}
+
+func (p PasswordPolicy) minLength() int {
+	if p.MinLength > 0 {
+		return p.MinLength
+	}
+	return DefaultPasswordMinLength
+}
+
+// check returns a field error for a non-empty password that breaks the
+// policy, or "" if it is acceptable.
+func (p PasswordPolicy) check(password string) string {
+	if len(password) > maxPasswordBytes {
+		return fmt.Sprintf("Password must be %d bytes or fewer.", maxPasswordBytes)
+	}
+	if n := p.minLength(); utf8.RuneCountInString(password) < n {
+		return fmt.Sprintf("Password must be at least %d characters.", n)
+	}
+	if p.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		return "This password is too common. Please choose another."
+	}
+	return ""
+}
@@ -21,23 +21,46 @@ import (
 const resetEmailCooldown = 15 * time.Minute
 
 func (a *App) forgotPasswordPage(w http.ResponseWriter, r *http.Request) {
-	a.render(w, "forgot_password", ForgotPasswordPageData{Base: a.baseData(r)})
+	a.render(w, "forgot_password", ForgotPasswordPageData{
+		Base:      a.baseData(r),
+		CaptchaID: a.newCaptchaID(a.captchaRequired(a.EmailIPLimiter, a.clientIP(r))),
+	})
 }
 
 func (a *App) forgotPassword(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "forgot_password", ForgotPasswordPageData{Base: a.baseData(r), Error: "Invalid request."})
+	if !parseForm(w, r) {
 		return
 	}
 
 	email := strings.TrimSpace(r.FormValue("email"))
+	needCaptcha := a.captchaRequired(a.EmailIPLimiter, a.clientIP(r))
+	renderError := func(msg string) {
+		a.render(w, "forgot_password", ForgotPasswordPageData{
+			Base:      a.baseData(r),
+			Email:     email,
+			Error:     msg,
+			CaptchaID: a.newCaptchaID(needCaptcha),
+		})
+	}
+
 	if email == "" {
-		a.render(w, "forgot_password", ForgotPasswordPageData{Base: a.baseData(r), Email: email, Error: "Please enter your e-mail address."})
+		renderError("Please enter your e-mail address.")
+		return
+	}
+	if needCaptcha && !a.validCaptcha(r) {
+		renderError(captchaErrorMessage)
 		return
 	}
 
 	successMsg := "If an account with that e-mail exists, we sent a password reset link."
 
+	// Rate-limit per IP so one client can't probe many addresses. The
+	// response stays the same so it doesn't reveal the limit was hit.
+	if !a.allowEmailFrom(r) {
+		a.render(w, "forgot_password", ForgotPasswordPageData{Base: a.baseData(r), Success: successMsg})
+		return
+	}
+
 	user, err := a.Queries.GetUserByLogin(r.Context(), email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -117,8 +140,7 @@ func (a *App) resetPasswordPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) resetPassword(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "reset_password", ResetPasswordPageData{Base: a.baseData(r), Error: "Invalid request."})
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -135,8 +157,8 @@ func (a *App) resetPassword(w http.ResponseWriter, r *http.Request) {
 		a.render(w, "reset_password", ResetPasswordPageData{Base: a.baseData(r), Token: token, Error: "Please enter a new password."})
 		return
 	}
-	if len(password) > 72 {
-		a.render(w, "reset_password", ResetPasswordPageData{Base: a.baseData(r), Token: token, Error: "Password must be 72 bytes or fewer."})
+	if msg := a.PasswordPolicy.check(password); msg != "" {
+		a.render(w, "reset_password", ResetPasswordPageData{Base: a.baseData(r), Token: token, Error: msg})
 		return
 	}
 	if password != confirmation {
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/ratelimit"
+	"crow.watch/internal/store"
+)
+
+func TestForgotPasswordIPLimit(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.EmailIPLimiter = ratelimit.New(2, time.Hour)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "UPDATE users SET email_confirmed_at = now() WHERE id = $1", u.ID)
+	require.NoError(t, err)
+
+	forgot := func(email string) string {
+		t.Helper()
+		form := url.Values{"email": {email}}
+		req := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "203.0.113.7:1234"
+		w := httptest.NewRecorder()
+		a.forgotPassword(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	forgot("nobody@example.com")
+	forgot("someone@example.com")
+	body := forgot("alice@example.com")
+	assert.Contains(t, body, "If an account with that e-mail exists")
+
+	user, err := a.Queries.GetUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.False(t, user.PasswordResetTokenHash.Valid, "limited IP must not start a reset flow")
+}
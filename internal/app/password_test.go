@@ -0,0 +1,48 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{RejectCommon: true}
+
+	tests := []struct {
+		name     string
+		password string
+		errMsg   string
+	}{
+		{"too short", "short", "Password must be at least 8 characters."},
+		{"common", "password123", "This password is too common. Please choose another."},
+		{"common any case", "PassWord123", "This password is too common. Please choose another."},
+		{"too long", strings.Repeat("x", 73), "Password must be 72 bytes or fewer."},
+		{"valid", "correct horse battery", ""},
+		{"multibyte counts runes", "пароль!!", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.errMsg, policy.check(tt.password))
+		})
+	}
+}
+
+func TestPasswordPolicyConfig(t *testing.T) {
+	assert.Empty(t, PasswordPolicy{}.check("password"), "common check is optional")
+	assert.Equal(t, "Password must be at least 12 characters.", PasswordPolicy{MinLength: 12}.check("elevenchars"))
+}
+
+func TestValidateRegistrationPassword(t *testing.T) {
+	policy := PasswordPolicy{RejectCommon: true}
+
+	errs := validateRegistration("alice", "alice@example.com", "qwerty", "qwerty", policy, EmailPolicy{})
+	assert.Equal(t, "Password must be at least 8 characters.", errs["password"])
+
+	errs = validateRegistration("alice", "alice@example.com", "12345678", "12345678", policy, EmailPolicy{})
+	assert.Equal(t, "This password is too common. Please choose another.", errs["password"])
+
+	errs = validateRegistration("alice", "alice@example.com", "tangerine sky", "tangerine sky", policy, EmailPolicy{})
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func (a *App) pickStory(w http.ResponseWriter, r *http.Request) {
+	a.setEditorsPick(w, r, true)
+}
+
+func (a *App) unpickStory(w http.ResponseWriter, r *http.Request) {
+	a.setEditorsPick(w, r, false)
+}
+
+// setEditorsPick adds or removes the editor's pick badge of a story.
+// Unlike a pin it doesn't move the story and never expires.
+func (a *App) setEditorsPick(w http.ResponseWriter, r *http.Request, pick bool) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	// Nothing to change, or a deleted story — just redirect back.
+	if row.PickedAt.Valid == pick || row.DeletedAt.Valid {
+		http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		reason = "(no reason given)"
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	action := "story.pick"
+	if pick {
+		err = qtx.PickStory(r.Context(), row.ID)
+	} else {
+		action = "story.unpick"
+		err = qtx.UnpickStory(r.Context(), row.ID)
+	}
+	if err != nil {
+		a.serverError(w, r, "set editor's pick", err)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      action,
+		TargetType:  "story",
+		TargetID:    row.ID,
+		Reason:      reason,
+		Metadata:    []byte("{}"),
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestBuildStoryListEditorsPick(t *testing.T) {
+	rows := []store.ListStoriesRow{
+		{ID: 1, Tags: []byte("[]"), PickedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}},
+		{ID: 2, Tags: []byte("[]")},
+	}
+
+	items, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.True(t, items[0].IsPick)
+	assert.False(t, items[1].IsPick)
+}
+
+func TestRenderEditorsPickBadge(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "home", HomePageData{Stories: []StoryItem{
+		{ID: 1, ShortCode: "aaaaaa", Title: "Standout", Username: "alice", IsText: true, IsPick: true},
+		{ID: 2, ShortCode: "bbbbbb", Title: "Ordinary", Username: "bob", IsText: true},
+	}})
+
+	body := w.Body.String()
+	assert.Equal(t, 1, strings.Count(body, `<span class="story-item__pick">editor's pick</span>`))
+}
+
+func TestPickStoryRequiresModerator(t *testing.T) {
+	a := testApp(t)
+
+	for _, user := range []*store.User{nil, {ID: 1, Username: "alice"}} {
+		for _, handler := range []http.HandlerFunc{a.pickStory, a.unpickStory} {
+			req := httptest.NewRequest(http.MethodPost, "/x/abc123/pick", nil)
+			req.SetPathValue("code", "abc123")
+			if user != nil {
+				req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: *user}))
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, http.StatusSeeOther, w.Code)
+			assert.Equal(t, "/", w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestPickStory(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	mod := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username, IsModerator: true}}
+
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Standout",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	post := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/pick", nil)
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), mod))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	picked := func() bool {
+		t.Helper()
+		row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: story.ID, Valid: true}})
+		require.NoError(t, err)
+		return row.PickedAt.Valid
+	}
+
+	assert.Equal(t, http.StatusSeeOther, post(a.pickStory).Code)
+	assert.True(t, picked())
+	assert.Equal(t, http.StatusSeeOther, post(a.pickStory).Code)
+
+	assert.Equal(t, http.StatusSeeOther, post(a.unpickStory).Code)
+	assert.False(t, picked())
+
+	logs, err := a.Queries.ListModerationLog(ctx, store.ListModerationLogParams{LogLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 2, "picking twice is logged once")
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+const (
+	maxPinnedStories = 3
+	defaultPinDays   = 7
+	maxPinDays       = 30
+)
+
+// parsePinDays parses the requested pin duration in days. An empty value
+// means the default duration.
+func parsePinDays(v string) (int, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return defaultPinDays, true
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 || days > maxPinDays {
+		return 0, false
+	}
+	return days, true
+}
+
+func (a *App) pinStory(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	if row.DeletedAt.Valid {
+		http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		a.renderStoryActionError(w, r, current, code, row, "A reason is required to pin a story.")
+		return
+	}
+
+	days, ok := parsePinDays(r.FormValue("days"))
+	if !ok {
+		a.renderStoryActionError(w, r, current, code, row,
+			fmt.Sprintf("Pin duration must be between 1 and %d days.", maxPinDays))
+		return
+	}
+
+	pinned, err := a.Queries.CountPinnedStories(r.Context(), row.ID)
+	if err != nil {
+		a.serverError(w, r, "count pinned stories", err)
+		return
+	}
+	if pinned >= maxPinnedStories {
+		a.renderStoryActionError(w, r, current, code, row,
+			fmt.Sprintf("At most %d stories can be pinned at a time. Unpin one first.", maxPinnedStories))
+		return
+	}
+
+	pinnedUntil := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+	metadataJSON, err := json.Marshal(map[string]any{
+		"pinned_until": pinnedUntil.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		a.serverError(w, r, "marshal metadata", err)
+		return
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.PinStory(r.Context(), store.PinStoryParams{
+		PinnedUntil: pgtype.Timestamptz{Time: pinnedUntil, Valid: true},
+		ID:          row.ID,
+	}); err != nil {
+		a.serverError(w, r, "pin story", err)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      "story.pin",
+		TargetType:  "story",
+		TargetID:    row.ID,
+		Reason:      reason,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (a *App) unpinStory(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	// Not pinned — just redirect back
+	if !row.PinnedUntil.Valid {
+		http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		reason = "(no reason given)"
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.UnpinStory(r.Context(), row.ID); err != nil {
+		a.serverError(w, r, "unpin story", err)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      "story.unpin",
+		TargetType:  "story",
+		TargetID:    row.ID,
+		Reason:      reason,
+		Metadata:    []byte("{}"),
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+}
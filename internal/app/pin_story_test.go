@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestPinStoryErrorKeepsTags(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	mod := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username, IsModerator: true}}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('golang') RETURNING id").Scan(&tagID))
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Announcement",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: tagID}))
+
+	form := url.Values{"days": {"3"}}
+	req := httptest.NewRequest(http.MethodPost, "/x/abc123/pin", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("code", "abc123")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), mod))
+	w := httptest.NewRecorder()
+	a.pinStory(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "A reason is required to pin a story.")
+	assert.Contains(t, body, `data-role="tag-picker-chip"`, "the story's tags stay selected")
+}
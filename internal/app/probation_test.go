@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestProbationApplies(t *testing.T) {
+	now := time.Now()
+	p := Probation{Period: 7 * 24 * time.Hour, Karma: 10}
+
+	assert.True(t, p.applies(now.Add(-24*time.Hour), 0, now), "new account")
+	assert.False(t, p.applies(now.Add(-8*24*time.Hour), 0, now), "lifted by age")
+	assert.False(t, p.applies(now.Add(-24*time.Hour), 10, now), "lifted by karma")
+	assert.True(t, Probation{Period: p.Period}.applies(now.Add(-24*time.Hour), 500, now), "zero karma threshold: only age lifts it")
+	assert.False(t, Probation{}.applies(now, 0, now), "disabled")
+}
+
+func TestProbationRankingPenalty(t *testing.T) {
+	// The probationer's story is a few minutes newer, which would put it
+	// first on its own.
+	now := time.Now()
+	rows := []store.ListStoriesRow{
+		{ID: 1, UserID: 10, Upvotes: 3, CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}, Tags: []byte("[]")},
+		{ID: 2, UserID: 20, Upvotes: 3, CreatedAt: pgtype.Timestamptz{Time: now.Add(-50 * time.Minute), Valid: true}, Tags: []byte("[]")},
+	}
+	ids := func(items []StoryItem) []int64 {
+		out := make([]int64, len(items))
+		for i, it := range items {
+			out[i] = it.ID
+		}
+		return out
+	}
+
+	during, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{rankByHotness: true, probation: map[int64]bool{20: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids(during), "probation pushes the story down")
+
+	after, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{rankByHotness: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, ids(after), "lifted probation ranks on merit")
+}
+
+func TestProbationUsersSkipsEstablishedAccounts(t *testing.T) {
+	a := testApp(t)
+	a.Probation = Probation{Period: 7 * 24 * time.Hour}
+	rows := []store.ListStoriesRow{
+		{ID: 1, UserID: 10, UserCreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-30 * 24 * time.Hour), Valid: true}},
+	}
+
+	// No young submitters means no karma lookup; a.Queries is nil here.
+	got, err := a.probationUsers(context.Background(), rows, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestProbationLiftsWithKarma(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.Probation = Probation{Period: 7 * 24 * time.Hour, Karma: 5}
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "New here",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	user := store.User{ID: u.ID, CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}
+
+	ends, err := a.probationEnds(ctx, user, time.Now())
+	require.NoError(t, err)
+	assert.False(t, ends.IsZero(), "on probation with no karma")
+
+	require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+		Upvotes:   5,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		ID:        story.ID,
+	}))
+	ends, err = a.probationEnds(ctx, user, time.Now())
+	require.NoError(t, err)
+	assert.True(t, ends.IsZero(), "karma threshold lifts probation")
+}
+
+func TestProbationHidesFlagControls(t *testing.T) {
+	a := testApp(t)
+	a.Probation = Probation{Period: 7 * 24 * time.Hour}
+	newbie := store.User{ID: 1, CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}
+	mod := newbie
+	mod.IsModerator = true
+
+	ctx := auth.ContextWithUser(context.Background(), auth.AuthenticatedUser{User: newbie})
+	onProbation, err := a.viewerOnProbation(ctx)
+	require.NoError(t, err)
+	assert.True(t, onProbation)
+
+	ctx = auth.ContextWithUser(context.Background(), auth.AuthenticatedUser{User: mod})
+	onProbation, err = a.viewerOnProbation(ctx)
+	require.NoError(t, err)
+	assert.False(t, onProbation, "moderators are never on probation")
+
+	item := StoryItem{ID: 1, ShortCode: "abc123", Title: "T", IsLoggedIn: true}
+	w := httptest.NewRecorder()
+	a.renderFragment(w, "story-item", item)
+	assert.NotContains(t, w.Body.String(), "story-flag-btn")
+	assert.Contains(t, w.Body.String(), "story-hide", "hiding stays available")
+
+	item.CanFlag = true
+	w = httptest.NewRecorder()
+	a.renderFragment(w, "story-item", item)
+	assert.Contains(t, w.Body.String(), "story-flag-btn")
+}
+
+func TestProbationUsersSkipsModerators(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.Probation = Probation{Period: 7 * 24 * time.Hour}
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "mod", Email: "mod@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "UPDATE users SET is_moderator = true WHERE id = $1", u.ID)
+	require.NoError(t, err)
+
+	rows := []store.ListStoriesRow{
+		{ID: 1, UserID: u.ID, UserCreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}},
+	}
+	got, err := a.probationUsers(ctx, rows, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
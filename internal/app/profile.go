@@ -3,23 +3,25 @@ package app
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func (a *App) profilePage(w http.ResponseWriter, r *http.Request) {
 	username := r.PathValue("username")
 	if username == "" {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	profile, err := a.Queries.GetPublicProfile(r.Context(), username)
+	profile, err := a.reads().GetPublicProfile(r.Context(), username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.profileTombstone(w, r, username)
+		return
+	}
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
-			return
-		}
 		a.serverError(w, r, "get public profile", err)
 		return
 	}
@@ -29,8 +31,9 @@ func (a *App) profilePage(w http.ResponseWriter, r *http.Request) {
 		invitedBy = profile.InviterName.String
 	}
 
+	base := a.baseData(r)
 	a.render(w, "profile", ProfilePageData{
-		Base:            a.baseData(r),
+		Base:            base,
 		ProfileUsername: profile.Username,
 		About:           profile.About,
 		Website:         profile.Website,
@@ -38,5 +41,58 @@ func (a *App) profilePage(w http.ResponseWriter, r *http.Request) {
 		StoryCount:      profile.StoryCount,
 		InvitedBy:       invitedBy,
 		CreatedAt:       profile.CreatedAt.Time,
+		LastActive:      lastActiveBucket(profile.LastSeenAt, time.Now()),
+		CanImpersonate:  base.IsModerator && !profile.IsModerator && base.ImpersonatedBy == "",
 	})
 }
+
+// profileTombstone renders the profile of a banned or deleted account,
+// which shows only that the account is gone.
+func (a *App) profileTombstone(w http.ResponseWriter, r *http.Request, username string) {
+	gone, err := a.reads().GetUserTombstone(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get user tombstone", err)
+		return
+	}
+
+	a.render(w, "profile", ProfilePageData{
+		Base:            a.baseData(r),
+		ProfileUsername: gone.Username,
+		Tombstone:       authorState(gone.BannedAt, gone.DeletedAt),
+	})
+}
+
+// authorState describes an account that can no longer post: "deleted"
+// when it was removed, "banned" when a moderator banned it, otherwise "".
+func authorState(bannedAt, deletedAt pgtype.Timestamptz) string {
+	switch {
+	case deletedAt.Valid:
+		return "deleted"
+	case bannedAt.Valid:
+		return "banned"
+	default:
+		return ""
+	}
+}
+
+// lastActiveBucket describes when a user was last seen in coarse terms,
+// so profiles show whether an account is live without exposing exact times.
+func lastActiveBucket(lastSeen pgtype.Timestamptz, now time.Time) string {
+	if !lastSeen.Valid {
+		return ""
+	}
+	switch d := now.Sub(lastSeen.Time); {
+	case d < 24*time.Hour:
+		return "active today"
+	case d < 7*24*time.Hour:
+		return "active this week"
+	case d < 30*24*time.Hour:
+		return "active this month"
+	default:
+		return "not active recently"
+	}
+}
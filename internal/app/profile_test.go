@@ -0,0 +1,96 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestLastActiveBucket(t *testing.T) {
+	now := time.Now()
+	seen := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: now.Add(-d), Valid: true}
+	}
+
+	tests := []struct {
+		name     string
+		lastSeen pgtype.Timestamptz
+		want     string
+	}{
+		{"never", pgtype.Timestamptz{}, ""},
+		{"minutes ago", seen(5 * time.Minute), "active today"},
+		{"yesterday", seen(30 * time.Hour), "active this week"},
+		{"two weeks", seen(14 * 24 * time.Hour), "active this month"},
+		{"months", seen(90 * 24 * time.Hour), "not active recently"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, lastActiveBucket(tt.lastSeen, now))
+		})
+	}
+}
+
+func TestRenderProfileLastActive(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "profile", ProfilePageData{
+		ProfileUsername: "alice",
+		CreatedAt:       time.Now(),
+		LastActive:      "active this week",
+	})
+
+	assert.Contains(t, w.Body.String(), "active this week")
+}
+
+func TestAuthorState(t *testing.T) {
+	set := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	assert.Equal(t, "", authorState(pgtype.Timestamptz{}, pgtype.Timestamptz{}))
+	assert.Equal(t, "banned", authorState(set, pgtype.Timestamptz{}))
+	assert.Equal(t, "deleted", authorState(pgtype.Timestamptz{}, set))
+	assert.Equal(t, "deleted", authorState(set, set))
+}
+
+func TestRenderBannedAuthor(t *testing.T) {
+	a := testApp(t)
+
+	row := commentRow(1, 0, 1, 0, time.Hour)
+	row.Username = "carol"
+	row.UserBannedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	comments := buildCommentTree([]store.ListCommentsByStoryRow{row}, buildTreeOpts{})
+	require.Len(t, comments, 1)
+	assert.Equal(t, "banned", comments[0].AuthorState)
+
+	w := httptest.NewRecorder()
+	a.render(w, "story", StoryPageData{
+		Story: StoryItem{
+			ID: 1, ShortCode: "abc123", Title: "Story", Username: "bob",
+			AuthorState: "banned", IsText: true, CreatedAt: time.Now(),
+		},
+		Comments: comments,
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `<s class="author--gone">bob</s>`)
+	assert.Contains(t, body, `<s class="comment__author author--gone">carol</s>`)
+	assert.Contains(t, body, "[banned]")
+	assert.NotContains(t, body, `href="/u/bob"`)
+	assert.NotContains(t, body, `href="/u/carol"`)
+}
+
+func TestRenderProfileTombstone(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "profile", ProfilePageData{ProfileUsername: "bob", Tombstone: "banned"})
+
+	body := w.Body.String()
+	assert.Contains(t, body, "This account has been banned.")
+	assert.NotContains(t, body, "member since")
+}
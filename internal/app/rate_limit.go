@@ -0,0 +1,35 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"crow.watch/internal/ratelimit"
+)
+
+// rateLimitMessage sets Retry-After to the time until key may try again
+// and returns a message telling the user how long that is. what names the
+// attempts being limited, e.g. "login attempts".
+func rateLimitMessage(w http.ResponseWriter, l *ratelimit.Limiter, key, what string) string {
+	d := l.RetryAfter(key)
+	seconds := max(int(math.Ceil(d.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return fmt.Sprintf("Too many %s. Please try again in %s.", what, retryMinutes(d))
+}
+
+// retryMinutes formats d as a whole number of minutes, or of hours from two
+// hours on, rounded up so the user never retries too early.
+func retryMinutes(d time.Duration) string {
+	minutes := int(math.Ceil(d.Minutes()))
+	switch {
+	case minutes <= 1:
+		return "1 minute"
+	case minutes < 120:
+		return fmt.Sprintf("%d minutes", minutes)
+	default:
+		return fmt.Sprintf("%d hours", (minutes+59)/60)
+	}
+}
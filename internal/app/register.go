@@ -19,7 +19,7 @@ import (
 
 var usernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
-func validateRegistration(username, email, password, passwordConfirmation string) map[string]string {
+func validateRegistration(username, email, password, passwordConfirmation string, policy PasswordPolicy, emailPolicy EmailPolicy) map[string]string {
 	errs := make(map[string]string)
 
 	if username == "" {
@@ -32,12 +32,14 @@ func validateRegistration(username, email, password, passwordConfirmation string
 
 	if email == "" {
 		errs["email"] = "E-mail is required."
+	} else if msg := emailPolicy.check(email); msg != "" {
+		errs["email"] = msg
 	}
 
 	if password == "" {
 		errs["password"] = "Password is required."
-	} else if len(password) > 72 {
-		errs["password"] = "Password must be 72 bytes or fewer."
+	} else if msg := policy.check(password); msg != "" {
+		errs["password"] = msg
 	} else if password != passwordConfirmation {
 		errs["password_confirmation"] = "Passwords do not match."
 	}
@@ -45,6 +47,13 @@ func validateRegistration(username, email, password, passwordConfirmation string
 	return errs
 }
 
+// ValidateAccount checks an account created outside the sign-up forms, such
+// as the initial admin, against the rules registration applies. It returns
+// the problems keyed by field: "username", "email" and "password".
+func ValidateAccount(username, email, password string, policy PasswordPolicy, emailPolicy EmailPolicy) map[string]string {
+	return validateRegistration(username, email, password, password, policy, emailPolicy)
+}
+
 // registerPage handles GET /register/{token} (invitation flow).
 func (a *App) registerPage(w http.ResponseWriter, r *http.Request) {
 	if _, ok := auth.UserFromContext(r.Context()); ok {
@@ -88,17 +97,13 @@ func (a *App) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		a.render(w, "register", RegisterPageData{
-			Base:   a.baseData(r),
-			Errors: map[string]string{"form": "Invalid request."},
-		})
+	if !parseForm(w, r) {
 		return
 	}
 
 	token := r.PathValue("token")
 	tokenHash := auth.HashToken(token)
-	username := strings.TrimSpace(r.FormValue("username"))
+	username := cleanText(r.FormValue("username"))
 	email := strings.TrimSpace(r.FormValue("email"))
 	password := r.FormValue("password")
 	passwordConfirmation := r.FormValue("password_confirmation")
@@ -127,7 +132,7 @@ func (a *App) register(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	errs := validateRegistration(username, email, password, passwordConfirmation)
+	errs := validateRegistration(username, email, password, passwordConfirmation, a.PasswordPolicy, a.EmailPolicy)
 	if len(errs) > 0 {
 		renderErr(errs)
 		return
@@ -207,7 +212,7 @@ func (a *App) joinPage(w http.ResponseWriter, r *http.Request) {
 	campaign, err := a.Queries.GetActiveCampaignBySlug(r.Context(), slug)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get campaign", err)
@@ -235,8 +240,7 @@ func (a *App) joinRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
@@ -245,14 +249,14 @@ func (a *App) joinRegister(w http.ResponseWriter, r *http.Request) {
 	campaign, err := a.Queries.GetActiveCampaignBySlug(r.Context(), slug)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get campaign", err)
 		return
 	}
 
-	username := strings.TrimSpace(r.FormValue("username"))
+	username := cleanText(r.FormValue("username"))
 	email := strings.TrimSpace(r.FormValue("email"))
 	password := r.FormValue("password")
 	passwordConfirmation := r.FormValue("password_confirmation")
@@ -270,7 +274,7 @@ func (a *App) joinRegister(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	errs := validateRegistration(username, email, password, passwordConfirmation)
+	errs := validateRegistration(username, email, password, passwordConfirmation, a.PasswordPolicy, a.EmailPolicy)
 
 	captchaID := r.FormValue("captcha_id")
 	captchaAnswer, _ := strconv.Atoi(r.FormValue("captcha_answer"))
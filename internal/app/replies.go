@@ -1,12 +1,16 @@
 package app
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
 )
 
 type RepliesPageData struct {
@@ -18,7 +22,10 @@ type ReplyItem struct {
 	CommentID     int64
 	StoryTitle    string
 	StoryPath     string
+	Permalink     string
+	ParentSnippet string
 	CommentAuthor string
+	IsReply       bool // false for a new comment on a subscribed story
 	Body          template.HTML
 	CreatedAt     time.Time
 	IsUnread      bool
@@ -37,21 +44,55 @@ func (a *App) repliesPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a.render(w, "replies", RepliesPageData{
+		Base:    a.baseData(r),
+		Replies: buildReplyItems(rows),
+	})
+}
+
+func buildReplyItems(rows []store.ListRepliesRow) []ReplyItem {
 	var replies []ReplyItem
 	for _, r := range rows {
+		// Comments from subscribed stories have no parent to quote.
+		var parent string
+		if r.IsReply {
+			parent = "[deleted]"
+			if !r.ParentDeletedAt.Valid {
+				parent = commentSnippet(r.ParentBody.String, replySnippetLength)
+			}
+		}
 		replies = append(replies, ReplyItem{
 			CommentID:     r.CommentID,
 			StoryTitle:    r.StoryTitle,
 			StoryPath:     storyPath(r.StoryShortCode, r.StoryTitle),
+			Permalink:     commentPath(r.StoryShortCode, r.CommentID),
+			ParentSnippet: parent,
 			CommentAuthor: r.CommentAuthor,
+			IsReply:       r.IsReply,
 			Body:          markdown.Render(r.Body),
 			CreatedAt:     r.CreatedAt.Time,
 			IsUnread:      r.IsUnread,
 		})
 	}
+	return replies
+}
 
-	a.render(w, "replies", RepliesPageData{
-		Base:    a.baseData(r),
-		Replies: replies,
-	})
+// replySnippetLength is how many characters of the parent comment are
+// shown above a reply.
+const replySnippetLength = 120
+
+// commentPath returns the permalink of a comment's thread.
+func commentPath(code string, commentID int64) string {
+	return fmt.Sprintf("/x/%s/comments/%d#comment-%d", code, commentID, commentID)
+}
+
+// commentSnippet flattens a comment body to one line and cuts it to at
+// most n characters.
+func commentSnippet(body string, n int) string {
+	s := strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n])) + "…"
 }
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestBuildReplyItems(t *testing.T) {
+	rows := []store.ListRepliesRow{
+		{
+			CommentID:      42,
+			Body:           "I disagree",
+			CommentAuthor:  "bob",
+			StoryTitle:     "Hello World",
+			StoryShortCode: "abc123",
+			ParentBody:     pgtype.Text{String: "Rust is\n\nthe best   language", Valid: true},
+			IsReply:        true,
+			CreatedAt:      pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		},
+		{
+			CommentID:       43,
+			StoryTitle:      "Hello World",
+			StoryShortCode:  "abc123",
+			ParentBody:      pgtype.Text{String: "gone", Valid: true},
+			ParentDeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			IsReply:         true,
+		},
+		{
+			CommentID:      44,
+			CommentAuthor:  "carol",
+			StoryTitle:     "Hello World",
+			StoryShortCode: "abc123",
+		},
+	}
+
+	items := buildReplyItems(rows)
+	require.Len(t, items, 3)
+	assert.Equal(t, "/x/abc123/comments/42#comment-42", items[0].Permalink)
+	assert.Equal(t, "/x/abc123/hello_world", items[0].StoryPath)
+	assert.Equal(t, "Rust is the best language", items[0].ParentSnippet)
+	assert.Equal(t, "[deleted]", items[1].ParentSnippet)
+	assert.False(t, items[2].IsReply)
+	assert.Empty(t, items[2].ParentSnippet, "a subscribed story's comment quotes nothing")
+}
+
+func TestCommentSnippet(t *testing.T) {
+	assert.Equal(t, "short", commentSnippet("short", 10))
+	assert.Equal(t, "abcde…", commentSnippet("abcde fghij", 5))
+	assert.Equal(t, "ёжики…", commentSnippet(strings.Repeat("ёжики", 3), 5))
+}
+
+func TestRenderRepliesPermalink(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "replies", RepliesPageData{
+		Base: Base{IsLoggedIn: true, Username: "alice"},
+		Replies: []ReplyItem{{
+			CommentID:     42,
+			StoryTitle:    "Hello",
+			StoryPath:     "/x/abc123/hello",
+			Permalink:     "/x/abc123/comments/42#comment-42",
+			ParentSnippet: "original point",
+			CommentAuthor: "bob",
+			IsReply:       true,
+			CreatedAt:     time.Now(),
+		}},
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `href="/x/abc123/comments/42#comment-42"`)
+	assert.Contains(t, body, "original point")
+	assert.Contains(t, body, "replied on")
+}
+
+func TestRenderRepliesSubscribedComment(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "replies", RepliesPageData{
+		Base: Base{IsLoggedIn: true, Username: "alice"},
+		Replies: []ReplyItem{{
+			CommentID:     43,
+			StoryTitle:    "Hello",
+			StoryPath:     "/x/abc123/hello",
+			Permalink:     "/x/abc123/comments/43#comment-43",
+			CommentAuthor: "bob",
+			CreatedAt:     time.Now(),
+		}},
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, "commented on")
+	assert.NotContains(t, body, `class="reply-item__parent"`)
+}
@@ -1,28 +1,109 @@
 package app
 
 import (
-	"crypto/rand"
+	"context"
+	"errors"
 	"regexp"
 	"strings"
-)
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 
-const shortCodeCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
+)
 
-func generateShortCode() string {
-	b := make([]byte, 6)
-	if _, err := rand.Read(b); err != nil {
-		panic("crypto/rand failed: " + err.Error())
+func (a *App) shortCodeLength() int {
+	if a.ShortCodeLength >= link.DefaultShortCodeLength && a.ShortCodeLength <= link.MaxShortCodeLength {
+		return a.ShortCodeLength
 	}
-	for i := range b {
-		b[i] = shortCodeCharset[int(b[i])%len(shortCodeCharset)]
+	return link.DefaultShortCodeLength
+}
+
+// validShortCode reports whether code has a length a story short code can
+// have. Anything between the default and the configured length is
+// accepted, since raising the length doesn't reissue existing codes.
+func (a *App) validShortCode(code string) bool {
+	return len(code) >= link.DefaultShortCodeLength && len(code) <= a.shortCodeLength()
+}
+
+// shortCodeAttempts is how many fresh short codes are tried before giving
+// up on a story insert that keeps colliding.
+const shortCodeAttempts = 5
+
+// createStory inserts a story with a new short code, drawing another code
+// if it collides with an existing story. Each attempt runs in a savepoint
+// so a collision doesn't abort the surrounding transaction.
+func (a *App) createStory(ctx context.Context, tx pgx.Tx, params store.CreateStoryParams) (store.CreateStoryRow, error) {
+	var story store.CreateStoryRow
+	err := retryShortCode(shortCodeAttempts, a.newShortCode, func(code string) error {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer sp.Rollback(ctx)
+
+		params.ShortCode = code
+		story, err = a.Queries.WithTx(sp).CreateStory(ctx, params)
+		if err != nil {
+			return err
+		}
+		return sp.Commit(ctx)
+	})
+	return story, err
+}
+
+func (a *App) newShortCode() string {
+	return link.ShortCode(a.shortCodeLength())
+}
+
+// retryShortCode calls insert with codes from newCode until it succeeds,
+// fails with something other than a short code collision, or attempts run
+// out.
+func retryShortCode(attempts int, newCode func() string, insert func(code string) error) error {
+	var err error
+	for range attempts {
+		err = insert(newCode())
+		if !isShortCodeCollision(err) {
+			return err
+		}
 	}
-	return string(b)
+	return err
+}
+
+func isShortCodeCollision(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "stories_short_code_unique"
 }
 
 var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
 
+// foldLetters covers Latin letters that do not decompose into an ASCII
+// base letter plus combining marks.
+var foldLetters = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d",
+	"ð", "d", "ł", "l", "þ", "th", "ı", "i",
+)
+
+// toASCII decomposes title (NFKD) and drops combining marks, so "Café"
+// becomes "Cafe". Characters without an ASCII form are left for slugify
+// to strip.
+func toASCII(title string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(t, title)
+	if err != nil {
+		return title
+	}
+	return s
+}
+
 func slugify(title string) string {
-	s := strings.ToLower(title)
+	s := foldLetters.Replace(strings.ToLower(title))
+	s = toASCII(s)
 	s = nonAlnum.ReplaceAllString(s, "_")
 	s = strings.Trim(s, "_")
 	if len(s) > 80 {
@@ -32,6 +113,26 @@ func slugify(title string) string {
 	return s
 }
 
+// storyPath returns the canonical path of a story. Titles with nothing
+// left to slugify (CJK, emoji) fall back to a fixed "story" slug.
 func storyPath(code, title string) string {
-	return "/x/" + code + "/" + slugify(title)
+	slug := slugify(title)
+	if slug == "" {
+		slug = "story"
+	}
+	return "/x/" + code + "/" + slug
+}
+
+// canonicalStoryRedirect reports where a request for path should be
+// redirected when it does not match the story's current slug, e.g. after
+// the title was edited. The query string is carried over.
+func canonicalStoryRedirect(path, rawQuery, code, title string) (string, bool) {
+	canonical := storyPath(code, title)
+	if path == canonical {
+		return "", false
+	}
+	if rawQuery != "" {
+		canonical += "?" + rawQuery
+	}
+	return canonical, true
 }
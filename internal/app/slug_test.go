@@ -1,21 +1,129 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
 )
 
-func TestGenerateShortCode(t *testing.T) {
-	code := generateShortCode()
-	assert.Len(t, code, 6)
-	for _, c := range code {
-		assert.Contains(t, shortCodeCharset, string(c))
+func TestRetryShortCode(t *testing.T) {
+	collision := &pgconn.PgError{Code: "23505", ConstraintName: "stories_short_code_unique"}
+	codes := []string{"aaaaaa", "bbbbbb", "cccccc"}
+
+	t.Run("retries on collision", func(t *testing.T) {
+		var tried []string
+		err := retryShortCode(5, sequence(codes), func(code string) error {
+			tried = append(tried, code)
+			if code == "cccccc" {
+				return nil
+			}
+			return collision
+		})
+		require.NoError(t, err)
+		assert.Equal(t, codes, tried)
+	})
+
+	t.Run("stops on other errors", func(t *testing.T) {
+		other := &pgconn.PgError{Code: "23505", ConstraintName: "stories_pkey"}
+		calls := 0
+		err := retryShortCode(5, sequence(codes), func(string) error {
+			calls++
+			return other
+		})
+		assert.ErrorIs(t, err, other)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("gives up after attempts", func(t *testing.T) {
+		calls := 0
+		err := retryShortCode(3, sequence(codes), func(string) error {
+			calls++
+			return fmt.Errorf("create story: %w", collision)
+		})
+		assert.True(t, isShortCodeCollision(err))
+		assert.Equal(t, 3, calls)
+	})
+}
+
+func sequence(codes []string) func() string {
+	i := 0
+	return func() string {
+		c := codes[i%len(codes)]
+		i++
+		return c
 	}
+}
+
+func TestValidShortCode(t *testing.T) {
+	a := &App{}
+	assert.True(t, a.validShortCode("abc123"))
+	assert.False(t, a.validShortCode("abc12"))
+	assert.False(t, a.validShortCode("abc1234"))
 
-	// Two codes should (almost certainly) differ
-	code2 := generateShortCode()
-	assert.NotEqual(t, code, code2)
+	a.ShortCodeLength = 8
+	assert.True(t, a.validShortCode("abc123"), "codes issued before the change stay valid")
+	assert.True(t, a.validShortCode("abcd1234"))
+	assert.False(t, a.validShortCode("abcd12345"))
+	assert.Len(t, a.newShortCode(), 8)
+
+	a.ShortCodeLength = 100
+	assert.Len(t, a.newShortCode(), 6, "out of range lengths fall back to the default")
+}
+
+func TestConfiguredShortCodeLength(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.ShortCodeLength = 10
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+
+	form := url.Values{
+		"title": {"Longer codes"},
+		"body":  {"body"},
+		"tags":  {strconv.FormatInt(tagID, 10)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w := httptest.NewRecorder()
+	a.submitStory(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	location := w.Header().Get("Location")
+	code := strings.Split(location, "/")[2]
+	assert.Len(t, code, 10)
+
+	req = httptest.NewRequest(http.MethodGet, location, nil)
+	req.SetPathValue("code", code)
+	req.SetPathValue("slug", "longer_codes")
+	w = httptest.NewRecorder()
+	a.showStory(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Longer codes")
 }
 
 func TestSlugify(t *testing.T) {
@@ -51,3 +159,109 @@ func TestSlugifyTruncates(t *testing.T) {
 func TestStoryPath(t *testing.T) {
 	assert.Equal(t, "/x/abc123/hello_world", storyPath("abc123", "Hello World"))
 }
+
+func TestSlugifyUnicode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"accents", "Café Déjà Vu", "cafe_deja_vu"},
+		{"non-decomposing letters", "Straße Łódź Ærø", "strasse_lodz_aero"},
+		{"ligature", "ﬁle systems", "file_systems"},
+		{"cjk", "日本語のタイトル", ""},
+		{"cjk mixed", "Go 言語 入門", "go"},
+		{"emoji", "🚀🔥", ""},
+		{"emoji mixed", "Shipping 🚀 Rust 1.80", "shipping_rust_1_80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slugify(tt.input)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, got, slugify(tt.input), "slug must be stable")
+		})
+	}
+}
+
+func TestSlugifyTruncatesMultibyte(t *testing.T) {
+	slug := slugify(strings.Repeat("é", 100))
+	assert.Len(t, slug, 80)
+	assert.Equal(t, strings.Repeat("e", 80), slug)
+}
+
+func TestStoryPathFallbackSlug(t *testing.T) {
+	assert.Equal(t, "/x/abc123/story", storyPath("abc123", "日本語のタイトル"))
+	assert.Equal(t, "/x/abc123/story", storyPath("abc123", "🚀🔥"))
+}
+
+func TestCanonicalStoryRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		query    string
+		title    string
+		want     string
+		redirect bool
+	}{
+		{"canonical", "/x/abc123/hello_world", "", "Hello World", "", false},
+		{"missing slug", "/x/abc123", "", "Hello World", "/x/abc123/hello_world", true},
+		{"title changed", "/x/abc123/old_title", "", "New Title", "/x/abc123/new_title", true},
+		{"keeps query", "/x/abc123/old_title", "sort=new", "New Title", "/x/abc123/new_title?sort=new", true},
+		{"cjk canonical", "/x/abc123/story", "", "日本語", "", false},
+		{"cjk redirect", "/x/abc123/", "", "日本語", "/x/abc123/story", true},
+		{"emoji canonical", "/x/abc123/story", "", "🚀", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := canonicalStoryRedirect(tt.path, tt.query, "abc123", tt.title)
+			assert.Equal(t, tt.redirect, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShortStoryLinkInvalidCode(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/s/abc", nil)
+	req.SetPathValue("code", "abc")
+	w := httptest.NewRecorder()
+	a.shortStoryLink(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestShortStoryLink(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Hello World",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	get := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/s/"+code, nil)
+		req.SetPathValue("code", code)
+		w := httptest.NewRecorder()
+		a.shortStoryLink(w, req)
+		return w
+	}
+
+	w := get("abc123")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/x/abc123/hello_world", w.Header().Get("Location"))
+
+	w = get("zzz999")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,29 +2,77 @@ package app
 
 import (
 	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
+	"crow.watch/internal/analytics"
 	"crow.watch/internal/auth"
+	"crow.watch/internal/link"
 	"crow.watch/internal/markdown"
 	"crow.watch/internal/store"
 )
 
 func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
+	a.serveStory(w, r, 0)
+}
+
+// showCommentThread serves GET /x/{code}/comments/{id}: the story page
+// with only the subtree rooted at that comment.
+func (a *App) showCommentThread(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || commentID <= 0 {
+		a.notFound(w, r)
+		return
+	}
+	a.serveStory(w, r, commentID)
+}
+
+// shortStoryLink serves GET /s/{code}, a short permalink for sharing that
+// redirects to the story's canonical path, so shared links survive title
+// edits.
+func (a *App) shortStoryLink(w http.ResponseWriter, r *http.Request) {
 	code := r.PathValue("code")
-	if len(code) != 6 {
-		http.NotFound(w, r)
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
 		return
 	}
 
 	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	target := storyPath(row.ShortCode, row.Title)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// serveStory renders a story page. A non-zero focusID limits the comments
+// to that comment's subtree.
+func (a *App) serveStory(w http.ResponseWriter, r *http.Request, focusID int64) {
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get story by short code", err)
@@ -32,17 +80,104 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Canonical slug redirect
-	canonical := storyPath(row.ShortCode, row.Title)
-	if r.URL.Path != canonical {
-		http.Redirect(w, r, canonical, http.StatusMovedPermanently)
+	if focusID == 0 {
+		if target, ok := canonicalStoryRedirect(r.URL.Path, r.URL.RawQuery, row.ShortCode, row.Title); ok {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	a.renderStory(w, r, row, focusID, stashedCommentDraft(r))
+}
+
+// renderStory renders the story page for row. draft refills the comment
+// form with a rejected or stashed comment.
+func (a *App) renderStory(w http.ResponseWriter, r *http.Request, row store.GetStoryRow, focusID int64, draft CommentDraft) {
+	item, err := a.storyItem(r, row)
+	if err != nil {
+		a.serverError(w, r, "load story", err)
 		return
 	}
 
-	tagRows, err := a.Queries.GetStoryTags(r.Context(), row.ID)
+	commentSort := parseCommentSort(r.URL.Query().Get("sort"))
+	comments, err := a.storyComments(r, row, commentSort)
 	if err != nil {
-		a.serverError(w, r, "get story tags", err)
+		a.serverError(w, r, "load comments", err)
 		return
 	}
+
+	if focusID != 0 {
+		node := findComment(comments, focusID)
+		if node == nil {
+			a.notFound(w, r)
+			return
+		}
+		comments = []*CommentNode{node}
+	}
+	unreadIDs := linkUnread(comments)
+
+	current, loggedIn := auth.UserFromContext(r.Context())
+	var body template.HTML
+	var embedURL string
+	if item.DeletedAt == nil {
+		body = markdown.Render(row.Body.String)
+		embedURL = storyEmbedURL(loggedIn && current.User.EnableEmbeds, item.Tags, row.Url.String)
+	}
+
+	var duplicates []DuplicateStory
+	dupRows, err := a.Queries.ListDuplicatesOf(r.Context(), row.ID)
+	if err != nil {
+		a.serverError(w, r, "list duplicates", err)
+		return
+	}
+	for _, d := range dupRows {
+		duplicates = append(duplicates, DuplicateStory{
+			ShortCode: d.ShortCode,
+			Title:     d.Title,
+		})
+	}
+
+	var subscribed bool
+	if loggedIn {
+		subscribed, err = a.Queries.IsSubscribedToStory(r.Context(), store.IsSubscribedToStoryParams{
+			UserID:  current.User.ID,
+			StoryID: row.ID,
+		})
+		if err != nil {
+			a.serverError(w, r, "check story subscription", err)
+			return
+		}
+	}
+
+	// The tombstone stays viewable, but tell caches and crawlers the
+	// story is gone for good.
+	status := http.StatusOK
+	if item.DeletedAt != nil {
+		status = http.StatusGone
+	}
+	a.renderStatus(w, status, "story", StoryPageData{
+		Base:        a.baseData(r),
+		Story:       item,
+		Body:        body,
+		Comments:    comments,
+		CommentSort: commentSort,
+		Duplicates:  duplicates,
+		FocusID:     focusID,
+		UnreadIDs:   unreadIDs,
+		EmbedURL:    embedURL,
+		CanHistory:  loggedIn && canViewStoryHistory(current.User, row.UserID),
+		Draft:       draft,
+		Subscribed:  subscribed,
+	})
+}
+
+// storyItem builds the story header shown above the comments, including
+// the current user's vote, flag and hide state, and counts the view.
+func (a *App) storyItem(r *http.Request, row store.GetStoryRow) (StoryItem, error) {
+	tagRows, err := a.Queries.GetStoryTags(r.Context(), row.ID)
+	if err != nil {
+		return StoryItem{}, fmt.Errorf("get story tags: %w", err)
+	}
 	var tags []StoryTag
 	for _, t := range tagRows {
 		tags = append(tags, StoryTag{Tag: t.Tag, IsMedia: t.IsMedia})
@@ -51,10 +186,8 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 	var hasUpvoted bool
 	var hasStoryFlagged bool
 	var hasStoryHidden bool
-	var currentUserID int64
 	current, loggedIn := auth.UserFromContext(r.Context())
 	if loggedIn {
-		currentUserID = current.User.ID
 		votedIDs, err := a.Queries.GetUserVotes(r.Context(), store.GetUserVotesParams{
 			UserID:   current.User.ID,
 			StoryIds: []int64{row.ID},
@@ -98,15 +231,20 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	storyDomain := row.Domain.String
+	domainName := row.Domain.String
 	if row.Origin.Valid {
 		storyDomain = row.Origin.String
 	}
+	onProbation, err := a.viewerOnProbation(r.Context())
+	if err != nil {
+		return StoryItem{}, fmt.Errorf("check probation: %w", err)
+	}
+
 	// Fetch story flag breakdown
 	var flagCounts []FlagCount
 	flagRows, err := a.Queries.GetStoryFlagCounts(r.Context(), row.ID)
 	if err != nil {
-		a.serverError(w, r, "get story flag counts", err)
-		return
+		return StoryItem{}, fmt.Errorf("get story flag counts: %w", err)
 	}
 	for _, f := range flagRows {
 		flagCounts = append(flagCounts, FlagCount{Reason: f.Reason, Count: int(f.Count)})
@@ -114,42 +252,65 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 
 	storyTitle := row.Title
 	storyURL := row.Url.String
+	var canonicalURL, canonicalDomain string
+	if row.CanonicalUrl.Valid {
+		if res, err := link.Clean(row.CanonicalUrl.String); err == nil {
+			canonicalURL = res.Cleaned
+			canonicalDomain = res.Domain
+		}
+	}
 	if storyDeletedAt != nil {
 		storyTitle = "[deleted by moderator]"
 		storyURL = ""
 		storyDomain = ""
+		domainName = ""
+		canonicalURL = ""
+		canonicalDomain = ""
 	}
 
-	item := StoryItem{
+	return StoryItem{
 		ID:                   row.ID,
 		ShortCode:            row.ShortCode,
 		URL:                  storyURL,
 		Title:                storyTitle,
+		CanonicalURL:         canonicalURL,
+		CanonicalDomain:      canonicalDomain,
 		Domain:               storyDomain,
+		DomainName:           domainName,
 		Username:             row.Username,
+		AuthorState:          authorState(row.UserBannedAt, row.UserDeletedAt),
 		Tags:                 tags,
 		Upvotes:              int(row.Upvotes),
 		Downvotes:            int(row.Downvotes),
+		Score:                int(row.Upvotes - row.Downvotes + row.AdminAdjustment),
 		CommentCount:         int(row.CommentCount),
+		ViewCount:            a.recordStoryView(r, row.ID, int(row.ViewCount)),
 		HasUpvoted:           hasUpvoted,
 		HasFlagged:           hasStoryFlagged,
 		HasHidden:            hasStoryHidden,
-		FlagReasons:          storyFlagReasons,
+		FlagReasons:          a.storyFlagReasons().Names(),
 		FlagCounts:           flagCounts,
-		IsText:               row.Body.Valid,
+		IsText:               !row.Url.Valid,
 		IsLoggedIn:           loggedIn,
+		CanFlag:              loggedIn && !onProbation,
 		IsModerator:          loggedIn && current.User.IsModerator,
+		IsOwn:                loggedIn && current.User.ID == row.UserID,
+		CanEdit:              loggedIn && storyEditRoleFor(current.User, row.UserID, row.CreatedAt.Time, time.Now()) != storyEditNone,
 		CreatedAt:            row.CreatedAt.Time,
 		DeletedAt:            storyDeletedAt,
 		DuplicateOfShortCode: row.DuplicateOfShortCode.String,
 		DuplicateOfTitle:     row.DuplicateOfTitle.String,
-	}
+		IsPick:               row.PickedAt.Valid,
+	}, nil
+}
 
-	// Fetch comments
+// storyComments builds the comment tree of a story with the current
+// user's vote, flag and unread state, then records the visit so the same
+// comments aren't unread next time.
+func (a *App) storyComments(r *http.Request, row store.GetStoryRow, commentSort string) ([]*CommentNode, error) {
 	commentRows, err := a.Queries.ListCommentsByStory(r.Context(), row.ID)
 	if err != nil {
-		a.serverError(w, r, "list comments", err)
-		return
+		return nil, fmt.Errorf("list comments: %w", err)
 	}
 
 	// Batch-fetch comment votes, flags, and flag counts
@@ -175,6 +336,16 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var currentUserID int64
+	current, loggedIn := auth.UserFromContext(r.Context())
+	if loggedIn {
+		currentUserID = current.User.ID
+	}
+	onProbation, err := a.viewerOnProbation(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("check probation: %w", err)
+	}
+
 	if loggedIn && len(commentIDs) > 0 {
 		if votedIDs, err := a.Queries.GetUserCommentVotes(r.Context(), store.GetUserCommentVotesParams{
 			UserID:     current.User.ID,
@@ -211,7 +382,12 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 		flagCountsMap:    commentFlagCountsMap,
 		lastVisit:        lastVisit,
 		isLoggedIn:       loggedIn,
+		canFlag:          loggedIn && !onProbation,
 		storyCode:        row.ShortCode,
+		sort:             commentSort,
+		flagReasons:      a.commentFlagReasons().Names(),
+		collapse:         a.CommentCollapse,
+		submitterFirst:   a.SubmitterFirst,
 	})
 
 	// Update story visit AFTER building the tree (so current visit doesn't affect unread status)
@@ -221,30 +397,44 @@ func (a *App) showStory(w http.ResponseWriter, r *http.Request) {
 			StoryID: row.ID,
 		})
 	}
+	return comments, nil
+}
 
-	var body template.HTML
-	if storyDeletedAt == nil {
-		body = markdown.Render(row.Body.String)
+// storyEmbedURL returns the player to embed for a story link, or "" when
+// the viewer hasn't enabled embeds, the story has no media tag, or the
+// link isn't on a known media host.
+func storyEmbedURL(enabled bool, tags []StoryTag, rawURL string) string {
+	if !enabled || rawURL == "" {
+		return ""
+	}
+	for _, t := range tags {
+		if t.IsMedia {
+			return link.EmbedURL(rawURL)
+		}
 	}
+	return ""
+}
 
-	var duplicates []DuplicateStory
-	dupRows, err := a.Queries.ListDuplicatesOf(r.Context(), row.ID)
-	if err != nil {
-		a.serverError(w, r, "list duplicates", err)
-		return
+// recordStoryView counts the request as a view of the story and returns
+// the view count to display, including views not yet flushed to the
+// database. Logged-in users are deduplicated by account, anonymous
+// visitors by their daily analytics visitor hash; bots are not counted.
+func (a *App) recordStoryView(r *http.Request, storyID int64, stored int) int {
+	if a.Views == nil {
+		return stored
 	}
-	for _, d := range dupRows {
-		duplicates = append(duplicates, DuplicateStory{
-			ShortCode: d.ShortCode,
-			Title:     d.Title,
-		})
+	if viewer := a.viewerKey(r); viewer != "" {
+		a.Views.Record(storyID, viewer)
 	}
+	return stored + a.Views.Pending(storyID)
+}
 
-	a.render(w, "story", StoryPageData{
-		Base:       a.baseData(r),
-		Story:      item,
-		Body:       body,
-		Comments:   comments,
-		Duplicates: duplicates,
-	})
+func (a *App) viewerKey(r *http.Request) string {
+	if current, ok := auth.UserFromContext(r.Context()); ok {
+		return "u:" + strconv.FormatInt(current.User.ID, 10)
+	}
+	if a.Analytics == nil || analytics.ParseUA(r.UserAgent()).IsBot {
+		return ""
+	}
+	return "a:" + a.Analytics.VisitorID(a.clientIP(r), r.UserAgent())
 }
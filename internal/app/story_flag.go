@@ -1,94 +1,255 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"crow.watch/internal/auth"
+	"crow.watch/internal/flagreason"
 	"crow.watch/internal/store"
 )
 
-var storyFlagReasons = []string{"off-topic", "already posted", "broken link", "spam"}
+func (a *App) storyFlagReasons() flagreason.List {
+	if len(a.StoryFlags) > 0 {
+		return a.StoryFlags
+	}
+	return flagreason.DefaultStory
+}
+
+func (a *App) commentFlagReasons() flagreason.List {
+	if len(a.CommentFlags) > 0 {
+		return a.CommentFlags
+	}
+	return flagreason.DefaultComment
+}
+
+// DefaultFlagMinAge is how old an account must be before it can flag.
+const DefaultFlagMinAge = 72 * time.Hour
+
+// flagIneligibility returns why user may not flag stories or comments yet,
+// or "" if they may. New and unconfirmed accounts are kept from flagging
+// so throwaway sign-ups can't push stories down the ranking; moderators
+// are exempt.
+func flagIneligibility(user store.User, minAge time.Duration, now time.Time) string {
+	if user.IsModerator {
+		return ""
+	}
+	if !user.EmailConfirmedAt.Valid {
+		return "Confirm your email address before flagging."
+	}
+	if now.Sub(user.CreatedAt.Time) < minAge {
+		return "Your account is too new to flag. Try again later."
+	}
+	return ""
+}
+
+// downvoteParams builds the RecalculateStoryDownvotes arguments for a story
+// using the configured story flag weights and flagger trust.
+func (a *App) downvoteParams(storyID int64) store.RecalculateStoryDownvotesParams {
+	reasons, weights := a.storyFlagReasons().Weights()
+	trust := a.FlagTrust.Params(time.Now())
+	return store.RecalculateStoryDownvotesParams{
+		MaxPercent:     trust.MaxPercent,
+		WeighFlaggers:  trust.WeighFlaggers,
+		TrustedPercent: trust.TrustedPercent,
+		NewBefore:      pgtype.Timestamptz{Time: trust.NewBefore, Valid: true},
+		NewPercent:     trust.NewPercent,
+		TrustedBefore:  pgtype.Timestamptz{Time: trust.TrustedBefore, Valid: true},
+		TrustedKarma:   trust.TrustedKarma,
+		Reasons:        reasons,
+		Weights:        weights,
+		StoryID:        storyID,
+	}
+}
+
+type storyFlagResponse struct {
+	OK         bool             `json:"ok"`
+	HasFlagged bool             `json:"has_flagged"`
+	Flags      []flagCountEntry `json:"flags"`
+}
+
+type flagCountEntry struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+func newStoryFlagResponse(hasFlagged bool, rows []store.GetStoryFlagCountsRow) storyFlagResponse {
+	flags := make([]flagCountEntry, len(rows))
+	for i, f := range rows {
+		flags[i] = flagCountEntry{Reason: f.Reason, Count: int(f.Count)}
+	}
+	return storyFlagResponse{OK: true, HasFlagged: hasFlagged, Flags: flags}
+}
+
+// writeStoryFlagState responds with the viewer's flag state and the
+// story's current flag breakdown, or with the re-rendered flag control
+// when the client asks for HTML.
+func (a *App) writeStoryFlagState(w http.ResponseWriter, r *http.Request, storyID int64, hasFlagged bool) {
+	if wantsHTML(r) {
+		a.renderFragment(w, "story-flag", StoryItem{
+			ID:          storyID,
+			HasFlagged:  hasFlagged,
+			FlagReasons: a.storyFlagReasons().Names(),
+		})
+		return
+	}
+
+	rows, err := a.Queries.GetStoryFlagCounts(r.Context(), storyID)
+	if err != nil {
+		a.jsonServerError(w, r, "get story flag counts", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newStoryFlagResponse(hasFlagged, rows))
+}
+
+// updateStoryScore applies fn and recalculates the story's downvotes in one
+// transaction. The story row is locked first, so concurrent flags, hides
+// and comments on the same story queue up behind each other and every
+// recalculation sees all changes committed before it.
+func (a *App) updateStoryScore(ctx context.Context, storyID int64, fn func(q *store.Queries) error) error {
+	tx, err := a.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := a.Queries.WithTx(tx)
+	if err := qtx.LockStory(ctx, storyID); err != nil {
+		return fmt.Errorf("lock story: %w", err)
+	}
+	if err := fn(qtx); err != nil {
+		return err
+	}
+	if err := qtx.RecalculateStoryDownvotes(ctx, a.downvoteParams(storyID)); err != nil {
+		return fmt.Errorf("recalculate story downvotes: %w", err)
+	}
+	return tx.Commit(ctx)
+}
 
 func (a *App) flagStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
 	var req struct {
 		Reason string `json:"reason"`
+		// Original optionally names the story an "already posted" flag
+		// points at, by short code.
+		Original string `json:"original"`
 	}
-	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !decodeJSON(w, r, 1024, &req) {
 		return
 	}
 
-	validReason := false
-	for _, fr := range storyFlagReasons {
-		if fr == req.Reason {
-			validReason = true
-			break
-		}
+	if !a.storyFlagReasons().Contains(req.Reason) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid flag reason.")
+		return
 	}
-	if !validReason {
-		http.Error(w, "invalid flag reason", http.StatusBadRequest)
+	original := strings.TrimSpace(req.Original)
+	if original != "" && req.Reason != flagreason.AlreadyPosted {
+		writeJSONError(w, http.StatusBadRequest, "Only \""+flagreason.AlreadyPosted+"\" flags can name an original story.")
 		return
 	}
 
-	if err := a.Queries.CreateStoryFlag(r.Context(), store.CreateStoryFlagParams{
-		UserID:  current.User.ID,
-		StoryID: storyID,
-		Reason:  req.Reason,
-	}); err != nil {
-		a.serverError(w, r, "create story flag", err)
+	if msg := flagIneligibility(current.User, a.FlagMinAge, time.Now()); msg != "" {
+		writeJSONError(w, http.StatusForbidden, msg)
+		return
+	}
+	if ends, err := a.probationEnds(r.Context(), current.User, time.Now()); err != nil {
+		a.jsonServerError(w, r, "check probation", err)
+		return
+	} else if !ends.IsZero() {
+		writeJSONError(w, http.StatusForbidden, probationFlagMessage)
 		return
 	}
 
-	if err := a.Queries.RecalculateStoryDownvotes(r.Context(), storyID); err != nil {
-		a.serverError(w, r, "recalculate story downvotes", err)
+	var originalID pgtype.Int8
+	if original != "" {
+		id, err := a.originalStoryID(r.Context(), original, storyID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSONError(w, http.StatusBadRequest, "Original story not found.")
+			return
+		}
+		if err != nil {
+			a.jsonServerError(w, r, "get original story", err)
+			return
+		}
+		originalID = pgtype.Int8{Int64: id, Valid: true}
+	}
+
+	if err := a.updateStoryScore(r.Context(), storyID, func(q *store.Queries) error {
+		return q.CreateStoryFlag(r.Context(), store.CreateStoryFlagParams{
+			UserID:          current.User.ID,
+			StoryID:         storyID,
+			Reason:          req.Reason,
+			OriginalStoryID: originalID,
+		})
+	}); err != nil {
+		a.jsonServerError(w, r, "create story flag", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	a.writeStoryFlagState(w, r, storyID, true)
+}
+
+// originalStoryID resolves the short code an "already posted" flag names.
+// It returns pgx.ErrNoRows unless code is a live story other than the one
+// being flagged.
+func (a *App) originalStoryID(ctx context.Context, code string, flaggedID int64) (int64, error) {
+	if !a.validShortCode(code) {
+		return 0, pgx.ErrNoRows
+	}
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		return 0, err
+	}
+	if row.DeletedAt.Valid || row.ID == flaggedID {
+		return 0, pgx.ErrNoRows
+	}
+	return row.ID, nil
 }
 
 func (a *App) unflagStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
-	if err := a.Queries.DeleteStoryFlag(r.Context(), store.DeleteStoryFlagParams{
-		UserID:  current.User.ID,
-		StoryID: storyID,
+	if err := a.updateStoryScore(r.Context(), storyID, func(q *store.Queries) error {
+		return q.DeleteStoryFlag(r.Context(), store.DeleteStoryFlagParams{
+			UserID:  current.User.ID,
+			StoryID: storyID,
+		})
 	}); err != nil {
-		a.serverError(w, r, "delete story flag", err)
+		a.jsonServerError(w, r, "delete story flag", err)
 		return
 	}
 
-	if err := a.Queries.RecalculateStoryDownvotes(r.Context(), storyID); err != nil {
-		a.serverError(w, r, "recalculate story downvotes", err)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	a.writeStoryFlagState(w, r, storyID, false)
 }
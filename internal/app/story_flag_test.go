@@ -0,0 +1,396 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/store"
+)
+
+func TestDownvoteParamsUseConfiguredWeights(t *testing.T) {
+	a := &App{StoryFlags: flagreason.List{{Name: "off-topic", Weight: 1}, {Name: "spam", Weight: 5}}}
+
+	params := a.downvoteParams(42)
+	assert.Equal(t, int64(42), params.StoryID)
+	assert.Equal(t, []string{"off-topic", "spam"}, params.Reasons)
+	assert.Equal(t, []int32{1, 5}, params.Weights)
+}
+
+func TestFlagReasonsFallBackToDefaults(t *testing.T) {
+	a := &App{}
+	assert.Equal(t, flagreason.DefaultStory, a.storyFlagReasons())
+	assert.Equal(t, flagreason.DefaultComment, a.commentFlagReasons())
+	assert.False(t, a.storyFlagReasons().Contains("troll"), "comment-only reason")
+}
+
+// testDB creates a throwaway schema from db/schema.sql in the database at
+// TEST_DATABASE_URL, skipping the test when it is not set.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	schema, err := os.ReadFile("../../db/schema.sql")
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	conn, err := pgx.Connect(ctx, url)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	return pool
+}
+
+func TestConcurrentFlagsKeepDownvotesConsistent(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool)}
+
+	author, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "author", Email: "author@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    author.ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	const flaggers = 20
+	var userIDs []int64
+	for i := range flaggers {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username:       fmt.Sprintf("flagger%d", i),
+			Email:          fmt.Sprintf("flagger%d@example.com", i),
+			PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		userIDs = append(userIDs, u.ID)
+	}
+
+	// Each user hides and flags the story; every flag must be counted
+	// no matter how the transactions interleave.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*flaggers)
+	for _, uid := range userIDs {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- a.updateStoryScore(ctx, story.ID, func(q *store.Queries) error {
+				return q.HideStory(ctx, store.HideStoryParams{UserID: uid, StoryID: story.ID, Reason: hideReasonUnspecified})
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- a.updateStoryScore(ctx, story.ID, func(q *store.Queries) error {
+				return q.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: uid, StoryID: story.ID, Reason: "off-topic"})
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: story.ID, Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(flaggers), row.Downvotes)
+}
+
+func TestStoryFlagResponseJSON(t *testing.T) {
+	resp := newStoryFlagResponse(true, []store.GetStoryFlagCountsRow{
+		{Reason: "spam", Count: 2},
+		{Reason: "off-topic", Count: 1},
+	})
+
+	b, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ok":true,"has_flagged":true,"flags":[{"reason":"spam","count":2},{"reason":"off-topic","count":1}]}`, string(b))
+
+	b, err = json.Marshal(newStoryFlagResponse(false, nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ok":true,"has_flagged":false,"flags":[]}`, string(b))
+}
+
+func TestFlagStoryReturnsCounts(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool), FlagMinAge: DefaultFlagMinAge}
+
+	var users []store.User
+	for _, name := range []string{"alice", "bob"} {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		users = append(users, store.User{
+			ID:               u.ID,
+			Username:         u.Username,
+			EmailConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			CreatedAt:        pgtype.Timestamptz{Time: time.Now().Add(-30 * 24 * time.Hour), Valid: true},
+		})
+	}
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    users[0].ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	call := func(handler http.HandlerFunc, user store.User, body string) storyFlagResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("id", strconv.FormatInt(story.ID, 10))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: user}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp storyFlagResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := call(a.flagStory, users[0], `{"reason":"spam"}`)
+	assert.True(t, resp.OK)
+	assert.True(t, resp.HasFlagged)
+	assert.Equal(t, []flagCountEntry{{Reason: "spam", Count: 1}}, resp.Flags)
+
+	resp = call(a.flagStory, users[1], `{"reason":"spam"}`)
+	assert.Equal(t, []flagCountEntry{{Reason: "spam", Count: 2}}, resp.Flags)
+
+	resp = call(a.unflagStory, users[0], "")
+	assert.True(t, resp.OK)
+	assert.False(t, resp.HasFlagged)
+	assert.Equal(t, []flagCountEntry{{Reason: "spam", Count: 1}}, resp.Flags)
+}
+
+func TestFlagIneligibility(t *testing.T) {
+	now := time.Now()
+	confirmed := pgtype.Timestamptz{Time: now, Valid: true}
+	age := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: now.Add(-d), Valid: true}
+	}
+
+	tests := []struct {
+		name string
+		user store.User
+		ok   bool
+	}{
+		{"established and confirmed", store.User{CreatedAt: age(30 * 24 * time.Hour), EmailConfirmedAt: confirmed}, true},
+		{"too new", store.User{CreatedAt: age(time.Hour), EmailConfirmedAt: confirmed}, false},
+		{"unconfirmed", store.User{CreatedAt: age(30 * 24 * time.Hour)}, false},
+		{"new moderator", store.User{CreatedAt: age(time.Hour), IsModerator: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := flagIneligibility(tt.user, DefaultFlagMinAge, now)
+			assert.Equal(t, tt.ok, msg == "", msg)
+		})
+	}
+}
+
+func TestFlagRejectsNewAccount(t *testing.T) {
+	a := &App{FlagMinAge: DefaultFlagMinAge}
+	user := store.User{
+		ID:               1,
+		CreatedAt:        pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+		EmailConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"story":   a.flagStory,
+		"comment": a.flagComment,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"spam"}`))
+			req.SetPathValue("id", "1")
+			req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: user}))
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), "too new")
+		})
+	}
+}
+
+func TestTrustedFlagOutweighsNewAccountFlag(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool), FlagTrust: flagreason.DefaultTrust}
+
+	newUser := func(name string, age time.Duration) int64 {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, "UPDATE users SET created_at = now() - $2::interval, email_confirmed_at = now() WHERE id = $1",
+			u.ID, fmt.Sprintf("%d seconds", int(age.Seconds())))
+		require.NoError(t, err)
+		return u.ID
+	}
+	newStory := func(userID int64, code string) int64 {
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    userID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		return s.ID
+	}
+	flag := func(userID, storyID int64, reason string) int32 {
+		require.NoError(t, a.updateStoryScore(ctx, storyID, func(q *store.Queries) error {
+			if err := q.HideStory(ctx, store.HideStoryParams{UserID: userID, StoryID: storyID, Reason: hideReasonUnspecified}); err != nil {
+				return err
+			}
+			return q.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: userID, StoryID: storyID, Reason: reason})
+		}))
+		row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: storyID, Valid: true}})
+		require.NoError(t, err)
+		return row.Downvotes
+	}
+
+	author := newUser("author", 365*24*time.Hour)
+	trusted := newUser("trusted", 365*24*time.Hour)
+	fresh := newUser("fresh", 5*24*time.Hour)
+
+	// Give the trusted user enough karma from an upvoted story of their own.
+	own := newStory(trusted, "own001")
+	require.NoError(t, a.Queries.SetStoryUpvotes(ctx, store.SetStoryUpvotesParams{Upvotes: 100, ID: own}))
+
+	trustedDown := flag(trusted, newStory(author, "abc001"), "off-topic")
+	freshDown := flag(fresh, newStory(author, "abc002"), "off-topic")
+	assert.Greater(t, trustedDown, freshDown)
+	assert.Equal(t, int32(2), trustedDown)
+	assert.Equal(t, int32(0), freshDown, "half a flag rounds down")
+
+	// A heavy reason from a trusted user is capped.
+	assert.Equal(t, int32(3), flag(trusted, newStory(author, "abc003"), "spam"))
+
+	// Unconfirmed accounts don't count at all.
+	unconfirmed := newUser("unconfirmed", 365*24*time.Hour)
+	_, err := pool.Exec(ctx, "UPDATE users SET email_confirmed_at = NULL WHERE id = $1", unconfirmed)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), flag(unconfirmed, newStory(author, "abc004"), "off-topic"))
+}
+
+func TestFlagOriginalRequiresAlreadyPosted(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"spam","original":"abc123"}`))
+	req.SetPathValue("id", "1")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+	w := httptest.NewRecorder()
+	a.flagStory(w, req)
+	assertJSONError(t, w, http.StatusBadRequest)
+}
+
+func TestFlagAlreadyPostedStoresOriginal(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := &App{Pool: pool, Queries: store.New(pool), FlagMinAge: DefaultFlagMinAge}
+
+	var users []store.User
+	for _, name := range []string{"alice", "bob"} {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		users = append(users, store.User{
+			ID:               u.ID,
+			Username:         u.Username,
+			EmailConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			CreatedAt:        pgtype.Timestamptz{Time: time.Now().Add(-30 * 24 * time.Hour), Valid: true},
+		})
+	}
+	var stories []store.CreateStoryRow
+	for _, code := range []string{"orig01", "dupe01"} {
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    users[0].ID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		stories = append(stories, s)
+	}
+	dupe := stories[1]
+
+	flag := func(user store.User, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("id", strconv.FormatInt(dupe.ID, 10))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: user}))
+		w := httptest.NewRecorder()
+		a.flagStory(w, req)
+		return w
+	}
+	countFlags := func() int {
+		var n int
+		require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM story_flags WHERE story_id = $1", dupe.ID).Scan(&n))
+		return n
+	}
+
+	for _, code := range []string{"nope00", "dupe01"} {
+		w := flag(users[1], `{"reason":"already posted","original":"`+code+`"}`)
+		assertJSONError(t, w, http.StatusBadRequest)
+	}
+	assert.Zero(t, countFlags(), "rejected flags are not stored")
+
+	w := flag(users[1], `{"reason":"already posted","original":"orig01"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var original pgtype.Int8
+	require.NoError(t, pool.QueryRow(ctx, "SELECT original_story_id FROM story_flags WHERE story_id = $1", dupe.ID).Scan(&original))
+	assert.Equal(t, pgtype.Int8{Int64: stories[0].ID, Valid: true}, original)
+
+	queue, err := a.Queries.ListFlaggedStories(ctx, flagQueueLimit)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, "dupe01", queue[0].ShortCode)
+	assert.Equal(t, []string{"orig01"}, queue[0].OriginalShortCodes)
+	assert.Equal(t, []string{"Story orig01"}, queue[0].OriginalTitles)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
+)
+
+type StoryHistoryPageData struct {
+	Base      Base
+	ShortCode string
+	Title     string
+	StoryPath string
+	Revisions []StoryRevisionItem
+}
+
+type StoryRevisionItem struct {
+	Editor     string
+	Title      string
+	URL        string
+	Body       template.HTML
+	Tags       []string
+	Changed    []string // fields that differ from the previous revision
+	IsOriginal bool     // the story as it was submitted
+	CreatedAt  time.Time
+}
+
+// storySnapshot is the editable content of a story at one point in time.
+type storySnapshot struct {
+	Title string
+	URL   pgtype.Text
+	Body  pgtype.Text
+	Tags  []string
+}
+
+// recordStoryRevision stores the edited content of a story. The first
+// edit also stores the story as it was submitted, so the history always
+// starts from the original.
+func recordStoryRevision(ctx context.Context, q *store.Queries, row store.GetStoryRow, original, revised storySnapshot, editorID int64, now time.Time) error {
+	count, err := q.CountStoryRevisions(ctx, row.ID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := q.CreateStoryRevision(ctx, revisionParams(row.ID, row.UserID, original, row.CreatedAt.Time)); err != nil {
+			return err
+		}
+	}
+	return q.CreateStoryRevision(ctx, revisionParams(row.ID, editorID, revised, now))
+}
+
+func revisionParams(storyID, editorID int64, s storySnapshot, at time.Time) store.CreateStoryRevisionParams {
+	tags := slices.Clone(s.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	slices.Sort(tags)
+	return store.CreateStoryRevisionParams{
+		StoryID:   storyID,
+		EditorID:  editorID,
+		Title:     s.Title,
+		Url:       s.URL,
+		Body:      s.Body,
+		Tags:      tags,
+		CreatedAt: pgtype.Timestamptz{Time: at, Valid: true},
+	}
+}
+
+// canViewStoryHistory reports whether user may see a story's edit
+// history: moderators always, the submitter for their own stories.
+func canViewStoryHistory(user store.User, authorID int64) bool {
+	return user.IsModerator || user.ID == authorID
+}
+
+func (a *App) storyHistoryPage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	if !canViewStoryHistory(current.User, row.UserID) || (row.DeletedAt.Valid && !current.User.IsModerator) {
+		a.notFound(w, r)
+		return
+	}
+
+	rows, err := a.Queries.ListStoryRevisions(r.Context(), row.ID)
+	if err != nil {
+		a.serverError(w, r, "list story revisions", err)
+		return
+	}
+
+	a.render(w, "story_history", StoryHistoryPageData{
+		Base:      a.baseData(r),
+		ShortCode: row.ShortCode,
+		Title:     row.Title,
+		StoryPath: storyPath(row.ShortCode, row.Title),
+		Revisions: buildRevisionItems(rows),
+	})
+}
+
+// buildRevisionItems converts revisions, newest first, into page items and
+// marks which fields each one changed relative to the one before it.
+func buildRevisionItems(rows []store.ListStoryRevisionsRow) []StoryRevisionItem {
+	var items []StoryRevisionItem
+	for i, r := range rows {
+		item := StoryRevisionItem{
+			Editor:    r.EditorUsername,
+			Title:     r.Title,
+			URL:       r.Url.String,
+			Tags:      r.Tags,
+			CreatedAt: r.CreatedAt.Time,
+		}
+		if r.Body.Valid {
+			item.Body = markdown.Render(r.Body.String)
+		}
+		if i == len(rows)-1 {
+			item.IsOriginal = true
+		} else {
+			prev := rows[i+1]
+			if r.Title != prev.Title {
+				item.Changed = append(item.Changed, "title")
+			}
+			if r.Url != prev.Url {
+				item.Changed = append(item.Changed, "url")
+			}
+			if r.Body != prev.Body {
+				item.Changed = append(item.Changed, "body")
+			}
+			if !slices.Equal(r.Tags, prev.Tags) {
+				item.Changed = append(item.Changed, "tags")
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestBuildRevisionItems(t *testing.T) {
+	now := time.Now()
+	rows := []store.ListStoryRevisionsRow{
+		{ID: 3, Title: "Fixed title", Tags: []string{"go", "web"}, EditorUsername: "mod", CreatedAt: pgtype.Timestamptz{Time: now, Valid: true}},
+		{ID: 2, Title: "Fixed title", Tags: []string{"go"}, EditorUsername: "alice", CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true}},
+		{ID: 1, Title: "Fixd title", Tags: []string{"go"}, EditorUsername: "alice", CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}},
+	}
+
+	items := buildRevisionItems(rows)
+	require.Len(t, items, 3)
+	assert.Equal(t, []string{"tags"}, items[0].Changed)
+	assert.Equal(t, []string{"title"}, items[1].Changed)
+	assert.True(t, items[2].IsOriginal)
+	assert.Empty(t, items[2].Changed)
+}
+
+func TestCanViewStoryHistory(t *testing.T) {
+	assert.True(t, canViewStoryHistory(store.User{ID: 1}, 1))
+	assert.True(t, canViewStoryHistory(store.User{ID: 2, IsModerator: true}, 1))
+	assert.False(t, canViewStoryHistory(store.User{ID: 2}, 1))
+}
+
+func TestRenderStoryHistory(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+
+	a.render(w, "story_history", StoryHistoryPageData{
+		Base:      Base{IsLoggedIn: true, Username: "alice"},
+		Title:     "Fixed title",
+		StoryPath: "/x/abc123/fixed_title",
+		Revisions: []StoryRevisionItem{
+			{Editor: "mod", Title: "Fixed title", Tags: []string{"go", "web"}, Changed: []string{"tags"}, CreatedAt: time.Now()},
+			{Editor: "alice", Title: "Fixd title", Tags: []string{"go"}, IsOriginal: true, CreatedAt: time.Now()},
+		},
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `href="/x/abc123/fixed_title"`)
+	assert.Contains(t, body, "submitted by")
+	assert.Less(t, strings.Index(body, `href="/u/mod"`), strings.Index(body, `href="/u/alice"`))
+}
+
+func TestEditStoryRecordsRevisions(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	author := store.User{ID: u.ID, Username: u.Username}
+
+	var tagIDs []int64
+	for _, name := range []string{"go", "web"} {
+		var id int64
+		require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ($1) RETURNING id", name).Scan(&id))
+		tagIDs = append(tagIDs, id)
+	}
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    author.ID,
+		Title:     "Fixd title",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: tagIDs[0]}))
+
+	edit := func(title string, tags ...int64) {
+		t.Helper()
+		form := url.Values{"title": {title}}
+		for _, id := range tags {
+			form.Add("tags", strconv.FormatInt(id, 10))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "abc123")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: author}))
+		w := httptest.NewRecorder()
+		a.editStory(w, req)
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	}
+
+	edit("Fixed title", tagIDs[0])
+	edit("Fixed title", tagIDs...)
+
+	rows, err := a.Queries.ListStoryRevisions(ctx, story.ID)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"go", "web"}, rows[0].Tags)
+	assert.Equal(t, "Fixed title", rows[1].Title)
+	assert.Equal(t, "Fixd title", rows[2].Title)
+
+	req := httptest.NewRequest(http.MethodGet, "/x/abc123/history", nil)
+	req.SetPathValue("code", "abc123")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: author}))
+	w := httptest.NewRecorder()
+	a.storyHistoryPage(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Less(t, strings.Index(body, "edited by"), strings.Index(body, "submitted by"))
+
+	req = req.WithContext(auth.ContextWithUser(context.Background(), auth.AuthenticatedUser{User: store.User{ID: author.ID + 1}}))
+	w = httptest.NewRecorder()
+	a.storyHistoryPage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
+)
+
+// deletedMarker stands in for the author and body of a deleted comment.
+const deletedMarker = "[deleted]"
+
+type storyJSON struct {
+	Story    storyJSONItem      `json:"story"`
+	Comments []storyJSONComment `json:"comments"`
+}
+
+type storyJSONItem struct {
+	ID           int64      `json:"id"`
+	ShortCode    string     `json:"short_code"`
+	Path         string     `json:"path"`
+	Title        string     `json:"title"`
+	URL          string     `json:"url,omitempty"`
+	Domain       string     `json:"domain,omitempty"`
+	BodyHTML     string     `json:"body_html,omitempty"`
+	Username     string     `json:"username"`
+	Tags         []string   `json:"tags"`
+	Upvotes      int        `json:"upvotes"`
+	Downvotes    int        `json:"downvotes"`
+	Score        int        `json:"score"`
+	CommentCount int        `json:"comment_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	HasUpvoted   bool       `json:"has_upvoted"`
+	HasFlagged   bool       `json:"has_flagged"`
+	HasHidden    bool       `json:"has_hidden"`
+}
+
+type storyJSONComment struct {
+	ID          int64              `json:"id"`
+	ParentID    int64              `json:"parent_id,omitempty"`
+	Username    string             `json:"username"`
+	BodyHTML    string             `json:"body_html"`
+	Depth       int                `json:"depth"`
+	Upvotes     int                `json:"upvotes"`
+	Downvotes   int                `json:"downvotes"`
+	Score       int                `json:"score"`
+	CreatedAt   time.Time          `json:"created_at"`
+	EditedAt    *time.Time         `json:"edited_at,omitempty"`
+	IsDeleted   bool               `json:"is_deleted"`
+	IsSubmitter bool               `json:"is_submitter"`
+	HasUpvoted  bool               `json:"has_upvoted"`
+	HasFlagged  bool               `json:"has_flagged"`
+	IsUnread    bool               `json:"is_unread"`
+	CanEdit     bool               `json:"can_edit"`
+	Children    []storyJSONComment `json:"children"`
+}
+
+// storyFile serves GET /x/{file}. Only {code}.json is handled here; a bare
+// /x/{code} is sent on to the story page as the mux would have done.
+func (a *App) storyFile(w http.ResponseWriter, r *http.Request) {
+	code, ok := strings.CutSuffix(r.PathValue("file"), ".json")
+	if !ok {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	r.SetPathValue("code", code)
+	a.showStoryJSON(w, r)
+}
+
+// showStoryJSON serves the story and its comment tree as nested JSON for
+// script-driven clients. It mirrors showStory, including the current
+// user's vote, flag and unread state.
+func (a *App) showStoryJSON(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		writeJSONError(w, http.StatusNotFound, "Story not found.")
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Story not found.")
+			return
+		}
+		a.jsonServerError(w, r, "get story by short code", err)
+		return
+	}
+
+	item, err := a.storyItem(r, row)
+	if err != nil {
+		a.jsonServerError(w, r, "load story", err)
+		return
+	}
+
+	comments, err := a.storyComments(r, row, parseCommentSort(r.URL.Query().Get("sort")))
+	if err != nil {
+		a.jsonServerError(w, r, "load comments", err)
+		return
+	}
+
+	var body string
+	if item.DeletedAt == nil && row.Body.Valid {
+		body = string(markdown.Render(row.Body.String))
+	}
+	writeJSON(w, http.StatusOK, storyJSON{
+		Story:    storyToJSON(item, body),
+		Comments: commentsToJSON(comments),
+	})
+}
+
+func storyToJSON(s StoryItem, bodyHTML string) storyJSONItem {
+	tags := make([]string, len(s.Tags))
+	for i, t := range s.Tags {
+		tags[i] = t.Tag
+	}
+	return storyJSONItem{
+		ID:           s.ID,
+		ShortCode:    s.ShortCode,
+		Path:         storyPath(s.ShortCode, s.Title),
+		Title:        s.Title,
+		URL:          s.URL,
+		Domain:       s.Domain,
+		BodyHTML:     bodyHTML,
+		Username:     s.Username,
+		Tags:         tags,
+		Upvotes:      s.Upvotes,
+		Downvotes:    s.Downvotes,
+		Score:        s.Score,
+		CommentCount: s.CommentCount,
+		CreatedAt:    s.CreatedAt,
+		DeletedAt:    s.DeletedAt,
+		HasUpvoted:   s.HasUpvoted,
+		HasFlagged:   s.HasFlagged,
+		HasHidden:    s.HasHidden,
+	}
+}
+
+// commentsToJSON converts a comment tree, keeping its nesting. Deleted
+// comments keep their place in the tree so replies stay attached, but
+// their author and body are replaced with a marker.
+func commentsToJSON(nodes []*CommentNode) []storyJSONComment {
+	out := make([]storyJSONComment, 0, len(nodes))
+	for _, n := range nodes {
+		c := storyJSONComment{
+			ID:          n.ID,
+			ParentID:    n.ParentID,
+			Username:    n.Username,
+			BodyHTML:    string(n.Body),
+			Depth:       n.Depth,
+			Upvotes:     n.Upvotes,
+			Downvotes:   n.Downvotes,
+			Score:       n.Score,
+			CreatedAt:   n.CreatedAt,
+			EditedAt:    n.EditedAt,
+			IsDeleted:   n.IsDeleted,
+			IsSubmitter: n.IsSubmitter,
+			HasUpvoted:  n.HasUpvoted,
+			HasFlagged:  n.HasFlagged,
+			IsUnread:    n.IsUnread,
+			CanEdit:     n.CanEdit,
+			Children:    commentsToJSON(n.Children),
+		}
+		if n.IsDeleted {
+			c.Username = deletedMarker
+			c.BodyHTML = deletedMarker
+			c.IsSubmitter = false
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestCommentsToJSONNesting(t *testing.T) {
+	rows := []store.ListCommentsByStoryRow{
+		commentRow(1, 0, 1, 0, 4*time.Hour),
+		commentRow(2, 1, 1, 0, 3*time.Hour),
+		commentRow(3, 2, 1, 0, 2*time.Hour),
+		commentRow(4, 0, 1, 0, time.Hour),
+	}
+	rows[2].Depth = 2
+
+	got := commentsToJSON(buildCommentTree(rows, buildTreeOpts{}))
+
+	require.Len(t, got, 2)
+	assert.Equal(t, int64(1), got[0].ID)
+	require.Len(t, got[0].Children, 1)
+	assert.Equal(t, int64(2), got[0].Children[0].ID)
+	assert.Equal(t, int64(1), got[0].Children[0].ParentID)
+	require.Len(t, got[0].Children[0].Children, 1)
+	assert.Equal(t, int64(3), got[0].Children[0].Children[0].ID)
+	assert.Equal(t, 2, got[0].Children[0].Children[0].Depth)
+	assert.NotNil(t, got[1].Children, "leaves encode as an empty list")
+	assert.Empty(t, got[1].Children)
+}
+
+func TestCommentsToJSONDeleted(t *testing.T) {
+	deleted := commentRow(1, 0, 3, 0, 2*time.Hour)
+	deleted.Body = "original secret text"
+	deleted.Username = "alice"
+	deleted.DeletedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	reply := commentRow(2, 1, 1, 0, time.Hour)
+
+	got := commentsToJSON(buildCommentTree([]store.ListCommentsByStoryRow{deleted, reply}, buildTreeOpts{}))
+
+	require.Len(t, got, 1)
+	assert.True(t, got[0].IsDeleted)
+	assert.Equal(t, deletedMarker, got[0].Username)
+	assert.Equal(t, deletedMarker, got[0].BodyHTML)
+	require.Len(t, got[0].Children, 1, "replies stay attached")
+	assert.False(t, got[0].Children[0].IsDeleted)
+
+	b, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "original secret text")
+	assert.NotContains(t, string(b), "alice")
+}
+
+func TestStoryFileRedirectsBareCode(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/x/abc123", nil)
+	req.SetPathValue("file", "abc123")
+	w := httptest.NewRecorder()
+	a.storyFile(w, req)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/x/abc123/", w.Header().Get("Location"))
+}
+
+func TestStoryJSONInvalidCode(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/x/abc.json", nil)
+	req.SetPathValue("file", "abc.json")
+	w := httptest.NewRecorder()
+	a.storyFile(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestStoryScoreConsistentAcrossHandlers(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Scored",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+		Upvotes:   5,
+		Downvotes: 2,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		ID:        story.ID,
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/x/abc123.json", nil)
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: "abc123", Valid: true}})
+	require.NoError(t, err)
+	item, err := a.storyItem(req, row)
+	require.NoError(t, err)
+	assert.Equal(t, 3, item.Score)
+
+	listed, _, err := a.loadStoryList(req, Base{}, 1, store.ListStoriesParams{HideDeleted: true, StoryLimit: 10}, storyListOpts{})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, item.Upvotes, listed[0].Upvotes)
+	assert.Equal(t, item.Score, listed[0].Score)
+
+	w := httptest.NewRecorder()
+	req.SetPathValue("file", "abc123.json")
+	a.storyFile(w, req)
+	var got storyJSON
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 3, got.Story.Score)
+}
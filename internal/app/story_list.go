@@ -1,19 +1,55 @@
 package app
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
+
 	"crow.watch/internal/auth"
 	"crow.watch/internal/rank"
 	"crow.watch/internal/store"
 )
 
 type storyListOpts struct {
-	rankByHotness    bool
-	filterNegScore   bool
+	rankByHotness bool
+	// rankByScore orders stories by net score with no time decay, for
+	// listings already bounded to a time window.
+	rankByScore      bool
+	filterLowScore   bool
 	filterHidden     bool
 	filterDuplicates bool
+	// minScore is the lowest score kept when filterLowScore is set.
+	minScore int
+	// showPinned lifts stories pinned by a moderator out of the listing
+	// and shows them above it on the first page.
+	showPinned bool
+	// flagReasons are offered in each item's flag menu.
+	flagReasons []string
+	// probation marks submitters on probation, whose stories take
+	// probationPenalty in hotness ranking.
+	probation map[int64]bool
+	// viewerOnProbation hides the flag controls from a viewer who
+	// can't flag yet.
+	viewerOnProbation bool
+	// explain sets each item's Why summary.
+	explain bool
+	// filtered, when set, counts the stories each filter left out.
+	filtered *filterCounts
+	// paged fetches just the requested page of a chronological listing,
+	// with the hidden and duplicate filters applied in SQL. Listings that
+	// rank or filter by score in Go have to fetch every candidate.
+	paged bool
+}
+
+// filterCounts is how many stories each listing filter left out.
+type filterCounts struct {
+	Hidden     int
+	LowScore   int
+	Duplicates int
 }
 
 type storyDisplayInfo struct {
@@ -21,10 +57,13 @@ type storyDisplayInfo struct {
 	URL                  string
 	Title                string
 	Domain               string
+	DomainName           string
 	Username             string
+	AuthorState          string
 	Tags                 []StoryTag
 	Upvotes              int
 	Downvotes            int
+	Score                int
 	CommentCount         int
 	HasUpvoted           bool
 	HasFlagged           bool
@@ -34,79 +73,116 @@ type storyDisplayInfo struct {
 	DeletedAt            *time.Time
 	DuplicateOfShortCode string
 	DuplicateOfTitle     string
+	IsPinned             bool
+	IsPick               bool
+	OnProbation          bool
+}
+
+// listedTag is one element of the tags JSON aggregated by ListStories.
+type listedTag struct {
+	Tag        string  `json:"tag"`
+	IsMedia    bool    `json:"is_media"`
+	HotnessMod float64 `json:"hotness_mod"`
 }
 
 // loadStoryList fetches stories, applies ranking/filtering/pagination,
 // and returns the final StoryItem slice and whether more pages exist.
+// Tags and the viewer's vote/flag/hidden state come back with the
+// stories in a single round trip.
 func (a *App) loadStoryList(r *http.Request, base Base, page int, params store.ListStoriesParams, opts storyListOpts) ([]StoryItem, bool, error) {
 	ctx := r.Context()
 
-	stories, err := a.Queries.ListStories(ctx, params)
+	if current, ok := auth.UserFromContext(ctx); ok {
+		params.ViewerID = pgtype.Int8{Int64: current.User.ID, Valid: true}
+	}
+	if opts.paged {
+		params.Chronological = true
+		params.HideViewerHidden = opts.filterHidden
+		params.HideDuplicates = opts.filterDuplicates
+		params.StoryLimit = storiesPerPage + 1
+		params.StoryOffset = offsetFor(page, storiesPerPage)
+	}
+
+	stories, err := a.readsFor(r).ListStories(ctx, params)
 	if err != nil {
 		return nil, false, err
 	}
 
-	// Collect story IDs for batch queries
-	storyIDs := make([]int64, len(stories))
-	for i, s := range stories {
-		storyIDs[i] = s.ID
+	opts.flagReasons = a.storyFlagReasons().Names()
+	opts.viewerOnProbation, err = a.viewerOnProbation(ctx)
+	if err != nil {
+		return nil, false, err
 	}
-
-	// Batch-fetch user votes, flags, and hidden stories if logged in
-	votedMap := make(map[int64]bool)
-	flaggedMap := make(map[int64]bool)
-	hiddenMap := make(map[int64]bool)
-	if current, ok := auth.UserFromContext(ctx); ok && len(storyIDs) > 0 {
-		votedIDs, err := a.Queries.GetUserVotes(ctx, store.GetUserVotesParams{
-			UserID:   current.User.ID,
-			StoryIds: storyIDs,
-		})
-		if err != nil {
-			return nil, false, err
-		}
-		for _, id := range votedIDs {
-			votedMap[id] = true
-		}
-		flaggedIDs, err := a.Queries.GetUserStoryFlags(ctx, store.GetUserStoryFlagsParams{
-			UserID:   current.User.ID,
-			StoryIds: storyIDs,
-		})
-		if err != nil {
-			return nil, false, err
-		}
-		for _, id := range flaggedIDs {
-			flaggedMap[id] = true
-		}
-		hiddenIDs, err := a.Queries.GetUserHiddenStories(ctx, store.GetUserHiddenStoriesParams{
-			UserID:   current.User.ID,
-			StoryIds: storyIDs,
-		})
+	if opts.rankByHotness {
+		opts.probation, err = a.probationUsers(ctx, stories, time.Now())
 		if err != nil {
 			return nil, false, err
 		}
-		for _, id := range hiddenIDs {
-			hiddenMap[id] = true
-		}
 	}
+	items, hasMore, err := buildStoryList(stories, base, page, opts)
+	if err != nil || !base.IsModerator || len(items) == 0 {
+		return items, hasMore, err
+	}
+
+	// Moderators see each story's flag breakdown right in the listing,
+	// fetched for the visible page only.
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	flagRows, err := a.readsFor(r).GetStoryFlagCountsByStories(ctx, ids)
+	if err != nil {
+		return nil, false, fmt.Errorf("get story flag counts: %w", err)
+	}
+	attachFlagCounts(items, flagRows)
+	return items, hasMore, nil
+}
+
+// attachFlagCounts sets each item's FlagCounts from the batched rows,
+// keeping the rows' most-flagged-first order.
+func attachFlagCounts(items []StoryItem, rows []store.GetStoryFlagCountsByStoriesRow) {
+	byStory := make(map[int64][]FlagCount)
+	for _, f := range rows {
+		byStory[f.StoryID] = append(byStory[f.StoryID], FlagCount{Reason: f.Reason, Count: int(f.Count)})
+	}
+	for i := range items {
+		items[i].FlagCounts = byStory[items[i].ID]
+	}
+}
 
-	// Fetch tags for each story, build display info and optional rank inputs
+// scoreFilter turns on score filtering in opts, unless a logged-in viewer
+// asked to reveal low-scoring stories with ?show=low. It returns the
+// updated opts and whether they are revealed.
+func (a *App) scoreFilter(r *http.Request, base Base, opts storyListOpts) (storyListOpts, bool) {
+	opts.minScore = a.MinStoryScore
+	reveal := base.IsLoggedIn && r.URL.Query().Get("show") == "low"
+	opts.filterLowScore = !reveal
+	return opts, reveal
+}
+
+// buildStoryList turns ListStories rows into a ranked, filtered and
+// paginated page of StoryItems.
+func buildStoryList(stories []store.ListStoriesRow, base Base, page int, opts storyListOpts) ([]StoryItem, bool, error) {
+	// Build display info and optional rank inputs
 	var rankInputs []rank.StoryInput
-	if opts.rankByHotness {
+	ranked := opts.rankByHotness || opts.rankByScore
+	if ranked {
 		rankInputs = make([]rank.StoryInput, 0, len(stories))
 	}
 	meta := make(map[int64]storyDisplayInfo, len(stories))
-	// Preserve chronological order for non-ranked listings
-	var orderedIDs []int64
+	orderedIDs := make([]int64, 0, len(stories))
+	now := time.Now()
 
 	for _, s := range stories {
-		tagRows, err := a.Queries.GetStoryTags(ctx, s.ID)
-		if err != nil {
-			a.Log.Error("get story tags", "error", err, "story_id", s.ID)
-			continue
+		var tags []listedTag
+		if len(s.Tags) > 0 {
+			if err := json.Unmarshal(s.Tags, &tags); err != nil {
+				return nil, false, fmt.Errorf("decode tags of story %d: %w", s.ID, err)
+			}
 		}
 		var displayTags []StoryTag
 		var rankTags []rank.TagInput
-		for _, t := range tagRows {
+		for _, t := range tags {
 			displayTags = append(displayTags, StoryTag{Tag: t.Tag, IsMedia: t.IsMedia})
 			if opts.rankByHotness {
 				rankTags = append(rankTags, rank.TagInput{HotnessMod: t.HotnessMod})
@@ -115,15 +191,22 @@ func (a *App) loadStoryList(r *http.Request, base Base, page int, params store.L
 
 		upvotes := int(s.Upvotes)
 		downvotes := int(s.Downvotes)
+		score := upvotes - downvotes + int(s.AdminAdjustment)
 
-		if opts.rankByHotness {
-			rankInputs = append(rankInputs, rank.StoryInput{
+		if ranked {
+			input := rank.StoryInput{
 				ID:            s.ID,
 				CreatedAt:     s.CreatedAt.Time,
 				Tags:          rankTags,
-				StoryScore:    upvotes - downvotes,
+				StoryScore:    score,
 				CommentsCount: int(s.CommentCount),
-			})
+				Upvotes:       upvotes,
+				Downvotes:     downvotes,
+			}
+			if opts.probation[s.UserID] {
+				input.Penalty = probationPenalty
+			}
+			rankInputs = append(rankInputs, input)
 		}
 
 		domain := s.Domain.String
@@ -141,70 +224,107 @@ func (a *App) loadStoryList(r *http.Request, base Base, page int, params store.L
 			URL:                  s.Url.String,
 			Title:                s.Title,
 			Domain:               domain,
+			DomainName:           s.Domain.String,
 			Username:             s.Username,
+			AuthorState:          authorState(s.UserBannedAt, s.UserDeletedAt),
 			Tags:                 displayTags,
 			Upvotes:              upvotes,
 			Downvotes:            downvotes,
+			Score:                score,
 			CommentCount:         int(s.CommentCount),
-			HasUpvoted:           votedMap[s.ID],
-			HasFlagged:           flaggedMap[s.ID],
-			HasHidden:            hiddenMap[s.ID],
-			IsText:               s.Body.Valid,
+			HasUpvoted:           s.HasUpvoted,
+			HasFlagged:           s.HasFlagged,
+			HasHidden:            s.HasHidden,
+			IsText:               !s.Url.Valid,
 			CreatedAt:            s.CreatedAt.Time,
 			DeletedAt:            deletedAt,
 			DuplicateOfShortCode: s.DuplicateOfShortCode.String,
 			DuplicateOfTitle:     s.DuplicateOfTitle.String,
+			IsPinned:             s.PinnedUntil.Valid && s.PinnedUntil.Time.After(now),
+			IsPick:               s.PickedAt.Valid,
+			OnProbation:          opts.probation[s.UserID],
 		}
 		orderedIDs = append(orderedIDs, s.ID)
 	}
 
-	// Determine final ordering
+	// Determine final ordering. ListStories returns pinned stories first,
+	// so non-ranked listings are put back in chronological order.
 	if opts.rankByHotness {
 		ranked := rank.SortStories(rankInputs, rank.DefaultHotnessWindowSeconds)
 		orderedIDs = orderedIDs[:0]
 		for _, s := range ranked {
 			orderedIDs = append(orderedIDs, s.ID)
 		}
+	} else if opts.rankByScore {
+		orderedIDs = orderedIDs[:0]
+		for _, s := range rank.SortByScore(rankInputs) {
+			orderedIDs = append(orderedIDs, s.ID)
+		}
+	} else {
+		sort.SliceStable(orderedIDs, func(i, j int) bool {
+			return meta[orderedIDs[i]].CreatedAt.After(meta[orderedIDs[j]].CreatedAt)
+		})
 	}
 
 	// Filter
-	var visible []int64
+	var visible, pinned []int64
+	var filtered filterCounts
 	for _, id := range orderedIDs {
 		m := meta[id]
-		if opts.filterNegScore && m.Upvotes-m.Downvotes < 0 {
+		if opts.filterHidden && m.HasHidden {
+			filtered.Hidden++
+			continue
+		}
+		if opts.showPinned && m.IsPinned && len(pinned) < maxPinnedStories {
+			pinned = append(pinned, id)
 			continue
 		}
-		if opts.filterHidden && hiddenMap[id] {
+		if opts.filterLowScore && m.Score < opts.minScore {
+			filtered.LowScore++
 			continue
 		}
 		if opts.filterDuplicates && m.DuplicateOfShortCode != "" {
+			filtered.Duplicates++
 			continue
 		}
 		visible = append(visible, id)
 	}
-
-	// Paginate
-	start := (page - 1) * storiesPerPage
-	if start > len(visible) {
-		start = len(visible)
+	if opts.filtered != nil {
+		*opts.filtered = filtered
 	}
-	end := start + storiesPerPage
-	if end > len(visible) {
-		end = len(visible)
+
+	// Pinned stories sit above the listing and take up slots on the first
+	// page, so every page holds storiesPerPage stories. There are never
+	// more pins than fit on it.
+	listed := append(pinned[:len(pinned):len(pinned)], visible...)
+	var pageIDs []int64
+	var hasMore bool
+	if opts.paged {
+		pageIDs, hasMore = trimLookahead(listed, storiesPerPage)
+	} else {
+		pageIDs, hasMore = paginate(listed, page, storiesPerPage)
 	}
-	hasMore := end < len(visible)
 
 	// Build StoryItems
-	items := make([]StoryItem, 0, end-start)
-	for _, id := range visible[start:end] {
+	items := make([]StoryItem, 0, len(pageIDs))
+	for i, id := range pageIDs {
 		m := meta[id]
 		title := m.Title
 		url := m.URL
 		domain := m.Domain
+		domainName := m.DomainName
+		isOwn := base.IsLoggedIn && m.Username == base.Username
+		isPinned := page == 1 && i < len(pinned)
+		var why string
+		// A deleted story shows only a placeholder, so there is nothing to explain.
+		if opts.explain && m.DeletedAt == nil {
+			why = storyWhy(m, isPinned, opts)
+		}
 		if m.DeletedAt != nil {
 			title = "[deleted by moderator]"
 			url = ""
 			domain = ""
+			domainName = ""
 		}
 		items = append(items, StoryItem{
 			ID:                   id,
@@ -212,24 +332,86 @@ func (a *App) loadStoryList(r *http.Request, base Base, page int, params store.L
 			URL:                  url,
 			Title:                title,
 			Domain:               domain,
+			DomainName:           domainName,
 			Username:             m.Username,
+			AuthorState:          m.AuthorState,
 			Tags:                 m.Tags,
 			Upvotes:              m.Upvotes,
 			Downvotes:            m.Downvotes,
+			Score:                m.Score,
 			CommentCount:         m.CommentCount,
 			HasUpvoted:           m.HasUpvoted,
 			HasFlagged:           m.HasFlagged,
 			HasHidden:            m.HasHidden,
-			FlagReasons:          storyFlagReasons,
+			FlagReasons:          opts.flagReasons,
 			IsText:               m.IsText,
 			IsLoggedIn:           base.IsLoggedIn,
+			CanFlag:              base.IsLoggedIn && !opts.viewerOnProbation,
 			IsModerator:          base.IsModerator,
+			IsOwn:                isOwn,
+			CanEdit:              base.IsModerator || (isOwn && time.Since(m.CreatedAt) < storyEditWindowMinutes*time.Minute),
 			CreatedAt:            m.CreatedAt,
 			DeletedAt:            m.DeletedAt,
 			DuplicateOfShortCode: m.DuplicateOfShortCode,
 			DuplicateOfTitle:     m.DuplicateOfTitle,
+			IsPinned:             isPinned,
+			IsPick:               m.IsPick,
+			Why:                  why,
 		})
 	}
 
 	return items, hasMore, nil
 }
+
+// storyWhy summarizes why a story sits where it does in a listing, e.g.
+// "ranked by hotness from score 42 and age, posted 2 hours ago".
+func storyWhy(m storyDisplayInfo, pinned bool, opts storyListOpts) string {
+	if pinned {
+		return "pinned by a moderator, posted " + timeAgo(m.CreatedAt)
+	}
+	var why string
+	switch {
+	case opts.rankByHotness:
+		why = fmt.Sprintf("ranked by hotness from score %d and age, posted %s", m.Score, timeAgo(m.CreatedAt))
+		if m.OnProbation {
+			why += ", ranked lower while the submitter is new"
+		}
+	case opts.rankByScore:
+		why = fmt.Sprintf("ranked by score %d, posted %s", m.Score, timeAgo(m.CreatedAt))
+	default:
+		why = fmt.Sprintf("listed by date, posted %s, score %d", timeAgo(m.CreatedAt), m.Score)
+	}
+	return why
+}
+
+// explainFilters describes, for ?why=1, which of the viewer's filters
+// shaped a listing.
+func explainFilters(opts storyListOpts, counts filterCounts, hiddenTags int) []string {
+	var lines []string
+	switch {
+	case opts.rankByHotness:
+		lines = append(lines, "Stories are ranked by score and age, with newer stories favored.")
+	case opts.rankByScore:
+		lines = append(lines, "Stories are ranked by score.")
+	default:
+		lines = append(lines, "Stories are listed newest first.")
+	}
+	if hiddenTags > 0 {
+		lines = append(lines, fmt.Sprintf("Left out stories tagged with any of your %d hidden %s.",
+			hiddenTags, pluralize(hiddenTags, "tag", "tags")))
+	}
+	if counts.Hidden > 0 {
+		lines = append(lines, fmt.Sprintf("Left out %s you hid.", storyCount(counts.Hidden)))
+	}
+	if counts.LowScore > 0 {
+		lines = append(lines, fmt.Sprintf("Left out %s scoring below %d.", storyCount(counts.LowScore), opts.minScore))
+	}
+	if counts.Duplicates > 0 {
+		lines = append(lines, fmt.Sprintf("Left out %s marked as duplicates.", storyCount(counts.Duplicates)))
+	}
+	return lines
+}
+
+func storyCount(n int) string {
+	return fmt.Sprintf("%d %s", n, pluralize(n, "story", "stories"))
+}
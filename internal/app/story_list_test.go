@@ -0,0 +1,470 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+// storyListFixture mirrors what the old multi-query listing fetched:
+// the story rows, per-story tags, and the viewer's voted/flagged/hidden
+// story IDs.
+type storyListFixture struct {
+	stories []store.ListStoriesRow
+	tags    map[int64][]store.GetStoryTagsRow
+	voted   map[int64]bool
+	flagged map[int64]bool
+	hidden  map[int64]bool
+}
+
+func newStoryListFixture() storyListFixture {
+	now := time.Now()
+	ts := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: now.Add(-d), Valid: true}
+	}
+	return storyListFixture{
+		stories: []store.ListStoriesRow{
+			{ID: 1, ShortCode: "aaaaaa", Title: "First", Url: pgtype.Text{String: "https://a.example/x", Valid: true}, Domain: pgtype.Text{String: "a.example", Valid: true}, Username: "alice", Upvotes: 3, CommentCount: 2, CreatedAt: ts(time.Hour)},
+			{ID: 2, ShortCode: "bbbbbb", Title: "Second", Body: pgtype.Text{String: "text", Valid: true}, Username: "bob", Upvotes: 10, CommentCount: 7, CreatedAt: ts(2 * time.Hour)},
+			{ID: 3, ShortCode: "cccccc", Title: "Third", Url: pgtype.Text{String: "https://github.com/c", Valid: true}, Domain: pgtype.Text{String: "github.com", Valid: true}, Origin: pgtype.Text{String: "github.com/c", Valid: true}, Username: "carol", Upvotes: 1, CreatedAt: ts(3 * time.Hour)},
+			{ID: 4, ShortCode: "dddddd", Title: "Buried", Username: "dave", Body: pgtype.Text{String: "x", Valid: true}, Upvotes: 1, Downvotes: 4, CreatedAt: ts(4 * time.Hour)},
+		},
+		tags: map[int64][]store.GetStoryTagsRow{
+			1: {{ID: 10, Tag: "video", IsMedia: true, HotnessMod: 0}, {ID: 11, Tag: "go", HotnessMod: 0.5}},
+			2: {{ID: 12, Tag: "rust"}},
+			3: {{ID: 11, Tag: "go", HotnessMod: 0.5}},
+		},
+		voted:   map[int64]bool{2: true},
+		flagged: map[int64]bool{3: true},
+		hidden:  map[int64]bool{3: true},
+	}
+}
+
+// compositeRows encodes the fixture the way the composite ListStories
+// query returns it. Without a viewer, all overlay booleans are false.
+func (f storyListFixture) compositeRows(t *testing.T, loggedIn bool) []store.ListStoriesRow {
+	t.Helper()
+	rows := make([]store.ListStoriesRow, len(f.stories))
+	for i, s := range f.stories {
+		tags := make([]listedTag, 0, len(f.tags[s.ID]))
+		for _, tg := range f.tags[s.ID] {
+			tags = append(tags, listedTag{Tag: tg.Tag, IsMedia: tg.IsMedia, HotnessMod: tg.HotnessMod})
+		}
+		b, err := json.Marshal(tags)
+		require.NoError(t, err)
+		s.Tags = b
+		if loggedIn {
+			s.HasUpvoted = f.voted[s.ID]
+			s.HasFlagged = f.flagged[s.ID]
+			s.HasHidden = f.hidden[s.ID]
+		}
+		rows[i] = s
+	}
+	return rows
+}
+
+func TestBuildStoryListMatchesFixture(t *testing.T) {
+	f := newStoryListFixture()
+
+	for _, loggedIn := range []bool{false, true} {
+		base := Base{IsLoggedIn: loggedIn}
+		items, hasMore, err := buildStoryList(f.compositeRows(t, loggedIn), base, 1, storyListOpts{})
+		require.NoError(t, err)
+		assert.False(t, hasMore)
+		require.Len(t, items, len(f.stories))
+
+		for i, item := range items {
+			s := f.stories[i]
+			assert.Equal(t, s.ID, item.ID)
+			assert.Equal(t, int(s.Upvotes), item.Upvotes)
+			assert.Equal(t, int(s.CommentCount), item.CommentCount)
+			assert.Equal(t, s.Body.Valid, item.IsText)
+			assert.Equal(t, loggedIn, item.IsLoggedIn)
+
+			var wantTags []StoryTag
+			for _, tg := range f.tags[s.ID] {
+				wantTags = append(wantTags, StoryTag{Tag: tg.Tag, IsMedia: tg.IsMedia})
+			}
+			assert.Equal(t, wantTags, item.Tags, "story %d", s.ID)
+
+			assert.Equal(t, loggedIn && f.voted[s.ID], item.HasUpvoted, "story %d voted", s.ID)
+			assert.Equal(t, loggedIn && f.flagged[s.ID], item.HasFlagged, "story %d flagged", s.ID)
+			assert.Equal(t, loggedIn && f.hidden[s.ID], item.HasHidden, "story %d hidden", s.ID)
+		}
+	}
+}
+
+func TestBuildStoryListOriginOverridesDomain(t *testing.T) {
+	f := newStoryListFixture()
+	items, _, err := buildStoryList(f.compositeRows(t, false), Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "a.example", items[0].Domain)
+	assert.Equal(t, "github.com/c", items[2].Domain)
+}
+
+func TestBuildStoryListFilters(t *testing.T) {
+	f := newStoryListFixture()
+	opts := storyListOpts{rankByHotness: true, filterLowScore: true, filterHidden: true}
+
+	anon, _, err := buildStoryList(f.compositeRows(t, false), Base{}, 1, opts)
+	require.NoError(t, err)
+	var anonIDs []int64
+	for _, it := range anon {
+		anonIDs = append(anonIDs, it.ID)
+	}
+	assert.ElementsMatch(t, []int64{1, 2, 3}, anonIDs, "negative score story is filtered")
+
+	user, _, err := buildStoryList(f.compositeRows(t, true), Base{IsLoggedIn: true}, 1, opts)
+	require.NoError(t, err)
+	var userIDs []int64
+	for _, it := range user {
+		userIDs = append(userIDs, it.ID)
+	}
+	assert.ElementsMatch(t, []int64{1, 2}, userIDs, "hidden story is filtered for the viewer")
+}
+
+func TestBuildStoryListScore(t *testing.T) {
+	f := newStoryListFixture()
+	items, _, err := buildStoryList(f.compositeRows(t, false), Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	require.NotEmpty(t, items)
+	for _, it := range items {
+		assert.Equal(t, it.Upvotes-it.Downvotes, it.Score, it.ShortCode)
+	}
+}
+
+func TestBuildStoryListScoreThreshold(t *testing.T) {
+	f := newStoryListFixture()
+	ids := func(opts storyListOpts) []int64 {
+		t.Helper()
+		items, _, err := buildStoryList(f.compositeRows(t, false), Base{}, 1, opts)
+		require.NoError(t, err)
+		var ids []int64
+		for _, it := range items {
+			ids = append(ids, it.ID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4}, ids(storyListOpts{filterLowScore: true, minScore: -3}), "score -3 is not below -3")
+	assert.ElementsMatch(t, []int64{1, 2}, ids(storyListOpts{filterLowScore: true, minScore: 2}))
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4}, ids(storyListOpts{minScore: 2}), "threshold only applies when filtering")
+}
+
+func TestScoreFilter(t *testing.T) {
+	a := &App{MinStoryScore: -2}
+	req := func(target string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, target, nil)
+	}
+
+	opts, reveal := a.scoreFilter(req("/?show=low"), Base{}, storyListOpts{rankByHotness: true})
+	assert.True(t, opts.filterLowScore, "anonymous viewers can't reveal")
+	assert.Equal(t, -2, opts.minScore)
+	assert.True(t, opts.rankByHotness)
+	assert.False(t, reveal)
+
+	user := Base{IsLoggedIn: true}
+	opts, reveal = a.scoreFilter(req("/t/go"), user, storyListOpts{})
+	assert.True(t, opts.filterLowScore)
+	assert.False(t, reveal)
+
+	opts, reveal = a.scoreFilter(req("/t/go?show=low"), user, storyListOpts{})
+	assert.False(t, opts.filterLowScore)
+	assert.True(t, reveal)
+}
+
+func TestRenderScoreToggle(t *testing.T) {
+	a := testApp(t)
+
+	w := httptest.NewRecorder()
+	a.render(w, "home", HomePageData{
+		Base:         Base{IsLoggedIn: true, Username: "alice"},
+		CurrentPage:  1,
+		HasMore:      true,
+		PagePath:     "/page",
+		ShowLowScore: true,
+		Links:        listingLinks("/page", 1, true, scoreQuery(true), true),
+	})
+	body := w.Body.String()
+	assert.Contains(t, body, `<link rel="next" href="/page/2?show=low" />`)
+	assert.Contains(t, body, `class="more-link" href="/page/2?show=low"`)
+	assert.Contains(t, body, `href="/?show=low&amp;why=1"`)
+	assert.Contains(t, body, "hide low-scoring stories")
+
+	w = httptest.NewRecorder()
+	a.render(w, "home", HomePageData{CurrentPage: 1, PagePath: "/page"})
+	assert.NotContains(t, w.Body.String(), "low-scoring stories")
+}
+
+func TestBuildStoryListIsOwn(t *testing.T) {
+	rows := []store.ListStoriesRow{
+		{ID: 1, Username: "alice", Tags: []byte("[]")},
+		{ID: 2, Username: "bob", Tags: []byte("[]")},
+	}
+
+	items, _, err := buildStoryList(rows, Base{IsLoggedIn: true, Username: "alice"}, 1, storyListOpts{})
+	require.NoError(t, err)
+	assert.True(t, items[0].IsOwn)
+	assert.False(t, items[1].IsOwn)
+
+	anon, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	assert.False(t, anon[0].IsOwn)
+	assert.False(t, anon[1].IsOwn)
+}
+
+func TestRenderOwnStory(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "home", HomePageData{Stories: []StoryItem{
+		{ID: 1, ShortCode: "aaaaaa", Title: "Mine", Username: "alice", IsText: true, IsOwn: true},
+		{ID: 2, ShortCode: "bbbbbb", Title: "Theirs", Username: "bob", IsText: true},
+	}})
+
+	body := w.Body.String()
+	assert.Equal(t, 1, strings.Count(body, `<span class="author__own">(you)</span>`))
+}
+
+func TestBuildStoryListPaginates(t *testing.T) {
+	rows := make([]store.ListStoriesRow, storiesPerPage+5)
+	for i := range rows {
+		rows[i] = store.ListStoriesRow{ID: int64(i + 1), Tags: []byte("[]")}
+	}
+
+	first, hasMore, err := buildStoryList(rows, Base{}, 1, storyListOpts{})
+	require.NoError(t, err)
+	assert.Len(t, first, storiesPerPage)
+	assert.True(t, hasMore)
+
+	second, hasMore, err := buildStoryList(rows, Base{}, 2, storyListOpts{})
+	require.NoError(t, err)
+	assert.Len(t, second, 5)
+	assert.False(t, hasMore)
+
+	beyond, hasMore, err := buildStoryList(rows, Base{}, 3, storyListOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, beyond)
+	assert.False(t, hasMore)
+}
+
+func TestBuildStoryListBadTags(t *testing.T) {
+	rows := []store.ListStoriesRow{{ID: 1, Tags: []byte("{")}}
+	_, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{})
+	assert.Error(t, err)
+}
+
+func TestBuildStoryListPinnedFirstPageOnly(t *testing.T) {
+	now := time.Now()
+	rows := make([]store.ListStoriesRow, storiesPerPage+5)
+	for i := range rows {
+		rows[i] = store.ListStoriesRow{
+			ID:        int64(i + 1),
+			Upvotes:   int32(len(rows) - i),
+			CreatedAt: pgtype.Timestamptz{Time: now.Add(-time.Duration(i) * time.Minute), Valid: true},
+			Tags:      []byte("[]"),
+		}
+	}
+	last := len(rows) - 1
+	rows[last].PinnedUntil = pgtype.Timestamptz{Time: now.Add(time.Hour), Valid: true}
+	rows[last-1].PinnedUntil = pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}
+
+	opts := storyListOpts{rankByHotness: true, showPinned: true}
+	first, _, err := buildStoryList(rows, Base{}, 1, opts)
+	require.NoError(t, err)
+	require.Len(t, first, storiesPerPage, "pins count against the page size")
+	assert.Equal(t, rows[last].ID, first[0].ID)
+	assert.True(t, first[0].IsPinned)
+	assert.False(t, first[1].IsPinned)
+
+	second, hasMore, err := buildStoryList(rows, Base{}, 2, opts)
+	require.NoError(t, err)
+	assert.Len(t, second, len(rows)-storiesPerPage, "the story pushed off page 1 starts page 2")
+	assert.False(t, hasMore)
+	for _, it := range second {
+		assert.NotEqual(t, rows[last].ID, it.ID, "pinned story is not repeated")
+		assert.False(t, it.IsPinned, "expired pin is listed normally")
+	}
+
+	plain, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{rankByHotness: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, rows[last].ID, plain[0].ID, "pins only apply where enabled")
+}
+
+func TestBuildStoryListPinnedCap(t *testing.T) {
+	until := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	rows := make([]store.ListStoriesRow, maxPinnedStories+2)
+	for i := range rows {
+		rows[i] = store.ListStoriesRow{ID: int64(i + 1), PinnedUntil: until, Tags: []byte("[]")}
+	}
+
+	items, _, err := buildStoryList(rows, Base{}, 1, storyListOpts{showPinned: true})
+	require.NoError(t, err)
+	require.Len(t, items, len(rows))
+	pinned := 0
+	for _, it := range items {
+		if it.IsPinned {
+			pinned++
+		}
+	}
+	assert.Equal(t, maxPinnedStories, pinned)
+}
+
+func TestParsePinDays(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", defaultPinDays, true},
+		{"1", 1, true},
+		{" 14 ", 14, true},
+		{"30", 30, true},
+		{"0", 0, false},
+		{"31", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePinDays(tt.in)
+		assert.Equal(t, tt.ok, ok, "%q", tt.in)
+		assert.Equal(t, tt.want, got, "%q", tt.in)
+	}
+}
+
+func TestAttachFlagCounts(t *testing.T) {
+	items := []StoryItem{{ID: 1}, {ID: 2}}
+	attachFlagCounts(items, []store.GetStoryFlagCountsByStoriesRow{
+		{StoryID: 2, Reason: "spam", Count: 3},
+		{StoryID: 2, Reason: "off-topic", Count: 1},
+	})
+
+	assert.Empty(t, items[0].FlagCounts)
+	assert.Equal(t, []FlagCount{{Reason: "spam", Count: 3}, {Reason: "off-topic", Count: 1}}, items[1].FlagCounts)
+}
+
+func TestListingFlagCountsForModeratorsOnly(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Flagged",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: u.ID, StoryID: story.ID, Reason: "spam"}))
+
+	list := func(base Base) []StoryItem {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		items, _, err := a.loadStoryList(req, base, 1, store.ListStoriesParams{HideDeleted: true, StoryLimit: 10}, storyListOpts{})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		return items
+	}
+
+	assert.Equal(t, []FlagCount{{Reason: "spam", Count: 1}}, list(Base{IsLoggedIn: true, IsModerator: true})[0].FlagCounts)
+	assert.Empty(t, list(Base{IsLoggedIn: true})[0].FlagCounts)
+}
+
+func TestListStoriesQuery(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	author, err := q.CreateUser(ctx, store.CreateUserParams{Username: "alice", Email: "alice@example.com", PasswordDigest: "x"})
+	require.NoError(t, err)
+	viewer, err := q.CreateUser(ctx, store.CreateUserParams{Username: "bob", Email: "bob@example.com", PasswordDigest: "x"})
+	require.NoError(t, err)
+
+	var goID, videoID, rustID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&goID))
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag, is_media) VALUES ('video', true) RETURNING id").Scan(&videoID))
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('rust') RETURNING id").Scan(&rustID))
+
+	newStory := func(code, title string, tagIDs ...int64) int64 {
+		s, err := q.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    author.ID,
+			Title:     title,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		for _, id := range tagIDs {
+			require.NoError(t, q.CreateTagging(ctx, store.CreateTaggingParams{StoryID: s.ID, TagID: id}))
+		}
+		return s.ID
+	}
+	tagged := newStory("aaa111", "Tagged", goID, videoID)
+	flagged := newStory("bbb222", "Flagged")
+	newStory("ccc333", "Rusty", rustID)
+
+	_, err = q.CreateVote(ctx, store.CreateVoteParams{StoryID: tagged, UserID: viewer.ID})
+	require.NoError(t, err)
+	require.NoError(t, q.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: viewer.ID, StoryID: flagged, Reason: "spam"}))
+	require.NoError(t, q.HideStory(ctx, store.HideStoryParams{UserID: viewer.ID, StoryID: flagged, Reason: "unspecified"}))
+
+	rows, err := q.ListStories(ctx, store.ListStoriesParams{
+		ViewerID:     pgtype.Int8{Int64: viewer.ID, Valid: true},
+		HideDeleted:  true,
+		HiddenTagIds: []int64{rustID},
+		StoryLimit:   10,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "stories with a hidden tag are left out")
+
+	byID := map[int64]store.ListStoriesRow{}
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+
+	var tags []struct {
+		Tag     string `json:"tag"`
+		IsMedia bool   `json:"is_media"`
+	}
+	require.NoError(t, json.Unmarshal(byID[tagged].Tags, &tags))
+	require.Len(t, tags, 2)
+	assert.Equal(t, "video", tags[0].Tag, "media tags sort first")
+	assert.True(t, tags[0].IsMedia)
+	assert.Equal(t, "go", tags[1].Tag)
+	assert.Equal(t, "alice", byID[tagged].Username)
+
+	assert.True(t, byID[tagged].HasUpvoted)
+	assert.False(t, byID[tagged].HasFlagged)
+	assert.False(t, byID[tagged].HasHidden)
+	assert.JSONEq(t, "[]", string(byID[flagged].Tags))
+	assert.False(t, byID[flagged].HasUpvoted)
+	assert.True(t, byID[flagged].HasFlagged)
+	assert.True(t, byID[flagged].HasHidden)
+
+	items, _, err := buildStoryList(rows, Base{IsLoggedIn: true}, 1, storyListOpts{filterHidden: true})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Tagged", items[0].Title)
+	assert.Equal(t, []StoryTag{{Tag: "video", IsMedia: true}, {Tag: "go"}}, items[0].Tags)
+
+	anon, err := q.ListStories(ctx, store.ListStoriesParams{HideDeleted: true, StoryLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, anon, 3)
+	for _, row := range anon {
+		assert.False(t, row.HasUpvoted || row.HasFlagged || row.HasHidden, "no viewer, no overlays")
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func (a *App) subscribeStory(w http.ResponseWriter, r *http.Request) {
+	a.setStorySubscription(w, r, true)
+}
+
+func (a *App) unsubscribeStory(w http.ResponseWriter, r *http.Request) {
+	a.setStorySubscription(w, r, false)
+}
+
+// setStorySubscription turns new-comment notifications for a story on or
+// off. Unsubscribing sticks: commenting again doesn't resubscribe.
+func (a *App) setStorySubscription(w http.ResponseWriter, r *http.Request, subscribed bool) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	code := r.PathValue("code")
+	if !a.validShortCode(code) {
+		a.notFound(w, r)
+		return
+	}
+
+	row, err := a.Queries.GetStory(r.Context(), store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get story by short code", err)
+		return
+	}
+
+	if err := a.Queries.SetStorySubscription(r.Context(), store.SetStorySubscriptionParams{
+		UserID:     current.User.ID,
+		StoryID:    row.ID,
+		Subscribed: subscribed,
+	}); err != nil {
+		a.serverError(w, r, "set story subscription", err)
+		return
+	}
+
+	http.Redirect(w, r, storyPath(row.ShortCode, row.Title), http.StatusSeeOther)
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestStorySubscriptionRequiresLogin(t *testing.T) {
+	a := testApp(t)
+
+	for _, handler := range []http.HandlerFunc{a.subscribeStory, a.unsubscribeStory} {
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/subscribe", nil)
+		req.SetPathValue("code", "abc123")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login", w.Header().Get("Location"))
+	}
+}
+
+func TestStorySubscriptionNotifications(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	users := map[string]store.User{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		users[name] = store.User{ID: u.ID, Username: u.Username}
+	}
+
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    users["alice"].ID,
+		Title:     "Story",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.AutoSubscribeStory(ctx, store.AutoSubscribeStoryParams{
+		UserID: users["alice"].ID, StoryID: story.ID,
+	}))
+
+	as := func(req *http.Request, name string) *http.Request {
+		req.SetPathValue("code", "abc123")
+		return req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: users[name]}))
+	}
+	comment := func(name, body string) {
+		t.Helper()
+		form := url.Values{"body": {body}}
+		req := httptest.NewRequest(http.MethodPost, "/x/abc123/comments", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.createComment(w, as(req, name))
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	}
+	subscribe := func(name string, handler http.HandlerFunc) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler(w, as(httptest.NewRequest(http.MethodPost, "/x/abc123/subscribe", nil), name))
+		require.Equal(t, http.StatusSeeOther, w.Code)
+	}
+	notified := func(name string) []store.ListRepliesRow {
+		t.Helper()
+		rows, err := a.Queries.ListReplies(ctx, users[name].ID)
+		require.NoError(t, err)
+		return rows
+	}
+
+	subscribe("carol", a.subscribeStory)
+	comment("bob", "First!")
+
+	require.Len(t, notified("alice"), 1, "the submitter is subscribed")
+	assert.False(t, notified("alice")[0].IsReply)
+	assert.Equal(t, "bob", notified("alice")[0].CommentAuthor)
+	require.Len(t, notified("carol"), 1)
+	assert.Empty(t, notified("bob"), "the commenter isn't notified of their own comment")
+
+	count, err := a.Queries.CountUnreadReplies(ctx, users["carol"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Unsubscribing sticks, even after commenting on the story.
+	subscribe("carol", a.unsubscribeStory)
+	comment("carol", "Me too")
+	comment("bob", "Second")
+
+	assert.Len(t, notified("carol"), 1, "only the comment from before unsubscribing")
+	assert.Len(t, notified("alice"), 3)
+	require.Len(t, notified("bob"), 1, "commenting subscribed bob")
+	assert.Equal(t, "carol", notified("bob")[0].CommentAuthor)
+
+	// A reply on a subscribed story matches both branches but shows once.
+	rows, err := a.Queries.ListCommentsByStory(ctx, story.ID)
+	require.NoError(t, err)
+	form := url.Values{"body": {"Welcome"}, "parent_id": {strconv.FormatInt(rows[0].ID, 10)}}
+	req := httptest.NewRequest(http.MethodPost, "/x/abc123/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.createComment(w, as(req, "alice"))
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	replies := notified("bob")
+	require.Len(t, replies, 2)
+	assert.Equal(t, "alice", replies[0].CommentAuthor)
+	assert.True(t, replies[0].IsReply)
+	count, err = a.Queries.CountUnreadReplies(ctx, users["bob"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestShowStoryInvalidCodeRendersNotFound(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/x/abc/", nil)
+	req.SetPathValue("code", "abc")
+	w := httptest.NewRecorder()
+	a.showStory(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "This page doesn't exist.")
+}
+
+func TestShowStoryNotFoundAndGone(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Soon gone",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "gone12",
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.SoftDeleteStory(ctx, story.ID))
+
+	show := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, storyPath(code, "Soon gone"), nil)
+		req.SetPathValue("code", code)
+		w := httptest.NewRecorder()
+		a.showStory(w, req)
+		return w
+	}
+
+	w := show("nope12")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "This page doesn't exist.", "unknown codes get the styled page")
+
+	w = show("gone12")
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "Soon gone", "the tombstone is still rendered")
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 
 	"crow.watch/internal/auth"
 	"crow.watch/internal/link"
+	"crow.watch/internal/markdown"
 	"crow.watch/internal/store"
 )
 
@@ -35,18 +37,129 @@ func (a *App) submitPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tab := r.URL.Query().Get("tab")
-	if tab != "text" {
+	q := r.URL.Query()
+	tab := q.Get("tab")
+	if tab != "text" && tab != "show" {
 		tab = "link"
 	}
 
+	// ?url=, ?title= and ?tags=go,web let bookmarklets and share targets
+	// pre-populate the form. Tag names may be synonyms.
+	var wanted []store.Tag
+	if names := prefillTagNames(q.Get("tags")); len(names) > 0 {
+		wanted, err = a.Queries.GetTagsByNames(r.Context(), names)
+		if err != nil {
+			a.serverError(w, r, "get tags by names", err)
+			return
+		}
+	}
+	groups := toTagGroups(tags, current.User.IsModerator)
+	rawURL, title, errs := prefillLink(a.LinkPolicy, q.Get("url"), q.Get("title"))
 	a.render(w, "submit", SubmitPageData{
 		Base:      a.baseData(r),
 		Tab:       tab,
-		TagGroups: toTagGroups(tags, current.User.IsModerator),
+		URL:       rawURL,
+		Title:     title,
+		TagGroups: groups,
+		Selected:  prefillTagIDs(groups, wanted, current.User.IsModerator),
+		Errors:    errs,
 	})
 }
 
+// prefillLink prepares a URL and title passed to GET /submit, typically by
+// a bookmarklet. A valid URL has its tracking parameters stripped and the
+// title is cleaned as if it had been fetched; an invalid URL is kept as
+// given with a validation error so the submitter sees the problem upfront.
+func prefillLink(policy link.Config, rawURL, title string) (string, string, map[string]string) {
+	rawURL = strings.TrimSpace(rawURL)
+	title = strings.TrimSpace(title)
+	if rawURL == "" {
+		return rawURL, title, nil
+	}
+
+	result, err := policy.Clean(rawURL)
+	if err != nil {
+		msg := "Invalid URL."
+		var ve *link.ValidationError
+		if errors.As(err, &ve) {
+			msg = ve.Message
+		}
+		return rawURL, title, map[string]string{"url": msg}
+	}
+	return result.Cleaned, cleanTitle(title, result.Cleaned), nil
+}
+
+// prefillTagNames splits a comma-separated list of tag names, as passed
+// in ?tags=, into lowercased names.
+func prefillTagNames(names string) []string {
+	var out []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// prefillTagIDs returns the IDs of the tags in groups that were asked for,
+// already resolved from names or synonyms. Privileged tags are left out for
+// non-moderators.
+func prefillTagIDs(groups []TagGroup, wanted []store.Tag, isModerator bool) []int64 {
+	if len(wanted) == 0 {
+		return nil
+	}
+	byID := make(map[int64]bool, len(wanted))
+	for _, t := range wanted {
+		byID[t.ID] = true
+	}
+
+	var ids []int64
+	for _, g := range groups {
+		for _, t := range g.Tags {
+			if byID[t.ID] && (!t.Privileged || isModerator) {
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// formTagIDs resolves submitted tag values to IDs. Values are normally tag
+// IDs from the form's checkboxes, but a tag name or one of its synonyms is
+// accepted too and maps to the canonical tag. Unknown names are ignored.
+func (a *App) formTagIDs(ctx context.Context, values []string) ([]int64, error) {
+	var ids []int64
+	var names []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+			ids = append(ids, id)
+		} else if v != "" {
+			names = append(names, strings.ToLower(v))
+		}
+	}
+	if len(names) > 0 {
+		tags, err := a.Queries.GetTagsByNames(ctx, names)
+		if err != nil {
+			return nil, fmt.Errorf("get tags by names: %w", err)
+		}
+		for _, t := range tags {
+			ids = append(ids, t.ID)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// showTitlePrefix marks a "Show CW" post: an author sharing their own
+// project, which may carry both a link and a text body.
+const showTitlePrefix = "show cw:"
+
+// isShowTitle reports whether title starts with "Show CW:", in any case.
+func isShowTitle(title string) bool {
+	return len(title) >= len(showTitlePrefix) && strings.EqualFold(title[:len(showTitlePrefix)], showTitlePrefix)
+}
+
 func (a *App) submitStory(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -54,44 +167,164 @@ func (a *App) submitStory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !parseForm(w, r) {
+		return
+	}
+
+	sub := storySubmission{
+		URL:   strings.TrimSpace(r.FormValue("url")),
+		Title: cleanText(r.FormValue("title")),
+		Body:  strings.TrimSpace(r.FormValue("body")),
+	}
+	tagIDs, err := a.formTagIDs(r.Context(), r.Form["tags"])
+	if err != nil {
+		a.serverError(w, r, "resolve tag names", err)
+		return
+	}
+	sub.TagIDs = tagIDs
+
+	// Infer active tab from form content
+	tab := "link"
+	if sub.Body != "" && sub.URL != "" {
+		tab = "show"
+	} else if sub.Body != "" {
+		tab = "text"
+	}
+
+	story, rejected, err := a.submitNewStory(r.Context(), current.User, sub)
+	if err != nil {
+		a.serverError(w, r, "submit story", err)
+		return
+	}
+	if rejected != nil {
+		if rejected.Duplicate != nil {
+			a.renderSubmitDuplicate(w, r, current, tab, sub.URL, sub.Title, sub.Body, sub.TagIDs, *rejected.Duplicate)
+			return
+		}
+		a.renderSubmitError(w, r, current, tab, sub.URL, sub.Title, sub.Body, sub.TagIDs, rejected.Errors, rejected.Message)
+		return
+	}
+
+	a.recordIP(r, current.User.ID, "story")
+	a.setFlash(w, "story_submitted")
+
+	if sub.URL == "" {
+		http.Redirect(w, r, storyPath(story.ShortCode, sub.Title), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// submitStoryJSON is the JSON variant of submitStory (POST /submit.json)
+// for scripts and mobile clients using a session. Validation failures
+// come back as field-keyed errors and success as the story's permalink.
+func (a *App) submitStoryJSON(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
+		return
+	}
+
+	var req struct {
+		URL   string  `json:"url"`
+		Title string  `json:"title"`
+		Body  string  `json:"body"`
+		Tags  []int64 `json:"tags"`
+	}
+	if !decodeJSON(w, r, a.maxBodyBytes(), &req) {
+		return
+	}
+
+	sub := storySubmission{
+		URL:    strings.TrimSpace(req.URL),
+		Title:  cleanText(req.Title),
+		Body:   strings.TrimSpace(req.Body),
+		TagIDs: req.Tags,
+	}
+	story, rejected, err := a.submitNewStory(r.Context(), current.User, sub)
+	if err != nil {
+		a.jsonServerError(w, r, "submit story", err)
+		return
+	}
+	if rejected != nil {
+		writeSubmitRejection(w, rejected)
 		return
 	}
 
-	rawURL := strings.TrimSpace(r.FormValue("url"))
-	title := strings.TrimSpace(r.FormValue("title"))
-	body := strings.TrimSpace(r.FormValue("body"))
-	tagIDStrs := r.Form["tags"]
+	a.recordIP(r, current.User.ID, "story")
+	writeJSON(w, http.StatusCreated, map[string]string{"url": storyPath(story.ShortCode, sub.Title)})
+}
+
+// writeSubmitRejection answers a JSON submission that submitNewStory
+// turned down: 409 for a duplicate link, 422 otherwise.
+func writeSubmitRejection(w http.ResponseWriter, rejected *submitRejection) {
+	switch {
+	case rejected.Duplicate != nil:
+		writeJSON(w, http.StatusConflict, map[string]string{
+			"error":         duplicateMessage(*rejected.Duplicate),
+			"duplicate_url": storyPath(rejected.Duplicate.ShortCode, rejected.Duplicate.Title),
+			"submitted_at":  rejected.Duplicate.CreatedAt.Time.UTC().Format(time.RFC3339),
+		})
+	case len(rejected.Errors) > 0:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": rejected.Errors})
+	default:
+		writeJSONError(w, http.StatusUnprocessableEntity, rejected.Message)
+	}
+}
+
+// storySubmission is a new story as entered by the submitter. Upvotes
+// seeds the score of API submissions; below 2 the submitter's own vote
+// stands alone.
+type storySubmission struct {
+	URL     string
+	Title   string
+	Body    string
+	TagIDs  []int64
+	Upvotes int32
+}
 
+// submitRejection explains why a submission was not accepted: field-keyed
+// validation errors, a general message, or a recent story with the same
+// link.
+type submitRejection struct {
+	Errors    map[string]string
+	Message   string
+	Duplicate *store.FindRecentByNormalizedURLRow
+}
+
+// submitNewStory validates sub and creates the story with its taggings and
+// the submitter's upvote. A rejection is returned for anything the
+// submitter can fix; the error is reserved for failures on our side.
+func (a *App) submitNewStory(ctx context.Context, user store.User, sub storySubmission) (store.CreateStoryRow, *submitRejection, error) {
 	errs := make(map[string]string)
 
 	// Validate title
-	if title == "" {
+	if sub.Title == "" {
 		errs["title"] = "Title is required."
-	} else if len(title) > 150 {
+	} else if len(sub.Title) > 150 {
 		errs["title"] = "Title must be 150 characters or fewer."
 	}
 
-	// Validate content: need URL xor body
-	hasURL := rawURL != ""
-	hasBody := body != ""
-	if hasURL && hasBody {
-		errs["url"] = "A story must have either a URL or a text body, not both."
+	// Validate content: need URL xor body, except show posts which may
+	// describe the linked project in a body as well.
+	hasURL := sub.URL != ""
+	hasBody := sub.Body != ""
+	if hasURL && hasBody && !isShowTitle(sub.Title) {
+		errs["url"] = "A story must have either a URL or a text body, not both, unless it is a \"Show CW:\" post."
 	} else if !hasURL && !hasBody {
 		errs["url"] = "URL or text body is required."
 	}
 
 	// Validate body length
-	if hasBody && len(body) > 10000 {
+	if hasBody && len(sub.Body) > 10000 {
 		errs["body"] = "Text body must be 10,000 characters or fewer."
 	}
 
-	// Clean URL (only if it's a link post)
+	// Clean URL
 	var result link.CleanResult
-	if hasURL && !hasBody {
+	if hasURL && errs["url"] == "" {
 		var err error
-		result, err = link.Clean(rawURL)
+		result, err = a.LinkPolicy.Clean(sub.URL)
 		if err != nil {
 			var ve *link.ValidationError
 			if errors.As(err, &ve) {
@@ -102,31 +335,20 @@ func (a *App) submitStory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Parse tag IDs
-	var tagIDs []int64
-	for _, s := range tagIDStrs {
-		id, err := strconv.ParseInt(s, 10, 64)
-		if err == nil {
-			tagIDs = append(tagIDs, id)
-		}
-	}
-
-	// Infer active tab from form content
-	tab := "link"
-	if hasBody {
-		tab = "text"
+	if len(errs) > 0 {
+		return store.CreateStoryRow{}, &submitRejection{Errors: errs}, nil
 	}
 
-	if len(errs) > 0 {
-		a.renderSubmitError(w, r, current, tab, rawURL, title, body, tagIDs, errs, "")
-		return
+	if msg, err := a.submitCooldown(ctx, user, time.Now()); err != nil {
+		return store.CreateStoryRow{}, nil, err
+	} else if msg != "" {
+		return store.CreateStoryRow{}, &submitRejection{Message: msg}, nil
 	}
 
 	// Load and validate tags
-	tags, err := a.Queries.GetTagsByIDs(r.Context(), tagIDs)
+	tags, err := a.Queries.GetTagsByIDs(ctx, sub.TagIDs)
 	if err != nil {
-		a.serverError(w, r, "get tags by ids", err)
-		return
+		return store.CreateStoryRow{}, nil, fmt.Errorf("get tags by ids: %w", err)
 	}
 
 	hasNonMedia := false
@@ -134,20 +356,19 @@ func (a *App) submitStory(w http.ResponseWriter, r *http.Request) {
 		if !tag.IsMedia {
 			hasNonMedia = true
 		}
-		if tag.Privileged && !current.User.IsModerator {
-			a.renderSubmitError(w, r, current, tab, rawURL, title, body, tagIDs, nil,
-				"You do not have permission to use the tag \""+tag.Tag+"\".")
-			return
+		if tag.Privileged && !user.IsModerator {
+			return store.CreateStoryRow{}, &submitRejection{
+				Message: "You do not have permission to use the tag \"" + tag.Tag + "\".",
+			}, nil
 		}
 	}
 
 	if !hasNonMedia {
 		errs["tags"] = "At least one non-media tag is required."
-		a.renderSubmitError(w, r, current, tab, rawURL, title, body, tagIDs, errs, "")
-		return
+		return store.CreateStoryRow{}, &submitRejection{Errors: errs}, nil
 	}
 
-	isText := hasBody
+	isText := !hasURL
 
 	// Link-specific validation
 	var domain store.Domain
@@ -155,119 +376,112 @@ func (a *App) submitStory(w http.ResponseWriter, r *http.Request) {
 	if !isText {
 		// Get or create domain
 		var err error
-		domain, err = a.Queries.GetOrCreateDomain(r.Context(), result.Domain)
+		domain, err = a.Queries.GetOrCreateDomain(ctx, result.Domain)
 		if err != nil {
-			a.serverError(w, r, "get or create domain", err)
-			return
+			return store.CreateStoryRow{}, nil, fmt.Errorf("get or create domain: %w", err)
 		}
 		if domain.Banned {
-			a.renderSubmitError(w, r, current, tab, rawURL, title, body, tagIDs, nil,
-				"This domain has been banned: "+domain.BanReason)
-			return
+			return store.CreateStoryRow{}, &submitRejection{Message: "This domain has been banned: " + domain.BanReason}, nil
 		}
 
 		// Get or create origin
 		if result.Origin != "" {
-			origin, err := a.Queries.GetOrCreateOrigin(r.Context(), store.GetOrCreateOriginParams{DomainID: domain.ID, Origin: result.Origin})
+			origin, err := a.Queries.GetOrCreateOrigin(ctx, store.GetOrCreateOriginParams{DomainID: domain.ID, Origin: result.Origin})
 			if err != nil {
-				a.serverError(w, r, "get or create origin", err)
-				return
+				return store.CreateStoryRow{}, nil, fmt.Errorf("get or create origin: %w", err)
 			}
 			if origin.Banned {
-				a.renderSubmitError(w, r, current, tab, rawURL, title, body, tagIDs, nil,
-					"This origin has been banned: "+origin.BanReason)
-				return
+				return store.CreateStoryRow{}, &submitRejection{Message: "This origin has been banned: " + origin.BanReason}, nil
 			}
 			originID = pgtype.Int8{Int64: origin.ID, Valid: true}
 		}
 
 		// Duplicate check
-		existing, err := a.Queries.FindRecentByNormalizedURL(r.Context(), pgtype.Text{String: result.Normalized, Valid: true})
+		existing, err := a.findDuplicate(ctx, result.Normalized, time.Now())
 		if err == nil {
-			a.renderSubmitDuplicate(w, r, current, tab, rawURL, title, body, tagIDs, storyPath(existing.ShortCode, existing.Title))
-			return
+			return store.CreateStoryRow{}, &submitRejection{Duplicate: &existing}, nil
 		}
 		if !errors.Is(err, pgx.ErrNoRows) {
-			a.serverError(w, r, "check duplicate url", err)
-			return
+			return store.CreateStoryRow{}, nil, fmt.Errorf("check duplicate url: %w", err)
 		}
 	}
 
 	// Transaction: create story + taggings + increment counts
-	tx, err := a.Pool.Begin(r.Context())
+	tx, err := a.Pool.Begin(ctx)
 	if err != nil {
-		a.serverError(w, r, "begin transaction", err)
-		return
+		return store.CreateStoryRow{}, nil, fmt.Errorf("begin transaction: %w", err)
 	}
-	defer tx.Rollback(r.Context())
+	defer tx.Rollback(ctx)
 
 	qtx := a.Queries.WithTx(tx)
 
-	shortCode := generateShortCode()
 	params := store.CreateStoryParams{
-		UserID:    current.User.ID,
-		Title:     title,
-		ShortCode: shortCode,
+		UserID: user.ID,
+		Title:  sub.Title,
 	}
-	if isText {
-		params.Body = pgtype.Text{String: body, Valid: true}
-	} else {
+	if hasBody {
+		params.Body = pgtype.Text{String: sub.Body, Valid: true}
+	}
+	if !isText {
 		params.DomainID = pgtype.Int8{Int64: domain.ID, Valid: true}
 		params.OriginID = originID
 		params.Url = pgtype.Text{String: result.Cleaned, Valid: true}
 		params.NormalizedUrl = pgtype.Text{String: result.Normalized, Valid: true}
 	}
 
-	story, err := qtx.CreateStory(r.Context(), params)
+	story, err := a.createStory(ctx, tx, params)
 	if err != nil {
-		a.serverError(w, r, "create story", err)
-		return
+		return store.CreateStoryRow{}, nil, fmt.Errorf("create story: %w", err)
 	}
 
 	for _, tag := range tags {
-		if err := qtx.CreateTagging(r.Context(), store.CreateTaggingParams{
+		if err := qtx.CreateTagging(ctx, store.CreateTaggingParams{
 			StoryID: story.ID,
 			TagID:   tag.ID,
 		}); err != nil {
-			a.serverError(w, r, "create tagging", err)
-			return
+			return store.CreateStoryRow{}, nil, fmt.Errorf("create tagging: %w", err)
 		}
 	}
 
-	if _, err := qtx.CreateVote(r.Context(), store.CreateVoteParams{
-		UserID:  current.User.ID,
+	if _, err := qtx.CreateVote(ctx, store.CreateVoteParams{
+		UserID:  user.ID,
 		StoryID: story.ID,
 	}); err != nil {
-		a.serverError(w, r, "auto-upvote story", err)
-		return
+		return store.CreateStoryRow{}, nil, fmt.Errorf("auto-upvote story: %w", err)
+	}
+
+	if err := qtx.AutoSubscribeStory(ctx, store.AutoSubscribeStoryParams{
+		UserID:  user.ID,
+		StoryID: story.ID,
+	}); err != nil {
+		return store.CreateStoryRow{}, nil, fmt.Errorf("subscribe to story: %w", err)
+	}
+
+	if sub.Upvotes > 1 {
+		if err := qtx.SetStoryUpvotes(ctx, store.SetStoryUpvotesParams{
+			ID:      story.ID,
+			Upvotes: sub.Upvotes,
+		}); err != nil {
+			return store.CreateStoryRow{}, nil, fmt.Errorf("set story upvotes: %w", err)
+		}
 	}
 
 	if !isText {
-		if err := qtx.IncrementDomainStoryCount(r.Context(), domain.ID); err != nil {
-			a.serverError(w, r, "increment domain story count", err)
-			return
+		if err := qtx.IncrementDomainStoryCount(ctx, domain.ID); err != nil {
+			return store.CreateStoryRow{}, nil, fmt.Errorf("increment domain story count: %w", err)
 		}
 
 		if originID.Valid {
-			if err := qtx.IncrementOriginStoryCount(r.Context(), originID.Int64); err != nil {
-				a.serverError(w, r, "increment origin story count", err)
-				return
+			if err := qtx.IncrementOriginStoryCount(ctx, originID.Int64); err != nil {
+				return store.CreateStoryRow{}, nil, fmt.Errorf("increment origin story count: %w", err)
 			}
 		}
 	}
 
-	if err := tx.Commit(r.Context()); err != nil {
-		a.serverError(w, r, "commit transaction", err)
-		return
-	}
-
-	a.recordIP(r, current.User.ID, "story")
-
-	if isText {
-		http.Redirect(w, r, storyPath(shortCode, title), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	if err := tx.Commit(ctx); err != nil {
+		return store.CreateStoryRow{}, nil, fmt.Errorf("commit transaction: %w", err)
 	}
+	return story, nil, nil
 }
 
 func (a *App) renderSubmitError(w http.ResponseWriter, r *http.Request, current auth.AuthenticatedUser, tab, rawURL, title, body string, selectedIDs []int64, errs map[string]string, generalErr string) {
@@ -285,7 +499,56 @@ func (a *App) renderSubmitError(w http.ResponseWriter, r *http.Request, current
 	})
 }
 
-func (a *App) renderSubmitDuplicate(w http.ResponseWriter, r *http.Request, current auth.AuthenticatedUser, tab, rawURL, title, body string, selectedIDs []int64, dupURL string) {
+// submitCooldown returns a message telling user how long to wait if their
+// last story was submitted less than SubmitCooldown ago, or "" if they may
+// submit now. Moderators are exempt.
+func (a *App) submitCooldown(ctx context.Context, user store.User, now time.Time) (string, error) {
+	if a.SubmitCooldown <= 0 || user.IsModerator {
+		return "", nil
+	}
+	latest, err := a.Queries.GetUserLatestStoryTime(ctx, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("get latest story time: %w", err)
+	}
+	wait := cooldownRemaining(latest, a.SubmitCooldown, now)
+	if wait <= 0 {
+		return "", nil
+	}
+	return "You are submitting too quickly. You can submit another story in " + retryMinutes(wait) + ".", nil
+}
+
+// cooldownRemaining is how much of the submission cooldown is left after
+// the user's latest story, or zero if it has passed or there is none.
+func cooldownRemaining(latest pgtype.Timestamptz, cooldown time.Duration, now time.Time) time.Duration {
+	if !latest.Valid {
+		return 0
+	}
+	return max(latest.Time.Add(cooldown).Sub(now), 0)
+}
+
+// DefaultDuplicateWindow is how long a submitted link blocks resubmission
+// when DUPLICATE_WINDOW_DAYS is not set.
+const DefaultDuplicateWindow = 30 * 24 * time.Hour
+
+// findDuplicate returns the newest live story submitted with the same
+// normalized URL within the duplicate window. A zero window matches
+// stories of any age.
+func (a *App) findDuplicate(ctx context.Context, normalized string, now time.Time) (store.FindRecentByNormalizedURLRow, error) {
+	params := store.FindRecentByNormalizedURLParams{
+		NormalizedUrl: pgtype.Text{String: normalized, Valid: true},
+	}
+	if a.DuplicateWindow > 0 {
+		params.CreatedAfter = pgtype.Timestamptz{Time: now.Add(-a.DuplicateWindow), Valid: true}
+	}
+	return a.Queries.FindRecentByNormalizedURL(ctx, params)
+}
+
+// duplicateMessage tells the submitter how long ago the link was posted.
+func duplicateMessage(existing store.FindRecentByNormalizedURLRow) string {
+	return "This link was already submitted " + timeAgo(existing.CreatedAt.Time) + "."
+}
+
+func (a *App) renderSubmitDuplicate(w http.ResponseWriter, r *http.Request, current auth.AuthenticatedUser, tab, rawURL, title, body string, selectedIDs []int64, existing store.FindRecentByNormalizedURLRow) {
 	allTags, _ := a.Queries.ListActiveTagsWithCategory(r.Context())
 	a.render(w, "submit", SubmitPageData{
 		Base:         a.baseData(r),
@@ -295,28 +558,27 @@ func (a *App) renderSubmitDuplicate(w http.ResponseWriter, r *http.Request, curr
 		Body:         body,
 		TagGroups:    toTagGroups(allTags, current.User.IsModerator),
 		Selected:     selectedIDs,
-		Error:        "This link has already been submitted recently.",
-		DuplicateURL: dupURL,
+		Error:        duplicateMessage(existing),
+		DuplicateURL: storyPath(existing.ShortCode, existing.Title),
 	})
 }
 
 func (a *App) fetchTitle(w http.ResponseWriter, r *http.Request) {
 	if _, ok := auth.UserFromContext(r.Context()); !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	var req struct {
 		URL string `json:"url"`
 	}
-	if err := json.NewDecoder(io.LimitReader(r.Body, 2048)).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body."})
+	if !decodeJSON(w, r, 2048, &req) {
 		return
 	}
 
-	result, err := link.Clean(req.URL)
+	result, err := a.LinkPolicy.Clean(req.URL)
 	if err != nil {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Invalid URL."})
+		writeJSONError(w, http.StatusUnprocessableEntity, "Invalid URL.")
 		return
 	}
 
@@ -336,7 +598,7 @@ func (a *App) fetchTitle(w http.ResponseWriter, r *http.Request) {
 
 	httpReq, err := http.NewRequestWithContext(r.Context(), "GET", result.Cleaned, nil)
 	if err != nil {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Could not fetch URL."})
+		writeJSONError(w, http.StatusUnprocessableEntity, "Could not fetch URL.")
 		return
 	}
 	httpReq.Header.Set("User-Agent", "crow.watch/1.0 (title fetcher)")
@@ -344,13 +606,13 @@ func (a *App) fetchTitle(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Could not fetch URL."})
+		writeJSONError(w, http.StatusUnprocessableEntity, "Could not fetch URL.")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "URL returned an error."})
+		writeJSONError(w, http.StatusUnprocessableEntity, "URL returned an error.")
 		return
 	}
 
@@ -358,7 +620,7 @@ func (a *App) fetchTitle(w http.ResponseWriter, r *http.Request) {
 	body := io.LimitReader(resp.Body, 256*1024)
 	title := extractTitle(body)
 	if title == "" {
-		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "No title found."})
+		writeJSONError(w, http.StatusUnprocessableEntity, "No title found.")
 		return
 	}
 	title = cleanTitle(title, result.Cleaned)
@@ -441,6 +703,12 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// writeJSONError responds with {"error": msg}, the error shape every JSON
+// endpoint uses.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
 // safeTransport returns an http.Transport that blocks connections to
 // private, loopback, and link-local IP addresses to prevent SSRF.
 func safeTransport() *http.Transport {
@@ -469,6 +737,9 @@ func safeTransport() *http.Transport {
 	}
 }
 
+// toTagGroups groups tags by category. Categories are ordered by name,
+// with uncategorized tags first, and tags alphabetically within each, so
+// the order does not depend on how the database collates the rows.
 func toTagGroups(tags []store.ListActiveTagsWithCategoryRow, isModerator bool) []TagGroup {
 	var groups []TagGroup
 	groupIdx := make(map[string]int)
@@ -476,7 +747,7 @@ func toTagGroups(tags []store.ListActiveTagsWithCategoryRow, isModerator bool) [
 		opt := TagOption{
 			ID:          t.ID,
 			Tag:         t.Tag,
-			Description: t.Description,
+			Description: markdown.Render(t.Description),
 			IsMedia:     t.IsMedia,
 			Privileged:  t.Privileged,
 		}
@@ -488,5 +759,13 @@ func toTagGroups(tags []store.ListActiveTagsWithCategoryRow, isModerator bool) [
 			groups = append(groups, TagGroup{Category: cat, Tags: []TagOption{opt}})
 		}
 	}
+	slices.SortFunc(groups, func(a, b TagGroup) int {
+		return strings.Compare(a.Category, b.Category)
+	})
+	for _, g := range groups {
+		slices.SortFunc(g.Tags, func(a, b TagOption) int {
+			return strings.Compare(a.Tag, b.Tag)
+		})
+	}
 	return groups
 }
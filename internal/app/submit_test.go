@@ -1,11 +1,25 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
 )
 
 func TestExtractTitle(t *testing.T) {
@@ -122,3 +136,525 @@ func TestCleanTitle(t *testing.T) {
 		})
 	}
 }
+
+func TestPrefillTagNames(t *testing.T) {
+	assert.Equal(t, []string{"go", "web"}, prefillTagNames(" Go,,web "))
+	assert.Empty(t, prefillTagNames(""))
+}
+
+func TestPrefillTagIDs(t *testing.T) {
+	groups := []TagGroup{
+		{Category: "Topics", Tags: []TagOption{
+			{ID: 1, Tag: "go"},
+			{ID: 2, Tag: "web"},
+			{ID: 3, Tag: "rust"},
+		}},
+		{Category: "Meta", Tags: []TagOption{
+			{ID: 4, Tag: "announce", Privileged: true},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		wanted    []int64
+		moderator bool
+		want      []int64
+	}{
+		{"tags are selected", []int64{2, 1}, false, []int64{1, 2}},
+		{"tag not offered is ignored", []int64{1, 99}, false, []int64{1}},
+		{"empty", nil, false, nil},
+		{"privileged tag needs a moderator", []int64{4, 3}, false, []int64{3}},
+		{"moderator gets privileged tag", []int64{4}, true, []int64{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wanted []store.Tag
+			for _, id := range tt.wanted {
+				wanted = append(wanted, store.Tag{ID: id})
+			}
+			assert.Equal(t, tt.want, prefillTagIDs(groups, wanted, tt.moderator))
+		})
+	}
+}
+
+func TestToTagGroupsOrder(t *testing.T) {
+	rows := []store.ListActiveTagsWithCategoryRow{
+		{ID: 1, Tag: "web", CategoryName: "Topics"},
+		{ID: 2, Tag: "announce", CategoryName: "Meta"},
+		{ID: 3, Tag: "go", CategoryName: "Topics"},
+		{ID: 4, Tag: "misc"},
+		{ID: 5, Tag: "ask", CategoryName: "Meta"},
+	}
+
+	groups := toTagGroups(rows, false)
+
+	var got []string
+	for _, g := range groups {
+		var tags []string
+		for _, opt := range g.Tags {
+			tags = append(tags, opt.Tag)
+		}
+		got = append(got, g.Category+":"+strings.Join(tags, ","))
+	}
+	assert.Equal(t, []string{":misc", "Meta:announce,ask", "Topics:go,web"}, got)
+}
+
+func TestTagDescriptionsRenderMarkdown(t *testing.T) {
+	groups := toTagGroups([]store.ListActiveTagsWithCategoryRow{
+		{ID: 1, Tag: "go", Description: "The **Go** language <script>x</script>"},
+	}, false)
+
+	a := testApp(t)
+	for _, page := range []string{"tags", "submit"} {
+		w := httptest.NewRecorder()
+		if page == "tags" {
+			a.render(w, page, TagsPageData{TagGroups: groups})
+		} else {
+			a.render(w, page, SubmitPageData{Base: Base{IsLoggedIn: true}, Tab: "link", TagGroups: groups})
+		}
+		body := w.Body.String()
+		assert.Contains(t, body, "The <strong>Go</strong> language", page)
+		assert.NotContains(t, body, "**Go**", page)
+		assert.NotContains(t, body, "<script>x</script>", page)
+	}
+}
+
+func TestRenderSubmitPrefilledTags(t *testing.T) {
+	a := testApp(t)
+	groups := []TagGroup{{Category: "Topics", Tags: []TagOption{
+		{ID: 1, Tag: "go"},
+		{ID: 2, Tag: "web"},
+	}}}
+
+	w := httptest.NewRecorder()
+	a.render(w, "submit", SubmitPageData{
+		Base:      Base{IsLoggedIn: true, Username: "alice"},
+		Tab:       "link",
+		URL:       "https://example.com/series/part-2",
+		Title:     "Part 2",
+		TagGroups: groups,
+		Selected:  prefillTagIDs(groups, []store.Tag{{ID: 1}}, false),
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `value="https://example.com/series/part-2"`)
+	assert.Contains(t, body, `value="Part 2"`)
+	assert.Contains(t, body, `data-tag-input="1"`)
+	assert.NotContains(t, body, `data-tag-input="2"`)
+}
+
+func TestPrefillLink(t *testing.T) {
+	url, title, errs := prefillLink(
+		link.Config{},
+		"https://github.com/golang/go?utm_source=bookmarklet",
+		"GitHub - golang/go: The Go programming language",
+	)
+	assert.Empty(t, errs)
+	assert.Equal(t, "https://github.com/golang/go", url)
+	assert.Equal(t, "The Go programming language", title)
+
+	url, title, errs = prefillLink(link.Config{}, "ftp://example.com/file", "A file")
+	assert.Equal(t, "ftp://example.com/file", url, "invalid URL is kept for editing")
+	assert.Equal(t, "A file", title)
+	assert.NotEmpty(t, errs["url"])
+
+	url, title, errs = prefillLink(link.Config{}, "", "")
+	assert.Empty(t, url)
+	assert.Empty(t, title)
+	assert.Nil(t, errs)
+}
+
+func TestRenderSubmitFromBookmarklet(t *testing.T) {
+	a := testApp(t)
+
+	url, title, errs := prefillLink(link.Config{}, "not a url", "Some page")
+	w := httptest.NewRecorder()
+	a.render(w, "submit", SubmitPageData{
+		Base:   Base{IsLoggedIn: true, Username: "alice"},
+		Tab:    "link",
+		URL:    url,
+		Title:  title,
+		Errors: errs,
+	})
+
+	body := w.Body.String()
+	assert.Contains(t, body, `value="not a url"`)
+	assert.Contains(t, body, `value="Some page"`)
+	assert.Contains(t, body, `class="field-error"`)
+	assert.NotContains(t, body, "btn.click()", "no title fetch for a bad URL")
+}
+
+func TestSubmitPageHonorsQueryParams(t *testing.T) {
+	pool := testDB(t)
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	get := func(target string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1, Username: "alice"}}))
+		w := httptest.NewRecorder()
+		a.submitPage(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	body := get("/submit?url=" + url.QueryEscape("https://example.com/post?utm_source=x") + "&title=Hello")
+	assert.Contains(t, body, `value="https://example.com/post"`)
+	assert.Contains(t, body, `value="Hello"`)
+	assert.NotContains(t, body, `class="field-error"`)
+
+	body = get("/submit?url=nonsense&title=Hello")
+	assert.Contains(t, body, `value="nonsense"`)
+	assert.Contains(t, body, `class="field-error"`)
+}
+
+func TestSubmitWithTagSynonym(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+
+	require.NoError(t, a.Queries.UpsertTag(ctx, store.UpsertTagParams{Tag: "js"}))
+	js, err := a.Queries.GetTagByName(ctx, "js")
+	require.NoError(t, err)
+	require.NoError(t, a.Queries.CreateTagSynonym(ctx, store.CreateTagSynonymParams{TagID: js.ID, Synonym: "JavaScript"}))
+
+	ids, err := a.formTagIDs(ctx, []string{"nosuchtag"})
+	require.NoError(t, err)
+	assert.Empty(t, ids, "an unknown name is ignored")
+
+	form := url.Values{
+		"title": {"Closures explained"},
+		"body":  {"They capture variables."},
+		"tags":  {"javascript", "nosuchtag"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w := httptest.NewRecorder()
+	a.submitStory(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	var storyID int64
+	require.NoError(t, pool.QueryRow(ctx, "SELECT id FROM stories WHERE title = $1", "Closures explained").Scan(&storyID))
+	tags, err := a.Queries.GetStoryTags(ctx, storyID)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "js", tags[0].Tag, "the synonym maps to the canonical tag")
+
+	req = httptest.NewRequest(http.MethodGet, "/submit?tags=JavaScript,nosuchtag", nil)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+	w = httptest.NewRecorder()
+	a.submitPage(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`data-tag-input="%d"`, js.ID), "a prefilled synonym selects the canonical tag")
+
+	tests := []struct {
+		path, tag, page, want string
+	}{
+		{"/t/javascript", "javascript", "", "/t/js"},
+		{"/t/JavaScript/page/2?show=low", "JavaScript", "2", "/t/js/page/2?show=low"},
+	}
+	for _, tt := range tests {
+		req = httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.SetPathValue("tag", tt.tag)
+		req.SetPathValue("page", tt.page)
+		w = httptest.NewRecorder()
+		a.tagPage(w, req)
+		assert.Equal(t, http.StatusMovedPermanently, w.Code, tt.path)
+		assert.Equal(t, tt.want, w.Header().Get("Location"), tt.path)
+	}
+}
+
+func TestIsShowTitle(t *testing.T) {
+	assert.True(t, isShowTitle("Show CW: My project"))
+	assert.True(t, isShowTitle("show cw: lowercase"))
+	assert.False(t, isShowTitle("Ask CW: Anything?"))
+	assert.False(t, isShowTitle("A story about Show CW: posts"))
+	assert.False(t, isShowTitle("Show"))
+}
+
+func TestSubmitShowPostWithBody(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+
+	submit := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		form.Set("tags", strconv.FormatInt(tagID, 10))
+		req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		a.submitStory(w, req)
+		return w
+	}
+
+	w := submit(url.Values{
+		"title": {"Show CW: A tiny crow"},
+		"url":   {"https://example.com/crow?utm_source=x"},
+		"body":  {"Built it **myself**."},
+	})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	var code string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT short_code FROM stories WHERE title = $1", "Show CW: A tiny crow").Scan(&code))
+	row, err := a.Queries.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/crow", row.Url.String)
+	assert.Equal(t, "Built it **myself**.", row.Body.String)
+
+	req := httptest.NewRequest(http.MethodGet, "/x/"+code+"/", nil)
+	req.SetPathValue("code", code)
+	req.SetPathValue("slug", "show_cw_a_tiny_crow")
+	w = httptest.NewRecorder()
+	a.showStory(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="https://example.com/crow"`)
+	assert.Contains(t, w.Body.String(), "<strong>myself</strong>")
+
+	w = submit(url.Values{
+		"title": {"Just a link"},
+		"url":   {"https://example.com/other"},
+		"body":  {"Some text"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "not both")
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM stories WHERE title = $1", "Just a link").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestDuplicateMessage(t *testing.T) {
+	existing := store.FindRecentByNormalizedURLRow{
+		CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-3 * 24 * time.Hour), Valid: true},
+	}
+	assert.Equal(t, "This link was already submitted 3 days ago.", duplicateMessage(existing))
+}
+
+func TestDuplicateWindow(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.DuplicateWindow = 24 * time.Hour
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	domain, err := a.Queries.GetOrCreateDomain(ctx, "example.com")
+	require.NoError(t, err)
+	newStory := func(code string, age time.Duration) string {
+		t.Helper()
+		normalized := "https://example.com/" + code
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:        u.ID,
+			DomainID:      pgtype.Int8{Int64: domain.ID, Valid: true},
+			Url:           pgtype.Text{String: normalized, Valid: true},
+			NormalizedUrl: pgtype.Text{String: normalized, Valid: true},
+			Title:         "Story " + code,
+			ShortCode:     code,
+		})
+		require.NoError(t, err)
+		require.NoError(t, a.Queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-age), Valid: true},
+			ID:        s.ID,
+		}))
+		return normalized
+	}
+	inside := newStory("inside", 23*time.Hour)
+	outside := newStory("outsid", 25*time.Hour)
+
+	found, err := a.findDuplicate(ctx, inside, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "inside", found.ShortCode)
+
+	_, err = a.findDuplicate(ctx, outside, time.Now())
+	assert.ErrorIs(t, err, pgx.ErrNoRows, "older than the window may be resubmitted")
+
+	a.DuplicateWindow = 0
+	found, err = a.findDuplicate(ctx, outside, time.Now())
+	require.NoError(t, err, "a zero window blocks resubmission forever")
+	assert.Equal(t, "outsid", found.ShortCode)
+}
+
+func TestCooldownRemaining(t *testing.T) {
+	now := time.Now()
+	at := func(ago time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: now.Add(-ago), Valid: true}
+	}
+	assert.Zero(t, cooldownRemaining(pgtype.Timestamptz{}, time.Hour, now), "no previous story")
+	assert.Equal(t, 45*time.Minute, cooldownRemaining(at(15*time.Minute), time.Hour, now))
+	assert.Zero(t, cooldownRemaining(at(2*time.Hour), time.Hour, now))
+}
+
+func TestSubmitCooldown(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+	a.SubmitCooldown = 30 * time.Minute
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := store.User{ID: u.ID, Username: u.Username}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+	sub := func(title string) storySubmission {
+		return storySubmission{Title: title, Body: "Some text", TagIDs: []int64{tagID}}
+	}
+
+	_, rejection, err := a.submitNewStory(ctx, user, sub("First"))
+	require.NoError(t, err)
+	require.Nil(t, rejection)
+
+	_, rejection, err = a.submitNewStory(ctx, user, sub("Second"))
+	require.NoError(t, err)
+	require.NotNil(t, rejection, "a rapid second submission is blocked")
+	assert.Equal(t, "You are submitting too quickly. You can submit another story in 30 minutes.", rejection.Message)
+
+	moderator := user
+	moderator.IsModerator = true
+	_, rejection, err = a.submitNewStory(ctx, moderator, sub("Moderated"))
+	require.NoError(t, err)
+	assert.Nil(t, rejection, "moderators are exempt")
+
+	_, err = pool.Exec(ctx, "UPDATE stories SET created_at = now() - interval '31 minutes' WHERE user_id = $1", u.ID)
+	require.NoError(t, err)
+	_, rejection, err = a.submitNewStory(ctx, user, sub("Second"))
+	require.NoError(t, err)
+	assert.Nil(t, rejection, "allowed once the interval has passed")
+}
+
+func TestSubmitStoryJSONValidationErrors(t *testing.T) {
+	a := testApp(t)
+	body := `{"url":"https://example.com","title":"","body":"text","tags":[]}`
+	req := httptest.NewRequest(http.MethodPost, "/submit.json", strings.NewReader(body))
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: store.User{ID: 1}}))
+	w := httptest.NewRecorder()
+	a.submitStoryJSON(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var got struct {
+		Errors map[string]string `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "Title is required.", got.Errors["title"])
+	assert.Contains(t, got.Errors["url"], "not both")
+}
+
+func TestSubmitStoryJSONReturnsPermalink(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	user := auth.AuthenticatedUser{User: store.User{ID: u.ID, Username: u.Username}}
+
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/submit.json", strings.NewReader(body))
+		req = req.WithContext(auth.ContextWithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		a.submitStoryJSON(w, req)
+		return w
+	}
+
+	body := `{"url":"https://example.com/crow","title":"A crow","tags":[` + strconv.FormatInt(tagID, 10) + `]}`
+	w := submit(body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var code string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT short_code FROM stories WHERE title = $1", "A crow").Scan(&code))
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, storyPath(code, "A crow"), got["url"])
+
+	w = submit(body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, storyPath(code, "A crow"), got["duplicate_url"])
+}
+
+func TestAPISubmitStory(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	_, err = a.Queries.CreateAPIKey(ctx, store.CreateAPIKeyParams{
+		UserID: u.ID, TokenHash: auth.HashToken("secret"), Name: "script",
+	})
+	require.NoError(t, err)
+	var tagID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO tags (tag) VALUES ('go') RETURNING id").Scan(&tagID))
+	_, err = pool.Exec(ctx, "INSERT INTO tag_synonyms (tag_id, synonym) VALUES ($1, 'golang')", tagID)
+	require.NoError(t, err)
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/story", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.apiSubmitStory(w, req)
+		return w
+	}
+
+	w := submit(`{"url":"https://example.com/crow","title":"A crow","tags":["golang"],"hotness":5}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var upvotes int32
+	require.NoError(t, pool.QueryRow(ctx, "SELECT upvotes FROM stories WHERE title = 'A crow'").Scan(&upvotes))
+	assert.Equal(t, int32(5), upvotes)
+
+	// Rejections come from the same checks as the submit form.
+	w = submit(`{"url":"https://example.com/crow","title":"Again","tags":["go"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = submit(`{"title":"","body":"text","tags":["go"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
@@ -1,13 +1,18 @@
 package app
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
+	"crow.watch/internal/auth"
+	"crow.watch/internal/markdown"
 	"crow.watch/internal/store"
 )
 
@@ -16,33 +21,44 @@ import (
 func (a *App) tagPage(w http.ResponseWriter, r *http.Request) {
 	tagName := r.PathValue("tag")
 	if tagName == "" {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
 	tag, err := a.Queries.GetTagByName(r.Context(), tagName)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.redirectTagSynonym(w, r, tagName)
 			return
 		}
 		a.serverError(w, r, "get tag by name", err)
 		return
 	}
 
+	synonyms, err := a.Queries.ListTagSynonyms(r.Context(), tag.ID)
+	if err != nil {
+		a.serverError(w, r, "list tag synonyms", err)
+		return
+	}
+
 	page := parsePage(r)
 	data := TagPageData{
 		Base:           a.baseData(r),
 		TagName:        tag.Tag,
-		TagDescription: tag.Description,
+		TagDescription: markdown.Render(tag.Description),
+		RawDescription: tag.Description,
+		Synonyms:       synonyms,
 		CurrentPage:    page,
 		PagePath:       fmt.Sprintf("/t/%s/page", tag.Tag),
 	}
 
+	opts, reveal := a.scoreFilter(r, data.Base, storyListOpts{rankByHotness: true, filterHidden: true})
+	data.ShowLowScore = reveal
+
 	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
 		TagID:      pgtype.Int8{Int64: tag.ID, Valid: true},
 		StoryLimit: 500,
-	}, storyListOpts{rankByHotness: true, filterNegScore: true, filterHidden: true})
+	}, opts)
 	if err != nil {
 		a.serverError(w, r, "load stories", err)
 		return
@@ -50,5 +66,237 @@ func (a *App) tagPage(w http.ResponseWriter, r *http.Request) {
 
 	data.Stories = stories
 	data.HasMore = hasMore
+	data.Links = listingLinks(data.PagePath, page, hasMore, scoreQuery(reveal), data.Base.IsLoggedIn)
 	a.render(w, "tag", data)
 }
+
+// redirectTagSynonym sends a tag page requested under one of its synonyms
+// to the canonical tag, or renders the 404 page if name is not a synonym.
+func (a *App) redirectTagSynonym(w http.ResponseWriter, r *http.Request, name string) {
+	tags, err := a.Queries.GetTagsByNames(r.Context(), []string{strings.ToLower(name)})
+	if err != nil {
+		a.serverError(w, r, "get tags by names", err)
+		return
+	}
+	if len(tags) != 1 {
+		a.notFound(w, r)
+		return
+	}
+
+	target := "/t/" + url.PathEscape(tags[0].Tag)
+	if page := r.PathValue("page"); page != "" {
+		target += "/page/" + url.PathEscape(page)
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// maxTagDescriptionLength bounds the markdown a moderator can put on a
+// tag page.
+const maxTagDescriptionLength = 2000
+
+// updateTagDescription lets moderators replace a tag's markdown
+// description (POST /t/{tag}/description).
+func (a *App) updateTagDescription(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	tag, err := a.Queries.GetTagByName(r.Context(), r.PathValue("tag"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get tag by name", err)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+	description := strings.TrimSpace(r.FormValue("description"))
+	if len(description) > maxTagDescriptionLength {
+		http.Error(w, "description too long", http.StatusBadRequest)
+		return
+	}
+
+	metadataJSON, err := json.Marshal(map[string]any{
+		"old_description": tag.Description,
+		"new_description": description,
+	})
+	if err != nil {
+		a.serverError(w, r, "marshal metadata", err)
+		return
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.UpdateTagDescription(r.Context(), store.UpdateTagDescriptionParams{
+		Description: description,
+		ID:          tag.ID,
+	}); err != nil {
+		a.serverError(w, r, "update tag description", err)
+		return
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      "tag.edit_description",
+		TargetType:  "tag",
+		TargetID:    tag.ID,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, "/t/"+tag.Tag, http.StatusSeeOther)
+}
+
+// maxTagSynonyms bounds how many alternative names one tag can carry.
+const maxTagSynonyms = 20
+
+// parseTagSynonyms splits a comma-separated list of synonyms, dropping
+// blanks, case-insensitive repeats and the tag's own name. It returns a
+// message for input a moderator has to fix.
+func parseTagSynonyms(tag, raw string) ([]string, string) {
+	var synonyms []string
+	seen := map[string]bool{strings.ToLower(tag): true}
+	for _, s := range strings.Split(raw, ",") {
+		s = cleanText(s)
+		if s == "" || seen[strings.ToLower(s)] {
+			continue
+		}
+		if len(s) > 50 || strings.ContainsAny(s, " /") {
+			return nil, fmt.Sprintf("Synonym %q must be a single word of at most 50 characters.", s)
+		}
+		seen[strings.ToLower(s)] = true
+		synonyms = append(synonyms, s)
+	}
+	if len(synonyms) > maxTagSynonyms {
+		return nil, fmt.Sprintf("A tag can have at most %d synonyms.", maxTagSynonyms)
+	}
+	return synonyms, ""
+}
+
+// updateTagSynonyms lets moderators replace the names that map to a tag
+// on submit (POST /t/{tag}/synonyms). A synonym may not be another tag's
+// name or synonym.
+func (a *App) updateTagSynonyms(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	tag, err := a.Queries.GetTagByName(r.Context(), r.PathValue("tag"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get tag by name", err)
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+	synonyms, msg := parseTagSynonyms(tag.Tag, r.FormValue("synonyms"))
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	lower := make([]string, len(synonyms))
+	for i, s := range synonyms {
+		lower[i] = strings.ToLower(s)
+	}
+	taken, err := a.Queries.GetTagsByNames(r.Context(), lower)
+	if err != nil {
+		a.serverError(w, r, "get tags by names", err)
+		return
+	}
+	for _, t := range taken {
+		if t.ID != tag.ID {
+			http.Error(w, fmt.Sprintf("A synonym already names the tag %q.", t.Tag), http.StatusBadRequest)
+			return
+		}
+	}
+
+	old, err := a.Queries.ListTagSynonyms(r.Context(), tag.ID)
+	if err != nil {
+		a.serverError(w, r, "list tag synonyms", err)
+		return
+	}
+	metadataJSON, err := json.Marshal(map[string]any{
+		"old_synonyms": old,
+		"new_synonyms": synonyms,
+	})
+	if err != nil {
+		a.serverError(w, r, "marshal metadata", err)
+		return
+	}
+
+	tx, err := a.Pool.Begin(r.Context())
+	if err != nil {
+		a.serverError(w, r, "begin transaction", err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := a.Queries.WithTx(tx)
+
+	if err := qtx.DeleteTagSynonyms(r.Context(), tag.ID); err != nil {
+		a.serverError(w, r, "delete tag synonyms", err)
+		return
+	}
+	for _, s := range synonyms {
+		if err := qtx.CreateTagSynonym(r.Context(), store.CreateTagSynonymParams{
+			TagID:   tag.ID,
+			Synonym: s,
+		}); err != nil {
+			a.serverError(w, r, "create tag synonym", err)
+			return
+		}
+	}
+
+	entry, err := qtx.CreateModerationLog(r.Context(), store.CreateModerationLogParams{
+		ModeratorID: current.User.ID,
+		Action:      "tag.edit_synonyms",
+		TargetType:  "tag",
+		TargetID:    tag.ID,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		a.serverError(w, r, "create moderation log", err)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		a.serverError(w, r, "commit transaction", err)
+		return
+	}
+	a.notifyModeration(current.User.Username, entry)
+
+	http.Redirect(w, r, "/t/"+tag.Tag, http.StatusSeeOther)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
+)
+
+func TestRenderTagDescriptionMarkdown(t *testing.T) {
+	a := testApp(t)
+	src := "Read the [guidelines](https://example.com/rules) first.\n\n<script>alert(1)</script>"
+
+	w := httptest.NewRecorder()
+	a.render(w, "tag", TagPageData{TagName: "go", TagDescription: markdown.Render(src), RawDescription: src})
+	body := w.Body.String()
+	assert.Contains(t, body, `<a href="https://example.com/rules" rel="nofollow">guidelines</a>`)
+	assert.NotContains(t, body, "<script>alert(1)</script>")
+	assert.NotContains(t, body, "Edit description", "only moderators see the edit form")
+
+	w = httptest.NewRecorder()
+	a.render(w, "tag", TagPageData{Base: Base{IsModerator: true}, TagName: "go", RawDescription: src})
+	assert.Contains(t, w.Body.String(), `action="/t/go/description"`)
+	assert.Contains(t, w.Body.String(), "&lt;script&gt;", "the raw source is escaped in the textarea")
+}
+
+func TestUpdateTagDescriptionRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodPost, "/t/go/description", strings.NewReader("description=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("tag", "go")
+	w := httptest.NewRecorder()
+	a.updateTagDescription(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateTagDescription(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	require.NoError(t, a.Queries.UpsertTag(ctx, store.UpsertTagParams{Tag: "go", Description: "Go"}))
+	var users []store.User
+	for _, name := range []string{"alice", "mod"} {
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		users = append(users, store.User{ID: u.ID, Username: u.Username, IsModerator: name == "mod"})
+	}
+
+	post := func(user store.User, description string) *httptest.ResponseRecorder {
+		form := url.Values{"description": {description}}
+		req := httptest.NewRequest(http.MethodPost, "/t/go/description", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("tag", "go")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: user}))
+		w := httptest.NewRecorder()
+		a.updateTagDescription(w, req)
+		return w
+	}
+
+	w := post(users[0], "hijacked")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	tag, err := a.Queries.GetTagByName(ctx, "go")
+	require.NoError(t, err)
+	assert.Equal(t, "Go", tag.Description)
+
+	w = post(users[1], "  The **Go** language.  ")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/t/go", w.Header().Get("Location"))
+	tag, err = a.Queries.GetTagByName(ctx, "go")
+	require.NoError(t, err)
+	assert.Equal(t, "The **Go** language.", tag.Description)
+
+	w = post(users[1], strings.Repeat("x", maxTagDescriptionLength+1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseTagSynonyms(t *testing.T) {
+	got, msg := parseTagSynonyms("js", " javascript, ECMAScript,,JavaScript, js ")
+	assert.Empty(t, msg)
+	assert.Equal(t, []string{"javascript", "ECMAScript"}, got, "blanks, repeats and the tag itself are dropped")
+
+	_, msg = parseTagSynonyms("js", "java script")
+	assert.NotEmpty(t, msg)
+}
+
+func TestRenderTagSynonyms(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.render(w, "tag", TagPageData{TagName: "js", Synonyms: []string{"javascript", "ecmascript"}})
+	assert.Contains(t, w.Body.String(), "Also known as javascript, ecmascript")
+	assert.NotContains(t, w.Body.String(), "Edit synonyms")
+}
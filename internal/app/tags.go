@@ -7,7 +7,7 @@ import (
 )
 
 func (a *App) tagsPage(w http.ResponseWriter, r *http.Request) {
-	tags, err := a.Queries.ListActiveTagsWithCategory(r.Context())
+	tags, err := a.reads().ListActiveTagsWithCategory(r.Context())
 	if err != nil {
 		a.serverError(w, r, "list active tags", err)
 		return
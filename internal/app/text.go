@@ -0,0 +1,45 @@
+package app
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// cleanText normalizes single-line user input such as titles and
+// usernames. The text is put in NFC, invalid UTF-8 and control and format
+// characters (zero-width spaces, bidi overrides, soft hyphens) are
+// dropped, and runs of whitespace become a single space. The zero-width
+// joiner and non-joiner are kept, as emoji sequences and several scripts
+// need them, and so is a literal U+FFFD.
+func cleanText(s string) string {
+	s = norm.NFC.String(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// Invalid UTF-8.
+		case unicode.IsSpace(r):
+			space = true
+		case unicode.Is(unicode.Cc, r), unicode.Is(unicode.Cf, r) && r != zeroWidthJoiner && r != zeroWidthNonJoiner:
+		default:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+const (
+	zeroWidthNonJoiner = '\u200c'
+	zeroWidthJoiner    = '\u200d'
+)
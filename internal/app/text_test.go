@@ -0,0 +1,39 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "Hello world", "Hello world"},
+		{"non-breaking spaces", "Hello\u00a0world\u00a0!", "Hello world !"},
+		{"zero-width characters", "Go\u200b 1.26\ufeff", "Go 1.26"},
+		{"keeps zero-width non-joiner", "\u0645\u06cc\u200c\u062e\u0648\u0627\u0647\u0645", "\u0645\u06cc\u200c\u062e\u0648\u0627\u0647\u0645"},
+		{"keeps zero-width joiner", "Family \U0001F468\u200d\U0001F469\u200d\U0001F467", "Family \U0001F468\u200d\U0001F469\u200d\U0001F467"},
+		{"keeps replacement character", "Mojibake \ufffd fixed", "Mojibake \ufffd fixed"},
+		{"bidi override", "evil\u202etxt.exe", "eviltxt.exe"},
+		{"control characters", "Title\x00\x07\t\n", "Title"},
+		{"collapses whitespace", "  a \t\n  b  ", "a b"},
+		{"composes to NFC", "Cafe\u0301", "Caf\u00e9"},
+		{"invalid UTF-8", "bad\xffbyte", "badbyte"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cleanText(tt.input))
+		})
+	}
+}
+
+func TestCleanTextUsername(t *testing.T) {
+	assert.Empty(t, validateRegistration(cleanText("alice\u200b"), "alice@example.com", "tangerine sky", "tangerine sky", PasswordPolicy{}, EmailPolicy{}))
+
+	errs := validateRegistration("alice\u200b", "alice@example.com", "tangerine sky", "tangerine sky", PasswordPolicy{}, EmailPolicy{})
+	assert.NotEmpty(t, errs["username"], "uncleaned invisible characters are rejected")
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowHandler stands in for a handler stuck on a slow query: it waits a
+// second unless the request context is cancelled first.
+func slowHandler(a *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			a.serverError(w, r, "slow query", r.Context().Err())
+		}
+	})
+}
+
+func TestRequestTimeoutCutsOffSlowHandler(t *testing.T) {
+	a := testApp(t)
+	a.RequestTimeout = 20 * time.Millisecond
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	a.requestTimeout(slowHandler(a)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRequestTimeoutExemptions(t *testing.T) {
+	a := testApp(t)
+	a.RequestTimeout = 20 * time.Millisecond
+	var deadline bool
+	h := a.requestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = r.Context().Deadline()
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/__dev/reload", nil))
+	assert.False(t, deadline, "dev reload long-poll is exempt")
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, deadline, "clients can't opt out with Accept")
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, deadline)
+
+	a.RequestTimeout = 0
+	a.requestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = r.Context().Deadline()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, deadline, "zero disables the timeout")
+}
+
+func TestJSONServerErrorTimeout(t *testing.T) {
+	a := testApp(t)
+	w := httptest.NewRecorder()
+	a.jsonServerError(w, httptest.NewRequest(http.MethodPost, "/", nil), "slow query", context.DeadlineExceeded)
+	assertJSONError(t, w, http.StatusGatewayTimeout)
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"crow.watch/internal/markdown"
+	"crow.watch/internal/store"
+)
+
+const userCommentsPerPage = 25
+
+type UserCommentItem struct {
+	StoryTitle string
+	StoryPath  string
+	Permalink  string
+	Body       template.HTML
+	Score      int
+	CreatedAt  time.Time
+	IsDeleted  bool
+}
+
+func (a *App) userCommentsPage(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	if username == "" {
+		a.notFound(w, r)
+		return
+	}
+
+	profile, err := a.reads().GetPublicProfile(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get public profile", err)
+		return
+	}
+
+	page := parsePage(r)
+	rows, err := a.reads().ListCommentsByUsername(r.Context(), store.ListCommentsByUsernameParams{
+		Username:      profile.Username,
+		CommentLimit:  userCommentsPerPage + 1,
+		CommentOffset: offsetFor(page, userCommentsPerPage),
+	})
+	if err != nil {
+		a.serverError(w, r, "list comments by username", err)
+		return
+	}
+
+	rows, hasMore := trimLookahead(rows, userCommentsPerPage)
+
+	a.render(w, "user_comments", UserCommentsPageData{
+		Base:            a.baseData(r),
+		ProfileUsername: profile.Username,
+		Comments:        buildUserCommentItems(rows),
+		CurrentPage:     page,
+		HasMore:         hasMore,
+		PagePath:        fmt.Sprintf("/u/%s/comments/page", profile.Username),
+	})
+}
+
+// buildUserCommentItems renders a user's comments for their history page.
+// Deleted comments keep their place but show no body.
+func buildUserCommentItems(rows []store.ListCommentsByUsernameRow) []UserCommentItem {
+	var items []UserCommentItem
+	for _, row := range rows {
+		item := UserCommentItem{
+			StoryTitle: row.StoryTitle,
+			StoryPath:  storyPath(row.StoryShortCode, row.StoryTitle),
+			Permalink:  commentPath(row.StoryShortCode, row.ID),
+			Score:      int(row.Upvotes - row.Downvotes),
+			CreatedAt:  row.CreatedAt.Time,
+			IsDeleted:  row.DeletedAt.Valid,
+		}
+		if item.IsDeleted {
+			item.Body = deletedMarker
+		} else {
+			item.Body = markdown.Render(row.Body)
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestBuildUserCommentItemsDeleted(t *testing.T) {
+	rows := []store.ListCommentsByUsernameRow{
+		{ID: 1, Body: "visible *text*", Upvotes: 3, Downvotes: 1, StoryTitle: "A story", StoryShortCode: "abc123"},
+		{ID: 2, Body: "", StoryTitle: "A story", StoryShortCode: "abc123", DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}},
+	}
+
+	items := buildUserCommentItems(rows)
+
+	require.Len(t, items, 2)
+	assert.Contains(t, string(items[0].Body), "<em>text</em>")
+	assert.Equal(t, 2, items[0].Score)
+	assert.Equal(t, "/x/abc123/comments/1#comment-1", items[0].Permalink)
+	assert.True(t, items[1].IsDeleted)
+	assert.Equal(t, deletedMarker, string(items[1].Body))
+}
+
+func TestUserCommentsPage(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	story, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+		UserID:    u.ID,
+		Title:     "Commented",
+		Body:      pgtype.Text{String: "body", Valid: true},
+		ShortCode: "abc123",
+	})
+	require.NoError(t, err)
+
+	// Comment n is n minutes old, so comment 0 is the newest.
+	total := userCommentsPerPage + 1
+	var ids []int64
+	for n := range total {
+		c, err := a.Queries.CreateComment(ctx, store.CreateCommentParams{
+			StoryID: story.ID,
+			UserID:  u.ID,
+			Body:    fmt.Sprintf("comment number %d.", n),
+		})
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, "UPDATE comments SET created_at = now() - $1 * interval '1 minute' WHERE id = $2", n, c.ID)
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+	require.NoError(t, a.Queries.SoftDeleteComment(ctx, ids[1]))
+
+	rows, err := a.Queries.ListCommentsByUsername(ctx, store.ListCommentsByUsernameParams{
+		Username: "ALICE", CommentLimit: 3,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []int64{ids[0], ids[1], ids[2]}, []int64{rows[0].ID, rows[1].ID, rows[2].ID}, "newest first")
+
+	get := func(page string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/u/alice/comments", nil)
+		req.SetPathValue("username", "alice")
+		req.SetPathValue("page", page)
+		w := httptest.NewRecorder()
+		a.userCommentsPage(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	body := get("")
+	assert.Contains(t, body, "comment number 0.")
+	assert.NotContains(t, body, "comment number 1.")
+	assert.Contains(t, body, deletedMarker)
+	assert.Equal(t, userCommentsPerPage, strings.Count(body, `class="user-comment__context"`))
+	assert.Contains(t, body, `href="/u/alice/comments/page/2"`)
+
+	body = get("2")
+	assert.Contains(t, body, fmt.Sprintf("comment number %d.", total-1))
+	assert.Equal(t, 1, strings.Count(body, `class="user-comment__context"`))
+	assert.NotContains(t, body, "/u/alice/comments/page/3")
+}
+
+func TestUserCommentsPageUnknownUser(t *testing.T) {
+	pool := testDB(t)
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	req := httptest.NewRequest(http.MethodGet, "/u/nobody/comments", nil)
+	req.SetPathValue("username", "nobody")
+	w := httptest.NewRecorder()
+	a.userCommentsPage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"crow.watch/internal/auth"
+)
+
+type UserFlagsPageData struct {
+	Base            Base
+	ProfileUsername string
+	Given           []UserFlagSummary
+	Received        []UserFlagSummary
+}
+
+// UserFlagSummary counts flags of one reason on one kind of content.
+// Users is how many distinct people were on the other side: authors
+// flagged for given flags, flaggers for received ones.
+type UserFlagSummary struct {
+	TargetType string
+	Reason     string
+	Count      int
+	Users      int
+}
+
+// userFlagsPage shows moderators the flags a user has cast and the flags
+// their stories and comments received, to spot abuse of flagging.
+func (a *App) userFlagsPage(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.UserFromContext(r.Context())
+	if !ok || !current.User.IsModerator {
+		a.notFound(w, r)
+		return
+	}
+
+	userID, err := a.Queries.GetUserIDByUsername(r.Context(), r.PathValue("username"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.notFound(w, r)
+			return
+		}
+		a.serverError(w, r, "get user id by username", err)
+		return
+	}
+	user, err := a.Queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "get user by id", err)
+		return
+	}
+
+	givenRows, err := a.Queries.ListFlagsGivenByUser(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "list flags given by user", err)
+		return
+	}
+	receivedRows, err := a.Queries.ListFlagsReceivedByUser(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "list flags received by user", err)
+		return
+	}
+
+	data := UserFlagsPageData{
+		Base:            a.baseData(r),
+		ProfileUsername: user.Username,
+	}
+	for _, row := range givenRows {
+		data.Given = append(data.Given, UserFlagSummary{TargetType: row.TargetType, Reason: row.Reason, Count: int(row.Count), Users: int(row.Users)})
+	}
+	for _, row := range receivedRows {
+		data.Received = append(data.Received, UserFlagSummary{TargetType: row.TargetType, Reason: row.Reason, Count: int(row.Count), Users: int(row.Users)})
+	}
+	a.render(w, "user_flags", data)
+}
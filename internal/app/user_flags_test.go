@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/auth"
+	"crow.watch/internal/store"
+)
+
+func TestUserFlagsPageRequiresLogin(t *testing.T) {
+	a := testApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/u/alice/flags", nil)
+	req.SetPathValue("username", "alice")
+	w := httptest.NewRecorder()
+	a.userFlagsPage(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUserFlags(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+
+	a := testApp(t)
+	a.Pool = pool
+	a.Queries = store.New(pool)
+
+	newUser := func(name string) int64 {
+		t.Helper()
+		u, err := a.Queries.CreateUser(ctx, store.CreateUserParams{
+			Username: name, Email: name + "@example.com", PasswordDigest: "x",
+		})
+		require.NoError(t, err)
+		return u.ID
+	}
+	alice, bob, carol := newUser("alice"), newUser("bob"), newUser("carol")
+
+	newStory := func(userID int64, code string) int64 {
+		t.Helper()
+		s, err := a.Queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    userID,
+			Title:     code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		return s.ID
+	}
+	aliceStory1, aliceStory2 := newStory(alice, "aaaaa1"), newStory(alice, "aaaaa2")
+	carolStory := newStory(carol, "ccccc1")
+	comment, err := a.Queries.CreateComment(ctx, store.CreateCommentParams{StoryID: carolStory, UserID: alice, Body: "hi"})
+	require.NoError(t, err)
+
+	// bob flags both of alice's stories as spam and her comment as troll;
+	// carol flags one of alice's stories as spam; alice flags carol's story.
+	flagStory := func(userID, storyID int64, reason string) {
+		t.Helper()
+		require.NoError(t, a.Queries.CreateStoryFlag(ctx, store.CreateStoryFlagParams{UserID: userID, StoryID: storyID, Reason: reason}))
+	}
+	flagStory(bob, aliceStory1, "spam")
+	flagStory(bob, aliceStory2, "spam")
+	flagStory(carol, aliceStory1, "spam")
+	flagStory(alice, carolStory, "off-topic")
+	_, err = a.Queries.CreateCommentFlag(ctx, store.CreateCommentFlagParams{UserID: bob, CommentID: comment.ID, Reason: "troll"})
+	require.NoError(t, err)
+
+	received, err := a.Queries.ListFlagsReceivedByUser(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, []store.ListFlagsReceivedByUserRow{
+		{TargetType: "story", Reason: "spam", Count: 3, Users: 2},
+		{TargetType: "comment", Reason: "troll", Count: 1, Users: 1},
+	}, received)
+
+	given, err := a.Queries.ListFlagsGivenByUser(ctx, bob)
+	require.NoError(t, err)
+	assert.Equal(t, []store.ListFlagsGivenByUserRow{
+		{TargetType: "story", Reason: "spam", Count: 2, Users: 1},
+		{TargetType: "comment", Reason: "troll", Count: 1, Users: 1},
+	}, given)
+
+	get := func(viewer store.User) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/u/alice/flags", nil)
+		req.SetPathValue("username", "alice")
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.AuthenticatedUser{User: viewer}))
+		w := httptest.NewRecorder()
+		a.userFlagsPage(w, req)
+		return w
+	}
+
+	w := get(store.User{ID: bob, Username: "bob"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get(store.User{ID: carol, Username: "carol", IsModerator: true})
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "off-topic")
+	assert.Contains(t, body, "spam")
+	assert.Contains(t, body, "troll")
+}
@@ -14,14 +14,14 @@ import (
 func (a *App) userStoriesPage(w http.ResponseWriter, r *http.Request) {
 	username := r.PathValue("username")
 	if username == "" {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	_, err := a.Queries.GetPublicProfile(r.Context(), username)
+	_, err := a.reads().GetPublicProfile(r.Context(), username)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.NotFound(w, r)
+			a.notFound(w, r)
 			return
 		}
 		a.serverError(w, r, "get public profile", err)
@@ -37,9 +37,8 @@ func (a *App) userStoriesPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	stories, hasMore, err := a.loadStoryList(r, data.Base, page, store.ListStoriesParams{
-		Username:   pgtype.Text{String: username, Valid: true},
-		StoryLimit: 500,
-	}, storyListOpts{})
+		Username: pgtype.Text{String: username, Valid: true},
+	}, storyListOpts{paged: true})
 	if err != nil {
 		a.serverError(w, r, "load stories", err)
 		return
@@ -14,16 +14,33 @@ type voteResponse struct {
 	Upvotes int  `json:"upvotes"`
 }
 
+// writeVoteState responds with the story's vote count, or with the
+// re-rendered vote button group when the client asks for HTML.
+func (a *App) writeVoteState(w http.ResponseWriter, r *http.Request, storyID int64, hasUpvoted bool, upvotes int) {
+	if wantsHTML(r) {
+		a.renderFragment(w, "story-vote", StoryItem{
+			ID:         storyID,
+			Upvotes:    upvotes,
+			HasUpvoted: hasUpvoted,
+			IsLoggedIn: true,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(voteResponse{OK: true, Upvotes: upvotes})
+}
+
 func (a *App) upvote(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -32,24 +49,23 @@ func (a *App) upvote(w http.ResponseWriter, r *http.Request) {
 		StoryID: storyID,
 	})
 	if err != nil {
-		a.serverError(w, r, "create vote", err)
+		a.jsonServerError(w, r, "create vote", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(voteResponse{OK: true, Upvotes: int(upvotes)})
+	a.writeVoteState(w, r, storyID, true, int(upvotes))
 }
 
 func (a *App) unvote(w http.ResponseWriter, r *http.Request) {
 	current, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Login required.")
 		return
 	}
 
 	storyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Bad request.")
 		return
 	}
 
@@ -58,10 +74,9 @@ func (a *App) unvote(w http.ResponseWriter, r *http.Request) {
 		StoryID: storyID,
 	})
 	if err != nil {
-		a.serverError(w, r, "delete vote", err)
+		a.jsonServerError(w, r, "delete vote", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(voteResponse{OK: true, Upvotes: int(upvotes)})
+	a.writeVoteState(w, r, storyID, false, int(upvotes))
 }
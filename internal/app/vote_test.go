@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/flagreason"
+	"crow.watch/internal/store"
+)
+
+func TestRecalculateStoryScoresIncremental(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	queries := store.New(pool)
+
+	u, err := queries.CreateUser(ctx, store.CreateUserParams{
+		Username: "alice", Email: "alice@example.com", PasswordDigest: "x",
+	})
+	require.NoError(t, err)
+	var ids []int64
+	for _, code := range []string{"aaa111", "bbb222"} {
+		story, err := queries.CreateStory(ctx, store.CreateStoryParams{
+			UserID:    u.ID,
+			Title:     "Story " + code,
+			Body:      pgtype.Text{String: "body", Valid: true},
+			ShortCode: code,
+		})
+		require.NoError(t, err)
+		ids = append(ids, story.ID)
+	}
+
+	reasons, weights := flagreason.DefaultStory.Weights()
+	trust := flagreason.Trust{}.Params(time.Now())
+	recalculate := func(incremental bool) int64 {
+		t.Helper()
+		n, err := queries.RecalculateStoryScores(ctx, store.RecalculateStoryScoresParams{
+			Incremental:    incremental,
+			MaxPercent:     trust.MaxPercent,
+			TrustedPercent: trust.TrustedPercent,
+			Reasons:        reasons,
+			Weights:        weights,
+		})
+		require.NoError(t, err)
+		return n
+	}
+	upvotes := func(id int64) int32 {
+		t.Helper()
+		row, err := queries.GetStory(ctx, store.GetStoryParams{ID: pgtype.Int8{Int64: id, Valid: true}})
+		require.NoError(t, err)
+		return row.Upvotes
+	}
+
+	assert.Equal(t, int64(2), recalculate(true), "never-scored stories are stale")
+	assert.Equal(t, int64(0), recalculate(true), "nothing changed since")
+
+	// Skew both counters without recording activity, then vote on the
+	// second story only.
+	for _, id := range ids {
+		require.NoError(t, queries.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+			Upvotes:   99,
+			CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			ID:        id,
+		}))
+	}
+	_, err = queries.CreateVote(ctx, store.CreateVoteParams{UserID: u.ID, StoryID: ids[1]})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), recalculate(true))
+	assert.Equal(t, int32(99), upvotes(ids[0]), "untouched story is left alone")
+	assert.Equal(t, int32(1), upvotes(ids[1]))
+
+	assert.Equal(t, int64(2), recalculate(false))
+	assert.Equal(t, int32(0), upvotes(ids[0]))
+}
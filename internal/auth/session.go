@@ -6,8 +6,10 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -20,21 +22,77 @@ type contextKey string
 
 const userContextKey contextKey = "authenticated_user"
 
+// lastSeenInterval bounds how often a user's last_seen_at is written, so
+// a burst of requests costs one write rather than one per request.
+const lastSeenInterval = 10 * time.Minute
+
 type SessionManager struct {
 	queries    *store.Queries
 	cookieName string
 	ttl        time.Duration
-	secure     bool
+	cookie     CookieOptions
 	log        *slog.Logger
 }
 
+// CookieOptions controls the attributes of the session cookie.
+type CookieOptions struct {
+	Secure bool
+	// Domain lets subdomains share the session (e.g. "crow.watch" for
+	// app.crow.watch). Empty keeps the cookie host-only.
+	Domain string
+	// SameSite defaults to Lax when left zero.
+	SameSite http.SameSite
+}
+
+// ParseSameSite maps "lax" or "strict" to a SameSite mode; empty means Lax.
+// "none" is rejected, since SameSite is what stops cross-site form posts.
+func ParseSameSite(v string) (http.SameSite, error) {
+	switch strings.ToLower(v) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	}
+	return 0, fmt.Errorf("invalid SameSite mode %q", v)
+}
+
 type AuthenticatedUser struct {
 	SessionID int64
 	User      store.User
+	// Impersonator is the moderator whose session is acting as User, or
+	// nil for a normal session.
+	Impersonator *store.User
+}
+
+var (
+	ErrImpersonateSelf      = errors.New("cannot impersonate yourself")
+	ErrImpersonateModerator = errors.New("cannot impersonate a moderator")
+	ErrImpersonateInactive  = errors.New("cannot impersonate a banned or deleted user")
+	ErrNotModerator         = errors.New("only moderators can impersonate users")
+)
+
+// CheckImpersonation reports whether actor may act as target. Only
+// moderators may impersonate, and never another moderator or an account
+// that could not sign in itself.
+func CheckImpersonation(actor, target store.User) error {
+	switch {
+	case !actor.IsModerator:
+		return ErrNotModerator
+	case actor.ID == target.ID:
+		return ErrImpersonateSelf
+	case target.IsModerator:
+		return ErrImpersonateModerator
+	case target.BannedAt.Valid || target.DeletedAt.Valid || target.PasswordDigest == "*":
+		return ErrImpersonateInactive
+	}
+	return nil
 }
 
-func NewSessionManager(queries *store.Queries, cookieName string, ttl time.Duration, secure bool, log *slog.Logger) *SessionManager {
-	return &SessionManager{queries: queries, cookieName: cookieName, ttl: ttl, secure: secure, log: log}
+func NewSessionManager(queries *store.Queries, cookieName string, ttl time.Duration, cookie CookieOptions, log *slog.Logger) *SessionManager {
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	return &SessionManager{queries: queries, cookieName: cookieName, ttl: ttl, cookie: cookie, log: log}
 }
 
 func (m *SessionManager) AuthenticateRequest(next http.Handler) http.Handler {
@@ -66,6 +124,9 @@ func (m *SessionManager) AuthenticateRequest(next http.Handler) http.Handler {
 		}
 
 		_ = m.queries.TouchSession(r.Context(), sessionUser.SessionID)
+		if shouldTouchLastSeen(sessionUser.LastSeenAt, time.Now()) {
+			_ = m.queries.TouchUserLastSeen(r.Context(), sessionUser.ID)
+		}
 
 		ctxUser := AuthenticatedUser{
 			SessionID: sessionUser.SessionID,
@@ -85,16 +146,49 @@ func (m *SessionManager) AuthenticateRequest(next http.Handler) http.Handler {
 				UnconfirmedEmail:                sessionUser.UnconfirmedEmail,
 				Website:                         sessionUser.Website,
 				About:                           sessionUser.About,
+				Slogan:                          sessionUser.Slogan,
+				LastSeenAt:                      sessionUser.LastSeenAt,
+				EnableEmbeds:                    sessionUser.EnableEmbeds,
 				CreatedAt:                       sessionUser.CreatedAt,
 				UpdatedAt:                       sessionUser.UpdatedAt,
 			},
 		}
 
-		ctx := context.WithValue(r.Context(), userContextKey, ctxUser)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		if sessionUser.ImpersonatedUserID.Valid {
+			ctxUser = m.impersonate(r, ctxUser, sessionUser.ImpersonatedUserID.Int64)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), ctxUser)))
 	})
 }
 
+// impersonate swaps the session's moderator for the user they are acting
+// as. If the target can no longer be impersonated the session falls back
+// to the moderator and the impersonation is dropped.
+func (m *SessionManager) impersonate(r *http.Request, moderator AuthenticatedUser, targetID int64) AuthenticatedUser {
+	target, err := m.queries.GetUserByID(r.Context(), targetID)
+	if err == nil {
+		err = CheckImpersonation(moderator.User, target)
+	}
+	if err != nil {
+		m.log.Warn("dropping impersonation", "error", err, "moderator", moderator.User.Username, "target_id", targetID)
+		_ = m.queries.SetSessionImpersonation(r.Context(), store.SetSessionImpersonationParams{ID: moderator.SessionID})
+		return moderator
+	}
+
+	// The moderator acts as the user but never holds their credentials.
+	target.PasswordDigest = ""
+	target.PasswordResetTokenHash = pgtype.Text{}
+	target.EmailConfirmationTokenHash = pgtype.Text{}
+
+	admin := moderator.User
+	return AuthenticatedUser{
+		SessionID:    moderator.SessionID,
+		User:         target,
+		Impersonator: &admin,
+	}
+}
+
 func (m *SessionManager) Login(w http.ResponseWriter, r *http.Request, user store.User) error {
 	rawToken, err := newRawToken()
 	if err != nil {
@@ -115,15 +209,9 @@ func (m *SessionManager) Login(w http.ResponseWriter, r *http.Request, user stor
 		return err
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     m.cookieName,
-		Value:    rawToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   m.secure,
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(m.ttl),
-	})
+	cookie := m.newCookie(rawToken)
+	cookie.Expires = time.Now().Add(m.ttl)
+	http.SetCookie(w, cookie)
 
 	return nil
 }
@@ -137,11 +225,23 @@ func (m *SessionManager) Logout(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// shouldTouchLastSeen reports whether last_seen_at is missing or older than
+// lastSeenInterval.
+func shouldTouchLastSeen(lastSeen pgtype.Timestamptz, now time.Time) bool {
+	return !lastSeen.Valid || now.Sub(lastSeen.Time) >= lastSeenInterval
+}
+
 func UserFromContext(ctx context.Context) (AuthenticatedUser, bool) {
 	user, ok := ctx.Value(userContextKey).(AuthenticatedUser)
 	return user, ok
 }
 
+// ContextWithUser returns a copy of ctx carrying user as the
+// authenticated user.
+func ContextWithUser(ctx context.Context, user AuthenticatedUser) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
 func newRawToken() (string, error) {
 	buf := make([]byte, 32)
 	if _, err := rand.Read(buf); err != nil {
@@ -155,14 +255,26 @@ func HashToken(raw string) string {
 	return base64.RawURLEncoding.EncodeToString(sum[:])
 }
 
-func (m *SessionManager) clearCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
+// CookieOptions returns the options session cookies are set with, for
+// other cookies that should follow the same rules.
+func (m *SessionManager) CookieOptions() CookieOptions {
+	return m.cookie
+}
+
+func (m *SessionManager) newCookie(value string) *http.Cookie {
+	return &http.Cookie{
 		Name:     m.cookieName,
-		Value:    "",
+		Value:    value,
 		Path:     "/",
+		Domain:   m.cookie.Domain,
 		HttpOnly: true,
-		Secure:   m.secure,
-		MaxAge:   -1,
-		SameSite: http.SameSiteLaxMode,
-	})
+		Secure:   m.cookie.Secure,
+		SameSite: m.cookie.SameSite,
+	}
+}
+
+func (m *SessionManager) clearCookie(w http.ResponseWriter) {
+	cookie := m.newCookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
 }
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/store"
+)
+
+func TestShouldTouchLastSeenThrottles(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lastSeen pgtype.Timestamptz
+	writes := 0
+
+	request := func(at time.Time) {
+		if shouldTouchLastSeen(lastSeen, at) {
+			writes++
+			lastSeen = pgtype.Timestamptz{Time: at, Valid: true}
+		}
+	}
+
+	request(now)
+	request(now.Add(2 * time.Second))
+	assert.Equal(t, 1, writes, "two quick requests produce one write")
+
+	request(now.Add(lastSeenInterval - time.Second))
+	assert.Equal(t, 1, writes)
+
+	request(now.Add(lastSeenInterval))
+	assert.Equal(t, 2, writes, "written again once the interval passes")
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := []struct {
+		in   string
+		want http.SameSite
+	}{
+		{"", http.SameSiteLaxMode},
+		{"lax", http.SameSiteLaxMode},
+		{"Strict", http.SameSiteStrictMode},
+	}
+	for _, tt := range tests {
+		got, err := ParseSameSite(tt.in)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	_, err := ParseSameSite("sometimes")
+	assert.Error(t, err)
+	_, err = ParseSameSite("none")
+	assert.Error(t, err, "none would allow cross-site form posts")
+}
+
+func TestSessionCookieOptions(t *testing.T) {
+	m := NewSessionManager(nil, "session", time.Hour, CookieOptions{
+		Secure:   true,
+		Domain:   "crow.watch",
+		SameSite: http.SameSiteStrictMode,
+	}, nil)
+
+	cookie := m.newCookie("token")
+	assert.Equal(t, "crow.watch", cookie.Domain)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+
+	// Clearing must use the same scope or the browser keeps the old cookie.
+	w := httptest.NewRecorder()
+	m.clearCookie(w)
+	cleared := w.Result().Cookies()
+	require.Len(t, cleared, 1)
+	assert.Equal(t, "crow.watch", cleared[0].Domain)
+	assert.Equal(t, http.SameSiteStrictMode, cleared[0].SameSite)
+	assert.Equal(t, -1, cleared[0].MaxAge)
+}
+
+func TestSessionCookieDefaultsToLax(t *testing.T) {
+	m := NewSessionManager(nil, "session", time.Hour, CookieOptions{}, nil)
+
+	cookie := m.newCookie("token")
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.Empty(t, cookie.Domain)
+}
+
+func TestCheckImpersonation(t *testing.T) {
+	mod := store.User{ID: 1, IsModerator: true}
+	banned := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name   string
+		actor  store.User
+		target store.User
+		want   error
+	}{
+		{"moderator impersonates user", mod, store.User{ID: 2}, nil},
+		{"regular user", store.User{ID: 3}, store.User{ID: 2}, ErrNotModerator},
+		{"self", mod, mod, ErrImpersonateSelf},
+		{"another moderator", mod, store.User{ID: 4, IsModerator: true}, ErrImpersonateModerator},
+		{"banned user", mod, store.User{ID: 5, BannedAt: banned}, ErrImpersonateInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, CheckImpersonation(tt.actor, tt.target), tt.want)
+		})
+	}
+}
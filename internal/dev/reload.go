@@ -21,36 +21,78 @@ type Reloader struct {
 	watcher *fsnotify.Watcher
 	log     *slog.Logger
 
+	// OnGoChange, if set, is called once Go sources have changed. Nothing
+	// recompiles the running server, so it is meant to exit the process
+	// and let an external supervisor rebuild and restart it; open pages
+	// reload when the new server comes up.
+	OnGoChange func()
+
+	maxDirs   int
+	watched   int
+	capWarned bool
+
 	mu   sync.Mutex
 	subs []chan Event
 }
 
+// maxWatchedDirs caps how many directories are watched, so a large
+// checkout can't exhaust file descriptors.
+const maxWatchedDirs = 1000
+
+// ignoredDirs are never watched, wherever they appear. Dot-directories are
+// skipped as well.
+var ignoredDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"testdata":     true,
+}
+
 func NewReloader(dirs []string, log *slog.Logger) (*Reloader, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
+	r := &Reloader{watcher: watcher, log: log, maxDirs: maxWatchedDirs}
 
 	for _, dir := range dirs {
-		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				if strings.HasPrefix(d.Name(), ".") {
-					return filepath.SkipDir
-				}
-				return watcher.Add(path)
-			}
-			return nil
-		})
-		if err != nil {
+		if err := r.watchTree(dir); err != nil {
 			_ = watcher.Close()
 			return nil, err
 		}
 	}
+	return r, nil
+}
+
+// watchTree watches dir and every directory below it that isn't ignored,
+// up to maxDirs in total. Hitting the cap is logged once.
+func (r *Reloader) watchTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		if r.watched >= r.maxDirs {
+			if !r.capWarned {
+				r.log.Warn("dev reloader watching too many directories, ignoring the rest", "max", r.maxDirs, "skipped", path)
+				r.capWarned = true
+			}
+			return filepath.SkipAll
+		}
+		if err := r.watcher.Add(path); err != nil {
+			return err
+		}
+		r.watched++
+		return nil
+	})
+}
 
-	return &Reloader{watcher: watcher, log: log}, nil
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || ignoredDirs[name]
 }
 
 func (r *Reloader) Run() {
@@ -73,7 +115,7 @@ func (r *Reloader) Run() {
 			}
 
 			kind := classify(ev.Name)
-			if kind == "" {
+			if kind == "" || (kind == "go" && r.OnGoChange == nil) {
 				continue
 			}
 
@@ -81,6 +123,11 @@ func (r *Reloader) Run() {
 			debounce.Reset(50 * time.Millisecond)
 
 		case <-debounce.C:
+			if pending == "go" {
+				r.log.Info("go sources changed, restarting")
+				r.OnGoChange()
+				pending = ""
+			}
 			if pending != "" {
 				r.log.Info("reload", "kind", pending)
 				r.broadcast(Event{Kind: pending})
@@ -101,10 +148,10 @@ func (r *Reloader) maybeWatchDir(path string) {
 	if err != nil || !fi.IsDir() {
 		return
 	}
-	if strings.HasPrefix(filepath.Base(path), ".") {
+	if skipDir(filepath.Base(path)) {
 		return
 	}
-	_ = r.watcher.Add(path)
+	_ = r.watchTree(path)
 }
 
 func (r *Reloader) Close() error {
@@ -165,7 +212,12 @@ func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// classify maps a changed file to the kind of reload it needs, or "" when
+// it can be ignored.
 func classify(name string) string {
+	if isTempFile(name) {
+		return ""
+	}
 	ext := strings.ToLower(filepath.Ext(name))
 	switch ext {
 	case ".css":
@@ -174,13 +226,33 @@ func classify(name string) string {
 		return "tmpl"
 	case ".js":
 		return "reload"
+	case ".go":
+		return "go"
 	default:
 		return ""
 	}
 }
 
+// isTempFile reports whether name looks like an editor swap, backup or
+// lock file, which come and go on every save.
+func isTempFile(name string) bool {
+	base := filepath.Base(name)
+	switch {
+	case strings.HasSuffix(base, "~"),
+		strings.HasPrefix(base, ".#"),
+		strings.HasPrefix(base, "#") && strings.HasSuffix(base, "#"),
+		base == "4913": // vim's write-permission probe
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".swp", ".swo", ".swx", ".tmp", ".bak":
+		return true
+	}
+	return false
+}
+
 func merge(current, incoming string) string {
-	rank := map[string]int{"css": 1, "tmpl": 2, "reload": 3}
+	rank := map[string]int{"css": 1, "tmpl": 2, "reload": 3, "go": 4}
 	if rank[incoming] > rank[current] {
 		return incoming
 	}
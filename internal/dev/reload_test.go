@@ -0,0 +1,84 @@
+package dev
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"web/static/css/base.css", "css"},
+		{"web/templates/pages/home.tmpl", "tmpl"},
+		{"web/static/js/vote.js", "reload"},
+		{"WEB/STATIC/APP.JS", "reload"},
+		{"internal/app/home.go", "go"},
+		{"README.md", ""},
+		{"web/static/logo.png", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, classify(tt.name), tt.name)
+	}
+}
+
+func TestClassifyIgnoresTempFiles(t *testing.T) {
+	for _, name := range []string{
+		"web/templates/pages/home.tmpl~",
+		"web/templates/pages/.home.tmpl.swp",
+		"web/templates/pages/.home.tmpl.swo",
+		"web/static/css/base.css.tmp",
+		"web/static/css/#base.css#",
+		"web/static/css/.#base.css",
+		"internal/app/home.go.bak",
+		"internal/app/4913",
+	} {
+		assert.Empty(t, classify(name), name)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	assert.Equal(t, "tmpl", merge("css", "tmpl"))
+	assert.Equal(t, "reload", merge("reload", "css"))
+	assert.Equal(t, "go", merge("reload", "go"))
+	assert.Equal(t, "css", merge("", "css"))
+}
+
+func TestNewReloaderSkipsIgnoredDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"app/sub", "node_modules/pkg", "vendor/mod", "app/testdata", ".git/objects"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+
+	r, err := NewReloader([]string{root}, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.ElementsMatch(t, []string{
+		root,
+		filepath.Join(root, "app"),
+		filepath.Join(root, "app", "sub"),
+	}, r.watcher.WatchList())
+}
+
+func TestWatchTreeCap(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, dir), 0o755))
+	}
+
+	r, err := NewReloader(nil, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	defer r.Close()
+	r.maxDirs = 2
+
+	require.NoError(t, r.watchTree(root))
+	assert.Len(t, r.watcher.WatchList(), 2)
+	assert.True(t, r.capWarned)
+}
@@ -10,29 +10,42 @@ import (
 	"time"
 )
 
+// DefaultFromName is the sender display name used when none is configured.
+const DefaultFromName = "Crow Watch"
+
 type Sender struct {
 	host      string
 	token     string
 	fromEmail string
+	fromName  string
+	replyTo   string
 	client    *http.Client
 	log       *slog.Logger
 }
 
-func NewSender(host, token, fromEmail string, log *slog.Logger) *Sender {
+// NewSender creates a ZeptoMail sender. An empty fromName falls back to
+// DefaultFromName; an empty replyTo sends no Reply-To.
+func NewSender(host, token, fromEmail, fromName, replyTo string, log *slog.Logger) *Sender {
+	if fromName == "" {
+		fromName = DefaultFromName
+	}
 	return &Sender{
 		host:      host,
 		token:     token,
 		fromEmail: fromEmail,
+		fromName:  fromName,
+		replyTo:   replyTo,
 		client:    &http.Client{Timeout: 30 * time.Second},
 		log:       log,
 	}
 }
 
 type zeptoRequest struct {
-	From    zeptoAddress `json:"from"`
-	To      []zeptoTo    `json:"to"`
-	Subject string       `json:"subject"`
-	HTML    string       `json:"htmlbody"`
+	From    zeptoAddress   `json:"from"`
+	To      []zeptoTo      `json:"to"`
+	Subject string         `json:"subject"`
+	HTML    string         `json:"htmlbody"`
+	ReplyTo []zeptoAddress `json:"reply_to,omitempty"`
 }
 
 type zeptoAddress struct {
@@ -46,11 +59,14 @@ type zeptoTo struct {
 
 func (s *Sender) Send(ctx context.Context, to, subject, htmlBody string) error {
 	payload := zeptoRequest{
-		From:    zeptoAddress{Address: s.fromEmail, Name: "Crow Watch"},
+		From:    zeptoAddress{Address: s.fromEmail, Name: s.fromName},
 		To:      []zeptoTo{{EmailAddress: zeptoAddress{Address: to}}},
 		Subject: subject,
 		HTML:    htmlBody,
 	}
+	if s.replyTo != "" {
+		payload.ReplyTo = []zeptoAddress{{Address: s.replyTo}}
+	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSender returns a Sender posting to a local server and a function
+// returning the last payload it received.
+func testSender(t *testing.T, fromName, replyTo string) (*Sender, func() map[string]any) {
+	t.Helper()
+	var last map[string]any
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1.1/email", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	s := NewSender(host, "secret", "noreply@example.com", fromName, replyTo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.client = srv.Client()
+	return s, func() map[string]any { return last }
+}
+
+func TestSendFromNameAndReplyTo(t *testing.T) {
+	s, payload := testSender(t, "Example Support", "support@example.com")
+	require.NoError(t, s.Send(context.Background(), "alice@example.com", "Hi", "<p>Hi</p>"))
+
+	got := payload()
+	assert.Equal(t, map[string]any{"address": "noreply@example.com", "name": "Example Support"}, got["from"])
+	assert.Equal(t, []any{map[string]any{"address": "support@example.com"}}, got["reply_to"])
+}
+
+func TestSendDefaults(t *testing.T) {
+	s, payload := testSender(t, "", "")
+	require.NoError(t, s.Send(context.Background(), "alice@example.com", "Hi", "<p>Hi</p>"))
+
+	got := payload()
+	assert.Equal(t, map[string]any{"address": "noreply@example.com", "name": DefaultFromName}, got["from"])
+	assert.NotContains(t, got, "reply_to")
+}
@@ -0,0 +1,123 @@
+// Package flagreason holds the configurable lists of reasons users may
+// give when flagging a story or comment, and how much each one weighs.
+package flagreason
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reason is a flag reason and its downvote weight.
+type Reason struct {
+	Name   string
+	Weight int32
+}
+
+// List is an ordered set of flag reasons, in the order they are offered.
+type List []Reason
+
+// AlreadyPosted is the story flag reason that may name the original
+// story being reposted.
+const AlreadyPosted = "already posted"
+
+// DefaultStory is used when no story flag reasons are configured.
+var DefaultStory = List{
+	{Name: "off-topic", Weight: 1},
+	{Name: AlreadyPosted, Weight: 1},
+	{Name: "broken link", Weight: 1},
+	{Name: "spam", Weight: 2},
+}
+
+// DefaultComment is used when no comment flag reasons are configured.
+var DefaultComment = List{
+	{Name: "off-topic", Weight: 1},
+	{Name: "troll", Weight: 1},
+	{Name: "unkind", Weight: 1},
+	{Name: "spam", Weight: 2},
+}
+
+// Parse reads a comma-separated list of reasons, each optionally followed
+// by ":weight", e.g. "off-topic:1,spam:3". Weight defaults to 1.
+func Parse(spec string) (List, error) {
+	var list List
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weightStr, hasWeight := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("flag reason %q: empty name", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("flag reason %q: duplicate", name)
+		}
+		weight := int32(1)
+		if hasWeight {
+			n, err := strconv.ParseInt(strings.TrimSpace(weightStr), 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("flag reason %q: weight must be a non-negative integer", name)
+			}
+			weight = int32(n)
+		}
+		seen[name] = true
+		list = append(list, Reason{Name: name, Weight: weight})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no flag reasons in %q", spec)
+	}
+	return list, nil
+}
+
+// ParseOrDefault parses spec, returning def when spec is empty.
+func ParseOrDefault(spec string, def List) (List, error) {
+	if strings.TrimSpace(spec) == "" {
+		return def, nil
+	}
+	return Parse(spec)
+}
+
+// Names returns the reason names in order.
+func (l List) Names() []string {
+	names := make([]string, len(l))
+	for i, r := range l {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// Contains reports whether name is one of the reasons.
+func (l List) Contains(name string) bool {
+	for _, r := range l {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Weight returns the weight of the named reason, or 1 when it is not in
+// the list.
+func (l List) Weight(name string) int32 {
+	for _, r := range l {
+		if r.Name == name {
+			return r.Weight
+		}
+	}
+	return 1
+}
+
+// Weights returns parallel name and weight slices, the shape the
+// downvote recalculation queries take.
+func (l List) Weights() ([]string, []int32) {
+	names := make([]string, len(l))
+	weights := make([]int32, len(l))
+	for i, r := range l {
+		names[i] = r.Name
+		weights[i] = r.Weight
+	}
+	return names, weights
+}
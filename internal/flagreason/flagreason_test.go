@@ -0,0 +1,64 @@
+package flagreason
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	list, err := Parse("off-topic, already posted:1 ,spam:3")
+	require.NoError(t, err)
+	assert.Equal(t, List{
+		{Name: "off-topic", Weight: 1},
+		{Name: "already posted", Weight: 1},
+		{Name: "spam", Weight: 3},
+	}, list)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", " , ", "spam:x", "spam:-1", ":2", "spam,spam"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, "%q", spec)
+	}
+}
+
+func TestParseOrDefault(t *testing.T) {
+	list, err := ParseOrDefault("  ", DefaultStory)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStory, list)
+
+	_, err = ParseOrDefault("spam:nope", DefaultStory)
+	assert.Error(t, err)
+}
+
+func TestContainsRejectsUnknown(t *testing.T) {
+	list, err := Parse("off-topic,spam:3")
+	require.NoError(t, err)
+	assert.True(t, list.Contains("spam"))
+	assert.False(t, list.Contains("broken link"), "not configured")
+	assert.False(t, list.Contains(""))
+}
+
+func TestWeights(t *testing.T) {
+	list, err := Parse("off-topic:1,spam:3")
+	require.NoError(t, err)
+
+	names, weights := list.Weights()
+	assert.Equal(t, []string{"off-topic", "spam"}, names)
+	assert.Equal(t, []int32{1, 3}, weights)
+	assert.Equal(t, []string{"off-topic", "spam"}, list.Names())
+	assert.Equal(t, int32(3), list.Weight("spam"))
+	assert.Equal(t, int32(1), list.Weight("unknown"))
+}
+
+func TestDefaultsWeighSpamHeavier(t *testing.T) {
+	for _, list := range []List{DefaultStory, DefaultComment} {
+		weight := map[string]int32{}
+		for _, r := range list {
+			weight[r.Name] = r.Weight
+		}
+		assert.Greater(t, weight["spam"], weight["off-topic"])
+	}
+}
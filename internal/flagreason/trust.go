@@ -0,0 +1,73 @@
+package flagreason
+
+import (
+	"math"
+	"time"
+)
+
+// Trust scales each flag by how much the flagger is trusted, so a handful
+// of fresh accounts can't brigade a story down. Percentages are relative
+// to an ordinary account's flag, which counts 100. The zero value weighs
+// every flagger equally.
+type Trust struct {
+	// NewAccountAge marks accounts younger than this as new. Flags from
+	// accounts that haven't confirmed their e-mail never count.
+	NewAccountAge time.Duration
+	NewPercent    int32
+
+	// TrustedAge and TrustedKarma are both required to be trusted; karma
+	// is the upvotes received on the user's live stories and comments.
+	// Moderators are always trusted.
+	TrustedAge     time.Duration
+	TrustedKarma   int32
+	TrustedPercent int32
+
+	// MaxUserWeight caps, in whole downvotes, what one user's flag can
+	// add to a story. Zero means no cap.
+	MaxUserWeight int32
+}
+
+// DefaultTrust is used unless flag weighting is turned off.
+var DefaultTrust = Trust{
+	NewAccountAge:  30 * 24 * time.Hour,
+	NewPercent:     50,
+	TrustedAge:     180 * 24 * time.Hour,
+	TrustedKarma:   50,
+	TrustedPercent: 200,
+	MaxUserWeight:  3,
+}
+
+// TrustParams is Trust resolved against the current time, the shape the
+// downvote recalculation queries take.
+type TrustParams struct {
+	WeighFlaggers  bool
+	NewBefore      time.Time
+	NewPercent     int32
+	TrustedBefore  time.Time
+	TrustedKarma   int32
+	TrustedPercent int32
+	MaxPercent     int32
+}
+
+// Params resolves t for a recalculation run at now.
+func (t Trust) Params(now time.Time) TrustParams {
+	if t == (Trust{}) {
+		return TrustParams{TrustedPercent: 100, MaxPercent: math.MaxInt32}
+	}
+	p := TrustParams{
+		WeighFlaggers:  true,
+		NewBefore:      now.Add(-t.NewAccountAge),
+		NewPercent:     t.NewPercent,
+		TrustedBefore:  now.Add(-t.TrustedAge),
+		TrustedKarma:   t.TrustedKarma,
+		TrustedPercent: t.TrustedPercent,
+		MaxPercent:     math.MaxInt32,
+	}
+	if p.TrustedPercent <= 0 {
+		p.TrustedPercent = 100
+	}
+	if t.MaxUserWeight > 0 {
+		p.MaxPercent = t.MaxUserWeight * 100
+	}
+	return p
+}
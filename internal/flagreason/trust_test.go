@@ -0,0 +1,34 @@
+package flagreason
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustZeroValueWeighsEveryoneEqually(t *testing.T) {
+	p := Trust{}.Params(time.Now())
+	assert.False(t, p.WeighFlaggers)
+	assert.Equal(t, int32(100), p.TrustedPercent)
+	assert.Equal(t, int32(math.MaxInt32), p.MaxPercent)
+}
+
+func TestTrustParams(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := DefaultTrust.Params(now)
+	assert.True(t, p.WeighFlaggers)
+	assert.Equal(t, now.AddDate(0, 0, -30), p.NewBefore)
+	assert.Equal(t, now.AddDate(0, 0, -180), p.TrustedBefore)
+	assert.Equal(t, int32(50), p.NewPercent)
+	assert.Equal(t, int32(200), p.TrustedPercent)
+	assert.Equal(t, int32(300), p.MaxPercent)
+}
+
+func TestTrustParamsUncapped(t *testing.T) {
+	p := Trust{NewAccountAge: time.Hour}.Params(time.Now())
+	assert.True(t, p.WeighFlaggers)
+	assert.Equal(t, int32(100), p.TrustedPercent, "unset trusted percent counts as ordinary")
+	assert.Equal(t, int32(math.MaxInt32), p.MaxPercent)
+}
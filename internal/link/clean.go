@@ -39,10 +39,24 @@ var trackingParams = map[string]bool{
 	"trk":          true,
 }
 
+// Config is the scheme and port policy links must meet. The zero value
+// accepts http and https on any port.
+type Config struct {
+	RequireHTTPS     bool // reject plain http links
+	DefaultPortsOnly bool // reject explicit ports other than 80 for http and 443 for https
+}
+
+// Clean validates and cleans raw under the permissive default policy.
 func Clean(raw string) (CleanResult, error) {
+	return Config{}.Clean(raw)
+}
+
+// Clean validates raw against the policy, strips tracking parameters and
+// computes the normalized form used to detect duplicates.
+func (c Config) Clean(raw string) (CleanResult, error) {
 	raw = strings.TrimSpace(raw)
 
-	u, err := validate(raw)
+	u, err := c.validate(raw)
 	if err != nil {
 		return CleanResult{}, err
 	}
@@ -71,7 +85,7 @@ func Clean(raw string) (CleanResult, error) {
 	}, nil
 }
 
-func validate(raw string) (*url.URL, error) {
+func (c Config) validate(raw string) (*url.URL, error) {
 	if raw == "" {
 		return nil, &ValidationError{Field: "url", Message: "URL is required"}
 	}
@@ -89,6 +103,12 @@ func validate(raw string) (*url.URL, error) {
 	if scheme != "http" && scheme != "https" {
 		return nil, &ValidationError{Field: "url", Message: "URL must use http or https"}
 	}
+	if c.RequireHTTPS && scheme != "https" {
+		return nil, &ValidationError{Field: "url", Message: "URL must use https"}
+	}
+	if c.DefaultPortsOnly && u.Port() != "" && !defaultPort(scheme, u.Port()) {
+		return nil, &ValidationError{Field: "url", Message: "URL must not specify a port"}
+	}
 
 	host := u.Hostname()
 	if host == "" || !strings.Contains(host, ".") {
@@ -110,6 +130,10 @@ func stripTracking(u *url.URL) {
 	if changed {
 		u.RawQuery = q.Encode()
 	}
+	if u.RawQuery == "" {
+		// Drop the "?" of "/page?" along with an emptied query.
+		u.ForceQuery = false
+	}
 }
 
 func normalizePort(u *url.URL) {
@@ -117,12 +141,15 @@ func normalizePort(u *url.URL) {
 	if port == "" {
 		return
 	}
-	scheme := strings.ToLower(u.Scheme)
-	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+	if defaultPort(strings.ToLower(u.Scheme), port) {
 		u.Host = u.Hostname()
 	}
 }
 
+func defaultPort(scheme, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
+
 func normalize(u *url.URL) {
 	// Force https
 	u.Scheme = "https"
@@ -164,6 +191,7 @@ func normalize(u *url.URL) {
 		}
 		u.RawQuery = sorted.Encode()
 	}
+	u.ForceQuery = false
 
 	// Normalize path
 	path := u.Path
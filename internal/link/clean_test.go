@@ -55,6 +55,20 @@ func TestClean_TrackingParams(t *testing.T) {
 	}
 }
 
+func TestClean_EmptyQuery(t *testing.T) {
+	for _, input := range []string{
+		"https://example.com/page?",
+		"https://example.com/page?utm_source=twitter&utm_medium=social",
+	} {
+		t.Run(input, func(t *testing.T) {
+			result, err := Clean(input)
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/page", result.Cleaned)
+			assert.Equal(t, "https://example.com/page", result.Normalized)
+		})
+	}
+}
+
 func TestClean_PortNormalization(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -95,6 +109,10 @@ func TestClean_Normalization(t *testing.T) {
 		{"removes /Default.aspx", "https://example.com/section/Default.aspx", "https://example.com/section"},
 		{"sorts query params", "https://example.com/page?z=1&a=2", "https://example.com/page?a=2&z=1"},
 		{"lowercases host", "https://EXAMPLE.COM/Page", "https://example.com/Page"},
+		{"drops trailing question mark", "https://example.com/page?", "https://example.com/page"},
+		{"drops question mark before fragment", "https://example.com/page?#top", "https://example.com/page"},
+		{"drops query of only tracking params", "https://example.com/page?utm_source=x&fbclid=y", "https://example.com/page"},
+		{"drops query of only separators", "https://example.com/page?&", "https://example.com/page"},
 	}
 
 	for _, tt := range tests {
@@ -150,6 +168,27 @@ func TestClean_YouTube(t *testing.T) {
 	}
 }
 
+func TestEmbedURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"normalized watch", "https://youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"short url", "https://youtu.be/dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"mobile", "https://m.youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"channel page", "https://youtube.com/@someone", ""},
+		{"bad id", "https://youtube.com/watch?v=short", ""},
+		{"generic link", "https://example.com/watch?v=dQw4w9WgXcQ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, EmbedURL(tt.input))
+		})
+	}
+}
+
 func TestClean_RFC(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -230,3 +269,34 @@ func TestClean_FullFlow(t *testing.T) {
 	assert.Equal(t, "example.com", result.Domain)
 	assert.Equal(t, "", result.Origin)
 }
+
+func TestConfig_Clean(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		input   string
+		wantErr string
+	}{
+		{"default allows http", Config{}, "http://example.com/page", ""},
+		{"default allows any port", Config{}, "https://example.com:8080/page", ""},
+		{"https only rejects http", Config{RequireHTTPS: true}, "http://example.com/page", "URL must use https"},
+		{"https only allows https", Config{RequireHTTPS: true}, "https://example.com/page", ""},
+		{"port policy rejects 8080", Config{DefaultPortsOnly: true}, "https://example.com:8080/page", "URL must not specify a port"},
+		{"port policy allows default port", Config{DefaultPortsOnly: true}, "https://example.com:443/page", ""},
+		{"port policy allows no port", Config{DefaultPortsOnly: true}, "http://example.com/page", ""},
+		{"port policy rejects swapped default", Config{DefaultPortsOnly: true}, "http://example.com:443/page", "URL must not specify a port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.Clean(tt.input)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			var ve *ValidationError
+			require.ErrorAs(t, err, &ve)
+			assert.Equal(t, tt.wantErr, ve.Message)
+		})
+	}
+}
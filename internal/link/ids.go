@@ -0,0 +1,51 @@
+package link
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// DefaultShortCodeLength is the number of characters in a story short
+// code unless configured otherwise. It is also the shortest length allowed,
+// so codes issued before a site raised its length stay valid.
+const DefaultShortCodeLength = 6
+
+// MaxShortCodeLength is the longest short code the stories table can hold.
+const MaxShortCodeLength = 16
+
+const shortCodeCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// maxUnbiased is the largest multiple of the charset size that fits in a
+// byte. Random bytes at or above it are discarded, so every character is
+// equally likely instead of the first few being favored by the modulo.
+const maxUnbiased = 256 - 256%len(shortCodeCharset)
+
+// ShortCode returns a random n-character base36 story code.
+func ShortCode(n int) string {
+	code, err := ShortCodeFrom(rand.Reader, n)
+	if err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return code
+}
+
+// ShortCodeFrom builds an n-character base36 code from the bytes of r.
+func ShortCodeFrom(r io.Reader, n int) (string, error) {
+	code := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(code) < n {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= maxUnbiased {
+				continue
+			}
+			code = append(code, shortCodeCharset[int(b)%len(shortCodeCharset)])
+			if len(code) == n {
+				break
+			}
+		}
+	}
+	return string(code), nil
+}
@@ -0,0 +1,50 @@
+package link
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortCode(t *testing.T) {
+	code := ShortCode(DefaultShortCodeLength)
+	assert.Len(t, code, DefaultShortCodeLength)
+	for _, c := range code {
+		assert.Contains(t, shortCodeCharset, string(c))
+	}
+
+	// Two codes should (almost certainly) differ
+	assert.NotEqual(t, code, ShortCode(DefaultShortCodeLength))
+
+	assert.Len(t, ShortCode(10), 10)
+}
+
+func TestShortCodeFromSkipsBiasedBytes(t *testing.T) {
+	// 252..255 would wrap around to "a".."d" under a plain modulo.
+	r := bytes.NewReader([]byte{252, 253, 254, 255, 0, 35, 36, 251})
+	code, err := ShortCodeFrom(r, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "a9a9", code)
+}
+
+func TestShortCodeFromUniform(t *testing.T) {
+	// Every byte value once: each character must come out exactly
+	// maxUnbiased/36 times.
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	code, err := ShortCodeFrom(bytes.NewReader(all), maxUnbiased)
+	require.NoError(t, err)
+	for _, c := range shortCodeCharset {
+		assert.Equal(t, maxUnbiased/len(shortCodeCharset), strings.Count(code, string(c)), "char %q", c)
+	}
+}
+
+func TestShortCodeFromShortRead(t *testing.T) {
+	_, err := ShortCodeFrom(bytes.NewReader([]byte{255, 255}), 2)
+	assert.Error(t, err)
+}
@@ -33,45 +33,59 @@ func normalizeArxiv(u *url.URL) {
 }
 
 func normalizeYouTube(u *url.URL) {
-	var videoID string
+	videoID := youtubeVideoID(u)
+	if videoID == "" {
+		return
+	}
 
-	host := u.Hostname()
+	u.Host = "youtube.com"
+	u.Path = "/watch"
+	u.RawQuery = "v=" + videoID
+}
+
+// youtubeVideoID extracts the video ID from a youtube.com or youtu.be URL,
+// or returns "" if the URL doesn't point at a video.
+func youtubeVideoID(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
 	if host == "youtu.be" {
 		// youtu.be/<id>
 		id := strings.TrimPrefix(u.Path, "/")
 		if youtubeIDRegex.MatchString(id) {
-			videoID = id
-		}
-	} else {
-		// youtube.com paths
-		switch {
-		case strings.HasPrefix(u.Path, "/embed/"):
-			id := strings.TrimPrefix(u.Path, "/embed/")
-			id = strings.Split(id, "/")[0]
-			if youtubeIDRegex.MatchString(id) {
-				videoID = id
-			}
-		case strings.HasPrefix(u.Path, "/shorts/"):
-			id := strings.TrimPrefix(u.Path, "/shorts/")
-			id = strings.Split(id, "/")[0]
-			if youtubeIDRegex.MatchString(id) {
-				videoID = id
-			}
-		case u.Path == "/watch":
-			id := u.Query().Get("v")
-			if youtubeIDRegex.MatchString(id) {
-				videoID = id
-			}
+			return id
 		}
+		return ""
+	}
+	if host != "youtube.com" && host != "m.youtube.com" {
+		return ""
 	}
 
-	if videoID == "" {
-		return
+	// youtube.com paths
+	var id string
+	switch {
+	case strings.HasPrefix(u.Path, "/embed/"):
+		id = strings.Split(strings.TrimPrefix(u.Path, "/embed/"), "/")[0]
+	case strings.HasPrefix(u.Path, "/shorts/"):
+		id = strings.Split(strings.TrimPrefix(u.Path, "/shorts/"), "/")[0]
+	case u.Path == "/watch":
+		id = u.Query().Get("v")
+	}
+	if youtubeIDRegex.MatchString(id) {
+		return id
 	}
+	return ""
+}
 
-	u.Host = "youtube.com"
-	u.Path = "/watch"
-	u.RawQuery = "v=" + videoID
+// EmbedURL returns a privacy-respecting player URL for links to known media
+// hosts, or "" if the link can't be embedded.
+func EmbedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if id := youtubeVideoID(u); id != "" {
+		return "https://www.youtube-nocookie.com/embed/" + id
+	}
+	return ""
 }
 
 func normalizeRFC(u *url.URL) {
@@ -21,6 +21,19 @@ func WilsonScore(upvotes, downvotes int) float64 {
 	return (phat + z*z/(2*n) - z*math.Sqrt((phat*(1-phat)+z*z/(4*n))/n)) / (1 + z*z/n)
 }
 
+// Controversy scores how contested an item is. It is zero when either side
+// has no votes and grows with the total number of votes, scaled by how
+// evenly they are split: an even split counts the full total, a lopsided
+// one shrinks towards 1.
+func Controversy(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	magnitude := float64(upvotes + downvotes)
+	balance := float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	return math.Pow(magnitude, balance)
+}
+
 type TagInput struct {
 	HotnessMod float64
 }
@@ -31,6 +44,12 @@ type StoryInput struct {
 	Tags          []TagInput
 	StoryScore    int
 	CommentsCount int
+	// Upvotes and Downvotes break score ties in SortByScore.
+	Upvotes   int
+	Downvotes int
+	// Penalty pushes the story down in hotness ranking, in the same units
+	// as tag hotness mods (e.g. for submitters still on probation).
+	Penalty float64
 }
 
 type ScoredStory struct {
@@ -92,7 +111,7 @@ func ComputeAge(createdAt time.Time, windowSeconds float64) float64 {
 }
 
 // ComputeHotness calculates the full hotness score for a story.
-// hotness = -1 * (base + order * sign + age)
+// hotness = -1 * (base - penalty + order * sign + age)
 // Lower (more negative) hotness values rank higher.
 func ComputeHotness(story StoryInput, windowSeconds float64) ScoredStory {
 	base := ComputeBase(story.Tags)
@@ -100,7 +119,7 @@ func ComputeHotness(story StoryInput, windowSeconds float64) ScoredStory {
 	order := ComputeOrder(story.StoryScore, cpoints)
 	sign := ComputeSign(story.StoryScore)
 	age := ComputeAge(story.CreatedAt, windowSeconds)
-	hotness := -1 * (base + order*float64(sign) + age)
+	hotness := -1 * (base - story.Penalty + order*float64(sign) + age)
 
 	return ScoredStory{
 		StoryInput: story,
@@ -124,3 +143,23 @@ func SortStories(stories []StoryInput, windowSeconds float64) []ScoredStory {
 	})
 	return scored
 }
+
+// SortByScore returns stories ordered by net score, highest first, with no
+// time decay. Equal scores go to the higher Wilson lower bound, so a story
+// with fewer disputed votes wins, then to the newer story.
+func SortByScore(stories []StoryInput) []StoryInput {
+	sorted := make([]StoryInput, len(stories))
+	copy(sorted, stories)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.StoryScore != b.StoryScore {
+			return a.StoryScore > b.StoryScore
+		}
+		wa, wb := WilsonScore(a.Upvotes, a.Downvotes), WilsonScore(b.Upvotes, b.Downvotes)
+		if wa != wb {
+			return wa > wb
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return sorted
+}
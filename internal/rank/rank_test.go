@@ -36,6 +36,34 @@ func TestWilsonScore(t *testing.T) {
 	}
 }
 
+func TestControversy(t *testing.T) {
+	tests := []struct {
+		name      string
+		upvotes   int
+		downvotes int
+		want      float64
+	}{
+		{"zero votes", 0, 0, 0},
+		{"only upvotes", 10, 0, 0},
+		{"only downvotes", 0, 10, 0},
+		{"balanced small", 2, 2, 4},
+		{"balanced large", 50, 50, 100},
+		{"one-sided", 10, 1, 1.2710},
+		{"reversed one-sided", 1, 10, 1.2710},
+		{"mostly balanced", 6, 4, 4.6416},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Controversy(tt.upvotes, tt.downvotes), 0.001)
+		})
+	}
+}
+
+func TestControversyOrdering(t *testing.T) {
+	assert.Greater(t, Controversy(5, 5), Controversy(9, 1), "balanced beats one-sided with the same total")
+	assert.Greater(t, Controversy(20, 20), Controversy(5, 5), "more votes at the same balance is more controversial")
+}
+
 func TestWilsonScoreProperties(t *testing.T) {
 	t.Run("result always in [0, 1]", func(t *testing.T) {
 		cases := [][2]int{{0, 0}, {1, 0}, {0, 1}, {100, 0}, {0, 100}, {50, 50}, {1000, 1}}
@@ -204,6 +232,13 @@ func TestComputeHotness(t *testing.T) {
 		hTag := ComputeHotness(StoryInput{ID: 2, CreatedAt: now, Tags: []TagInput{{-2.0}}, StoryScore: 10}, window)
 		assert.Greater(t, hTag.Hotness, hNoTag.Hotness, "negative tag mod should produce less negative hotness (ranks lower)")
 	})
+
+	t.Run("penalty ranks lower without touching comment points", func(t *testing.T) {
+		plain := ComputeHotness(StoryInput{ID: 1, CreatedAt: now, StoryScore: 10, CommentsCount: 5}, window)
+		penalized := ComputeHotness(StoryInput{ID: 2, CreatedAt: now, StoryScore: 10, CommentsCount: 5, Penalty: 0.5}, window)
+		assert.InDelta(t, plain.Hotness+0.5, penalized.Hotness, 0.0001)
+		assert.Equal(t, plain.Cpoints, penalized.Cpoints)
+	})
 }
 
 // --- B) Property tests ---
@@ -323,3 +358,24 @@ func TestRankStoriesSnapshot(t *testing.T) {
 	require.NoError(t, json.Unmarshal(data, &roundtripped))
 	assert.Equal(t, ids, roundtripped)
 }
+
+func TestSortByScore(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	stories := []StoryInput{
+		{ID: 1, CreatedAt: now, StoryScore: 3, Upvotes: 3},
+		{ID: 2, CreatedAt: now.Add(-48 * time.Hour), StoryScore: 20, Upvotes: 20},
+		// Same score as 4, but with contested votes.
+		{ID: 3, CreatedAt: now, StoryScore: 5, Upvotes: 15, Downvotes: 10},
+		{ID: 4, CreatedAt: now.Add(-time.Hour), StoryScore: 5, Upvotes: 5},
+		// Same score and votes as 4, but newer.
+		{ID: 5, CreatedAt: now.Add(-time.Minute), StoryScore: 5, Upvotes: 5},
+	}
+
+	sorted := SortByScore(stories)
+	ids := make([]int64, len(sorted))
+	for i, s := range sorted {
+		ids[i] = s.ID
+	}
+	assert.Equal(t, []int64{2, 5, 4, 3, 1}, ids, "no time decay: the old high scorer leads")
+	assert.Equal(t, int64(1), stories[0].ID, "input is left untouched")
+}
@@ -15,6 +15,7 @@ type Limiter struct {
 	entries map[string]*entry
 	max     int
 	window  time.Duration
+	now     func() time.Time
 }
 
 // New creates a Limiter that allows max attempts per window.
@@ -23,6 +24,7 @@ func New(max int, window time.Duration) *Limiter {
 		entries: make(map[string]*entry),
 		max:     max,
 		window:  window,
+		now:     time.Now,
 	}
 }
 
@@ -33,7 +35,7 @@ func (l *Limiter) Allow(key string) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	now := time.Now()
+	now := l.now()
 	cutoff := now.Add(-l.window)
 
 	e, ok := l.entries[key]
@@ -59,6 +61,55 @@ func (l *Limiter) Allow(key string) bool {
 	return true
 }
 
+// RetryAfter reports how long until key may make another attempt. It is
+// zero when the key is currently allowed, otherwise the time until the
+// oldest attempt in the window expires.
+func (l *Limiter) RetryAfter(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+
+	var valid []time.Time
+	for _, t := range e.timestamps {
+		if t.After(cutoff) {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) < l.max {
+		return 0
+	}
+
+	// Timestamps are appended in order, so the oldest ones free up first.
+	return valid[len(valid)-l.max].Add(l.window).Sub(now)
+}
+
+// Count reports how many attempts key has recorded within the window.
+func (l *Limiter) Count(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	cutoff := l.now().Add(-l.window)
+	n := 0
+	for _, t := range e.timestamps {
+		if t.After(cutoff) {
+			n++
+		}
+	}
+	return n
+}
+
 // Reset clears all recorded attempts for a key (e.g. on successful login).
 func (l *Limiter) Reset(key string) {
 	l.mu.Lock()
@@ -71,7 +122,7 @@ func (l *Limiter) Cleanup() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	now := time.Now()
+	now := l.now()
 	cutoff := now.Add(-l.window)
 
 	for key, e := range l.entries {
@@ -39,6 +39,21 @@ func TestReset(t *testing.T) {
 	assert.True(t, l.Allow("k")) // allowed again after reset
 }
 
+func TestCount(t *testing.T) {
+	now := time.Now()
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+	assert.Equal(t, 0, l.Count("k"))
+
+	l.Allow("k")
+	l.Allow("k")
+	assert.Equal(t, 2, l.Count("k"))
+	assert.Equal(t, 0, l.Count("other"))
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 0, l.Count("k"), "expired attempts are not counted")
+}
+
 func TestCleanup(t *testing.T) {
 	l := New(5, 10*time.Millisecond)
 	l.Allow("a")
@@ -96,3 +111,35 @@ func TestStartCleanup(t *testing.T) {
 
 	assert.Equal(t, 0, count, "background cleanup should remove stale entries")
 }
+
+func TestRetryAfter_ZeroWhenAllowed(t *testing.T) {
+	l := New(2, time.Minute)
+	assert.Zero(t, l.RetryAfter("k"), "unknown key")
+
+	require.True(t, l.Allow("k"))
+	assert.Zero(t, l.RetryAfter("k"), "below the limit")
+}
+
+func TestRetryAfter_ShrinksAsWindowAges(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(2, 10*time.Minute)
+	l.now = func() time.Time { return now }
+
+	require.True(t, l.Allow("k"))
+	now = now.Add(3 * time.Minute)
+	require.True(t, l.Allow("k"))
+	require.False(t, l.Allow("k"))
+
+	// The first attempt expires 10 minutes after it was made.
+	assert.Equal(t, 7*time.Minute, l.RetryAfter("k"))
+
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, 2*time.Minute, l.RetryAfter("k"))
+
+	now = now.Add(2 * time.Minute)
+	assert.Zero(t, l.RetryAfter("k"), "oldest attempt has left the window")
+	assert.True(t, l.Allow("k"))
+
+	// Now the second attempt is the oldest one holding the limit.
+	assert.Equal(t, 3*time.Minute, l.RetryAfter("k"))
+}
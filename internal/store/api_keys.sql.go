@@ -71,6 +71,9 @@ SELECT
     u.unconfirmed_email,
     u.website,
     u.about,
+    u.slogan,
+    u.last_seen_at,
+    u.enable_embeds,
     u.created_at,
     u.updated_at
 FROM api_keys ak
@@ -97,6 +100,9 @@ type GetAPIKeyUserByTokenHashRow struct {
 	UnconfirmedEmail                pgtype.Text
 	Website                         string
 	About                           string
+	Slogan                          string
+	LastSeenAt                      pgtype.Timestamptz
+	EnableEmbeds                    bool
 	CreatedAt                       pgtype.Timestamptz
 	UpdatedAt                       pgtype.Timestamptz
 }
@@ -123,6 +129,9 @@ func (q *Queries) GetAPIKeyUserByTokenHash(ctx context.Context, tokenHash string
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
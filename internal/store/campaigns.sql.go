@@ -85,6 +85,49 @@ func (q *Queries) GetActiveCampaignBySlug(ctx context.Context, slug string) (Get
 	return i, err
 }
 
+const listCampaignSignups = `-- name: ListCampaignSignups :many
+SELECT campaign, username, created_at
+FROM (
+    SELECT
+        campaign,
+        username,
+        created_at,
+        row_number() OVER (PARTITION BY campaign ORDER BY created_at DESC, id DESC) AS rn
+    FROM users
+    WHERE campaign <> ''
+) AS signups
+WHERE rn <= $1::int
+ORDER BY campaign, created_at DESC
+`
+
+type ListCampaignSignupsRow struct {
+	Campaign  string
+	Username  string
+	CreatedAt pgtype.Timestamptz
+}
+
+// The most recent signups of each campaign, matched the same way as
+// ListCampaigns' registered_count.
+func (q *Queries) ListCampaignSignups(ctx context.Context, perCampaign int32) ([]ListCampaignSignupsRow, error) {
+	rows, err := q.db.Query(ctx, listCampaignSignups, perCampaign)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCampaignSignupsRow
+	for rows.Next() {
+		var i ListCampaignSignupsRow
+		if err := rows.Scan(&i.Campaign, &i.Username, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listCampaigns = `-- name: ListCampaigns :many
 SELECT
     c.id, c.slug, c.welcome_message, c.sponsor_id, c.created_by_id, c.active, c.created_at, c.updated_at,
@@ -9,14 +9,47 @@ import (
 	"context"
 )
 
+const clearCommentFlags = `-- name: ClearCommentFlags :many
+WITH del AS (
+    DELETE FROM comment_flags
+    WHERE comment_id = ANY($1::bigint[])
+    RETURNING comment_id, weight
+)
+UPDATE comments AS c SET downvotes = c.downvotes - d.flags
+FROM (SELECT comment_id, sum(weight)::int AS flags FROM del GROUP BY comment_id) AS d
+WHERE c.id = d.comment_id
+RETURNING c.id
+`
+
+// Each removed flag gives back the downvotes it cost the comment.
+func (q *Queries) ClearCommentFlags(ctx context.Context, commentIds []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, clearCommentFlags, commentIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createCommentFlag = `-- name: CreateCommentFlag :one
 WITH ins AS (
-    INSERT INTO comment_flags (user_id, comment_id, reason)
-    VALUES ($2, $1, $3)
+    INSERT INTO comment_flags (user_id, comment_id, reason, weight)
+    VALUES ($2, $1, $3, $4)
     ON CONFLICT DO NOTHING
-    RETURNING comment_id
+    RETURNING weight
 )
-UPDATE comments SET downvotes = downvotes + (SELECT count(*) FROM ins)::int
+UPDATE comments SET downvotes = downvotes + coalesce((SELECT sum(weight) FROM ins), 0)::int
 WHERE id = $1
 RETURNING upvotes - downvotes AS score
 `
@@ -25,10 +58,19 @@ type CreateCommentFlagParams struct {
 	CommentID int64
 	UserID    int64
 	Reason    string
+	Weight    int32
 }
 
+// The flag costs the comment its reason's weight in downvotes. The weight
+// is stored on the flag so removing it gives back exactly what it took,
+// even if the configured weights change in between.
 func (q *Queries) CreateCommentFlag(ctx context.Context, arg CreateCommentFlagParams) (int32, error) {
-	row := q.db.QueryRow(ctx, createCommentFlag, arg.CommentID, arg.UserID, arg.Reason)
+	row := q.db.QueryRow(ctx, createCommentFlag,
+		arg.CommentID,
+		arg.UserID,
+		arg.Reason,
+		arg.Weight,
+	)
 	var score int32
 	err := row.Scan(&score)
 	return score, err
@@ -38,9 +80,9 @@ const deleteCommentFlag = `-- name: DeleteCommentFlag :one
 WITH del AS (
     DELETE FROM comment_flags
     WHERE comment_flags.user_id = $2 AND comment_flags.comment_id = $1
-    RETURNING comment_id
+    RETURNING weight
 )
-UPDATE comments SET downvotes = downvotes - (SELECT count(*) FROM del)::int
+UPDATE comments SET downvotes = downvotes - coalesce((SELECT sum(weight) FROM del), 0)::int
 WHERE id = $1
 RETURNING upvotes - downvotes AS score
 `
@@ -7,6 +7,8 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createCommentVote = `-- name: CreateCommentVote :one
@@ -56,6 +58,48 @@ func (q *Queries) DeleteCommentVote(ctx context.Context, arg DeleteCommentVotePa
 	return score, err
 }
 
+const exportUserCommentVotes = `-- name: ExportUserCommentVotes :many
+SELECT
+    v.comment_id,
+    s.short_code AS story_short_code,
+    v.created_at
+FROM comment_votes AS v
+JOIN comments AS c ON c.id = v.comment_id
+JOIN stories AS s ON s.id = c.story_id
+WHERE v.user_id = $1
+ORDER BY v.created_at, v.comment_id
+`
+
+type ExportUserCommentVotesRow struct {
+	CommentID      int64
+	StoryShortCode string
+	CreatedAt      pgtype.Timestamptz
+}
+
+func (q *Queries) ExportUserCommentVotes(ctx context.Context, userID int64) ([]ExportUserCommentVotesRow, error) {
+	rows, err := q.db.Query(ctx, exportUserCommentVotes, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserCommentVotesRow
+	for rows.Next() {
+		var i ExportUserCommentVotesRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.StoryShortCode,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserCommentVotes = `-- name: GetUserCommentVotes :many
 SELECT comment_id
 FROM comment_votes
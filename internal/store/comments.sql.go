@@ -14,7 +14,7 @@ import (
 const createComment = `-- name: CreateComment :one
 INSERT INTO comments (story_id, user_id, parent_id, body, depth)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at
+RETURNING id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at, edited_at
 `
 
 type CreateCommentParams struct {
@@ -46,6 +46,7 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -59,8 +60,70 @@ func (q *Queries) DecrementStoryCommentCount(ctx context.Context, id int64) erro
 	return err
 }
 
+const exportUserComments = `-- name: ExportUserComments :many
+SELECT
+    c.id,
+    c.parent_id,
+    c.body,
+    c.upvotes,
+    c.downvotes,
+    c.created_at,
+    c.edited_at,
+    c.deleted_at,
+    s.short_code AS story_short_code,
+    s.title AS story_title
+FROM comments AS c
+JOIN stories AS s ON s.id = c.story_id
+WHERE c.user_id = $1
+ORDER BY c.created_at, c.id
+`
+
+type ExportUserCommentsRow struct {
+	ID             int64
+	ParentID       pgtype.Int8
+	Body           string
+	Upvotes        int32
+	Downvotes      int32
+	CreatedAt      pgtype.Timestamptz
+	EditedAt       pgtype.Timestamptz
+	DeletedAt      pgtype.Timestamptz
+	StoryShortCode string
+	StoryTitle     string
+}
+
+func (q *Queries) ExportUserComments(ctx context.Context, userID int64) ([]ExportUserCommentsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserComments, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserCommentsRow
+	for rows.Next() {
+		var i ExportUserCommentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentID,
+			&i.Body,
+			&i.Upvotes,
+			&i.Downvotes,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.StoryShortCode,
+			&i.StoryTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getCommentByID = `-- name: GetCommentByID :one
-SELECT id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at
+SELECT id, story_id, user_id, parent_id, body, depth, upvotes, downvotes, created_at, updated_at, deleted_at, edited_at
 FROM comments
 WHERE id = $1
 `
@@ -80,6 +143,7 @@ func (q *Queries) GetCommentByID(ctx context.Context, id int64) (Comment, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -106,7 +170,10 @@ SELECT
     c.created_at,
     c.updated_at,
     c.deleted_at,
-    u.username
+    c.edited_at,
+    u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at
 FROM comments AS c
 JOIN users AS u ON u.id = c.user_id
 WHERE c.story_id = $1
@@ -114,18 +181,21 @@ ORDER BY c.created_at ASC
 `
 
 type ListCommentsByStoryRow struct {
-	ID        int64
-	StoryID   int64
-	UserID    int64
-	ParentID  pgtype.Int8
-	Body      string
-	Depth     int32
-	Upvotes   int32
-	Downvotes int32
-	CreatedAt pgtype.Timestamptz
-	UpdatedAt pgtype.Timestamptz
-	DeletedAt pgtype.Timestamptz
-	Username  string
+	ID            int64
+	StoryID       int64
+	UserID        int64
+	ParentID      pgtype.Int8
+	Body          string
+	Depth         int32
+	Upvotes       int32
+	Downvotes     int32
+	CreatedAt     pgtype.Timestamptz
+	UpdatedAt     pgtype.Timestamptz
+	DeletedAt     pgtype.Timestamptz
+	EditedAt      pgtype.Timestamptz
+	Username      string
+	UserBannedAt  pgtype.Timestamptz
+	UserDeletedAt pgtype.Timestamptz
 }
 
 func (q *Queries) ListCommentsByStory(ctx context.Context, storyID int64) ([]ListCommentsByStoryRow, error) {
@@ -149,7 +219,75 @@ func (q *Queries) ListCommentsByStory(ctx context.Context, storyID int64) ([]Lis
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.EditedAt,
 			&i.Username,
+			&i.UserBannedAt,
+			&i.UserDeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCommentsByUsername = `-- name: ListCommentsByUsername :many
+SELECT
+    c.id,
+    c.body,
+    c.upvotes,
+    c.downvotes,
+    c.created_at,
+    c.deleted_at,
+    s.title AS story_title,
+    s.short_code AS story_short_code
+FROM comments AS c
+JOIN users AS u ON u.id = c.user_id
+JOIN stories AS s ON s.id = c.story_id
+WHERE lower(u.username) = lower($1)
+  AND s.deleted_at IS NULL
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListCommentsByUsernameParams struct {
+	Username      string
+	CommentLimit  int32
+	CommentOffset int32
+}
+
+type ListCommentsByUsernameRow struct {
+	ID             int64
+	Body           string
+	Upvotes        int32
+	Downvotes      int32
+	CreatedAt      pgtype.Timestamptz
+	DeletedAt      pgtype.Timestamptz
+	StoryTitle     string
+	StoryShortCode string
+}
+
+func (q *Queries) ListCommentsByUsername(ctx context.Context, arg ListCommentsByUsernameParams) ([]ListCommentsByUsernameRow, error) {
+	rows, err := q.db.Query(ctx, listCommentsByUsername, arg.Username, arg.CommentLimit, arg.CommentOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCommentsByUsernameRow
+	for rows.Next() {
+		var i ListCommentsByUsernameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.Upvotes,
+			&i.Downvotes,
+			&i.CreatedAt,
+			&i.DeletedAt,
+			&i.StoryTitle,
+			&i.StoryShortCode,
 		); err != nil {
 			return nil, err
 		}
@@ -171,8 +309,40 @@ func (q *Queries) SoftDeleteComment(ctx context.Context, id int64) error {
 	return err
 }
 
+const softDeleteComments = `-- name: SoftDeleteComments :many
+UPDATE comments SET deleted_at = now(), body = ''
+WHERE id = ANY($1::bigint[])
+  AND deleted_at IS NULL
+RETURNING id, story_id
+`
+
+type SoftDeleteCommentsRow struct {
+	ID      int64
+	StoryID int64
+}
+
+func (q *Queries) SoftDeleteComments(ctx context.Context, ids []int64) ([]SoftDeleteCommentsRow, error) {
+	rows, err := q.db.Query(ctx, softDeleteComments, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SoftDeleteCommentsRow
+	for rows.Next() {
+		var i SoftDeleteCommentsRow
+		if err := rows.Scan(&i.ID, &i.StoryID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateCommentBody = `-- name: UpdateCommentBody :exec
-UPDATE comments SET body = $1, updated_at = now()
+UPDATE comments SET body = $1, updated_at = now(), edited_at = now()
 WHERE id = $2
 `
 
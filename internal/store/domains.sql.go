@@ -9,6 +9,59 @@ import (
 	"context"
 )
 
+const decrementDomainStoryCount = `-- name: DecrementDomainStoryCount :exec
+UPDATE domains
+SET story_count = greatest(story_count - 1, 0), updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) DecrementDomainStoryCount(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, decrementDomainStoryCount, id)
+	return err
+}
+
+const getDomainByID = `-- name: GetDomainByID :one
+SELECT id, domain, banned, ban_reason, story_count, created_at, updated_at
+FROM domains
+WHERE id = $1
+`
+
+func (q *Queries) GetDomainByID(ctx context.Context, id int64) (Domain, error) {
+	row := q.db.QueryRow(ctx, getDomainByID, id)
+	var i Domain
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Banned,
+		&i.BanReason,
+		&i.StoryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getDomainByName = `-- name: GetDomainByName :one
+SELECT id, domain, banned, ban_reason, story_count, created_at, updated_at
+FROM domains
+WHERE lower(domain) = lower($1)
+`
+
+func (q *Queries) GetDomainByName(ctx context.Context, domain string) (Domain, error) {
+	row := q.db.QueryRow(ctx, getDomainByName, domain)
+	var i Domain
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Banned,
+		&i.BanReason,
+		&i.StoryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const getOrCreateDomain = `-- name: GetOrCreateDomain :one
 INSERT INTO domains (domain)
 VALUES ($1)
@@ -41,18 +41,19 @@ func (q *Queries) GetUserHiddenStories(ctx context.Context, arg GetUserHiddenSto
 }
 
 const hideStory = `-- name: HideStory :exec
-INSERT INTO hidden_stories (user_id, story_id)
-VALUES ($1, $2)
+INSERT INTO hidden_stories (user_id, story_id, reason)
+VALUES ($1, $2, $3)
 ON CONFLICT DO NOTHING
 `
 
 type HideStoryParams struct {
 	UserID  int64
 	StoryID int64
+	Reason  string
 }
 
 func (q *Queries) HideStory(ctx context.Context, arg HideStoryParams) error {
-	_, err := q.db.Exec(ctx, hideStory, arg.UserID, arg.StoryID)
+	_, err := q.db.Exec(ctx, hideStory, arg.UserID, arg.StoryID, arg.Reason)
 	return err
 }
 
@@ -30,6 +30,29 @@ func (q *Queries) ClaimInvitation(ctx context.Context, arg ClaimInvitationParams
 	return id, err
 }
 
+const countInvitationsByUser = `-- name: CountInvitationsByUser :one
+SELECT
+    count(*) FILTER (
+        WHERE used_by_id IS NULL
+          AND created_at > now() - INTERVAL '24 hours'
+    ) AS outstanding,
+    count(*) AS total
+FROM invitations
+WHERE inviter_id = $1
+`
+
+type CountInvitationsByUserRow struct {
+	Outstanding int64
+	Total       int64
+}
+
+func (q *Queries) CountInvitationsByUser(ctx context.Context, inviterID int64) (CountInvitationsByUserRow, error) {
+	row := q.db.QueryRow(ctx, countInvitationsByUser, inviterID)
+	var i CountInvitationsByUserRow
+	err := row.Scan(&i.Outstanding, &i.Total)
+	return i, err
+}
+
 const createInvitation = `-- name: CreateInvitation :one
 INSERT INTO invitations (inviter_id, email, token_hash)
 VALUES ($1, $2, $3)
@@ -56,6 +79,96 @@ func (q *Queries) CreateInvitation(ctx context.Context, arg CreateInvitationPara
 	return i, err
 }
 
+const createInvitationWithinQuota = `-- name: CreateInvitationWithinQuota :one
+INSERT INTO invitations (inviter_id, email, token_hash)
+SELECT $1::bigint, $2::text, $3::text
+WHERE ($4::int <= 0 OR (
+        SELECT count(*) FROM invitations WHERE inviter_id = $1
+    ) < $4)
+  AND ($5::int <= 0 OR (
+        SELECT count(*) FROM invitations
+        WHERE inviter_id = $1
+          AND used_by_id IS NULL
+          AND created_at > now() - INTERVAL '24 hours'
+    ) < $5)
+RETURNING id, inviter_id, email, token_hash, used_by_id, created_at
+`
+
+type CreateInvitationWithinQuotaParams struct {
+	InviterID      int64
+	Email          pgtype.Text
+	TokenHash      string
+	MaxTotal       int32
+	MaxOutstanding int32
+}
+
+// Inserts nothing, and so returns no row, once the inviter has created
+// max_total invitations or has max_outstanding pending ones, counted as in
+// CountInvitationsByUser. A limit of zero means none. Run it holding the
+// inviter's LockUser lock so concurrent invitations are counted in turn.
+func (q *Queries) CreateInvitationWithinQuota(ctx context.Context, arg CreateInvitationWithinQuotaParams) (Invitation, error) {
+	row := q.db.QueryRow(ctx, createInvitationWithinQuota,
+		arg.InviterID,
+		arg.Email,
+		arg.TokenHash,
+		arg.MaxTotal,
+		arg.MaxOutstanding,
+	)
+	var i Invitation
+	err := row.Scan(
+		&i.ID,
+		&i.InviterID,
+		&i.Email,
+		&i.TokenHash,
+		&i.UsedByID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const exportUserInvitations = `-- name: ExportUserInvitations :many
+SELECT
+    CASE WHEN i.used_by_id IS NULL THEN i.email END AS email,
+    i.created_at,
+    ru.username AS registered_username
+FROM invitations AS i
+LEFT JOIN users AS ru ON ru.id = i.used_by_id
+WHERE i.inviter_id = $1
+ORDER BY i.created_at, i.id
+`
+
+type ExportUserInvitationsRow struct {
+	Email              pgtype.Text
+	CreatedAt          pgtype.Timestamptz
+	RegisteredUsername pgtype.Text
+}
+
+// Addresses of claimed invitations are left out: they belong to the
+// invitee, who is listed by username instead.
+func (q *Queries) ExportUserInvitations(ctx context.Context, inviterID int64) ([]ExportUserInvitationsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserInvitations, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserInvitationsRow
+	for rows.Next() {
+		var i ExportUserInvitationsRow
+		if err := rows.Scan(
+			&i.Email,
+			&i.CreatedAt,
+			&i.RegisteredUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getInvitationByTokenHash = `-- name: GetInvitationByTokenHash :one
 SELECT
     i.id,
@@ -47,6 +47,7 @@ type Comment struct {
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 	DeletedAt pgtype.Timestamptz
+	EditedAt  pgtype.Timestamptz
 }
 
 type CommentFlag struct {
@@ -54,6 +55,7 @@ type CommentFlag struct {
 	CommentID int64
 	Reason    string
 	CreatedAt pgtype.Timestamptz
+	Weight    int32
 }
 
 type CommentVote struct {
@@ -94,9 +96,16 @@ type Domain struct {
 	UpdatedAt  pgtype.Timestamptz
 }
 
+type DomainSubscription struct {
+	UserID    int64
+	DomainID  int64
+	CreatedAt pgtype.Timestamptz
+}
+
 type HiddenStory struct {
 	UserID    int64
 	StoryID   int64
+	Reason    string
 	CreatedAt pgtype.Timestamptz
 }
 
@@ -149,43 +158,74 @@ type PageView struct {
 }
 
 type Session struct {
-	ID         int64
-	UserID     int64
-	TokenHash  string
-	UserAgent  string
-	IpAddress  string
-	ExpiresAt  pgtype.Timestamptz
-	LastSeenAt pgtype.Timestamptz
-	CreatedAt  pgtype.Timestamptz
-	UpdatedAt  pgtype.Timestamptz
+	ID                 int64
+	UserID             int64
+	TokenHash          string
+	UserAgent          string
+	IpAddress          string
+	ExpiresAt          pgtype.Timestamptz
+	LastSeenAt         pgtype.Timestamptz
+	CreatedAt          pgtype.Timestamptz
+	UpdatedAt          pgtype.Timestamptz
+	ImpersonatedUserID pgtype.Int8
 }
 
 type Story struct {
-	ID            int64
-	UserID        int64
-	DomainID      pgtype.Int8
-	OriginID      pgtype.Int8
-	Url           pgtype.Text
-	NormalizedUrl pgtype.Text
-	Title         string
-	Body          pgtype.Text
-	ShortCode     string
-	Upvotes       int32
-	Downvotes     int32
-	CommentCount  int32
-	DuplicateOfID pgtype.Int8
-	CreatedAt     pgtype.Timestamptz
-	UpdatedAt     pgtype.Timestamptz
-	DeletedAt     pgtype.Timestamptz
+	ID                     int64
+	UserID                 int64
+	DomainID               pgtype.Int8
+	OriginID               pgtype.Int8
+	Url                    pgtype.Text
+	NormalizedUrl          pgtype.Text
+	CanonicalUrl           pgtype.Text
+	NormalizedCanonicalUrl pgtype.Text
+	Title                  string
+	Body                   pgtype.Text
+	ShortCode              string
+	Upvotes                int32
+	Downvotes              int32
+	AdminAdjustment        int32
+	CommentCount           int32
+	ViewCount              int32
+	DuplicateOfID          pgtype.Int8
+	PinnedUntil            pgtype.Timestamptz
+	ScoreActivityAt        pgtype.Timestamptz
+	ScoredAt               pgtype.Timestamptz
+	CreatedAt              pgtype.Timestamptz
+	UpdatedAt              pgtype.Timestamptz
+	DeletedAt              pgtype.Timestamptz
+	HiddenAt               pgtype.Timestamptz
+	PickedAt               pgtype.Timestamptz
+	ImportedUpvotes        int32
+	ImportedDownvotes      int32
 }
 
 type StoryFlag struct {
-	UserID    int64
+	UserID          int64
+	StoryID         int64
+	Reason          string
+	CreatedAt       pgtype.Timestamptz
+	OriginalStoryID pgtype.Int8
+}
+
+type StoryRevision struct {
+	ID        int64
 	StoryID   int64
-	Reason    string
+	EditorID  int64
+	Title     string
+	Url       pgtype.Text
+	Body      pgtype.Text
+	Tags      []string
 	CreatedAt pgtype.Timestamptz
 }
 
+type StorySubscription struct {
+	UserID     int64
+	StoryID    int64
+	Subscribed bool
+	UpdatedAt  pgtype.Timestamptz
+}
+
 type StoryVisit struct {
 	UserID     int64
 	StoryID    int64
@@ -193,16 +233,23 @@ type StoryVisit struct {
 }
 
 type Tag struct {
-	ID          int64
-	Tag         string
-	Description string
-	CategoryID  pgtype.Int8
-	Privileged  bool
-	IsMedia     bool
-	Active      bool
-	HotnessMod  float64
-	CreatedAt   pgtype.Timestamptz
-	UpdatedAt   pgtype.Timestamptz
+	ID                           int64
+	Tag                          string
+	Description                  string
+	CategoryID                   pgtype.Int8
+	Privileged                   bool
+	IsMedia                      bool
+	Active                       bool
+	HotnessMod                   float64
+	CreatedAt                    pgtype.Timestamptz
+	UpdatedAt                    pgtype.Timestamptz
+	DescriptionEditedByModerator bool
+}
+
+type TagSynonym struct {
+	TagID     int64
+	Synonym   string
+	CreatedAt pgtype.Timestamptz
 }
 
 type Tagging struct {
@@ -228,6 +275,9 @@ type User struct {
 	UnconfirmedEmail                pgtype.Text
 	Website                         string
 	About                           string
+	Slogan                          string
+	LastSeenAt                      pgtype.Timestamptz
+	EnableEmbeds                    bool
 	CreatedAt                       pgtype.Timestamptz
 	UpdatedAt                       pgtype.Timestamptz
 }
@@ -9,6 +9,17 @@ import (
 	"context"
 )
 
+const decrementOriginStoryCount = `-- name: DecrementOriginStoryCount :exec
+UPDATE origins
+SET story_count = greatest(story_count - 1, 0), updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) DecrementOriginStoryCount(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, decrementOriginStoryCount, id)
+	return err
+}
+
 const getOrCreateOrigin = `-- name: GetOrCreateOrigin :one
 INSERT INTO origins (domain_id, origin)
 VALUES ($1, $2)
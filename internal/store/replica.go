@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ReplicaDB sends queries to a read replica while it is healthy and falls
+// back to the primary otherwise. It is meant for read-only Queries; anything
+// that writes must keep using the primary directly.
+type ReplicaDB struct {
+	primary DBTX
+	replica DBTX
+	healthy atomic.Bool
+}
+
+// NewReplicaDB returns a ReplicaDB that starts out healthy. A nil replica
+// means every query goes to the primary.
+func NewReplicaDB(primary, replica DBTX) *ReplicaDB {
+	r := &ReplicaDB{primary: primary, replica: replica}
+	r.healthy.Store(replica != nil)
+	return r
+}
+
+// NewReader returns Queries for heavy read paths, backed by a ReplicaDB.
+func NewReader(primary, replica DBTX) (*Queries, *ReplicaDB) {
+	r := NewReplicaDB(primary, replica)
+	return New(r), r
+}
+
+// Healthy reports whether queries are currently routed to the replica.
+func (r *ReplicaDB) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// SetHealthy marks the replica as usable or not.
+func (r *ReplicaDB) SetHealthy(ok bool) {
+	r.healthy.Store(ok && r.replica != nil)
+}
+
+// Check pings the replica and updates its health accordingly.
+func (r *ReplicaDB) Check(ctx context.Context) error {
+	if r.replica == nil {
+		return nil
+	}
+	_, err := r.replica.Exec(ctx, "SELECT 1")
+	r.SetHealthy(err == nil)
+	return err
+}
+
+// Monitor runs Check every interval until done is closed, calling onChange
+// whenever the replica becomes healthy or unhealthy.
+func (r *ReplicaDB) Monitor(interval time.Duration, done <-chan struct{}, onChange func(healthy bool, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			was := r.Healthy()
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := r.Check(ctx)
+			cancel()
+			if now := r.Healthy(); now != was && onChange != nil {
+				onChange(now, err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (r *ReplicaDB) db() DBTX {
+	if r.healthy.Load() {
+		return r.replica
+	}
+	return r.primary
+}
+
+func (r *ReplicaDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.db().Exec(ctx, sql, args...)
+}
+
+func (r *ReplicaDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return r.db().Query(ctx, sql, args...)
+}
+
+func (r *ReplicaDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.db().QueryRow(ctx, sql, args...)
+}
@@ -86,6 +86,7 @@ const getSessionUserByTokenHash = `-- name: GetSessionUserByTokenHash :one
 SELECT
     s.id AS session_id,
     s.expires_at,
+    s.impersonated_user_id,
     u.id,
     u.username,
     u.email,
@@ -101,6 +102,9 @@ SELECT
     u.unconfirmed_email,
     u.website,
     u.about,
+    u.slogan,
+    u.last_seen_at,
+    u.enable_embeds,
     u.created_at,
     u.updated_at
 FROM sessions AS s
@@ -113,6 +117,7 @@ LIMIT 1
 type GetSessionUserByTokenHashRow struct {
 	SessionID                       int64
 	ExpiresAt                       pgtype.Timestamptz
+	ImpersonatedUserID              pgtype.Int8
 	ID                              int64
 	Username                        string
 	Email                           string
@@ -128,6 +133,9 @@ type GetSessionUserByTokenHashRow struct {
 	UnconfirmedEmail                pgtype.Text
 	Website                         string
 	About                           string
+	Slogan                          string
+	LastSeenAt                      pgtype.Timestamptz
+	EnableEmbeds                    bool
 	CreatedAt                       pgtype.Timestamptz
 	UpdatedAt                       pgtype.Timestamptz
 }
@@ -138,6 +146,7 @@ func (q *Queries) GetSessionUserByTokenHash(ctx context.Context, tokenHash strin
 	err := row.Scan(
 		&i.SessionID,
 		&i.ExpiresAt,
+		&i.ImpersonatedUserID,
 		&i.ID,
 		&i.Username,
 		&i.Email,
@@ -153,12 +162,32 @@ func (q *Queries) GetSessionUserByTokenHash(ctx context.Context, tokenHash strin
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const setSessionImpersonation = `-- name: SetSessionImpersonation :exec
+UPDATE sessions
+SET impersonated_user_id = $1,
+    updated_at = now()
+WHERE id = $2
+`
+
+type SetSessionImpersonationParams struct {
+	ImpersonatedUserID pgtype.Int8
+	ID                 int64
+}
+
+func (q *Queries) SetSessionImpersonation(ctx context.Context, arg SetSessionImpersonationParams) error {
+	_, err := q.db.Exec(ctx, setSessionImpersonation, arg.ImpersonatedUserID, arg.ID)
+	return err
+}
+
 const touchSession = `-- name: TouchSession :exec
 UPDATE sessions
 SET updated_at = now(),
@@ -11,6 +11,64 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const adjustStoryScore = `-- name: AdjustStoryScore :one
+UPDATE stories SET admin_adjustment = admin_adjustment + $1::int
+WHERE id = $2
+RETURNING admin_adjustment
+`
+
+type AdjustStoryScoreParams struct {
+	Delta int32
+	ID    int64
+}
+
+// Moderator score adjustments live apart from upvotes/downvotes so vote
+// recalculation leaves them alone.
+func (q *Queries) AdjustStoryScore(ctx context.Context, arg AdjustStoryScoreParams) (int32, error) {
+	row := q.db.QueryRow(ctx, adjustStoryScore, arg.Delta, arg.ID)
+	var admin_adjustment int32
+	err := row.Scan(&admin_adjustment)
+	return admin_adjustment, err
+}
+
+const clearStoryLink = `-- name: ClearStoryLink :one
+UPDATE stories AS s
+SET url = NULL, normalized_url = NULL, canonical_url = NULL, normalized_canonical_url = NULL,
+    domain_id = NULL, origin_id = NULL, updated_at = now()
+FROM (SELECT id, domain_id, origin_id FROM stories WHERE id = $1 FOR UPDATE) AS old
+WHERE s.id = old.id
+RETURNING old.domain_id, old.origin_id
+`
+
+type ClearStoryLinkRow struct {
+	DomainID pgtype.Int8
+	OriginID pgtype.Int8
+}
+
+// Turns a link story into a text post, which needs a body first, and
+// returns the domain and origin it was counted under.
+func (q *Queries) ClearStoryLink(ctx context.Context, id int64) (ClearStoryLinkRow, error) {
+	row := q.db.QueryRow(ctx, clearStoryLink, id)
+	var i ClearStoryLinkRow
+	err := row.Scan(&i.DomainID, &i.OriginID)
+	return i, err
+}
+
+const countPinnedStories = `-- name: CountPinnedStories :one
+SELECT count(*)
+FROM stories
+WHERE pinned_until > now()
+  AND deleted_at IS NULL
+  AND id != $1
+`
+
+func (q *Queries) CountPinnedStories(ctx context.Context, excludeID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, countPinnedStories, excludeID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countStories = `-- name: CountStories :one
 SELECT count(*) FROM stories WHERE deleted_at IS NULL
 `
@@ -109,16 +167,144 @@ func (q *Queries) DeleteTaggingsByStory(ctx context.Context, storyID int64) erro
 	return err
 }
 
+const exportStories = `-- name: ExportStories :many
+SELECT
+    s.short_code,
+    s.title,
+    s.url,
+    s.body,
+    s.upvotes,
+    s.downvotes,
+    s.created_at,
+    u.username,
+    COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.id IS NOT NULL), '{}')::text[] AS tags
+FROM stories AS s
+JOIN users AS u ON u.id = s.user_id
+LEFT JOIN taggings AS tg ON tg.story_id = s.id
+LEFT JOIN tags AS t ON t.id = tg.tag_id
+WHERE s.deleted_at IS NULL
+GROUP BY s.id, u.username
+ORDER BY s.created_at, s.id
+`
+
+type ExportStoriesRow struct {
+	ShortCode string
+	Title     string
+	Url       pgtype.Text
+	Body      pgtype.Text
+	Upvotes   int32
+	Downvotes int32
+	CreatedAt pgtype.Timestamptz
+	Username  string
+	Tags      []string
+}
+
+func (q *Queries) ExportStories(ctx context.Context) ([]ExportStoriesRow, error) {
+	rows, err := q.db.Query(ctx, exportStories)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportStoriesRow
+	for rows.Next() {
+		var i ExportStoriesRow
+		if err := rows.Scan(
+			&i.ShortCode,
+			&i.Title,
+			&i.Url,
+			&i.Body,
+			&i.Upvotes,
+			&i.Downvotes,
+			&i.CreatedAt,
+			&i.Username,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserStories = `-- name: ExportUserStories :many
+SELECT
+    s.short_code,
+    s.title,
+    s.url,
+    s.body,
+    s.upvotes,
+    s.downvotes,
+    s.created_at,
+    s.deleted_at,
+    COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.id IS NOT NULL), '{}')::text[] AS tags
+FROM stories AS s
+LEFT JOIN taggings AS tg ON tg.story_id = s.id
+LEFT JOIN tags AS t ON t.id = tg.tag_id
+WHERE s.user_id = $1
+GROUP BY s.id
+ORDER BY s.created_at, s.id
+`
+
+type ExportUserStoriesRow struct {
+	ShortCode string
+	Title     string
+	Url       pgtype.Text
+	Body      pgtype.Text
+	Upvotes   int32
+	Downvotes int32
+	CreatedAt pgtype.Timestamptz
+	DeletedAt pgtype.Timestamptz
+	Tags      []string
+}
+
+func (q *Queries) ExportUserStories(ctx context.Context, userID int64) ([]ExportUserStoriesRow, error) {
+	rows, err := q.db.Query(ctx, exportUserStories, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserStoriesRow
+	for rows.Next() {
+		var i ExportUserStoriesRow
+		if err := rows.Scan(
+			&i.ShortCode,
+			&i.Title,
+			&i.Url,
+			&i.Body,
+			&i.Upvotes,
+			&i.Downvotes,
+			&i.CreatedAt,
+			&i.DeletedAt,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const findRecentByNormalizedURL = `-- name: FindRecentByNormalizedURL :one
 SELECT id, url, title, short_code, created_at
 FROM stories
-WHERE normalized_url = $1
+WHERE (normalized_url = $1 OR normalized_canonical_url = $1)
   AND deleted_at IS NULL
-  AND created_at > now() - INTERVAL '30 days'
+  AND ($2::timestamptz IS NULL OR created_at > $2)
 ORDER BY created_at DESC
 LIMIT 1
 `
 
+type FindRecentByNormalizedURLParams struct {
+	NormalizedUrl pgtype.Text
+	CreatedAfter  pgtype.Timestamptz
+}
+
 type FindRecentByNormalizedURLRow struct {
 	ID        int64
 	Url       pgtype.Text
@@ -127,8 +313,8 @@ type FindRecentByNormalizedURLRow struct {
 	CreatedAt pgtype.Timestamptz
 }
 
-func (q *Queries) FindRecentByNormalizedURL(ctx context.Context, normalizedUrl pgtype.Text) (FindRecentByNormalizedURLRow, error) {
-	row := q.db.QueryRow(ctx, findRecentByNormalizedURL, normalizedUrl)
+func (q *Queries) FindRecentByNormalizedURL(ctx context.Context, arg FindRecentByNormalizedURLParams) (FindRecentByNormalizedURLRow, error) {
+	row := q.db.QueryRow(ctx, findRecentByNormalizedURL, arg.NormalizedUrl, arg.CreatedAfter)
 	var i FindRecentByNormalizedURLRow
 	err := row.Scan(
 		&i.ID,
@@ -140,21 +326,49 @@ func (q *Queries) FindRecentByNormalizedURL(ctx context.Context, normalizedUrl p
 	return i, err
 }
 
+const getLatestStoryActivity = `-- name: GetLatestStoryActivity :one
+SELECT greatest(
+    (SELECT max(updated_at) FROM stories),
+    (SELECT max(score_activity_at) FROM stories),
+    (SELECT max(created_at) FROM comments),
+    (SELECT max(deleted_at) FROM comments)
+)::timestamptz AS latest
+`
+
+// The last time anything a chronological listing shows changed: a story
+// was submitted, edited, deleted or voted on, or a comment was posted or
+// removed. Deletions move it forward, never back. A story's updated_at
+// starts at its created_at, and every max() here reads the end of an index.
+func (q *Queries) GetLatestStoryActivity(ctx context.Context) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getLatestStoryActivity)
+	var latest pgtype.Timestamptz
+	err := row.Scan(&latest)
+	return latest, err
+}
+
 const getStory = `-- name: GetStory :one
 SELECT
     s.id,
     s.user_id,
     s.url,
+    s.canonical_url,
     s.title,
     s.body,
     s.short_code,
     s.upvotes,
     s.downvotes,
+    s.admin_adjustment,
     s.comment_count,
+    s.view_count,
     s.created_at,
     s.deleted_at,
     s.duplicate_of_id,
+    s.pinned_until,
+    s.picked_at,
+    s.hidden_at,
     u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at,
     d.domain,
     o.origin,
     dup.short_code AS duplicate_of_short_code,
@@ -177,16 +391,24 @@ type GetStoryRow struct {
 	ID                   int64
 	UserID               int64
 	Url                  pgtype.Text
+	CanonicalUrl         pgtype.Text
 	Title                string
 	Body                 pgtype.Text
 	ShortCode            string
 	Upvotes              int32
 	Downvotes            int32
+	AdminAdjustment      int32
 	CommentCount         int32
+	ViewCount            int32
 	CreatedAt            pgtype.Timestamptz
 	DeletedAt            pgtype.Timestamptz
 	DuplicateOfID        pgtype.Int8
+	PinnedUntil          pgtype.Timestamptz
+	PickedAt             pgtype.Timestamptz
+	HiddenAt             pgtype.Timestamptz
 	Username             string
+	UserBannedAt         pgtype.Timestamptz
+	UserDeletedAt        pgtype.Timestamptz
 	Domain               pgtype.Text
 	Origin               pgtype.Text
 	DuplicateOfShortCode pgtype.Text
@@ -200,16 +422,24 @@ func (q *Queries) GetStory(ctx context.Context, arg GetStoryParams) (GetStoryRow
 		&i.ID,
 		&i.UserID,
 		&i.Url,
+		&i.CanonicalUrl,
 		&i.Title,
 		&i.Body,
 		&i.ShortCode,
 		&i.Upvotes,
 		&i.Downvotes,
+		&i.AdminAdjustment,
 		&i.CommentCount,
+		&i.ViewCount,
 		&i.CreatedAt,
 		&i.DeletedAt,
 		&i.DuplicateOfID,
+		&i.PinnedUntil,
+		&i.PickedAt,
+		&i.HiddenAt,
 		&i.Username,
+		&i.UserBannedAt,
+		&i.UserDeletedAt,
 		&i.Domain,
 		&i.Origin,
 		&i.DuplicateOfShortCode,
@@ -259,12 +489,14 @@ func (q *Queries) GetStoryTags(ctx context.Context, storyID int64) ([]GetStoryTa
 }
 
 const getTagsByNames = `-- name: GetTagsByNames :many
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
-WHERE lower(tag) = ANY($1::text[])
+WHERE (lower(tag) = ANY($1::text[])
+       OR id IN (SELECT tag_id FROM tag_synonyms WHERE lower(synonym) = ANY($1::text[])))
   AND active = true
 `
 
+// Names match a tag itself or any of its synonyms.
 func (q *Queries) GetTagsByNames(ctx context.Context, names []string) ([]Tag, error) {
 	rows, err := q.db.Query(ctx, getTagsByNames, names)
 	if err != nil {
@@ -285,6 +517,7 @@ func (q *Queries) GetTagsByNames(ctx context.Context, names []string) ([]Tag, er
 			&i.HotnessMod,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DescriptionEditedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -296,6 +529,65 @@ func (q *Queries) GetTagsByNames(ctx context.Context, names []string) ([]Tag, er
 	return items, nil
 }
 
+const getUserLatestStoryTime = `-- name: GetUserLatestStoryTime :one
+SELECT max(created_at)::timestamptz AS latest FROM stories WHERE user_id = $1
+`
+
+// Deleted stories count too, so deleting a story doesn't reset the
+// submission cooldown.
+func (q *Queries) GetUserLatestStoryTime(ctx context.Context, userID int64) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getUserLatestStoryTime, userID)
+	var latest pgtype.Timestamptz
+	err := row.Scan(&latest)
+	return latest, err
+}
+
+const hideStories = `-- name: HideStories :many
+UPDATE stories SET hidden_at = now(), updated_at = now()
+WHERE id = ANY($1::bigint[])
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+RETURNING id
+`
+
+// Hidden stories stay reachable by link but drop out of listings.
+func (q *Queries) HideStories(ctx context.Context, ids []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, hideStories, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const incrementStoryViews = `-- name: IncrementStoryViews :exec
+UPDATE stories AS s
+SET view_count = s.view_count + v.views
+FROM unnest($1::bigint[], $2::int[]) AS v(story_id, views)
+WHERE s.id = v.story_id
+`
+
+type IncrementStoryViewsParams struct {
+	StoryIds []int64
+	Views    []int32
+}
+
+func (q *Queries) IncrementStoryViews(ctx context.Context, arg IncrementStoryViewsParams) error {
+	_, err := q.db.Exec(ctx, incrementStoryViews, arg.StoryIds, arg.Views)
+	return err
+}
+
 const listDuplicatesOf = `-- name: ListDuplicatesOf :many
 SELECT s.id, s.short_code, s.title, s.created_at
 FROM stories s
@@ -339,73 +631,146 @@ func (q *Queries) ListDuplicatesOf(ctx context.Context, storyID int64) ([]ListDu
 const listStories = `-- name: ListStories :many
 SELECT
     s.id,
+    s.user_id,
     s.url,
     s.title,
     s.body,
     s.short_code,
     s.upvotes,
     s.downvotes,
+    s.admin_adjustment,
     s.comment_count,
     s.created_at,
     s.deleted_at,
     s.duplicate_of_id,
+    s.pinned_until,
+    s.picked_at,
     u.username,
+    u.banned_at AS user_banned_at,
+    u.deleted_at AS user_deleted_at,
+    u.created_at AS user_created_at,
     d.domain,
     o.origin,
     dup.short_code AS duplicate_of_short_code,
-    dup.title AS duplicate_of_title
+    dup.title AS duplicate_of_title,
+    (
+        SELECT coalesce(jsonb_agg(jsonb_build_object(
+            'tag', t.tag,
+            'is_media', t.is_media,
+            'hotness_mod', t.hotness_mod
+        ) ORDER BY t.is_media DESC, t.tag ASC), '[]')
+        FROM taggings AS tg3
+        JOIN tags AS t ON t.id = tg3.tag_id
+        WHERE tg3.story_id = s.id
+    )::jsonb AS tags,
+    EXISTS (
+        SELECT 1 FROM votes AS v
+        WHERE v.story_id = s.id AND v.user_id = $1
+    ) AS has_upvoted,
+    EXISTS (
+        SELECT 1 FROM story_flags AS sf
+        WHERE sf.story_id = s.id AND sf.user_id = $1
+    ) AS has_flagged,
+    EXISTS (
+        SELECT 1 FROM hidden_stories AS hs
+        WHERE hs.story_id = s.id AND hs.user_id = $1
+    ) AS has_hidden
 FROM stories AS s
 JOIN users AS u ON u.id = s.user_id
 LEFT JOIN domains AS d ON d.id = s.domain_id
 LEFT JOIN origins AS o ON o.id = s.origin_id
 LEFT JOIN stories AS dup ON dup.id = s.duplicate_of_id
-LEFT JOIN taggings AS tg ON tg.story_id = s.id AND tg.tag_id = $1
+LEFT JOIN taggings AS tg ON tg.story_id = s.id AND tg.tag_id = $2
 WHERE
-    ($1::bigint IS NULL OR tg.tag_id IS NOT NULL)
-    AND ($2::text IS NULL OR lower(u.username) = lower($2))
-    AND (NOT $3::bool OR s.deleted_at IS NULL)
+    ($2::bigint IS NULL OR tg.tag_id IS NOT NULL)
+    AND ($3::text IS NULL OR lower(u.username) = lower($3))
+    AND (NOT $4::bool OR s.deleted_at IS NULL)
+    AND s.hidden_at IS NULL
     AND s.id NOT IN (
         SELECT tg2.story_id FROM taggings AS tg2
-        WHERE tg2.tag_id = ANY($4::bigint[])
+        WHERE tg2.tag_id = ANY($5::bigint[])
     )
-ORDER BY s.created_at DESC
-LIMIT $5
+    AND ($6::timestamptz IS NULL OR s.created_at >= $6)
+    AND ($7::bigint IS NULL OR s.domain_id = $7)
+    AND ($8::bigint IS NULL OR s.domain_id IN (
+        SELECT ds.domain_id FROM domain_subscriptions AS ds
+        WHERE ds.user_id = $8
+    ))
+    AND (NOT $9::bool OR NOT EXISTS (
+        SELECT 1 FROM hidden_stories AS hs2
+        WHERE hs2.story_id = s.id AND hs2.user_id = $1
+    ))
+    AND (NOT $10::bool OR s.duplicate_of_id IS NULL)
+ORDER BY
+    CASE WHEN $11::bool THEN s.upvotes - s.downvotes + s.admin_adjustment END DESC,
+    CASE WHEN NOT $12::bool THEN s.pinned_until IS NOT NULL AND s.pinned_until > now() END DESC,
+    s.created_at DESC
+LIMIT $13 OFFSET $14
 `
 
 type ListStoriesParams struct {
-	TagID        pgtype.Int8
-	Username     pgtype.Text
-	HideDeleted  bool
-	HiddenTagIds []int64
-	StoryLimit   int32
+	ViewerID         pgtype.Int8
+	TagID            pgtype.Int8
+	Username         pgtype.Text
+	HideDeleted      bool
+	HiddenTagIds     []int64
+	CreatedAfter     pgtype.Timestamptz
+	DomainID         pgtype.Int8
+	SubscriberID     pgtype.Int8
+	HideViewerHidden bool
+	HideDuplicates   bool
+	OrderByScore     bool
+	Chronological    bool
+	StoryLimit       int32
+	StoryOffset      int32
 }
 
 type ListStoriesRow struct {
 	ID                   int64
+	UserID               int64
 	Url                  pgtype.Text
 	Title                string
 	Body                 pgtype.Text
 	ShortCode            string
 	Upvotes              int32
 	Downvotes            int32
+	AdminAdjustment      int32
 	CommentCount         int32
 	CreatedAt            pgtype.Timestamptz
 	DeletedAt            pgtype.Timestamptz
 	DuplicateOfID        pgtype.Int8
+	PinnedUntil          pgtype.Timestamptz
+	PickedAt             pgtype.Timestamptz
 	Username             string
+	UserBannedAt         pgtype.Timestamptz
+	UserDeletedAt        pgtype.Timestamptz
+	UserCreatedAt        pgtype.Timestamptz
 	Domain               pgtype.Text
 	Origin               pgtype.Text
 	DuplicateOfShortCode pgtype.Text
 	DuplicateOfTitle     pgtype.Text
+	Tags                 []byte
+	HasUpvoted           bool
+	HasFlagged           bool
+	HasHidden            bool
 }
 
 func (q *Queries) ListStories(ctx context.Context, arg ListStoriesParams) ([]ListStoriesRow, error) {
 	rows, err := q.db.Query(ctx, listStories,
+		arg.ViewerID,
 		arg.TagID,
 		arg.Username,
 		arg.HideDeleted,
 		arg.HiddenTagIds,
+		arg.CreatedAfter,
+		arg.DomainID,
+		arg.SubscriberID,
+		arg.HideViewerHidden,
+		arg.HideDuplicates,
+		arg.OrderByScore,
+		arg.Chronological,
 		arg.StoryLimit,
+		arg.StoryOffset,
 	)
 	if err != nil {
 		return nil, err
@@ -416,21 +781,32 @@ func (q *Queries) ListStories(ctx context.Context, arg ListStoriesParams) ([]Lis
 		var i ListStoriesRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.UserID,
 			&i.Url,
 			&i.Title,
 			&i.Body,
 			&i.ShortCode,
 			&i.Upvotes,
 			&i.Downvotes,
+			&i.AdminAdjustment,
 			&i.CommentCount,
 			&i.CreatedAt,
 			&i.DeletedAt,
 			&i.DuplicateOfID,
+			&i.PinnedUntil,
+			&i.PickedAt,
 			&i.Username,
+			&i.UserBannedAt,
+			&i.UserDeletedAt,
+			&i.UserCreatedAt,
 			&i.Domain,
 			&i.Origin,
 			&i.DuplicateOfShortCode,
 			&i.DuplicateOfTitle,
+			&i.Tags,
+			&i.HasUpvoted,
+			&i.HasFlagged,
+			&i.HasHidden,
 		); err != nil {
 			return nil, err
 		}
@@ -442,6 +818,16 @@ func (q *Queries) ListStories(ctx context.Context, arg ListStoriesParams) ([]Lis
 	return items, nil
 }
 
+const lockStory = `-- name: LockStory :exec
+SELECT id FROM stories WHERE id = $1 FOR UPDATE
+`
+
+// Take the story's row lock so concurrent score updates apply one at a time.
+func (q *Queries) LockStory(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, lockStory, id)
+	return err
+}
+
 const markStoryDuplicate = `-- name: MarkStoryDuplicate :exec
 UPDATE stories SET duplicate_of_id = $1, updated_at = now() WHERE id = $2
 `
@@ -456,35 +842,160 @@ func (q *Queries) MarkStoryDuplicate(ctx context.Context, arg MarkStoryDuplicate
 	return err
 }
 
+const pickStory = `-- name: PickStory :exec
+UPDATE stories SET picked_at = now(), updated_at = now() WHERE id = $1
+`
+
+func (q *Queries) PickStory(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, pickStory, id)
+	return err
+}
+
+const pinStory = `-- name: PinStory :exec
+UPDATE stories SET pinned_until = $1, updated_at = now() WHERE id = $2
+`
+
+type PinStoryParams struct {
+	PinnedUntil pgtype.Timestamptz
+	ID          int64
+}
+
+func (q *Queries) PinStory(ctx context.Context, arg PinStoryParams) error {
+	_, err := q.db.Exec(ctx, pinStory, arg.PinnedUntil, arg.ID)
+	return err
+}
+
 const recalculateStoryScores = `-- name: RecalculateStoryScores :execrows
+WITH stale AS (
+    SELECT id FROM stories WHERE NOT $1::bool
+    UNION
+    SELECT id FROM stories
+    WHERE $1::bool AND (scored_at IS NULL OR score_activity_at > scored_at)
+    UNION
+    SELECT id FROM stories
+    WHERE $1::bool AND scored_at < $2::timestamptz
+)
 UPDATE stories SET
-  upvotes = coalesce(v.cnt, 0)::int,
-  downvotes = coalesce(hf.cnt, 0)::int
-FROM stories s2
-LEFT JOIN (SELECT story_id, count(*) AS cnt FROM votes GROUP BY story_id) v ON v.story_id = s2.id
+  upvotes = stories.imported_upvotes + coalesce(v.cnt, 0)::int,
+  downvotes = stories.imported_downvotes + coalesce(hf.cnt, 0)::int,
+  scored_at = now()
+FROM stale s2
+LEFT JOIN (
+    SELECT story_id, count(*) AS cnt FROM votes
+    WHERE story_id IN (SELECT id FROM stale)
+    GROUP BY story_id
+) v ON v.story_id = s2.id
 LEFT JOIN (
-    SELECT hs.story_id, count(*) AS cnt
+    SELECT hs.story_id, sum(least(coalesce(w.weight, 1) * ft.percent, $3::int)) / 100 AS cnt
     FROM hidden_stories hs
     JOIN story_flags sf ON sf.user_id = hs.user_id AND sf.story_id = hs.story_id
-    WHERE NOT EXISTS (
-        SELECT 1 FROM comments c
-        WHERE c.story_id = hs.story_id AND c.user_id = hs.user_id AND c.deleted_at IS NULL
-    )
+    JOIN users u ON u.id = sf.user_id
+    CROSS JOIN LATERAL flagger_trust_percent(
+        u, $4::bool, $5::int, $6::timestamptz,
+        $7::int, $8::timestamptz, $9::int
+    ) AS ft(percent)
+    LEFT JOIN unnest($10::text[], $11::int[]) AS w(reason, weight) ON w.reason = sf.reason
+    WHERE hs.story_id IN (SELECT id FROM stale)
+      AND NOT EXISTS (
+          SELECT 1 FROM comments c
+          WHERE c.story_id = hs.story_id AND c.user_id = hs.user_id AND c.deleted_at IS NULL
+      )
     GROUP BY hs.story_id
 ) hf ON hf.story_id = s2.id
 WHERE stories.id = s2.id
 `
 
-func (q *Queries) RecalculateStoryScores(ctx context.Context) (int64, error) {
-	result, err := q.db.Exec(ctx, recalculateStoryScores)
+type RecalculateStoryScoresParams struct {
+	Incremental    bool
+	RescoreBefore  pgtype.Timestamptz
+	MaxPercent     int32
+	WeighFlaggers  bool
+	TrustedPercent int32
+	NewBefore      pgtype.Timestamptz
+	NewPercent     int32
+	TrustedBefore  pgtype.Timestamptz
+	TrustedKarma   int32
+	Reasons        []string
+	Weights        []int32
+}
+
+// Flags are weighed the same way as in RecalculateStoryDownvotes. With
+// @incremental set, only stories with vote, flag or hide activity since
+// they were last recalculated are recounted, plus any last recounted
+// before @rescore_before. Flag weights depend on the flagger's age, karma
+// and standing, which change without any story activity, so incremental
+// counts drift from a full run until the story is recounted again. Each
+// branch of stale stands alone so the incremental ones can use
+// stories_score_stale_idx and stories_scored_at_idx.
+func (q *Queries) RecalculateStoryScores(ctx context.Context, arg RecalculateStoryScoresParams) (int64, error) {
+	result, err := q.db.Exec(ctx, recalculateStoryScores,
+		arg.Incremental,
+		arg.RescoreBefore,
+		arg.MaxPercent,
+		arg.WeighFlaggers,
+		arg.TrustedPercent,
+		arg.NewBefore,
+		arg.NewPercent,
+		arg.TrustedBefore,
+		arg.TrustedKarma,
+		arg.Reasons,
+		arg.Weights,
+	)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected(), nil
 }
 
+const resetStoryScore = `-- name: ResetStoryScore :one
+UPDATE stories SET admin_adjustment = $1::int - (upvotes - downvotes)
+WHERE id = $2
+RETURNING admin_adjustment
+`
+
+type ResetStoryScoreParams struct {
+	Baseline int32
+	ID       int64
+}
+
+// Sets the adjustment so the story's current score equals @baseline.
+func (q *Queries) ResetStoryScore(ctx context.Context, arg ResetStoryScoreParams) (int32, error) {
+	row := q.db.QueryRow(ctx, resetStoryScore, arg.Baseline, arg.ID)
+	var admin_adjustment int32
+	err := row.Scan(&admin_adjustment)
+	return admin_adjustment, err
+}
+
+const restoreStoryStats = `-- name: RestoreStoryStats :exec
+UPDATE stories
+SET upvotes = $1, downvotes = $2,
+    imported_upvotes = $1, imported_downvotes = $2,
+    created_at = $3, updated_at = $3
+WHERE id = $4
+`
+
+type RestoreStoryStatsParams struct {
+	Upvotes   int32
+	Downvotes int32
+	CreatedAt pgtype.Timestamptz
+	ID        int64
+}
+
+// Carries scores and the original timestamp over to an imported story.
+// The scores are kept as a baseline that vote recounts add to, since the
+// votes behind them weren't imported.
+func (q *Queries) RestoreStoryStats(ctx context.Context, arg RestoreStoryStatsParams) error {
+	_, err := q.db.Exec(ctx, restoreStoryStats,
+		arg.Upvotes,
+		arg.Downvotes,
+		arg.CreatedAt,
+		arg.ID,
+	)
+	return err
+}
+
 const setStoryUpvotes = `-- name: SetStoryUpvotes :exec
-UPDATE stories SET upvotes = $1 WHERE id = $2
+UPDATE stories SET upvotes = $1, score_activity_at = now() WHERE id = $2
 `
 
 type SetStoryUpvotesParams struct {
@@ -497,6 +1008,33 @@ func (q *Queries) SetStoryUpvotes(ctx context.Context, arg SetStoryUpvotesParams
 	return err
 }
 
+const softDeleteStories = `-- name: SoftDeleteStories :many
+UPDATE stories SET deleted_at = now(), updated_at = now()
+WHERE id = ANY($1::bigint[])
+  AND deleted_at IS NULL
+RETURNING id
+`
+
+func (q *Queries) SoftDeleteStories(ctx context.Context, ids []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, softDeleteStories, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const softDeleteStory = `-- name: SoftDeleteStory :exec
 UPDATE stories SET deleted_at = now(), updated_at = now() WHERE id = $1
 `
@@ -506,6 +1044,34 @@ func (q *Queries) SoftDeleteStory(ctx context.Context, id int64) error {
 	return err
 }
 
+const unhideStories = `-- name: UnhideStories :many
+UPDATE stories SET hidden_at = NULL, updated_at = now()
+WHERE id = ANY($1::bigint[])
+  AND hidden_at IS NOT NULL
+RETURNING id
+`
+
+// Puts stories hidden by a moderator back into listings.
+func (q *Queries) UnhideStories(ctx context.Context, ids []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, unhideStories, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const unmarkStoryDuplicate = `-- name: UnmarkStoryDuplicate :exec
 UPDATE stories SET duplicate_of_id = NULL, updated_at = now() WHERE id = $1
 `
@@ -515,6 +1081,24 @@ func (q *Queries) UnmarkStoryDuplicate(ctx context.Context, id int64) error {
 	return err
 }
 
+const unpickStory = `-- name: UnpickStory :exec
+UPDATE stories SET picked_at = NULL, updated_at = now() WHERE id = $1
+`
+
+func (q *Queries) UnpickStory(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, unpickStory, id)
+	return err
+}
+
+const unpinStory = `-- name: UnpinStory :exec
+UPDATE stories SET pinned_until = NULL, updated_at = now() WHERE id = $1
+`
+
+func (q *Queries) UnpinStory(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, unpinStory, id)
+	return err
+}
+
 const updateStoryBody = `-- name: UpdateStoryBody :exec
 UPDATE stories SET body = $1, updated_at = now() WHERE id = $2
 `
@@ -529,6 +1113,21 @@ func (q *Queries) UpdateStoryBody(ctx context.Context, arg UpdateStoryBodyParams
 	return err
 }
 
+const updateStoryCanonicalURL = `-- name: UpdateStoryCanonicalURL :exec
+UPDATE stories SET canonical_url = $1, normalized_canonical_url = $2, updated_at = now() WHERE id = $3
+`
+
+type UpdateStoryCanonicalURLParams struct {
+	CanonicalUrl           pgtype.Text
+	NormalizedCanonicalUrl pgtype.Text
+	ID                     int64
+}
+
+func (q *Queries) UpdateStoryCanonicalURL(ctx context.Context, arg UpdateStoryCanonicalURLParams) error {
+	_, err := q.db.Exec(ctx, updateStoryCanonicalURL, arg.CanonicalUrl, arg.NormalizedCanonicalUrl, arg.ID)
+	return err
+}
+
 const updateStoryTitle = `-- name: UpdateStoryTitle :exec
 UPDATE stories SET title = $1, updated_at = now() WHERE id = $2
 `
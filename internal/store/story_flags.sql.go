@@ -7,22 +7,59 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const clearStoryFlags = `-- name: ClearStoryFlags :many
+WITH del AS (
+    DELETE FROM story_flags
+    WHERE story_id = ANY($1::bigint[])
+    RETURNING story_id
 )
+SELECT DISTINCT story_id FROM del
+`
+
+func (q *Queries) ClearStoryFlags(ctx context.Context, storyIds []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, clearStoryFlags, storyIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var story_id int64
+		if err := rows.Scan(&story_id); err != nil {
+			return nil, err
+		}
+		items = append(items, story_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
 const createStoryFlag = `-- name: CreateStoryFlag :exec
-INSERT INTO story_flags (user_id, story_id, reason)
-VALUES ($1, $2, $3)
+INSERT INTO story_flags (user_id, story_id, reason, original_story_id)
+VALUES ($1, $2, $3, $4)
 ON CONFLICT DO NOTHING
 `
 
 type CreateStoryFlagParams struct {
-	UserID  int64
-	StoryID int64
-	Reason  string
+	UserID          int64
+	StoryID         int64
+	Reason          string
+	OriginalStoryID pgtype.Int8
 }
 
 func (q *Queries) CreateStoryFlag(ctx context.Context, arg CreateStoryFlagParams) error {
-	_, err := q.db.Exec(ctx, createStoryFlag, arg.UserID, arg.StoryID, arg.Reason)
+	_, err := q.db.Exec(ctx, createStoryFlag,
+		arg.UserID,
+		arg.StoryID,
+		arg.Reason,
+		arg.OriginalStoryID,
+	)
 	return err
 }
 
@@ -74,6 +111,40 @@ func (q *Queries) GetStoryFlagCounts(ctx context.Context, storyID int64) ([]GetS
 	return items, nil
 }
 
+const getStoryFlagCountsByStories = `-- name: GetStoryFlagCountsByStories :many
+SELECT story_id, reason, count(*)::int AS count
+FROM story_flags
+WHERE story_id = ANY($1::bigint[])
+GROUP BY story_id, reason
+ORDER BY story_id, count DESC
+`
+
+type GetStoryFlagCountsByStoriesRow struct {
+	StoryID int64
+	Reason  string
+	Count   int32
+}
+
+func (q *Queries) GetStoryFlagCountsByStories(ctx context.Context, storyIds []int64) ([]GetStoryFlagCountsByStoriesRow, error) {
+	rows, err := q.db.Query(ctx, getStoryFlagCountsByStories, storyIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStoryFlagCountsByStoriesRow
+	for rows.Next() {
+		var i GetStoryFlagCountsByStoriesRow
+		if err := rows.Scan(&i.StoryID, &i.Reason, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserStoryFlags = `-- name: GetUserStoryFlags :many
 SELECT story_id
 FROM story_flags
@@ -105,22 +176,127 @@ func (q *Queries) GetUserStoryFlags(ctx context.Context, arg GetUserStoryFlagsPa
 	return items, nil
 }
 
+const listFlaggedStories = `-- name: ListFlaggedStories :many
+SELECT
+    s.id,
+    s.short_code,
+    s.title,
+    u.username,
+    s.created_at,
+    count(*)::int AS flag_count,
+    array_agg(DISTINCT sf.reason ORDER BY sf.reason)::text[] AS reasons,
+    coalesce(o.short_codes, '{}')::text[] AS original_short_codes,
+    coalesce(o.titles, '{}')::text[] AS original_titles
+FROM story_flags AS sf
+JOIN stories AS s ON s.id = sf.story_id
+JOIN users AS u ON u.id = s.user_id
+LEFT JOIN LATERAL (
+    SELECT array_agg(os.short_code ORDER BY os.short_code) AS short_codes,
+           array_agg(os.title ORDER BY os.short_code) AS titles
+    FROM stories AS os
+    WHERE os.deleted_at IS NULL
+      AND os.id IN (SELECT f.original_story_id FROM story_flags AS f WHERE f.story_id = s.id)
+) AS o ON true
+WHERE s.deleted_at IS NULL
+  AND s.hidden_at IS NULL
+GROUP BY s.id, u.username, o.short_codes, o.titles
+ORDER BY flag_count DESC, s.created_at DESC
+LIMIT $1
+`
+
+type ListFlaggedStoriesRow struct {
+	ID                 int64
+	ShortCode          string
+	Title              string
+	Username           string
+	CreatedAt          pgtype.Timestamptz
+	FlagCount          int32
+	Reasons            []string
+	OriginalShortCodes []string
+	OriginalTitles     []string
+}
+
+// The moderation queue: live, unhidden stories with flags, most flagged first, with
+// the originals named by "already posted" flags.
+func (q *Queries) ListFlaggedStories(ctx context.Context, storyLimit int32) ([]ListFlaggedStoriesRow, error) {
+	rows, err := q.db.Query(ctx, listFlaggedStories, storyLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFlaggedStoriesRow
+	for rows.Next() {
+		var i ListFlaggedStoriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShortCode,
+			&i.Title,
+			&i.Username,
+			&i.CreatedAt,
+			&i.FlagCount,
+			&i.Reasons,
+			&i.OriginalShortCodes,
+			&i.OriginalTitles,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const recalculateStoryDownvotes = `-- name: RecalculateStoryDownvotes :exec
-UPDATE stories SET downvotes = (
-    SELECT count(*)
+UPDATE stories SET score_activity_at = now(), downvotes = imported_downvotes + (
+    SELECT (coalesce(sum(least(coalesce(w.weight, 1) * ft.percent, $1::int)), 0) / 100)::int
     FROM hidden_stories hs
     JOIN story_flags sf ON sf.user_id = hs.user_id AND sf.story_id = hs.story_id
-    WHERE hs.story_id = $1
+    JOIN users u ON u.id = sf.user_id
+    CROSS JOIN LATERAL flagger_trust_percent(
+        u, $2::bool, $3::int, $4::timestamptz,
+        $5::int, $6::timestamptz, $7::int
+    ) AS ft(percent)
+    LEFT JOIN unnest($8::text[], $9::int[]) AS w(reason, weight) ON w.reason = sf.reason
+    WHERE hs.story_id = $10
       AND NOT EXISTS (
           SELECT 1 FROM comments c
-          WHERE c.story_id = $1 AND c.user_id = hs.user_id AND c.deleted_at IS NULL
+          WHERE c.story_id = $10 AND c.user_id = hs.user_id AND c.deleted_at IS NULL
       )
 )
-WHERE id = $1
+WHERE id = $10
 `
 
-// Count users who hid AND flagged this story AND have no comments on it
-func (q *Queries) RecalculateStoryDownvotes(ctx context.Context, storyID int64) error {
-	_, err := q.db.Exec(ctx, recalculateStoryDownvotes, storyID)
+type RecalculateStoryDownvotesParams struct {
+	MaxPercent     int32
+	WeighFlaggers  bool
+	TrustedPercent int32
+	NewBefore      pgtype.Timestamptz
+	NewPercent     int32
+	TrustedBefore  pgtype.Timestamptz
+	TrustedKarma   int32
+	Reasons        []string
+	Weights        []int32
+	StoryID        int64
+}
+
+// Sum the flag-reason weights of users who hid AND flagged this story AND
+// have no comments on it. Reasons missing from the weight list count as 1.
+// Each flag is scaled by the flagger's trust percentage and capped at
+// @max_percent before the total is turned back into whole downvotes.
+func (q *Queries) RecalculateStoryDownvotes(ctx context.Context, arg RecalculateStoryDownvotesParams) error {
+	_, err := q.db.Exec(ctx, recalculateStoryDownvotes,
+		arg.MaxPercent,
+		arg.WeighFlaggers,
+		arg.TrustedPercent,
+		arg.NewBefore,
+		arg.NewPercent,
+		arg.TrustedBefore,
+		arg.TrustedKarma,
+		arg.Reasons,
+		arg.Weights,
+		arg.StoryID,
+	)
 	return err
 }
@@ -29,6 +29,30 @@ func (q *Queries) CreateCategory(ctx context.Context, name string) (Category, er
 	return i, err
 }
 
+const createTagSynonym = `-- name: CreateTagSynonym :exec
+INSERT INTO tag_synonyms (tag_id, synonym)
+VALUES ($1, $2)
+`
+
+type CreateTagSynonymParams struct {
+	TagID   int64
+	Synonym string
+}
+
+func (q *Queries) CreateTagSynonym(ctx context.Context, arg CreateTagSynonymParams) error {
+	_, err := q.db.Exec(ctx, createTagSynonym, arg.TagID, arg.Synonym)
+	return err
+}
+
+const deleteTagSynonyms = `-- name: DeleteTagSynonyms :exec
+DELETE FROM tag_synonyms WHERE tag_id = $1
+`
+
+func (q *Queries) DeleteTagSynonyms(ctx context.Context, tagID int64) error {
+	_, err := q.db.Exec(ctx, deleteTagSynonyms, tagID)
+	return err
+}
+
 const getCategoryByName = `-- name: GetCategoryByName :one
 SELECT id, name, created_at, updated_at
 FROM categories
@@ -49,7 +73,7 @@ func (q *Queries) GetCategoryByName(ctx context.Context, name string) (Category,
 }
 
 const getTagByName = `-- name: GetTagByName :one
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
 WHERE lower(tag) = lower($1)
   AND active = true
@@ -70,12 +94,13 @@ func (q *Queries) GetTagByName(ctx context.Context, tag string) (Tag, error) {
 		&i.HotnessMod,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DescriptionEditedByModerator,
 	)
 	return i, err
 }
 
 const getTagsByIDs = `-- name: GetTagsByIDs :many
-SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at
+SELECT id, tag, description, category_id, privileged, is_media, active, hotness_mod, created_at, updated_at, description_edited_by_moderator
 FROM tags
 WHERE id = ANY($1::bigint[])
   AND active = true
@@ -101,6 +126,7 @@ func (q *Queries) GetTagsByIDs(ctx context.Context, ids []int64) ([]Tag, error)
 			&i.HotnessMod,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DescriptionEditedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -159,12 +185,56 @@ func (q *Queries) ListActiveTagsWithCategory(ctx context.Context) ([]ListActiveT
 	return items, nil
 }
 
+const listTagSynonyms = `-- name: ListTagSynonyms :many
+SELECT synonym
+FROM tag_synonyms
+WHERE tag_id = $1
+ORDER BY lower(synonym)
+`
+
+func (q *Queries) ListTagSynonyms(ctx context.Context, tagID int64) ([]string, error) {
+	rows, err := q.db.Query(ctx, listTagSynonyms, tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var synonym string
+		if err := rows.Scan(&synonym); err != nil {
+			return nil, err
+		}
+		items = append(items, synonym)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateTagDescription = `-- name: UpdateTagDescription :exec
+UPDATE tags
+SET description = $1, description_edited_by_moderator = true, updated_at = now()
+WHERE id = $2
+`
+
+type UpdateTagDescriptionParams struct {
+	Description string
+	ID          int64
+}
+
+// Marks the description as the moderators', so seeding no longer touches it.
+func (q *Queries) UpdateTagDescription(ctx context.Context, arg UpdateTagDescriptionParams) error {
+	_, err := q.db.Exec(ctx, updateTagDescription, arg.Description, arg.ID)
+	return err
+}
+
 const upsertTag = `-- name: UpsertTag :exec
 INSERT INTO tags (tag, description, category_id, privileged, is_media)
 VALUES ($1, $2, $3, $4, $5)
 ON CONFLICT ((lower(tag)))
 DO UPDATE SET
-  description = EXCLUDED.description,
+  description = CASE WHEN tags.description_edited_by_moderator THEN tags.description ELSE EXCLUDED.description END,
   category_id = EXCLUDED.category_id,
   privileged = EXCLUDED.privileged,
   is_media = EXCLUDED.is_media,
@@ -180,6 +250,7 @@ type UpsertTagParams struct {
 	IsMedia     bool
 }
 
+// A description a moderator has edited is kept; the seed owns the rest.
 func (q *Queries) UpsertTag(ctx context.Context, arg UpsertTagParams) error {
 	_, err := q.db.Exec(ctx, upsertTag,
 		arg.Tag,
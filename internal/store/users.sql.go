@@ -95,6 +95,7 @@ SELECT
     u.about,
     u.website,
     u.is_moderator,
+    u.last_seen_at,
     u.created_at,
     (SELECT count(*) FROM stories s WHERE s.user_id = u.id AND s.deleted_at IS NULL)::bigint AS story_count,
     inviter.username AS inviter_name
@@ -111,6 +112,7 @@ type GetPublicProfileRow struct {
 	About       string
 	Website     string
 	IsModerator bool
+	LastSeenAt  pgtype.Timestamptz
 	CreatedAt   pgtype.Timestamptz
 	StoryCount  int64
 	InviterName pgtype.Text
@@ -124,6 +126,7 @@ func (q *Queries) GetPublicProfile(ctx context.Context, username string) (GetPub
 		&i.About,
 		&i.Website,
 		&i.IsModerator,
+		&i.LastSeenAt,
 		&i.CreatedAt,
 		&i.StoryCount,
 		&i.InviterName,
@@ -132,7 +135,7 @@ func (q *Queries) GetPublicProfile(ctx context.Context, username string) (GetPub
 }
 
 const getUserByEmailConfirmationTokenHash = `-- name: GetUserByEmailConfirmationTokenHash :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE email_confirmation_token_hash = $1
   AND email_confirmation_token_created_at > now() - INTERVAL '24 hours'
@@ -160,6 +163,9 @@ func (q *Queries) GetUserByEmailConfirmationTokenHash(ctx context.Context, email
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
@@ -167,7 +173,7 @@ func (q *Queries) GetUserByEmailConfirmationTokenHash(ctx context.Context, email
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE id = $1
 LIMIT 1
@@ -194,6 +200,9 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
@@ -201,7 +210,7 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 }
 
 const getUserByLogin = `-- name: GetUserByLogin :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE (lower(email) = lower($1) AND email_confirmed_at IS NOT NULL)
    OR lower(username) = lower($1)
@@ -230,6 +239,9 @@ func (q *Queries) GetUserByLogin(ctx context.Context, login string) (User, error
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
@@ -237,7 +249,7 @@ func (q *Queries) GetUserByLogin(ctx context.Context, login string) (User, error
 }
 
 const getUserByPasswordResetTokenHash = `-- name: GetUserByPasswordResetTokenHash :one
-SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, created_at, updated_at
+SELECT id, username, email, password_digest, is_moderator, banned_at, deleted_at, inviter_id, campaign, password_reset_token_hash, password_reset_token_created_at, email_confirmed_at, email_confirmation_token_hash, email_confirmation_token_created_at, unconfirmed_email, website, about, slogan, last_seen_at, enable_embeds, created_at, updated_at
 FROM users
 WHERE password_reset_token_hash = $1
   AND password_reset_token_created_at > now() - INTERVAL '24 hours'
@@ -265,12 +277,106 @@ func (q *Queries) GetUserByPasswordResetTokenHash(ctx context.Context, passwordR
 		&i.UnconfirmedEmail,
 		&i.Website,
 		&i.About,
+		&i.Slogan,
+		&i.LastSeenAt,
+		&i.EnableEmbeds,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const getUserIDByUsername = `-- name: GetUserIDByUsername :one
+SELECT id FROM users WHERE lower(username) = lower($1) LIMIT 1
+`
+
+func (q *Queries) GetUserIDByUsername(ctx context.Context, username string) (int64, error) {
+	row := q.db.QueryRow(ctx, getUserIDByUsername, username)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getUserKarmas = `-- name: GetUserKarmas :many
+SELECT
+    u.id,
+    u.is_moderator,
+    ((SELECT coalesce(sum(s.upvotes), 0) FROM stories s WHERE s.user_id = u.id AND s.deleted_at IS NULL)
+      + (SELECT coalesce(sum(c.upvotes), 0) FROM comments c WHERE c.user_id = u.id AND c.deleted_at IS NULL))::int AS karma
+FROM users u
+WHERE u.id = ANY($1::bigint[])
+`
+
+type GetUserKarmasRow struct {
+	ID          int64
+	IsModerator bool
+	Karma       int32
+}
+
+// Karma is the upvotes a user's live stories and comments have received.
+func (q *Queries) GetUserKarmas(ctx context.Context, userIds []int64) ([]GetUserKarmasRow, error) {
+	rows, err := q.db.Query(ctx, getUserKarmas, userIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserKarmasRow
+	for rows.Next() {
+		var i GetUserKarmasRow
+		if err := rows.Scan(&i.ID, &i.IsModerator, &i.Karma); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserTombstone = `-- name: GetUserTombstone :one
+SELECT username, banned_at, deleted_at
+FROM users
+WHERE lower(username) = lower($1)
+  AND (banned_at IS NOT NULL OR deleted_at IS NOT NULL)
+LIMIT 1
+`
+
+type GetUserTombstoneRow struct {
+	Username  string
+	BannedAt  pgtype.Timestamptz
+	DeletedAt pgtype.Timestamptz
+}
+
+// Looks up a banned or deleted account so its profile can say so.
+func (q *Queries) GetUserTombstone(ctx context.Context, username string) (GetUserTombstoneRow, error) {
+	row := q.db.QueryRow(ctx, getUserTombstone, username)
+	var i GetUserTombstoneRow
+	err := row.Scan(&i.Username, &i.BannedAt, &i.DeletedAt)
+	return i, err
+}
+
+const hasUsers = `-- name: HasUsers :one
+SELECT EXISTS(SELECT 1 FROM users) AS exists
+`
+
+func (q *Queries) HasUsers(ctx context.Context) (bool, error) {
+	row := q.db.QueryRow(ctx, hasUsers)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const lockUser = `-- name: LockUser :exec
+SELECT id FROM users WHERE id = $1 FOR UPDATE
+`
+
+// Take the user's row lock so their quota-limited writes apply one at a time.
+func (q *Queries) LockUser(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, lockUser, id)
+	return err
+}
+
 const setEmailChangeConfirmationToken = `-- name: SetEmailChangeConfirmationToken :exec
 UPDATE users
 SET email_confirmation_token_hash = $1,
@@ -327,6 +433,17 @@ func (q *Queries) SetPasswordResetTokenHash(ctx context.Context, arg SetPassword
 	return err
 }
 
+const touchUserLastSeen = `-- name: TouchUserLastSeen :exec
+UPDATE users
+SET last_seen_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) TouchUserLastSeen(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, touchUserLastSeen, id)
+	return err
+}
+
 const updateUserEmail = `-- name: UpdateUserEmail :exec
 UPDATE users
 SET email = $1, updated_at = now()
@@ -361,17 +478,52 @@ func (q *Queries) UpdateUserPasswordByID(ctx context.Context, arg UpdateUserPass
 
 const updateUserProfile = `-- name: UpdateUserProfile :exec
 UPDATE users
-SET website = $1, about = $2, updated_at = now()
-WHERE id = $3
+SET website = $1, about = $2, slogan = $3, enable_embeds = $4, updated_at = now()
+WHERE id = $5
 `
 
 type UpdateUserProfileParams struct {
-	Website string
-	About   string
-	ID      int64
+	Website      string
+	About        string
+	Slogan       string
+	EnableEmbeds bool
+	ID           int64
 }
 
 func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error {
-	_, err := q.db.Exec(ctx, updateUserProfile, arg.Website, arg.About, arg.ID)
+	_, err := q.db.Exec(ctx, updateUserProfile,
+		arg.Website,
+		arg.About,
+		arg.Slogan,
+		arg.EnableEmbeds,
+		arg.ID,
+	)
 	return err
 }
+
+const createInitialAdmin = `-- name: CreateInitialAdmin :one
+INSERT INTO users (username, email, password_digest, is_moderator, email_confirmed_at)
+SELECT $1, $2, $3, true, now()
+WHERE NOT EXISTS (SELECT 1 FROM users)
+RETURNING id, username, email
+`
+
+type CreateInitialAdminParams struct {
+	Username       string
+	Email          string
+	PasswordDigest string
+}
+
+type CreateInitialAdminRow struct {
+	ID       int64
+	Username string
+	Email    string
+}
+
+// Inserts nothing, and so returns no row, once any user exists.
+func (q *Queries) CreateInitialAdmin(ctx context.Context, arg CreateInitialAdminParams) (CreateInitialAdminRow, error) {
+	row := q.db.QueryRow(ctx, createInitialAdmin, arg.Username, arg.Email, arg.PasswordDigest)
+	var i CreateInitialAdminRow
+	err := row.Scan(&i.ID, &i.Username, &i.Email)
+	return i, err
+}
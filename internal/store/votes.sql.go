@@ -7,6 +7,8 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createVote = `-- name: CreateVote :one
@@ -16,7 +18,7 @@ WITH ins AS (
     ON CONFLICT DO NOTHING
     RETURNING story_id
 )
-UPDATE stories SET upvotes = upvotes + (SELECT count(*) FROM ins)::int
+UPDATE stories SET upvotes = upvotes + (SELECT count(*) FROM ins)::int, score_activity_at = now()
 WHERE id = $1
 RETURNING upvotes
 `
@@ -39,7 +41,7 @@ WITH del AS (
     WHERE votes.user_id = $2 AND votes.story_id = $1
     RETURNING story_id
 )
-UPDATE stories SET upvotes = upvotes - (SELECT count(*) FROM del)::int
+UPDATE stories SET upvotes = upvotes - (SELECT count(*) FROM del)::int, score_activity_at = now()
 WHERE id = $1
 RETURNING upvotes
 `
@@ -56,6 +58,47 @@ func (q *Queries) DeleteVote(ctx context.Context, arg DeleteVoteParams) (int32,
 	return upvotes, err
 }
 
+const exportUserVotes = `-- name: ExportUserVotes :many
+SELECT
+    s.short_code AS story_short_code,
+    s.title AS story_title,
+    v.created_at
+FROM votes AS v
+JOIN stories AS s ON s.id = v.story_id
+WHERE v.user_id = $1
+ORDER BY v.created_at, v.story_id
+`
+
+type ExportUserVotesRow struct {
+	StoryShortCode string
+	StoryTitle     string
+	CreatedAt      pgtype.Timestamptz
+}
+
+func (q *Queries) ExportUserVotes(ctx context.Context, userID int64) ([]ExportUserVotesRow, error) {
+	rows, err := q.db.Query(ctx, exportUserVotes, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserVotesRow
+	for rows.Next() {
+		var i ExportUserVotesRow
+		if err := rows.Scan(
+			&i.StoryShortCode,
+			&i.StoryTitle,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserVotes = `-- name: GetUserVotes :many
 SELECT story_id
 FROM votes
@@ -0,0 +1,263 @@
+// Package storyio holds the story plumbing shared by the seed, export and
+// import tools: resolving links to domains and origins, and moving stories
+// between instances as JSON.
+package storyio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
+)
+
+// Story is the exported form of a story.
+type Story struct {
+	ShortCode string    `json:"short_code"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Username  string    `json:"username"`
+	Tags      []string  `json:"tags"`
+	Upvotes   int32     `json:"upvotes"`
+	Downvotes int32     `json:"downvotes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Authors decides who owns imported stories. A username on another
+// instance says nothing about who holds it here, so only usernames listed
+// in Map go to the local user they name; all other stories go to Fallback.
+type Authors struct {
+	Map      map[string]string // exported username -> local username
+	Fallback store.User
+}
+
+// ReadAuthors decodes an author mapping: a JSON object from exported
+// usernames to local ones.
+func ReadAuthors(r io.Reader) (map[string]string, error) {
+	var m map[string]string
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ErrExists is returned by Import when a story with the same short code is
+// already present, so re-running an import is harmless.
+var ErrExists = errors.New("story already exists")
+
+// Link is a cleaned story URL with its domain and origin rows.
+type Link struct {
+	Cleaned    string
+	Normalized string
+	DomainID   int64
+	OriginID   pgtype.Int8
+}
+
+// ResolveLink cleans rawURL under policy and gets or creates its domain and
+// origin.
+func ResolveLink(ctx context.Context, q *store.Queries, policy link.Config, rawURL string) (Link, error) {
+	result, err := policy.Clean(rawURL)
+	if err != nil {
+		return Link{}, err
+	}
+
+	domain, err := q.GetOrCreateDomain(ctx, result.Domain)
+	if err != nil {
+		return Link{}, fmt.Errorf("domain %q: %w", result.Domain, err)
+	}
+
+	l := Link{Cleaned: result.Cleaned, Normalized: result.Normalized, DomainID: domain.ID}
+	if result.Origin != "" {
+		origin, err := q.GetOrCreateOrigin(ctx, store.GetOrCreateOriginParams{DomainID: domain.ID, Origin: result.Origin})
+		if err != nil {
+			return Link{}, fmt.Errorf("origin %q: %w", result.Origin, err)
+		}
+		l.OriginID = pgtype.Int8{Int64: origin.ID, Valid: true}
+	}
+	return l, nil
+}
+
+// Apply fills the link columns of a new story.
+func (l Link) Apply(p *store.CreateStoryParams) {
+	p.DomainID = pgtype.Int8{Int64: l.DomainID, Valid: true}
+	p.OriginID = l.OriginID
+	p.Url = pgtype.Text{String: l.Cleaned, Valid: true}
+	p.NormalizedUrl = pgtype.Text{String: l.Normalized, Valid: true}
+}
+
+// Count bumps the story counters of the link's domain and origin.
+func (l Link) Count(ctx context.Context, q *store.Queries) error {
+	if err := q.IncrementDomainStoryCount(ctx, l.DomainID); err != nil {
+		return err
+	}
+	if l.OriginID.Valid {
+		return q.IncrementOriginStoryCount(ctx, l.OriginID.Int64)
+	}
+	return nil
+}
+
+// SeedUser returns the user called username, creating it with an unusable
+// password if it doesn't exist yet.
+func SeedUser(ctx context.Context, q *store.Queries, username string) (store.User, error) {
+	u, err := q.GetUserByLogin(ctx, username)
+	if err == nil {
+		return u, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return store.User{}, err
+	}
+
+	row, err := q.CreateUser(ctx, store.CreateUserParams{
+		Username:       username,
+		Email:          username + "@localhost",
+		PasswordDigest: "!", // unusable password
+	})
+	if err != nil {
+		return store.User{}, err
+	}
+	return store.User{
+		ID:       row.ID,
+		Username: row.Username,
+		Email:    row.Email,
+	}, nil
+}
+
+// Export returns every story that hasn't been deleted, oldest first.
+func Export(ctx context.Context, q *store.Queries) ([]Story, error) {
+	rows, err := q.ExportStories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stories := make([]Story, 0, len(rows))
+	for _, r := range rows {
+		stories = append(stories, Story{
+			ShortCode: r.ShortCode,
+			Title:     r.Title,
+			URL:       r.Url.String,
+			Body:      r.Body.String,
+			Username:  r.Username,
+			Tags:      r.Tags,
+			Upvotes:   r.Upvotes,
+			Downvotes: r.Downvotes,
+			CreatedAt: r.CreatedAt.Time,
+		})
+	}
+	return stories, nil
+}
+
+// Import creates s, keeping its short code, scores and created_at. The
+// story is attributed as authors says. Tags that don't exist on this
+// instance are skipped and returned so the caller can report them. Links
+// must pass policy, the same as links submitted on the site.
+func Import(ctx context.Context, q *store.Queries, policy link.Config, s Story, authors Authors) (missingTags []string, err error) {
+	code := s.ShortCode
+	if code == "" {
+		code = link.ShortCode(link.DefaultShortCodeLength)
+	} else if _, err := q.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: code, Valid: true}}); err == nil {
+		return nil, ErrExists
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	userID := authors.Fallback.ID
+	if local, ok := authors.Map[s.Username]; ok && s.Username != "" {
+		id, err := q.GetUserIDByUsername(ctx, local)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("author %q is mapped to unknown user %q", s.Username, local)
+		}
+		if err != nil {
+			return nil, err
+		}
+		userID = id
+	}
+
+	params := store.CreateStoryParams{
+		UserID:    userID,
+		Title:     s.Title,
+		ShortCode: code,
+	}
+	var l Link
+	if s.URL != "" {
+		l, err = ResolveLink(ctx, q, policy, s.URL)
+		if err != nil {
+			return nil, err
+		}
+		l.Apply(&params)
+	}
+	if s.Body != "" || s.URL == "" {
+		params.Body = pgtype.Text{String: s.Body, Valid: true}
+	}
+
+	story, err := q.CreateStory(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if s.URL != "" {
+		if err := l.Count(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+
+	createdAt := s.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if err := q.RestoreStoryStats(ctx, store.RestoreStoryStatsParams{
+		Upvotes:   s.Upvotes,
+		Downvotes: s.Downvotes,
+		CreatedAt: pgtype.Timestamptz{Time: createdAt, Valid: true},
+		ID:        story.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(s.Tags) == 0 {
+		return nil, nil
+	}
+	names := make([]string, len(s.Tags))
+	for i, t := range s.Tags {
+		names[i] = strings.ToLower(t)
+	}
+	tags, err := q.GetTagsByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		found[strings.ToLower(t.Tag)] = true
+		if err := q.CreateTagging(ctx, store.CreateTaggingParams{StoryID: story.ID, TagID: t.ID}); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range s.Tags {
+		if !found[strings.ToLower(t)] {
+			missingTags = append(missingTags, t)
+		}
+	}
+	return missingTags, nil
+}
+
+// Write encodes stories as indented JSON.
+func Write(w io.Writer, stories []Story) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stories)
+}
+
+// Read decodes stories written by Write.
+func Read(r io.Reader) ([]Story, error) {
+	var stories []Story
+	if err := json.NewDecoder(r).Decode(&stories); err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
@@ -0,0 +1,199 @@
+package storyio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"crow.watch/internal/link"
+	"crow.watch/internal/store"
+)
+
+var fixture = []Story{
+	{
+		ShortCode: "abc123",
+		Title:     "A link",
+		URL:       "https://example.com/post",
+		Username:  "alice",
+		Tags:      []string{"go", "web"},
+		Upvotes:   12,
+		Downvotes: 1,
+		CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ShortCode: "def456",
+		Title:     "Ask: a text post",
+		Body:      "What do you think?",
+		Username:  "bob",
+		Tags:      []string{"ask"},
+		Upvotes:   3,
+		CreatedAt: time.Date(2025, 3, 2, 8, 30, 0, 0, time.UTC),
+	},
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, fixture))
+
+	got, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, fixture, got)
+}
+
+// testDB creates a throwaway schema from db/schema.sql in the database at
+// TEST_DATABASE_URL, skipping the test when it is not set.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	schema, err := os.ReadFile("../../db/schema.sql")
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	conn, err := pgx.Connect(ctx, url)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	return pool
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	for _, tag := range []string{"go", "ask"} {
+		_, err := pool.Exec(ctx, "INSERT INTO tags (tag) VALUES ($1)", tag)
+		require.NoError(t, err)
+	}
+	_, err := SeedUser(ctx, q, "alice")
+	require.NoError(t, err)
+	fallback, err := SeedUser(ctx, q, "importbot")
+	require.NoError(t, err)
+	authors := Authors{Map: map[string]string{"alice": "alice"}, Fallback: fallback}
+
+	missing, err := Import(ctx, q, link.Config{}, fixture[0], authors)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, missing)
+	missing, err = Import(ctx, q, link.Config{}, fixture[1], authors)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	_, err = Import(ctx, q, link.Config{}, fixture[0], authors)
+	assert.ErrorIs(t, err, ErrExists)
+
+	insecure := Story{ShortCode: "ghi789", Title: "Plain http", URL: "http://example.com/insecure"}
+	_, err = Import(ctx, q, link.Config{RequireHTTPS: true}, insecure, authors)
+	var ve *link.ValidationError
+	assert.ErrorAs(t, err, &ve, "imports follow the link policy")
+
+	got, err := Export(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+
+	assert.Equal(t, "A link", got[0].Title)
+	assert.Equal(t, "https://example.com/post", got[0].URL)
+	assert.Equal(t, []string{"go"}, got[0].Tags)
+	assert.Equal(t, "alice", got[0].Username)
+	assert.Equal(t, int32(12), got[0].Upvotes)
+	assert.True(t, got[0].CreatedAt.Equal(fixture[0].CreatedAt))
+
+	assert.Equal(t, "Ask: a text post", got[1].Title)
+	assert.Equal(t, "What do you think?", got[1].Body)
+	assert.Equal(t, []string{"ask"}, got[1].Tags)
+	assert.Equal(t, "importbot", got[1].Username, "unmapped authors fall back")
+	assert.Equal(t, "def456", got[1].ShortCode)
+}
+
+func TestImportedScoresSurviveRecount(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	fallback, err := SeedUser(ctx, q, "importbot")
+	require.NoError(t, err)
+	_, err = Import(ctx, q, link.Config{}, fixture[1], Authors{Fallback: fallback})
+	require.NoError(t, err)
+	story, err := q.GetStory(ctx, store.GetStoryParams{ShortCode: pgtype.Text{String: "def456", Valid: true}})
+	require.NoError(t, err)
+
+	_, err = q.RecalculateStoryScores(ctx, store.RecalculateStoryScoresParams{MaxPercent: 100})
+	require.NoError(t, err)
+	got, err := Export(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, int32(3), got[0].Upvotes, "a full recount keeps the imported score")
+
+	voter, err := SeedUser(ctx, q, "carol")
+	require.NoError(t, err)
+	_, err = q.CreateVote(ctx, store.CreateVoteParams{UserID: voter.ID, StoryID: story.ID})
+	require.NoError(t, err)
+	_, err = q.RecalculateStoryScores(ctx, store.RecalculateStoryScoresParams{Incremental: true, MaxPercent: 100})
+	require.NoError(t, err)
+	got, err = Export(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), got[0].Upvotes, "local votes add to it")
+}
+
+func TestImportIgnoresMatchingUsernames(t *testing.T) {
+	pool := testDB(t)
+	ctx := context.Background()
+	q := store.New(pool)
+
+	_, err := SeedUser(ctx, q, "alice")
+	require.NoError(t, err)
+	fallback, err := SeedUser(ctx, q, "importbot")
+	require.NoError(t, err)
+
+	_, err = Import(ctx, q, link.Config{}, fixture[0], Authors{Fallback: fallback})
+	require.NoError(t, err)
+	got, err := Export(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "importbot", got[0].Username, "a local alice isn't assumed to be the same person")
+
+	_, err = Import(ctx, q, link.Config{}, fixture[1], Authors{Map: map[string]string{"bob": "nobody"}, Fallback: fallback})
+	assert.ErrorContains(t, err, `mapped to unknown user "nobody"`)
+}
+
+func TestReadAuthors(t *testing.T) {
+	m, err := ReadAuthors(strings.NewReader(`{"alice": "alice2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice": "alice2"}, m)
+
+	_, err = ReadAuthors(strings.NewReader(`["alice"]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+// Package viewcount keeps a lightweight, buffered count of story views.
+package viewcount
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxSeen bounds the per-day dedup set. When it fills up the set is
+// cleared, so counts stay approximate instead of growing memory.
+const maxSeen = 1 << 20
+
+// FlushFunc persists accumulated view increments. storyIDs and views are
+// parallel slices.
+type FlushFunc func(ctx context.Context, storyIDs []int64, views []int32) error
+
+type viewKey struct {
+	storyID int64
+	viewer  string
+}
+
+// Counter buffers story view increments in memory and writes them out in
+// batches. Each viewer counts at most once per story per UTC day.
+type Counter struct {
+	flush FlushFunc
+	log   *slog.Logger
+	now   func() time.Time
+
+	mu      sync.Mutex
+	day     string
+	seen    map[viewKey]struct{}
+	pending map[int64]int32
+}
+
+// New creates a Counter that hands buffered increments to flush.
+func New(flush FlushFunc, log *slog.Logger) *Counter {
+	return &Counter{
+		flush:   flush,
+		log:     log,
+		now:     time.Now,
+		seen:    make(map[viewKey]struct{}),
+		pending: make(map[int64]int32),
+	}
+}
+
+// Record counts a view of storyID by viewer unless that viewer has already
+// been counted for the story today. It reports whether the view was counted.
+func (c *Counter) Record(storyID int64, viewer string) bool {
+	if viewer == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	today := c.now().UTC().Format("2006-01-02")
+	if c.day != today || len(c.seen) >= maxSeen {
+		c.day = today
+		c.seen = make(map[viewKey]struct{})
+	}
+
+	key := viewKey{storyID: storyID, viewer: viewer}
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = struct{}{}
+	c.pending[storyID]++
+	return true
+}
+
+// Pending returns the number of counted views of storyID that have not
+// been flushed yet.
+func (c *Counter) Pending(storyID int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.pending[storyID])
+}
+
+// Flush writes all pending increments. On failure they are kept and
+// retried on the next flush.
+func (c *Counter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[int64]int32)
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	storyIDs := make([]int64, 0, len(batch))
+	views := make([]int32, 0, len(batch))
+	for id, n := range batch {
+		storyIDs = append(storyIDs, id)
+		views = append(views, n)
+	}
+
+	if err := c.flush(ctx, storyIDs, views); err != nil {
+		c.mu.Lock()
+		for id, n := range batch {
+			c.pending[id] += n
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes pending views every interval until stop is closed. Callers
+// should Flush once more after the server has stopped taking requests.
+func (c *Counter) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := c.Flush(ctx); err != nil {
+				c.log.Error("flush story views", "error", err)
+			}
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package viewcount
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	calls int
+	views map[int64]int32
+	err   error
+}
+
+func (r *recorder) flush(_ context.Context, storyIDs []int64, views []int32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+	if r.views == nil {
+		r.views = make(map[int64]int32)
+	}
+	for i, id := range storyIDs {
+		r.views[id] += views[i]
+	}
+	return nil
+}
+
+func TestRecord_DedupPerViewer(t *testing.T) {
+	c := New((&recorder{}).flush, slog.Default())
+	assert.True(t, c.Record(1, "u:1"))
+	assert.False(t, c.Record(1, "u:1"), "same viewer, same story, same day")
+	assert.True(t, c.Record(1, "u:2"))
+	assert.True(t, c.Record(2, "u:1"), "same viewer, different story")
+	assert.Equal(t, 2, c.Pending(1))
+	assert.Equal(t, 1, c.Pending(2))
+}
+
+func TestRecord_EmptyViewerIgnored(t *testing.T) {
+	c := New((&recorder{}).flush, slog.Default())
+	assert.False(t, c.Record(1, ""))
+	assert.Equal(t, 0, c.Pending(1))
+}
+
+func TestRecord_NewDayCountsAgain(t *testing.T) {
+	c := New((&recorder{}).flush, slog.Default())
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	assert.True(t, c.Record(1, "a:visitor"))
+	assert.False(t, c.Record(1, "a:visitor"))
+
+	now = now.Add(2 * time.Hour)
+	assert.True(t, c.Record(1, "a:visitor"))
+	assert.Equal(t, 2, c.Pending(1))
+}
+
+func TestFlush_WritesAndClears(t *testing.T) {
+	rec := &recorder{}
+	c := New(rec.flush, slog.Default())
+	c.Record(1, "u:1")
+	c.Record(1, "u:2")
+	c.Record(2, "u:1")
+
+	require.NoError(t, c.Flush(context.Background()))
+	assert.Equal(t, map[int64]int32{1: 2, 2: 1}, rec.views)
+	assert.Equal(t, 0, c.Pending(1))
+
+	// Dedup state survives a flush.
+	assert.False(t, c.Record(1, "u:1"))
+
+	require.NoError(t, c.Flush(context.Background()))
+	assert.Equal(t, 1, rec.calls, "empty flush does not call the store")
+}
+
+func TestFlush_ErrorKeepsPending(t *testing.T) {
+	rec := &recorder{err: errors.New("db down")}
+	c := New(rec.flush, slog.Default())
+	c.Record(1, "u:1")
+
+	assert.Error(t, c.Flush(context.Background()))
+	assert.Equal(t, 1, c.Pending(1))
+
+	c.Record(1, "u:2")
+	rec.err = nil
+	require.NoError(t, c.Flush(context.Background()))
+	assert.Equal(t, map[int64]int32{1: 2}, rec.views)
+}
+
+func TestRun_FlushesPeriodically(t *testing.T) {
+	rec := &recorder{}
+	c := New(rec.flush, slog.Default())
+	c.Record(7, "u:1")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		c.Run(5*time.Millisecond, stop)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return c.Pending(7) == 0 }, time.Second, 5*time.Millisecond)
+	close(stop)
+	<-done
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.Equal(t, map[int64]int32{7: 1}, rec.views)
+}
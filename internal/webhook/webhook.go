@@ -0,0 +1,124 @@
+// Package webhook posts moderation events to an operator-configured URL,
+// such as a Slack or Discord incoming webhook.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event describes one moderation action. Text is a human-readable summary;
+// it is sent both as "text" (Slack) and "content" (Discord) so either
+// service can display the event without a custom integration.
+type Event struct {
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Moderator  string          `json:"moderator"`
+	Reason     string          `json:"reason"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Text       string          `json:"text"`
+	Content    string          `json:"content"`
+}
+
+// Notifier delivers events in the background. A nil Notifier is valid and
+// drops every event, so callers don't need to check whether a webhook is
+// configured.
+type Notifier struct {
+	url      string
+	client   *http.Client
+	log      *slog.Logger
+	attempts int
+	backoff  time.Duration
+
+	wg sync.WaitGroup
+}
+
+// New returns a Notifier posting to url, or nil when url is empty.
+func New(url string, log *slog.Logger) *Notifier {
+	if url == "" {
+		return nil
+	}
+	return &Notifier{
+		url:      url,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		log:      log,
+		attempts: 3,
+		backoff:  2 * time.Second,
+	}
+}
+
+// Notify sends e asynchronously, retrying failed deliveries with a growing
+// delay. It never blocks the caller.
+func (n *Notifier) Notify(e Event) {
+	if n == nil {
+		return
+	}
+	if e.Content == "" {
+		e.Content = e.Text
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.deliver(e)
+	}()
+}
+
+// Wait blocks until all pending deliveries have finished.
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+func (n *Notifier) deliver(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		n.log.Error("marshal webhook event", "error", err)
+		return
+	}
+
+	delay := n.backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(body)
+		if err == nil {
+			return
+		}
+		if attempt >= n.attempts {
+			n.log.Error("moderation webhook", "action", e.Action, "attempts", attempt, "error", err)
+			return
+		}
+		n.log.Warn("moderation webhook, retrying", "action", e.Action, "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (n *Notifier) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
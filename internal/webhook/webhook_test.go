@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotifier(url string) *Notifier {
+	n := New(url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestNotifySendsPayload(t *testing.T) {
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var e Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		got <- e
+	}))
+	defer srv.Close()
+
+	n := testNotifier(srv.URL)
+	n.Notify(Event{Action: "story.edit_title", TargetType: "story", TargetID: 7, Moderator: "mod", Reason: "typo", Text: "mod edited title"})
+	n.Wait()
+
+	e := <-got
+	assert.Equal(t, "story.edit_title", e.Action)
+	assert.Equal(t, int64(7), e.TargetID)
+	assert.Equal(t, "mod", e.Moderator)
+	assert.Equal(t, "typo", e.Reason)
+	assert.Equal(t, "mod edited title", e.Content)
+}
+
+func TestNotifyRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n := testNotifier(srv.URL)
+	n.Notify(Event{Action: "story.delete"})
+	n.Wait()
+	assert.Equal(t, int32(3), calls.Load())
+
+	calls.Store(-10)
+	n.Notify(Event{Action: "story.delete"})
+	n.Wait()
+	assert.Equal(t, int32(-7), calls.Load(), "gives up after the last attempt")
+}
+
+func TestNilNotifier(t *testing.T) {
+	n := New("", nil)
+	require.Nil(t, n)
+	n.Notify(Event{Action: "story.delete"})
+	n.Wait()
+}
@@ -65,6 +65,22 @@
     }
   })
 
+  // Render a story's flag breakdown, e.g. "| 2 spam, 1 off-topic"
+  function updateFlagCounts(storyId, flags) {
+    var el = document.querySelector(
+      '[data-role=flag-counts][data-story-id="' + storyId + '"]',
+    )
+    if (!el || !flags) return
+    el.textContent = flags.length
+      ? " | " +
+        flags
+          .map(function (f) {
+            return f.count + " " + f.reason
+          })
+          .join(", ")
+      : ""
+  }
+
   // Story flag reason selection
   document.addEventListener("click", async function (e) {
     var option = e.target.closest("[data-action=flag-option]")
@@ -79,17 +95,36 @@
     if (storyBtn) {
       var storyId = storyBtn.dataset.storyId
       var reason = option.dataset.reason
+      var payload = { reason: reason }
+      if (reason === "already posted") {
+        var original = prompt(
+          "Link or short code of the original story (optional):",
+        )
+        if (original === null) {
+          closeAllDropdowns()
+          return
+        }
+        // Accept a pasted permalink as well as a bare short code
+        var match = original.match(/\/[xs]\/([^/?#]+)/)
+        payload.original = match ? match[1] : original.trim()
+      }
       var res = await fetch("/stories/" + storyId + "/flag", {
         method: "POST",
         headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ reason: reason }),
+        body: JSON.stringify(payload),
       })
       if (res.status === 401) {
         window.location.href = "/login"
         return
       }
+      if (res.status === 400 || res.status === 403) {
+        alert((await res.json()).error)
+        closeAllDropdowns()
+        return
+      }
       var data = await res.json()
       if (data && data.ok) {
+        updateFlagCounts(storyId, data.flags)
         // Replace the dropdown with an unflag button
         var parent = dropdown.parentNode
         var unflagBtn = document.createElement("button")
@@ -115,6 +150,11 @@
         window.location.href = "/login"
         return
       }
+      if (res.status === 403) {
+        alert((await res.json()).error)
+        closeAllDropdowns()
+        return
+      }
       var data = await res.json()
       if (data && data.ok) {
         // Update score
@@ -0,0 +1,22 @@
+;(function () {
+  "use strict"
+
+  document.addEventListener("click", async (e) => {
+    const btn = e.target.closest("[data-action=subscribe-domain]")
+    if (!btn) return
+
+    const domainId = btn.dataset.domainId
+    const subscribed = btn.dataset.subscribed.trim() === "true"
+    const url = `/domains/${domainId}${subscribed ? "/unsubscribe" : "/subscribe"}`
+
+    const res = await fetch(url, { method: "POST" })
+    if (res.status === 401) {
+      window.location.href = "/login"
+      return
+    }
+    const data = await res.json()
+    if (!data?.ok) return
+    btn.dataset.subscribed = subscribed ? "false" : "true"
+    btn.textContent = subscribed ? "follow" : "unfollow"
+  })
+})()
@@ -22,6 +22,7 @@
     )
     if (score) score.textContent = data.upvotes
     btn.dataset.voted = voted ? "false" : "true"
+    btn.setAttribute("aria-pressed", voted ? "false" : "true")
     btn.classList.toggle("vote-btn--active")
   })
 })()